@@ -15,13 +15,23 @@ import argparse
 import requests
 import os
 import json
+import hmac
+import hashlib
+import secrets
+import socket
 from datetime import datetime
+from urllib.parse import urlparse
 from ultralytics import YOLO
 
 # --- CONFIGURATION (can be overridden by ENV) ---
 BRAIN_URL = os.getenv("BRAIN_URL", "http://localhost:8080/api/internal/push")
 STREAM_URL = os.getenv("STREAM_URL", "http://localhost:8080/api/internal/stream/cam1")
 ENABLE_STREAM = os.getenv("ENABLE_STREAM", "true").lower() != "false"
+# Push clean frames plus detection metadata and let Central Brain draw the overlay
+SERVER_OVERLAY = os.getenv("SERVER_OVERLAY", "false").lower() == "true"
+# Service key issued by Central Brain (POST /api/service-keys); required unless it runs in DEMO_MODE
+ENGINE_KEY_ID = os.getenv("ENGINE_KEY_ID", "")
+ENGINE_KEY_SECRET = os.getenv("ENGINE_KEY_SECRET", "")
 ALERT_THRESHOLD_SECONDS = 3.0
 CONFIDENCE_THRESHOLD = 0.40  # Lower threshold to detect more objects including trains (detected as truck/bus)
 # Default ByteTrack config bundled with ultralytics
@@ -59,6 +69,36 @@ SOURCE_NAME = ACTIVE_SCENARIO
 DANGER_ZONE = [(350, 200), (850, 200), (1000, 600), (200, 600)]
 # ---------------------------------------------------------
 
+
+# Headers that change how Central Brain handles a request; they are signed
+SIGNED_HEADERS = ["Content-Type", "X-Capture-Timestamp", "X-Sequence", "X-Engine-ID", "X-Resolution"]
+
+
+def sign_headers(url, body, headers=None):
+    """Add X-Engine-Key / X-Timestamp / X-Nonce / X-Signature headers for Central Brain ingest.
+
+    headers must already hold every SIGNED_HEADERS entry the request sends,
+    including the multipart Content-Type with its boundary.
+    """
+    headers = dict(headers or {})
+    if not ENGINE_KEY_ID or not ENGINE_KEY_SECRET:
+        return headers
+    timestamp = str(int(time.time()))
+    nonce = secrets.token_hex(16)
+    canonical = "\n".join(
+        ["POST", urlparse(url).path, timestamp, nonce]
+        + [f"{name.lower()}:{headers.get(name, '')}" for name in SIGNED_HEADERS]
+        + [hashlib.sha256(body).hexdigest()]
+    )
+    signature = hmac.new(ENGINE_KEY_SECRET.encode(), canonical.encode(), hashlib.sha256).hexdigest()
+    headers.update({
+        "X-Engine-Key": ENGINE_KEY_ID,
+        "X-Timestamp": timestamp,
+        "X-Nonce": nonce,
+        "X-Signature": signature,
+    })
+    return headers
+
 class AIEngine:
     def __init__(
         self,
@@ -90,6 +130,10 @@ class AIEngine:
         # Optimized for smoother streaming (~30 FPS default for low lag). Tune via env FRAME_PUSH_INTERVAL.
         # Lower interval = more FPS = smoother but more bandwidth
         self.frame_push_interval = float(os.getenv("FRAME_PUSH_INTERVAL", "0.033"))
+        self.stream_session = requests.Session()
+        # Frame envelope: pushed frames are numbered so Central Brain can spot drops and reordering
+        self.frame_seq = 0
+        self.engine_id = os.getenv("ENGINE_ID", ENGINE_KEY_ID or socket.gethostname())
         
         # Danger classes (COCO indices)
         # 0: person, 1: bicycle, 2: car, 3: motorcycle, 5: bus, 7: truck
@@ -175,12 +219,21 @@ class AIEngine:
         self.alert_cooldowns[obj_id] = current_time
 
         try:
-            requests.post(BRAIN_URL, json=payload, timeout=2)
+            body = json.dumps(payload).encode()
+            headers = sign_headers(BRAIN_URL, body, {"Content-Type": "application/json"})
+            response = requests.post(BRAIN_URL, data=body, headers=headers, timeout=2)
+            if response.status_code in (401, 403):
+                print(f"[ERROR] Alert rejected by Central Brain ({response.status_code}): check ENGINE_KEY_ID/ENGINE_KEY_SECRET")
         except Exception as e:
             print(f"[ERROR] Failed to send alert: {e}")
 
-    def push_frame_stream(self, frame):
-        """Send JPEG frame to Go MJPEG endpoint (throttled)."""
+    def push_frame_stream(self, frame, detections=None, captured_at=None):
+        """Send JPEG frame to Go MJPEG endpoint (throttled).
+
+        With SERVER_OVERLAY the frame is clean and its detections are sent
+        alongside as multipart form data. Capture time, sequence, engine ID
+        and resolution travel as X- headers for latency tracking.
+        """
         if not self.enable_stream:
             return
         now = time.time()
@@ -196,12 +249,33 @@ class AIEngine:
                 print(f"[STREAM] Failed to encode frame for {STREAM_URL}")
                 return
             # Increased timeout to avoid blocking when backend down
-            response = requests.post(
-                STREAM_URL,
-                data=buffer.tobytes(),
-                headers={"Content-Type": "image/jpeg"},
-                timeout=1.0,  # Increased from 0.5 to 1.0
-            )
+            body = buffer.tobytes()
+            self.frame_seq += 1
+            h, w = frame.shape[:2]
+            envelope = {
+                "X-Capture-Timestamp": str(int((captured_at or now) * 1000)),
+                "X-Sequence": str(self.frame_seq),
+                "X-Engine-ID": self.engine_id,
+                "X-Resolution": f"{w}x{h}",
+            }
+            if detections is not None:
+                # The signature covers the whole multipart body
+                req = requests.Request(
+                    "POST",
+                    STREAM_URL,
+                    files={"frame": ("frame.jpg", body, "image/jpeg")},
+                    data={"meta": json.dumps({"detections": detections})},
+                ).prepare()
+                envelope["Content-Type"] = req.headers["Content-Type"]
+                req.headers.update(sign_headers(STREAM_URL, req.body, envelope))
+                response = self.stream_session.send(req, timeout=1.0)
+            else:
+                response = requests.post(
+                    STREAM_URL,
+                    data=body,
+                    headers=sign_headers(STREAM_URL, body, {"Content-Type": "image/jpeg", **envelope}),
+                    timeout=1.0,  # Increased from 0.5 to 1.0
+                )
             if response.status_code != 202:
                 print(f"[STREAM] Warning: Backend returned {response.status_code} for {STREAM_URL}")
             # Log success occasionally for debugging
@@ -308,6 +382,7 @@ class AIEngine:
                         print(f"[ERROR] Cannot read frame from webcam")
                         break
             
+            captured_at = time.time()
             frame_count += 1
             if frame_count % 100 == 0:  # Log every 100 frames
                 print(f"[INFO] Processed {frame_count} frames")
@@ -344,6 +419,8 @@ class AIEngine:
             
             # Reset zone status for visualization
             zone_color = (0, 255, 0) # Green (Safe)
+            # Detection metadata for SERVER_OVERLAY; nothing is drawn locally then
+            frame_detections = []
             
             if results[0].boxes.id is not None:
                 boxes = results[0].boxes.xywh.cpu()
@@ -360,6 +437,14 @@ class AIEngine:
                     
                     # 2. Check logic: Is center in DANGER_ZONE?
                     in_zone = self.is_point_in_polygon(center_point, self.zone)
+                    detection = {
+                        "track_id": track_id,
+                        "class": self.class_names.get(cls, f"class_{cls}"),
+                        "confidence": round(conf, 3),
+                        "bbox": [int(x - w/2), int(y - h/2), int(x + w/2), int(y + h/2)],
+                        "in_roi": bool(in_zone),
+                    }
+                    frame_detections.append(detection)
                     
                     if in_zone:
                         current_ids_in_zone.append(track_id)
@@ -376,10 +461,13 @@ class AIEngine:
                         label_color = (0, 255, 255) # Yellow (Warning)
                         class_name = self.class_names.get(cls, f"class_{cls}")
 
+                        detection["duration_seconds"] = round(duration, 1)
+
                         # Only trigger alert for person class
                         if class_name == "person" and duration > ALERT_THRESHOLD_SECONDS:
                             zone_color = (0, 0, 255) # Red (Critical)
                             label_color = (0, 0, 255)
+                            detection["alert"] = True
                             
                             # TRIGGER ALERT + EVIDENCE
                             self.send_alert(
@@ -395,9 +483,10 @@ class AIEngine:
                         x1, y1 = int(x - w/2), int(y - h/2)
                         x2, y2 = int(x + w/2), int(y + h/2)
                         
-                        cv2.rectangle(frame, (x1, y1), (x2, y2), label_color, 2)
-                        cv2.putText(frame, f"{class_name} {duration:.1f}s", (x1, y1 - 10), 
-                                    cv2.FONT_HERSHEY_SIMPLEX, 0.6, label_color, 2)
+                        if not SERVER_OVERLAY:
+                            cv2.rectangle(frame, (x1, y1), (x2, y2), label_color, 2)
+                            cv2.putText(frame, f"{class_name} {duration:.1f}s", (x1, y1 - 10), 
+                                        cv2.FONT_HERSHEY_SIMPLEX, 0.6, label_color, 2)
                         
                     else:
                         # Object detected but NOT in zone
@@ -420,16 +509,20 @@ class AIEngine:
                 # No objects detected at all
                 self.tracked_objects.clear()
 
-            # DRAW POLYGON ZONE
-            cv2.polylines(frame, [np.array(self.zone, dtype=np.int32)], isClosed=True, color=zone_color, thickness=2)
-            
-            # Fill polygon semi-transparent
-            overlay = frame.copy()
-            cv2.fillPoly(overlay, [np.array(self.zone, dtype=np.int32)], zone_color)
-            cv2.addWeighted(overlay, 0.3, frame, 0.7, 0, frame)
-
-            # Stream frame to backend (throttled)
-            self.push_frame_stream(frame)
+            if SERVER_OVERLAY:
+                # Clean frame; Central Brain draws boxes and the camera's ROI
+                self.push_frame_stream(frame, frame_detections, captured_at)
+            else:
+                # DRAW POLYGON ZONE
+                cv2.polylines(frame, [np.array(self.zone, dtype=np.int32)], isClosed=True, color=zone_color, thickness=2)
+                
+                # Fill polygon semi-transparent
+                overlay = frame.copy()
+                cv2.fillPoly(overlay, [np.array(self.zone, dtype=np.int32)], zone_color)
+                cv2.addWeighted(overlay, 0.3, frame, 0.7, 0, frame)
+
+                # Stream frame to backend (throttled)
+                self.push_frame_stream(frame, captured_at=captured_at)
 
             # Display Status (optional, avoid crash if no GUI backend)
             if self.enable_display:
@@ -1,6 +1,9 @@
 package api
 
 import (
+	"errors"
+	"strings"
+
 	"central-brain/auth"
 	"central-brain/models"
 	"central-brain/services"
@@ -30,6 +33,12 @@ func HandleLogin(c *fiber.Ctx) error {
 
 	// Validate credentials
 	user, err := services.ValidateLogin(req.ID, req.Password)
+	if errors.Is(err, services.ErrUserDisabled) {
+		return c.Status(403).JSON(fiber.Map{
+			"error":   "forbidden",
+			"message": "Account is disabled",
+		})
+	}
 	if err != nil {
 		return c.Status(401).JSON(fiber.Map{
 			"error":   "unauthorized",
@@ -37,8 +46,66 @@ func HandleLogin(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate JWT token
-	token, err := auth.GenerateToken(user.ID, user.Role, user.PostID, user.StationID)
+	// Issue access token and a new refresh token
+	access, refresh, err := auth.IssueSession(c.Context(), user)
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "server_error",
+			"message": "Failed to generate token",
+		})
+	}
+
+	return c.JSON(loginResponse(user, access, refresh))
+}
+
+// HandleRefresh exchanges a refresh token for a new access and refresh token
+// @Summary Refresh Token
+// @Description Refresh tokens rotate on every use; reusing an old one ends the session
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body models.RefreshRequest true "Refresh token"
+// @Success 200 {object} models.LoginResponse
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 401 {object} models.ErrorInfo
+// @Router /api/auth/refresh [post]
+func HandleRefresh(c *fiber.Ctx) error {
+	var req models.RefreshRequest
+	if err := c.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "bad_request",
+			"message": "refresh_token is required",
+		})
+	}
+
+	userID, refresh, err := auth.RotateRefreshToken(c.Context(), req.RefreshToken)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		return c.Status(401).JSON(fiber.Map{
+			"error":   "unauthorized",
+			"message": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "server_error",
+			"message": "Failed to refresh session",
+		})
+	}
+
+	// Re-read the user so role and assignment changes apply to the new token
+	user, err := services.GetUserByID(userID)
+	if err == nil && user.Disabled {
+		err = services.ErrUserDisabled
+	}
+	if err != nil {
+		_ = auth.RevokeRefreshToken(c.Context(), refresh)
+		return c.Status(401).JSON(fiber.Map{
+			"error":   "unauthorized",
+			"message": "Account is disabled or no longer exists",
+		})
+	}
+
+	access, err := auth.GenerateToken(user.ID, user.Role, user.PostID, user.StationID)
 	if err != nil {
 		return c.Status(500).JSON(fiber.Map{
 			"error":   "server_error",
@@ -46,14 +113,71 @@ func HandleLogin(c *fiber.Ctx) error {
 		})
 	}
 
-	// Return success response
-	return c.JSON(models.LoginResponse{
-		AccessToken: token,
-		ExpiresIn:   86400, // 24 hours
+	return c.JSON(loginResponse(user, access, refresh))
+}
+
+// HandleLogout ends the session of a refresh token and revokes the access
+// token sent in the Authorization header, if any
+// @Summary Logout
+// @Description With "all": true every session of the caller is ended (requires a valid access token)
+// @Tags auth
+// @Accept json
+// @Param body body models.LogoutRequest false "Refresh token to revoke"
+// @Success 204
+// @Failure 401 {object} models.ErrorInfo
+// @Router /api/auth/logout [post]
+func HandleLogout(c *fiber.Ctx) error {
+	var req models.LogoutRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "Invalid request body",
+			})
+		}
+	}
+
+	var claims *auth.Claims
+	if token := strings.TrimPrefix(c.Get("Authorization"), "Bearer "); token != "" {
+		claims, _ = auth.ValidateToken(token)
+	}
+	if claims == nil && (req.All || req.RefreshToken == "") {
+		return c.Status(401).JSON(fiber.Map{
+			"error":   "unauthorized",
+			"message": "A valid access token or refresh_token is required",
+		})
+	}
+
+	var err error
+	switch {
+	case req.All:
+		err = auth.RevokeUser(c.Context(), claims.UserID)
+	default:
+		if req.RefreshToken != "" {
+			err = auth.RevokeRefreshToken(c.Context(), req.RefreshToken)
+		}
+		if err == nil && claims != nil {
+			err = auth.RevokeAccessToken(c.Context(), claims)
+		}
+	}
+	if err != nil {
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "server_error",
+			"message": "Failed to revoke session",
+		})
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func loginResponse(user *models.User, access, refresh string) models.LoginResponse {
+	return models.LoginResponse{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
 		User: models.UserInfo{
 			ID:   user.ID,
 			Role: user.Role,
 			Name: user.Name,
 		},
-	})
+	}
 }
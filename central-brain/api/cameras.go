@@ -1,39 +1,293 @@
 package api
 
 import (
+	"errors"
+	"strconv"
+
+	"central-brain/models"
+	"central-brain/services"
+	"central-brain/stream"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 )
 
 // HandleGetCameras returns list of cameras
 // @Summary Get Cameras
-// @Description Get list of cameras accessible to user
+// @Description Get list of cameras under the user's post/station
 // @Tags cameras
 // @Security BearerAuth
 // @Produce json
 // @Param status query string false "Filter by status"
 // @Param limit query int false "Limit results" default(10)
+// @Param offset query int false "Offset results" default(0)
 // @Success 200 {object} models.PaginatedResponse
 // @Failure 401 {object} models.ErrorInfo
 // @Router /api/cameras [get]
-func HandleGetCameras(c *fiber.Ctx) error {
-	// TODO: Implement camera listing based on user role
-	// For now, return mock data
-	return c.JSON(fiber.Map{
-		"cameras": []fiber.Map{
-			{
-				"id":     "CCTV-JBG-01",
-				"name":   "CCTV-JBG-01 (Arah Timur)",
-				"type":   "CCTV",
-				"status": "ONLINE",
-				"location": fiber.Map{
-					"lat":  -7.5456,
-					"long": 112.2134,
-				},
-				"post_id": "JPL-102",
-			},
-		},
-		"total":  1,
-		"limit":  10,
-		"offset": 0,
+func HandleGetCameras(reg *stream.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := 10
+		if n, err := strconv.Atoi(c.Query("limit")); err == nil && n > 0 && n <= 500 {
+			limit = n
+		}
+		offset := 0
+		if n, err := strconv.Atoi(c.Query("offset")); err == nil && n > 0 {
+			offset = n
+		}
+		status := c.Query("status")
+		allowed := callerCameras(c)
+
+		cameras := []models.Camera{}
+		for _, cam := range reg.List() {
+			if status != "" && cam.Status != status {
+				continue
+			}
+			if allowed != nil && !allowed[cam.ID] {
+				continue
+			}
+			cameras = append(cameras, cam)
+		}
+
+		total := len(cameras)
+		if offset > total {
+			offset = total
+		}
+		end := offset + limit
+		if end > total {
+			end = total
+		}
+
+		return c.JSON(fiber.Map{
+			"cameras": cameras[offset:end],
+			"total":   total,
+			"limit":   limit,
+			"offset":  offset,
+		})
+	}
+}
+
+// HandleCameraHealth returns the watchdog status of the caller's cameras
+// @Summary Camera Health
+// @Description Feed status (ONLINE, STALE, OFFLINE, FROZEN), last frame time and ingest FPS
+// @Tags cameras
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.CameraHealth
+// @Router /api/cameras/health [get]
+func HandleCameraHealth(wd *stream.Watchdog) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		allowed := callerCameras(c)
+		list := []models.CameraHealth{}
+		for _, h := range wd.List() {
+			if allowed == nil || allowed[h.CameraID] {
+				list = append(list, h)
+			}
+		}
+		return c.JSON(fiber.Map{
+			"cameras": list,
+			"total":   len(list),
+		})
+	}
+}
+
+// HandleCameraLatency returns the capture-to-ingest latency histogram of a camera
+// @Summary Camera Frame Latency
+// @Description Latency from the engine's capture timestamp to ingest, plus out-of-order and missing sequence counts
+// @Tags cameras
+// @Security BearerAuth
+// @Produce json
+// @Param camera_id path string true "Camera ID"
+// @Success 200 {object} stream.LatencyStats
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/cameras/{camera_id}/latency [get]
+func HandleCameraLatency(reg *stream.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		hub, ok := reg.Hub(c.Params("camera_id"))
+		if !ok {
+			return c.Status(404).JSON(fiber.Map{
+				"error":   "not_found",
+				"message": "Camera " + c.Params("camera_id") + " not found",
+			})
+		}
+		stats := hub.Latency()
+		stats.CameraID = c.Params("camera_id")
+		return c.JSON(stats)
+	}
+}
+
+// HandleCameraQuality returns the latest tamper / image quality analysis of a camera
+// @Summary Camera Image Quality
+// @Description Brightness, glare, sharpness, coverage and scene shift of the last sampled frame
+// @Tags cameras
+// @Security BearerAuth
+// @Produce json
+// @Param camera_id path string true "Camera ID"
+// @Success 200 {object} stream.QualityReport
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/cameras/{camera_id}/quality [get]
+func HandleCameraQuality(analyzer *stream.Analyzer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if analyzer == nil {
+			return analysisDisabled(c)
+		}
+		report, ok := analyzer.Report(c.Params("camera_id"))
+		if !ok {
+			return c.Status(404).JSON(fiber.Map{
+				"error":   "not_found",
+				"message": "No analyzed frames for camera " + c.Params("camera_id"),
+			})
+		}
+		return c.JSON(report)
+	}
+}
+
+// HandleResetReference makes the next healthy frame the camera's reference for shift detection
+// @Summary Reset Camera Reference Frame
+// @Description Use after a camera has been deliberately re-aimed
+// @Tags cameras
+// @Security BearerAuth
+// @Param camera_id path string true "Camera ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/cameras/{camera_id}/reference [post]
+func HandleResetReference(reg *stream.Registry, analyzer *stream.Analyzer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if analyzer == nil {
+			return analysisDisabled(c)
+		}
+		if _, ok := reg.Get(c.Params("camera_id")); !ok {
+			return c.Status(404).JSON(fiber.Map{
+				"error":   "not_found",
+				"message": "Camera " + c.Params("camera_id") + " not found",
+			})
+		}
+		analyzer.ResetReference(c.Params("camera_id"))
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func analysisDisabled(c *fiber.Ctx) error {
+	return c.Status(404).JSON(fiber.Map{
+		"error":   "not_found",
+		"message": "Frame analysis is disabled; set FRAME_ANALYSIS=true",
 	})
 }
+
+// HandleCreateCamera registers a new camera and brings its stream online
+// @Summary Create Camera
+// @Description Register a camera and start its MJPEG hub (DAOP_ADMIN only)
+// @Tags cameras
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param camera body models.Camera true "Camera configuration"
+// @Success 201 {object} models.Camera
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 409 {object} models.ErrorInfo
+// @Router /api/cameras [post]
+func HandleCreateCamera(reg *stream.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var cam models.Camera
+		if err := c.BodyParser(&cam); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "Invalid request body",
+			})
+		}
+		if _, exists := reg.Get(cam.ID); exists {
+			return c.Status(409).JSON(fiber.Map{
+				"error":   "conflict",
+				"message": "Camera " + cam.ID + " already exists",
+			})
+		}
+		return saveCamera(c, reg, cam, fiber.StatusCreated)
+	}
+}
+
+// HandleUpdateCamera replaces the configuration of an existing camera
+// @Summary Update Camera
+// @Description Update camera configuration (DAOP_ADMIN only)
+// @Tags cameras
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param camera_id path string true "Camera ID"
+// @Param camera body models.Camera true "Camera configuration"
+// @Success 200 {object} models.Camera
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/cameras/{camera_id} [put]
+func HandleUpdateCamera(reg *stream.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Params point into the request buffer; copy before the ID is stored
+		id := utils.CopyString(c.Params("camera_id"))
+		if _, exists := reg.Get(id); !exists {
+			return c.Status(404).JSON(fiber.Map{
+				"error":   "not_found",
+				"message": "Camera " + id + " not found",
+			})
+		}
+		var cam models.Camera
+		if err := c.BodyParser(&cam); err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "Invalid request body",
+			})
+		}
+		cam.ID = id
+		return saveCamera(c, reg, cam, fiber.StatusOK)
+	}
+}
+
+// HandleDeleteCamera removes a camera and disconnects its viewers
+// @Summary Delete Camera
+// @Description Remove camera and stop its MJPEG hub (DAOP_ADMIN only)
+// @Tags cameras
+// @Security BearerAuth
+// @Param camera_id path string true "Camera ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/cameras/{camera_id} [delete]
+func HandleDeleteCamera(reg *stream.Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("camera_id")
+		if err := reg.Remove(c.Context(), id); err != nil {
+			if errors.Is(err, stream.ErrCameraNotFound) {
+				return c.Status(404).JSON(fiber.Map{
+					"error":   "not_found",
+					"message": "Camera " + id + " not found",
+				})
+			}
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to delete camera",
+			})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func saveCamera(c *fiber.Ctx, reg *stream.Registry, cam models.Camera, status int) error {
+	if cam.PostID != "" && !services.PostExists(cam.PostID) {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "bad_request",
+			"message": "Unknown post_id: " + cam.PostID,
+		})
+	}
+
+	saved, err := reg.Upsert(c.Context(), cam)
+	if err != nil {
+		if errors.Is(err, stream.ErrInvalidCameraID) || errors.Is(err, stream.ErrInvalidROI) ||
+			errors.Is(err, stream.ErrInvalidPrivacyMask) {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		}
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "db_error",
+			"message": "Failed to save camera",
+		})
+	}
+	return c.Status(status).JSON(saved)
+}
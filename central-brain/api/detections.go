@@ -1,7 +1,10 @@
 package api
 
 import (
+	"errors"
+	"log"
 	"strconv"
+	"time"
 
 	"central-brain/models"
 	"central-brain/storage"
@@ -9,48 +12,148 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// HandleDetections returns list of detection records (DB preferred, fallback to memory).
+// HandleDetections returns a filtered, cursor-paginated list of detection records
+// (DB preferred, fallback to memory), limited to the caller's post/station cameras.
+// @Summary Query Detections
+// @Description Filter detections and page back through history with next_cursor
+// @Tags detections
+// @Security BearerAuth
+// @Produce json
+// @Param camera_id query string false "Camera ID"
+// @Param object_class query string false "Object class (car, person, ...)"
+// @Param type query string false "Event type (OBSTACLE_STUCK, ...)"
+// @Param from query string false "RFC3339 start time (inclusive)"
+// @Param to query string false "RFC3339 end time (exclusive)"
+// @Param min_confidence query number false "Minimum confidence"
+// @Param in_roi query bool false "Only detections inside/outside the danger zone"
+// @Param min_duration query number false "Minimum duration_seconds"
+// @Param cursor query string false "next_cursor from the previous page"
+// @Param limit query int false "Page size (max 500)" default(50)
+// @Success 200 {object} models.PaginatedResponse
+// @Failure 400 {object} models.ErrorInfo
+// @Router /api/detections [get]
 func HandleDetections(
 	history *storage.HistoryStore,
-	fetchFn func(limit int) ([]models.DetectionPayload, error),
+	fetchFn func(f models.DetectionFilter) (models.DetectionPage, error),
 ) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		limit := 50
-		if q := c.Query("limit"); q != "" {
-			if n, err := strconv.Atoi(q); err == nil && n > 0 && n <= 500 {
-				limit = n
+		f, err := parseDetectionFilter(c)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		}
+		if isScoped(c) {
+			f.AllowedCameras = callerCameras(c)
+			if f.CameraID != "" && f.AllowedCameras != nil && !f.AllowedCameras[f.CameraID] {
+				return forbiddenCamera(c, f.CameraID)
 			}
 		}
 
-		var list []models.DetectionPayload
-		var err error
+		page, err := fetchDetections(history, fetchFn, f)
+		if err != nil {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		}
 
-		if fetchFn != nil {
-			list, err = fetchFn(limit)
-			if err != nil {
-				// fallback to memory below
-				list = nil
-			}
+		if page.Detections == nil {
+			page.Detections = []models.DetectionPayload{}
 		}
 
-		if list == nil && history != nil {
-			memList := history.List()
-			if len(memList) > limit {
-				list = memList[len(memList)-limit:]
-			} else {
-				list = memList
-			}
+		return c.JSON(models.PaginatedResponse{
+			Data:       page.Detections,
+			Total:      page.Total,
+			Limit:      f.Limit,
+			NextCursor: page.NextCursor,
+		})
+	}
+}
+
+// fetchDetections reads one page from the DB and falls back to the in-memory
+// history only when the DB is unavailable (no fetchFn, or the query failed).
+// An empty DB page is a valid result, e.g. past the last cursor. Only an
+// invalid cursor is an error.
+func fetchDetections(
+	history *storage.HistoryStore,
+	fetchFn func(f models.DetectionFilter) (models.DetectionPage, error),
+	f models.DetectionFilter,
+) (models.DetectionPage, error) {
+	if fetchFn != nil {
+		page, err := fetchFn(f)
+		if err == nil || errors.Is(err, models.ErrInvalidCursor) {
+			return page, err
 		}
+		log.Printf("[DB] failed to query detections, fallback to memory: %v", err)
+	}
 
-		if list == nil {
-			list = []models.DetectionPayload{}
+	// fallback to memory: newest first, no cursor support
+	var page models.DetectionPage
+	if history == nil {
+		return page, nil
+	}
+	memList := history.List()
+	for i := len(memList) - 1; i >= 0; i-- {
+		if f.Matches(memList[i]) {
+			page.Total++
+			if len(page.Detections) < f.Limit {
+				page.Detections = append(page.Detections, memList[i])
+			}
 		}
+	}
+	return page, nil
+}
 
-		return c.JSON(fiber.Map{
-			"detections": list,
-			"total":      len(list),
-			"limit":      limit,
-			"source":     detectSource(fetchFn != nil, list),
-		})
+func parseDetectionFilter(c *fiber.Ctx) (models.DetectionFilter, error) {
+	f := models.DetectionFilter{
+		CameraID:    c.Query("camera_id"),
+		ObjectClass: c.Query("object_class"),
+		Type:        c.Query("type"),
+		Cursor:      c.Query("cursor"),
+		Limit:       50,
+	}
+
+	if q := c.Query("limit"); q != "" {
+		if n, err := strconv.Atoi(q); err == nil && n > 0 && n <= 500 {
+			f.Limit = n
+		}
+	}
+	if q := c.Query("from"); q != "" {
+		t, err := time.Parse(time.RFC3339, q)
+		if err != nil {
+			return f, errors.New("from must be an RFC3339 timestamp")
+		}
+		f.From = t
+	}
+	if q := c.Query("to"); q != "" {
+		t, err := time.Parse(time.RFC3339, q)
+		if err != nil {
+			return f, errors.New("to must be an RFC3339 timestamp")
+		}
+		f.To = t
+	}
+	if q := c.Query("min_confidence"); q != "" {
+		v, err := strconv.ParseFloat(q, 64)
+		if err != nil {
+			return f, errors.New("min_confidence must be a number")
+		}
+		f.MinConfidence = v
+	}
+	if q := c.Query("in_roi"); q != "" {
+		v, err := strconv.ParseBool(q)
+		if err != nil {
+			return f, errors.New("in_roi must be true or false")
+		}
+		f.InROI = &v
+	}
+	if q := c.Query("min_duration"); q != "" {
+		v, err := strconv.ParseFloat(q, 64)
+		if err != nil {
+			return f, errors.New("min_duration must be a number")
+		}
+		f.MinDuration = v
 	}
+	return f, nil
 }
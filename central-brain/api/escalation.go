@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+
+	"central-brain/escalation"
+	"central-brain/middleware"
+	"central-brain/models"
+	"central-brain/services"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// HandleListEscalationPolicies returns the escalation policies of the posts in the caller's scope
+// @Summary List Escalation Policies
+// @Tags escalation
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.EscalationPolicy
+// @Router /api/escalation-policies [get]
+func HandleListEscalationPolicies(scheduler *escalation.Scheduler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		allowed := callerPosts(c)
+		out := make([]models.EscalationPolicy, 0)
+		for _, p := range scheduler.Policies() {
+			if allowed != nil && !allowed[p.PostID] {
+				continue
+			}
+			out = append(out, p)
+		}
+		return c.JSON(fiber.Map{
+			"policies": out,
+			"total":    len(out),
+		})
+	}
+}
+
+// HandleGetEscalationPolicy returns a post's escalation policy
+// @Summary Get Escalation Policy
+// @Tags escalation
+// @Security BearerAuth
+// @Produce json
+// @Param post_id path string true "JPL post ID"
+// @Success 200 {object} models.EscalationPolicy
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/posts/{post_id}/escalation-policy [get]
+func HandleGetEscalationPolicy(scheduler *escalation.Scheduler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		postID := c.Params("post_id")
+		if !services.PostExists(postID) {
+			return unknownPost(c)
+		}
+		p, ok := scheduler.Policy(postID)
+		if !ok {
+			return noPolicy(c)
+		}
+		return c.JSON(p)
+	}
+}
+
+// HandleSetEscalationPolicy replaces a post's escalation policy
+// @Summary Set Escalation Policy
+// @Description Each step notifies one recipient once an OPEN incident of the post stayed unacknowledged for delay_seconds
+// @Tags escalation
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param post_id path string true "JPL post ID"
+// @Param policy body models.EscalationPolicy true "Policy"
+// @Success 200 {object} models.EscalationPolicy
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/posts/{post_id}/escalation-policy [put]
+func HandleSetEscalationPolicy(scheduler *escalation.Scheduler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		postID := c.Params("post_id")
+		if !services.PostExists(postID) {
+			return unknownPost(c)
+		}
+		var p models.EscalationPolicy
+		if err := c.BodyParser(&p); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "Invalid JSON payload",
+			})
+		}
+		// The policy is kept by the scheduler; params point into the request buffer
+		p.PostID = utils.CopyString(postID)
+		p.UpdatedBy = middleware.GetUserID(c)
+
+		saved, err := scheduler.SetPolicy(c.Context(), p)
+		if errors.Is(err, escalation.ErrInvalidPolicy) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		}
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to save escalation policy",
+			})
+		}
+		return c.JSON(saved)
+	}
+}
+
+// HandleDeleteEscalationPolicy removes a post's escalation policy
+// @Summary Delete Escalation Policy
+// @Tags escalation
+// @Security BearerAuth
+// @Param post_id path string true "JPL post ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/posts/{post_id}/escalation-policy [delete]
+func HandleDeleteEscalationPolicy(scheduler *escalation.Scheduler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := scheduler.DeletePolicy(c.Context(), c.Params("post_id")); err != nil {
+			if errors.Is(err, escalation.ErrNoPolicy) {
+				return noPolicy(c)
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to delete escalation policy",
+			})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+func noPolicy(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error":   "not_found",
+		"message": "Post " + c.Params("post_id") + " has no escalation policy",
+	})
+}
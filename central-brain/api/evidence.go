@@ -0,0 +1,111 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"central-brain/storage"
+	"central-brain/stream"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleEvidence serves an evidence snapshot saved by the AI engine, but only
+// when the detection it belongs to is on one of the caller's cameras.
+// Snapshots that cannot be traced to a detection are visible to DAOP admins only.
+// Below DAOP_ADMIN the camera's privacy masks are applied.
+func HandleEvidence(
+	dir string,
+	cameras *stream.Registry,
+	history *storage.HistoryStore,
+	lookupFn func(ctx context.Context, file string) (cameraID string, found bool, err error),
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		file := c.Params("file")
+		if file == "" || file != filepath.Base(file) || strings.HasPrefix(file, ".") {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "Invalid evidence file name",
+			})
+		}
+
+		var cameraID string
+		if isScoped(c) {
+			if allowed := callerCameras(c); allowed != nil {
+				var found bool
+				cameraID, found = evidenceCamera(c.Context(), file, history, lookupFn)
+				if !found || !allowed[cameraID] {
+					return c.Status(403).JSON(fiber.Map{
+						"error":   "forbidden",
+						"message": "Evidence is outside your post/station",
+					})
+				}
+			}
+		}
+
+		path := filepath.Join(dir, file)
+		if SeesUnmasked(c) {
+			return c.SendFile(path)
+		}
+		if cameraID == "" {
+			cameraID, _ = evidenceCamera(c.Context(), file, history, lookupFn)
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return c.Status(404).JSON(fiber.Map{
+				"error":   "not_found",
+				"message": "Evidence not found",
+			})
+		}
+		if err != nil {
+			log.Printf("[EVIDENCE] failed to read %s: %v", file, err)
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "server_error",
+				"message": "Failed to read evidence",
+			})
+		}
+		cam, _ := cameras.Get(cameraID)
+		masked, err := stream.MaskFrame(data, cam)
+		if err != nil {
+			// Never fall back to the original; it may be unmasked
+			log.Printf("[EVIDENCE] failed to mask %s: %v", file, err)
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "server_error",
+				"message": "Failed to mask evidence",
+			})
+		}
+		c.Set("Content-Type", "image/jpeg")
+		return c.Send(masked)
+	}
+}
+
+func evidenceCamera(
+	ctx context.Context,
+	file string,
+	history *storage.HistoryStore,
+	lookupFn func(ctx context.Context, file string) (string, bool, error),
+) (string, bool) {
+	if lookupFn != nil {
+		cameraID, found, err := lookupFn(ctx, file)
+		if err != nil {
+			log.Printf("[DB] failed to look up evidence %s: %v", file, err)
+		}
+		if found {
+			return cameraID, true
+		}
+	}
+	if history != nil {
+		list := history.List()
+		for i := len(list) - 1; i >= 0; i-- {
+			if strings.HasSuffix(list[i].ImageURL, "/"+file) {
+				return list[i].CameraID, true
+			}
+		}
+	}
+	return "", false
+}
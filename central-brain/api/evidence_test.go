@@ -0,0 +1,90 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"central-brain/middleware"
+	"central-brain/models"
+	"central-brain/services"
+	"central-brain/storage"
+	"central-brain/stream"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Every role covers JPL-102; only the DAOP admin gets the original.
+func TestEvidenceMaskedBelowAdmin(t *testing.T) {
+	newScopeFixture(t) // demo users and hierarchy
+
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 4), G: uint8(y * 4), B: 200, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	original := buf.Bytes()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ev1.jpg"), original, 0o644); err != nil {
+		t.Fatalf("write evidence: %v", err)
+	}
+
+	cameras := stream.NewRegistry(nil)
+	cam := models.Camera{ID: "mask-cam1", Name: "masked", PostID: "JPL-102",
+		PrivacyMasks: [][][2]int{{{0, 0}, {32, 0}, {32, 32}, {0, 32}}}, PrivacyMaskMode: models.PrivacyMaskBlack}
+	if _, err := cameras.Upsert(context.Background(), cam); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	services.SetCameraPost(cam.ID, cam.PostID)
+	t.Cleanup(func() {
+		_ = cameras.Remove(context.Background(), cam.ID)
+		services.RemoveCameraPost(cam.ID)
+	})
+	history := storage.NewHistoryStore()
+	history.Append(models.DetectionPayload{Type: models.DetectionObstacleStuck, CameraID: cam.ID, ImageURL: "/evidence/ev1.jpg"})
+
+	app := fiber.New()
+	app.Get("/evidence/:file", middleware.AuthRequired(), HandleEvidence(dir, cameras, history, nil))
+
+	for _, role := range scopeRoles {
+		t.Run(role.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/evidence/ev1.jpg", nil)
+			req.Header.Set("Authorization", "Bearer "+tokenFor(t, role.userID))
+			resp, err := app.Test(req, 5000)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			defer resp.Body.Close()
+			body, _ := io.ReadAll(resp.Body)
+			if resp.StatusCode != 200 {
+				t.Fatalf("status %d: %s", resp.StatusCode, body)
+			}
+			unmasked := bytes.Equal(body, original)
+			if want := role.userID == "DAOP-7"; unmasked != want {
+				t.Fatalf("served the original: %v, want %v", unmasked, want)
+			}
+			if unmasked {
+				return
+			}
+			served, err := jpeg.Decode(bytes.NewReader(body))
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if r, g, b, _ := served.At(8, 8).RGBA(); r>>8 > 16 || g>>8 > 16 || b>>8 > 16 {
+				t.Errorf("masked pixel (%d,%d,%d), want black", r>>8, g>>8, b>>8)
+			}
+		})
+	}
+}
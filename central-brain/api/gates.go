@@ -0,0 +1,156 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"time"
+
+	"central-brain/gates"
+	"central-brain/models"
+	"central-brain/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleGateEvent records a state change reported by a post's gate sensor.
+// @Summary Report Gate State
+// @Description Internal endpoint for level-crossing gate sensors (OPEN, CLOSING, CLOSED, FAULT); HMAC-signed like detection pushes
+// @Tags gates
+// @Accept json
+// @Produce json
+// @Param body body models.GateEvent true "Gate event"
+// @Success 200 {object} models.GateStatus
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 403 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/internal/gates [post]
+func HandleGateEvent(monitor *gates.Monitor) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var ev models.GateEvent
+		if err := c.BodyParser(&ev); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "Invalid JSON payload",
+			})
+		}
+		if !services.PostExists(ev.PostID) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   "not_found",
+				"message": "Unknown post: " + ev.PostID,
+			})
+		}
+		if !serviceKeyAllowsPost(c, ev.PostID) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   "forbidden",
+				"message": "Service key is not allowed to report for post " + ev.PostID,
+			})
+		}
+		ev.ID = 0
+
+		st, err := monitor.Record(c.Context(), ev)
+		if errors.Is(err, gates.ErrInvalidState) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		}
+		if err != nil {
+			log.Printf("[GATE] failed to store gate event: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to store gate event",
+			})
+		}
+		return c.JSON(st)
+	}
+}
+
+// HandleGetGates returns the current gate state of every post in the caller's scope
+// @Summary Get Gate States
+// @Tags gates
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.GateStatus
+// @Router /api/gates [get]
+func HandleGetGates(monitor *gates.Monitor) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		allowed := callerPosts(c)
+		out := make([]models.GateStatus, 0)
+		for _, st := range monitor.Statuses() {
+			if allowed != nil && !allowed[st.PostID] {
+				continue
+			}
+			out = append(out, st)
+		}
+		return c.JSON(fiber.Map{
+			"gates": out,
+			"total": len(out),
+		})
+	}
+}
+
+// HandleGetGate returns a post's gate state and its history, newest first
+// @Summary Get Post Gate
+// @Tags gates
+// @Security BearerAuth
+// @Produce json
+// @Param post_id path string true "JPL post ID"
+// @Param from query string false "Start time (RFC3339)"
+// @Param to query string false "End time (RFC3339)"
+// @Param limit query int false "Max history events (default 100, max 1000)"
+// @Success 200 {array} models.GateEvent
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/posts/{post_id}/gate [get]
+func HandleGetGate(monitor *gates.Monitor) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		postID := c.Params("post_id")
+		if !services.PostExists(postID) {
+			return unknownPost(c)
+		}
+
+		var from, to time.Time
+		for name, t := range map[string]*time.Time{"from": &from, "to": &to} {
+			q := c.Query(name)
+			if q == "" {
+				continue
+			}
+			v, err := time.Parse(time.RFC3339, q)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":   "bad_request",
+					"message": name + " must be an RFC3339 timestamp",
+				})
+			}
+			*t = v
+		}
+		limit := 100
+		if q := c.Query("limit"); q != "" {
+			if n, err := strconv.Atoi(q); err == nil && n > 0 && n <= 1000 {
+				limit = n
+			}
+		}
+
+		history, err := monitor.History(c.Context(), postID, from, to, limit)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to query gate history",
+			})
+		}
+		if history == nil {
+			history = []models.GateEvent{}
+		}
+
+		resp := fiber.Map{
+			"post_id": postID,
+			"history": history,
+			"total":   len(history),
+		}
+		if st, ok := monitor.Status(postID); ok {
+			resp["gate"] = st
+		}
+		return c.JSON(resp)
+	}
+}
@@ -10,36 +10,42 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-// HandleHistory exposes detection history for UI.
+// HandleHistory exposes detection history for UI, limited to the caller's cameras.
 // If fetchFn is provided, it will be used as the primary source (e.g., DB).
-// In-memory history is used as a fallback or when fetchFn is nil.
-func HandleHistory(history *storage.HistoryStore, fetchFn func(limit int) ([]models.DetectionPayload, error)) fiber.Handler {
+// In-memory history is used when fetchFn is nil or fails, not when it is empty.
+func HandleHistory(history *storage.HistoryStore, fetchFn func(f models.DetectionFilter) (models.DetectionPage, error)) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		limit := 100
+		f := models.DetectionFilter{Limit: 100}
 		if q := c.Query("limit"); q != "" {
 			if n, err := strconv.Atoi(q); err == nil && n > 0 && n <= 500 {
-				limit = n
+				f.Limit = n
 			}
 		}
+		if isScoped(c) {
+			f.AllowedCameras = callerCameras(c)
+		}
 
-		var (
-			list []models.DetectionPayload
-			err  error
-		)
+		var list []models.DetectionPayload
+		fromDB := false
 
 		if fetchFn != nil {
-			list, err = fetchFn(limit)
+			page, err := fetchFn(f)
 			if err != nil {
 				log.Printf("[DB] failed to fetch history, fallback to memory: %v", err)
+			} else {
+				list = page.Detections
+				fromDB = true
 			}
 		}
 
-		if list == nil && history != nil {
-			memList := history.List()
-			if len(memList) > limit {
-				list = memList[len(memList)-limit:]
-			} else {
-				list = memList
+		if !fromDB && history != nil {
+			for _, item := range history.List() {
+				if f.Matches(item) {
+					list = append(list, item)
+				}
+			}
+			if len(list) > f.Limit {
+				list = list[len(list)-f.Limit:]
 			}
 		}
 
@@ -50,7 +56,7 @@ func HandleHistory(history *storage.HistoryStore, fetchFn func(limit int) ([]mod
 		return c.JSON(fiber.Map{
 			"history": list,
 			"total":   len(list),
-			"source":  detectSource(fetchFn != nil, list),
+			"source":  detectSource(fromDB, list),
 		})
 	}
 }
@@ -66,4 +72,3 @@ func detectSource(hasDB bool, list []models.DetectionPayload) string {
 func DetectSource(hasDB bool, list []models.DetectionPayload) string {
 	return detectSource(hasDB, list)
 }
-
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+
+	"central-brain/incident"
+	"central-brain/middleware"
+	"central-brain/models"
+	"central-brain/stream"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleListIncidents returns incidents of the caller's post/station cameras, newest first
+// @Summary List Incidents
+// @Description List grouped detection incidents
+// @Tags incidents
+// @Security BearerAuth
+// @Produce json
+// @Param status query string false "OPEN, ACKNOWLEDGED, RESOLVED or FALSE_POSITIVE"
+// @Param camera_id query string false "Filter by camera"
+// @Param limit query int false "Limit results" default(100)
+// @Success 200 {array} models.Incident
+// @Router /api/incidents [get]
+func HandleListIncidents(mgr *incident.Manager) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := 100
+		if n, err := strconv.Atoi(c.Query("limit")); err == nil && n > 0 && n <= 500 {
+			limit = n
+		}
+
+		f := incident.Filter{
+			Status:   c.Query("status"),
+			CameraID: c.Query("camera_id"),
+			Limit:    limit,
+		}
+		if isScoped(c) {
+			f.AllowedCameras = callerCameras(c)
+			if f.CameraID != "" && f.AllowedCameras != nil && !f.AllowedCameras[f.CameraID] {
+				return forbiddenCamera(c, f.CameraID)
+			}
+		}
+		list := mgr.List(f)
+
+		return c.JSON(fiber.Map{
+			"incidents": list,
+			"total":     len(list),
+			"limit":     limit,
+		})
+	}
+}
+
+// HandleGetIncident returns a single incident with its timeline
+// @Summary Get Incident
+// @Tags incidents
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Success 200 {object} models.Incident
+// @Failure 403 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/incidents/{id} [get]
+func HandleGetIncident(mgr *incident.Manager) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		inc, err := mgr.Get(c.Context(), c.Params("id"))
+		if err != nil {
+			return incidentError(c, err)
+		}
+		if !incidentInScope(c, inc) {
+			return forbiddenCamera(c, inc.CameraID)
+		}
+		return c.JSON(inc)
+	}
+}
+
+// HandleIncidentTransition moves an incident to the given status
+// @Summary Change Incident Status
+// @Description Acknowledge, resolve or mark an incident as false positive
+// @Tags incidents
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "Incident ID"
+// @Param body body models.IncidentActionRequest false "Optional note"
+// @Success 200 {object} models.Incident
+// @Failure 403 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Failure 409 {object} models.ErrorInfo
+// @Router /api/incidents/{id}/acknowledge [post]
+// @Router /api/incidents/{id}/resolve [post]
+// @Router /api/incidents/{id}/false-positive [post]
+func HandleIncidentTransition(mgr *incident.Manager, status string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req models.IncidentActionRequest
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&req); err != nil {
+				return c.Status(400).JSON(fiber.Map{
+					"error":   "bad_request",
+					"message": "Invalid request body",
+				})
+			}
+		}
+
+		// Officers may only act on incidents of their own post/station
+		current, err := mgr.Get(c.Context(), c.Params("id"))
+		if err != nil {
+			return incidentError(c, err)
+		}
+		if !incidentInScope(c, current) {
+			return forbiddenCamera(c, current.CameraID)
+		}
+
+		inc, err := mgr.Transition(c.Context(), c.Params("id"), status, middleware.GetUserID(c), req.Note)
+		if err != nil {
+			return incidentError(c, err)
+		}
+		return c.JSON(inc)
+	}
+}
+
+// HandleIncidentClip serves the video clip recorded around an incident
+// @Summary Get Incident Clip
+// @Description Motion-JPEG AVI from 10s before to 20s after the incident opened; privacy-masked below DAOP_ADMIN
+// @Tags incidents
+// @Security BearerAuth
+// @Produce video/x-msvideo
+// @Param id path string true "Incident ID"
+// @Success 200 {file} file
+// @Failure 403 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Failure 409 {object} models.ErrorInfo "Clip still recording"
+// @Router /api/incidents/{id}/clip [get]
+func HandleIncidentClip(mgr *incident.Manager, clips *stream.ClipRecorder) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		inc, err := mgr.Get(c.Context(), c.Params("id"))
+		if err != nil {
+			return incidentError(c, err)
+		}
+		if !incidentInScope(c, inc) {
+			return forbiddenCamera(c, inc.CameraID)
+		}
+
+		if inc.ClipURL == "" {
+			if clips.Recording(inc.ID) {
+				return c.Status(409).JSON(fiber.Map{
+					"error":   "conflict",
+					"message": "Clip is still being recorded",
+				})
+			}
+			return c.Status(404).JSON(fiber.Map{
+				"error":   "not_found",
+				"message": "No clip recorded for incident " + inc.ID,
+			})
+		}
+
+		c.Set("Content-Disposition", `inline; filename="`+inc.ID+`.avi"`)
+		if SeesUnmasked(c) {
+			return c.SendFile(clips.Path(inc.ID))
+		}
+		var buf bytes.Buffer
+		if err := clips.WriteMasked(&buf, inc.ID, inc.CameraID); err != nil {
+			if errors.Is(err, stream.ErrCameraNotFound) {
+				return c.Status(403).JSON(fiber.Map{
+					"error":   "forbidden",
+					"message": "Unmasked clip of removed camera " + inc.CameraID + " is restricted to DAOP_ADMIN",
+				})
+			}
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "server_error",
+				"message": "Failed to mask clip",
+			})
+		}
+		c.Set(fiber.HeaderContentType, "video/x-msvideo")
+		return c.Send(buf.Bytes())
+	}
+}
+
+// incidentInScope reports whether the incident's camera is under the caller's post/station.
+func incidentInScope(c *fiber.Ctx, inc models.Incident) bool {
+	if !isScoped(c) {
+		return true
+	}
+	allowed := callerCameras(c)
+	return allowed == nil || allowed[inc.CameraID]
+}
+
+func incidentError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, incident.ErrNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "not_found",
+			"message": "Incident " + c.Params("id") + " not found",
+		})
+	case errors.Is(err, incident.ErrInvalidTransition):
+		return c.Status(409).JSON(fiber.Map{
+			"error":   "conflict",
+			"message": err.Error(),
+		})
+	default:
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "db_error",
+			"message": "Failed to load incident",
+		})
+	}
+}
@@ -4,20 +4,120 @@ import (
 	"log"
 	"time"
 
+	"central-brain/gates"
+	"central-brain/incident"
+	"central-brain/middleware"
 	"central-brain/models"
 	"central-brain/realtime"
+	"central-brain/rules"
+	"central-brain/services"
 	"central-brain/storage"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// HandleInternalPush ingests detection data from Python and broadcasts to all WS clients.
-// Optional saveFn persists payloads to a database when provided.
-func HandleInternalPush(
-	hub *realtime.Hub,
-	history *storage.HistoryStore,
-	saveFn func(models.DetectionPayload) error,
-) fiber.Handler {
+// DetectionPipeline groups, stores and broadcasts detections, whether pushed
+// by the AI engine or raised by central-brain itself. Every field is optional.
+type DetectionPipeline struct {
+	Hub       *realtime.Hub
+	History   *storage.HistoryStore
+	Save      func(models.DetectionPayload) error // persists payloads to a database
+	Incidents *incident.Manager                   // groups repeated pushes for the same object
+	// Trains returns the next train at a post
+	Trains func(postID string) (models.TrainApproach, bool)
+	// Gates returns a post's current gate state
+	Gates func(postID string) (models.GateStatus, bool)
+	// Notify hands the incident to outbound notifiers; it must not block
+	Notify func(models.Incident, models.DetectionPayload)
+	// Rules classify detections before they are grouped and stored
+	Rules *rules.Engine
+}
+
+// Process fills defaults and runs a detection through the pipeline.
+// It returns the payload as stored, linked to its incident, or marked
+// Suppressed when a rule dropped it.
+func (p *DetectionPipeline) Process(payload models.DetectionPayload) models.DetectionPayload {
+	// Enforce defaults
+	if payload.Type == "" {
+		payload.Type = "detection"
+	}
+	if payload.Timestamp.IsZero() {
+		payload.Timestamp = time.Now().UTC()
+	}
+	if payload.PostID == "" {
+		payload.PostID = services.CameraPostID(payload.CameraID)
+	}
+	// Post-wide alerts are filed under one of the post's stream cameras so
+	// scoped viewers receive them and clips can be recorded
+	if payload.CameraID == "" && payload.PostID != "" {
+		payload.CameraID = services.AlertCameraForPost(payload.PostID)
+	}
+
+	var rc rules.Context
+	if p.Gates != nil {
+		if st, ok := p.Gates(payload.PostID); ok {
+			rc.Gate = &st
+		}
+	}
+	if p.Trains != nil {
+		if a, ok := p.Trains(payload.PostID); ok {
+			rc.Train = &a
+		}
+	}
+
+	// Rules run first; a suppressed detection is dropped before anything sees it
+	if p.Rules != nil {
+		rules.Apply(&payload, p.Rules.Evaluate(payload, rc))
+		if payload.Suppressed {
+			return payload
+		}
+	}
+
+	// An obstacle or a vehicle past the closed gate is critical while a
+	// train is on its way to the crossing, whatever the rules said
+	critical := payload.Type == models.DetectionObstacleStuck || payload.Type == models.DetectionGateViolation
+	if rc.Train != nil && rc.Train.Inbound && critical && payload.Train == nil {
+		payload.Severity = models.SeverityCritical
+		payload.Train = rc.Train
+	}
+
+	// Group into an incident before storing so the record links to it
+	if p.Incidents != nil {
+		inc := p.Incidents.Observe(payload)
+		payload.IncidentID = inc.ID
+		if p.Notify != nil {
+			p.Notify(inc, payload)
+		}
+	}
+
+	// Store history in-memory
+	if p.History != nil {
+		p.History.Append(payload)
+	}
+
+	// Persist to DB when available
+	if p.Save != nil {
+		if err := p.Save(payload); err != nil {
+			log.Printf("[DB] failed to persist detection: %v", err)
+		}
+	}
+
+	// Broadcast to websocket clients scoped to this camera
+	if p.Hub != nil {
+		p.Hub.Publish(payload.CameraID, payload.Type, payload)
+	}
+
+	// A vehicle in the danger zone while the gate is closed is a violation of its own
+	if rc.Gate != nil && payload.Type != models.DetectionGateViolation && payload.Type != models.DetectionGateFault {
+		if v, ok := gates.Violation(payload, *rc.Gate); ok {
+			p.Process(v)
+		}
+	}
+	return payload
+}
+
+// HandleInternalPush ingests detection data from Python and runs it through the pipeline.
+func HandleInternalPush(pipeline *DetectionPipeline) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		var payload models.DetectionPayload
 		if err := c.BodyParser(&payload); err != nil {
@@ -27,36 +127,23 @@ func HandleInternalPush(
 			})
 		}
 
-		// Enforce defaults
-		if payload.Type == "" {
-			payload.Type = "detection"
-		}
-		if payload.Timestamp.IsZero() {
-			payload.Timestamp = time.Now().UTC()
+		// Signed engines may only publish for cameras bound to their key
+		if !middleware.ServiceCameraAllowed(c, payload.CameraID) {
+			return middleware.ForbiddenServiceCamera(c, payload.CameraID)
 		}
 
-		// Store history in-memory
-		if history != nil {
-			history.Append(payload)
-		}
+		payload = pipeline.Process(payload)
 
-		// Persist to DB when available
-		if saveFn != nil {
-			if err := saveFn(payload); err != nil {
-				log.Printf("[DB] failed to persist detection: %v", err)
-			}
+		status := "ok"
+		if payload.Suppressed {
+			status = "suppressed"
 		}
-
-		// Broadcast to websocket clients
-		if hub != nil {
-			hub.BroadcastJSON(payload)
-		}
-
 		return c.JSON(fiber.Map{
-			"status":    "ok",
-			"received":  payload.Type,
-			"timestamp": payload.Timestamp,
+			"status":      status,
+			"received":    payload.Type,
+			"timestamp":   payload.Timestamp,
+			"incident_id": payload.IncidentID,
+			"severity":    payload.Severity,
 		})
 	}
 }
-
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"central-brain/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleJWKS publishes the public keys that verify access tokens
+// @Summary JSON Web Key Set
+// @Description RS256 and EdDSA keys that are active or still within rotation; HS256 secrets are never listed
+// @Tags auth
+// @Produce json
+// @Success 200 {object} auth.JWKSet
+// @Router /.well-known/jwks.json [get]
+func HandleJWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+	return c.JSON(auth.PublicJWKS())
+}
@@ -0,0 +1,173 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+
+	"central-brain/middleware"
+	"central-brain/models"
+	"central-brain/notify"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// HandleListNotificationRoutes returns every notification route
+// @Summary List Notification Routes
+// @Tags notifications
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.NotificationRoute
+// @Router /api/notifications/routes [get]
+func HandleListNotificationRoutes(dispatcher *notify.Dispatcher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		routes := dispatcher.Routes()
+		return c.JSON(fiber.Map{
+			"routes":   routes,
+			"total":    len(routes),
+			"channels": dispatcher.Channels(),
+		})
+	}
+}
+
+// HandleSaveNotificationRoute creates a route, or replaces the one named by :id
+// @Summary Create or Update Notification Route
+// @Description Sends incidents of at least min_severity for the listed posts, stations and detection types (empty = all) to one recipient
+// @Tags notifications
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string false "Route ID (update only)"
+// @Param route body models.NotificationRoute true "Route"
+// @Success 200 {object} models.NotificationRoute
+// @Success 201 {object} models.NotificationRoute
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/notifications/routes [post]
+// @Router /api/notifications/routes/{id} [put]
+func HandleSaveNotificationRoute(dispatcher *notify.Dispatcher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var r models.NotificationRoute
+		if err := c.BodyParser(&r); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "Invalid JSON payload",
+			})
+		}
+		// The route is kept by the dispatcher; params point into the request buffer
+		r.ID = ""
+		status := fiber.StatusCreated
+		if id := c.Params("id"); id != "" {
+			r.ID = utils.CopyString(id)
+			status = fiber.StatusOK
+		}
+		r.CreatedBy = middleware.GetUserID(c)
+
+		saved, err := dispatcher.SaveRoute(c.Context(), r)
+		switch {
+		case err == nil:
+			return c.Status(status).JSON(saved)
+		case errors.Is(err, notify.ErrUnknownRoute):
+			return unknownRoute(c)
+		case errors.Is(err, notify.ErrInvalidRoute), errors.Is(err, notify.ErrUnknownChannel):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to save notification route",
+			})
+		}
+	}
+}
+
+// HandleDeleteNotificationRoute removes a route
+// @Summary Delete Notification Route
+// @Tags notifications
+// @Security BearerAuth
+// @Param id path string true "Route ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/notifications/routes/{id} [delete]
+func HandleDeleteNotificationRoute(dispatcher *notify.Dispatcher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := dispatcher.DeleteRoute(c.Context(), c.Params("id")); err != nil {
+			if errors.Is(err, notify.ErrUnknownRoute) {
+				return unknownRoute(c)
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to delete notification route",
+			})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// HandleTestNotificationRoute sends a test notification through a route once
+// @Summary Test Notification Route
+// @Description Delivers synchronously without retries and reports the channel's error
+// @Tags notifications
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "Route ID"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} models.ErrorInfo
+// @Failure 502 {object} models.ErrorInfo
+// @Router /api/notifications/routes/{id}/test [post]
+func HandleTestNotificationRoute(dispatcher *notify.Dispatcher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := dispatcher.Test(c.Context(), c.Params("id")); err != nil {
+			if errors.Is(err, notify.ErrUnknownRoute) {
+				return unknownRoute(c)
+			}
+			return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{
+				"error":   "delivery_failed",
+				"message": err.Error(),
+			})
+		}
+		return c.JSON(fiber.Map{"status": "sent", "route_id": c.Params("id")})
+	}
+}
+
+// HandleListDeadLetters returns notifications that failed every delivery attempt
+// @Summary List Undelivered Notifications
+// @Tags notifications
+// @Security BearerAuth
+// @Produce json
+// @Param limit query int false "Max entries (default 50, max 500)"
+// @Success 200 {array} models.DeadLetter
+// @Router /api/notifications/dead-letters [get]
+func HandleListDeadLetters(dispatcher *notify.Dispatcher) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		limit := 50
+		if q := c.Query("limit"); q != "" {
+			if n, err := strconv.Atoi(q); err == nil && n > 0 && n <= 500 {
+				limit = n
+			}
+		}
+		list, err := dispatcher.DeadLetters(c.Context(), limit)
+		if err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to query dead letters",
+			})
+		}
+		if list == nil {
+			list = []models.DeadLetter{}
+		}
+		return c.JSON(fiber.Map{
+			"dead_letters": list,
+			"total":        len(list),
+		})
+	}
+}
+
+func unknownRoute(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error":   "not_found",
+		"message": "Notification route " + c.Params("id") + " not found",
+	})
+}
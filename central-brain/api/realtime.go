@@ -0,0 +1,72 @@
+package api
+
+import (
+	"central-brain/auth"
+	"central-brain/models"
+	"central-brain/realtime"
+	"central-brain/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleWSStats returns send-queue counters for every connected WebSocket client
+// @Summary WebSocket Client Stats
+// @Description Queue depth, sent and dropped message counts per /ws connection (DAOP_ADMIN only)
+// @Tags system
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} realtime.ClientStats
+// @Router /api/ws/stats [get]
+func HandleWSStats(hub *realtime.Hub) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		clients := hub.Stats()
+
+		var dropped uint64
+		for _, cs := range clients {
+			dropped += cs.Dropped
+		}
+
+		return c.JSON(fiber.Map{
+			"clients":       clients,
+			"total":         len(clients),
+			"total_dropped": dropped,
+		})
+	}
+}
+
+// WSAuthorizer validates a JWT for /ws and resolves the cameras the user may
+// receive events for, using the same post/station scoping as /api/hierarchy.
+// The scope re-checks the session, and resolves the cameras again, while the
+// socket stays open.
+func WSAuthorizer(token string) (realtime.Scope, error) {
+	claims, err := auth.ValidateToken(token)
+	if err != nil {
+		return realtime.Scope{}, err
+	}
+	if err := services.CheckActive(claims.UserID); err != nil {
+		return realtime.Scope{}, err
+	}
+	if _, known := models.RoleHierarchy[claims.Role]; !known {
+		return realtime.Scope{}, fiber.ErrForbidden
+	}
+
+	cameras, all := services.CamerasForScope(claims.Role, claims.PostID, claims.StationID)
+	return realtime.Scope{
+		UserID:     claims.UserID,
+		Role:       claims.Role,
+		Cameras:    cameras,
+		AllCameras: all,
+		// Fails once the token expires or is revoked (logout, role or
+		// assignment change, password reset) or the account is disabled
+		Recheck: func() (realtime.Scope, error) { return WSAuthorizer(token) },
+	}, nil
+}
+
+// DemoWSAuthorizer behaves like WSAuthorizer but lets clients without a token
+// receive every camera. Only used when DEMO_MODE is enabled.
+func DemoWSAuthorizer(token string) (realtime.Scope, error) {
+	if token == "" {
+		return realtime.Scope{UserID: "anonymous", AllCameras: true}, nil
+	}
+	return WSAuthorizer(token)
+}
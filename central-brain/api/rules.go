@@ -0,0 +1,302 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"central-brain/gates"
+	"central-brain/middleware"
+	"central-brain/models"
+	"central-brain/rules"
+	"central-brain/services"
+	"central-brain/storage"
+	"central-brain/trains"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// DryRunResult is what the rules would have done with one stored detection
+type DryRunResult struct {
+	ID             int64     `json:"id"`
+	Type           string    `json:"type"`
+	CameraID       string    `json:"camera_id"`
+	ObjectClass    string    `json:"object_class"`
+	Timestamp      time.Time `json:"timestamp"`
+	StoredSeverity string    `json:"stored_severity,omitempty"`
+	GateState      string    `json:"gate_state,omitempty"`
+	TrainInbound   bool      `json:"train_inbound,omitempty"`
+	models.RuleResult
+}
+
+// HandleListRules returns the detection rules in evaluation order
+// @Summary List Detection Rules
+// @Tags rules
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.Rule
+// @Router /api/rules [get]
+func HandleListRules(engine *rules.Engine) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		list := engine.Rules()
+		return c.JSON(fiber.Map{
+			"rules": list,
+			"total": len(list),
+		})
+	}
+}
+
+// HandleSaveRule creates a rule, or replaces the one named by :id
+// @Summary Create or Update Detection Rule
+// @Description Takes effect for the next detection; rules run by ascending priority
+// @Tags rules
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string false "Rule ID (update only)"
+// @Param rule body models.Rule true "Rule"
+// @Success 200 {object} models.Rule
+// @Success 201 {object} models.Rule
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/rules [post]
+// @Router /api/rules/{id} [put]
+func HandleSaveRule(engine *rules.Engine) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var r models.Rule
+		if err := c.BodyParser(&r); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "Invalid JSON payload",
+			})
+		}
+		// The rule is kept by the engine; params point into the request buffer
+		r.ID = ""
+		status := fiber.StatusCreated
+		if id := c.Params("id"); id != "" {
+			r.ID = utils.CopyString(id)
+			status = fiber.StatusOK
+		}
+		r.CreatedBy = middleware.GetUserID(c)
+
+		saved, err := engine.Save(c.Context(), r)
+		switch {
+		case err == nil:
+			return c.Status(status).JSON(saved)
+		case errors.Is(err, rules.ErrUnknownRule):
+			return unknownRule(c)
+		case errors.Is(err, rules.ErrInvalidRule):
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		default:
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to save detection rule",
+			})
+		}
+	}
+}
+
+// HandleDeleteRule removes a rule
+// @Summary Delete Detection Rule
+// @Tags rules
+// @Security BearerAuth
+// @Param id path string true "Rule ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/rules/{id} [delete]
+func HandleDeleteRule(engine *rules.Engine) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := engine.Delete(c.Context(), c.Params("id")); err != nil {
+			if errors.Is(err, rules.ErrUnknownRule) {
+				return unknownRule(c)
+			}
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to delete detection rule",
+			})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
+
+// HandleReloadRules re-reads the rules from the database
+// @Summary Reload Detection Rules
+// @Description Picks up rules changed directly in the database without a restart
+// @Tags rules
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Failure 500 {object} models.ErrorInfo
+// @Router /api/rules/reload [post]
+func HandleReloadRules(engine *rules.Engine) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := engine.Load(c.Context()); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to load detection rules",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"status": "reloaded",
+			"total":  len(engine.Rules()),
+		})
+	}
+}
+
+// HandleDryRunRules runs rules against stored detections without changing anything
+// @Summary Dry-Run Detection Rules
+// @Description Evaluates the active rules, or the candidate rules in the body, against historical detections selected with the /api/detections filters. Gate state and train approach are taken as they were at each detection's time.
+// @Tags rules
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param camera_id query string false "Camera ID"
+// @Param object_class query string false "Object class (car, person, ...)"
+// @Param type query string false "Event type (OBSTACLE_STUCK, ...)"
+// @Param from query string false "RFC3339 start time (inclusive)"
+// @Param to query string false "RFC3339 end time (exclusive)"
+// @Param limit query int false "Detections to evaluate (max 500)" default(50)
+// @Param rules body object false "Candidate rules: {\"rules\": [...]}"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} models.ErrorInfo
+// @Router /api/rules/dry-run [post]
+func HandleDryRunRules(
+	engine *rules.Engine,
+	history *storage.HistoryStore,
+	fetchFn func(f models.DetectionFilter) (models.DetectionPage, error),
+	gateMonitor *gates.Monitor,
+	tracker *trains.Tracker,
+) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		f, err := parseDetectionFilter(c)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		}
+
+		var body struct {
+			Rules []models.Rule `json:"rules"`
+		}
+		if len(c.Body()) > 0 {
+			if err := c.BodyParser(&body); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":   "bad_request",
+					"message": "Invalid JSON payload",
+				})
+			}
+		}
+		list := engine.Rules()
+		if body.Rules != nil {
+			for i := range body.Rules {
+				if err := engine.Validate(body.Rules[i]); err != nil {
+					return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+						"error":   "bad_request",
+						"message": err.Error(),
+					})
+				}
+				// Candidates have no ID yet; results refer to them by position
+				if body.Rules[i].ID == "" {
+					body.Rules[i].ID = "candidate_" + strconv.Itoa(i+1)
+				}
+			}
+			list = rules.Sorted(body.Rules)
+		}
+
+		page, err := fetchDetections(history, fetchFn, f)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		}
+
+		results := make([]DryRunResult, 0, len(page.Detections))
+		bySeverity := map[string]int{}
+		byRule := map[string]int{}
+		matched, suppressed := 0, 0
+		for _, d := range page.Detections {
+			stored := d.Severity
+			// Rules decide from what the engine reported, not from an earlier classification
+			d.Severity, d.Tags = "", nil
+			rc := contextAt(c.Context(), d, gateMonitor, tracker)
+
+			res := rules.Evaluate(list, d, rc)
+			r := DryRunResult{
+				ID:             d.ID,
+				Type:           d.Type,
+				CameraID:       d.CameraID,
+				ObjectClass:    d.ObjectClass,
+				Timestamp:      d.Timestamp,
+				StoredSeverity: stored,
+				TrainInbound:   rc.Train != nil && rc.Train.Inbound,
+				RuleResult:     res,
+			}
+			if rc.Gate != nil {
+				r.GateState = rc.Gate.State
+			}
+			results = append(results, r)
+
+			if len(res.Matched) > 0 {
+				matched++
+			}
+			if res.Suppressed {
+				suppressed++
+			}
+			if res.Severity != "" {
+				bySeverity[res.Severity]++
+			}
+			for _, id := range res.Matched {
+				byRule[id]++
+			}
+		}
+
+		return c.JSON(fiber.Map{
+			"results": results,
+			"summary": fiber.Map{
+				"evaluated":   len(results),
+				"matched":     matched,
+				"suppressed":  suppressed,
+				"by_severity": bySeverity,
+				"by_rule":     byRule,
+			},
+			"total":       page.Total,
+			"next_cursor": page.NextCursor,
+		})
+	}
+}
+
+// contextAt rebuilds what the pipeline knew about a detection's post when it
+// arrived: the last gate state reported before it and the train then expected.
+func contextAt(ctx context.Context, d models.DetectionPayload, gateMonitor *gates.Monitor, tracker *trains.Tracker) rules.Context {
+	var rc rules.Context
+	postID := d.PostID
+	if postID == "" {
+		postID = services.CameraPostID(d.CameraID)
+	}
+	if gateMonitor != nil {
+		if events, err := gateMonitor.History(ctx, postID, time.Time{}, d.Timestamp, 1); err == nil && len(events) > 0 {
+			e := events[0]
+			rc.Gate = &models.GateStatus{PostID: e.PostID, State: e.State, Since: e.Timestamp, Source: e.Source, Detail: e.Detail}
+		}
+	}
+	if tracker != nil {
+		if a, ok := tracker.Approach(postID, d.Timestamp); ok {
+			rc.Train = &a
+		}
+	}
+	return rc
+}
+
+func unknownRule(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error":   "not_found",
+		"message": "Detection rule " + c.Params("id") + " not found",
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"central-brain/middleware"
+	"central-brain/models"
+	"central-brain/realtime"
+	"central-brain/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// callerCameras returns the camera IDs the authenticated caller may see,
+// or nil when the caller is not restricted (DAOP admin).
+func callerCameras(c *fiber.Ctx) map[string]bool {
+	cameras, all := services.CamerasForScope(
+		middleware.GetUserRole(c),
+		middleware.GetPostID(c),
+		middleware.GetStationID(c),
+	)
+	if all {
+		return nil
+	}
+	return cameras
+}
+
+// isScoped reports whether the current route was reached through AuthRequired.
+// Demo-mode public routes have no role and are not scoped.
+func isScoped(c *fiber.Ctx) bool {
+	return middleware.GetUserRole(c) != ""
+}
+
+func forbiddenCamera(c *fiber.Ctx, cameraID string) error {
+	return c.Status(403).JSON(fiber.Map{
+		"error":   "forbidden",
+		"message": "Camera " + cameraID + " is outside your post/station",
+	})
+}
+
+// RequireCameraScope rejects requests for a :camera_id outside the caller's post/station.
+func RequireCameraScope() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if allowed := callerCameras(c); allowed != nil && !allowed[c.Params("camera_id")] {
+			return forbiddenCamera(c, c.Params("camera_id"))
+		}
+		return c.Next()
+	}
+}
+
+// callerPosts returns the JPL post IDs the authenticated caller may see,
+// or nil when the caller is not restricted (DAOP admin).
+func callerPosts(c *fiber.Ctx) map[string]bool {
+	posts, all := services.PostsForScope(
+		middleware.GetUserRole(c),
+		middleware.GetPostID(c),
+		middleware.GetStationID(c),
+	)
+	if all {
+		return nil
+	}
+	return posts
+}
+
+// RequirePostScope rejects requests for a :post_id outside the caller's post/station.
+func RequirePostScope() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if allowed := callerPosts(c); allowed != nil && !allowed[c.Params("post_id")] {
+			return c.Status(403).JSON(fiber.Map{
+				"error":   "forbidden",
+				"message": "Post " + c.Params("post_id") + " is outside your post/station",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// SeesUnmasked reports whether the caller may view frames without privacy
+// masks: the unmasked originals in the archive are for DAOP admins only.
+func SeesUnmasked(c *fiber.Ctx) bool {
+	return middleware.GetUserRole(c) == models.RoleDAOPAdmin
+}
+
+// ScopeSeesUnmasked is SeesUnmasked for a WebSocket connection.
+func ScopeSeesUnmasked(s realtime.Scope) bool {
+	return s.Role == models.RoleDAOPAdmin
+}
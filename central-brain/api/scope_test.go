@@ -0,0 +1,364 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"central-brain/auth"
+	"central-brain/incident"
+	"central-brain/middleware"
+	"central-brain/models"
+	"central-brain/realtime"
+	"central-brain/services"
+	"central-brain/storage"
+	"central-brain/stream"
+
+	fasthttpws "github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/websocket/v2"
+)
+
+// Test cameras: two posts of STA-JBG and one of STA-KTS.
+var scopeCameras = []models.Camera{
+	{ID: "cam1", Name: "JPL-102 cam", PostID: "JPL-102"},
+	{ID: "ptr-cam1", Name: "JPL-105 cam", PostID: "JPL-105"},
+	{ID: "brn-cam1", Name: "JPL-98 cam", PostID: "JPL-98"},
+}
+
+// scopeRoles lists each role with the test cameras it may see.
+var scopeRoles = []struct {
+	name    string
+	userID  string
+	cameras []string
+}{
+	{name: "daop admin", userID: "DAOP-7", cameras: []string{"brn-cam1", "cam1", "ptr-cam1"}},
+	{name: "station master", userID: "STA-JBG", cameras: []string{"cam1", "ptr-cam1"}},
+	{name: "jpl officer", userID: "JPL-102", cameras: []string{"cam1"}},
+}
+
+var loadDemoUsers sync.Once
+
+type scopeFixture struct {
+	app       *fiber.App
+	hub       *realtime.Hub
+	incidents map[string]models.Incident // by camera ID
+}
+
+// newScopeFixture wires the scoped routes the way main.go does, with one
+// detection and one incident per test camera.
+func newScopeFixture(t *testing.T) *scopeFixture {
+	t.Helper()
+	loadDemoUsers.Do(func() {
+		if err := services.LoadUsers(context.Background(), nil, true); err != nil {
+			t.Fatalf("load users: %v", err)
+		}
+	})
+
+	cameras := stream.NewRegistry(nil)
+	cameras.OnChange(func(cam models.Camera, removed bool) {
+		if removed {
+			services.RemoveCameraPost(cam.ID)
+			return
+		}
+		services.SetCameraPost(cam.ID, cam.PostID)
+	})
+	history := storage.NewHistoryStore()
+	mgr := incident.NewManager(0, 0, nil, nil)
+	f := &scopeFixture{hub: realtime.NewHub(), incidents: make(map[string]models.Incident)}
+	go f.hub.Run()
+
+	now := time.Now().UTC()
+	for _, cam := range scopeCameras {
+		if _, err := cameras.Upsert(context.Background(), cam); err != nil {
+			t.Fatalf("register %s: %v", cam.ID, err)
+		}
+		t.Cleanup(func() { _ = cameras.Remove(context.Background(), cam.ID) })
+		p := models.DetectionPayload{
+			Type:      models.DetectionObstacleStuck,
+			CameraID:  cam.ID,
+			ObjectID:  1,
+			Timestamp: now,
+		}
+		history.Append(p)
+		f.incidents[cam.ID] = mgr.Observe(p)
+	}
+
+	app := fiber.New()
+	app.Get("/ws", websocket.New(realtime.WSHandler(f.hub, WSAuthorizer)))
+	protected := app.Group("/api", middleware.AuthRequired())
+	protected.Get("/hierarchy", HandleGetHierarchy)
+	protected.Get("/cameras", middleware.RequireRole(models.RoleJPLOfficer), HandleGetCameras(cameras))
+	protected.Get("/detections", middleware.RequireRole(models.RoleJPLOfficer), HandleDetections(history, nil))
+	protected.Get("/history", middleware.RequireRole(models.RoleJPLOfficer), HandleHistory(history, nil))
+	protected.Get("/incidents", middleware.RequireRole(models.RoleJPLOfficer), HandleListIncidents(mgr))
+	protected.Get("/incidents/:id", middleware.RequireRole(models.RoleJPLOfficer), HandleGetIncident(mgr))
+	protected.Post("/incidents/:id/acknowledge", middleware.RequireRole(models.RoleJPLOfficer), HandleIncidentTransition(mgr, models.IncidentAcknowledged))
+	f.app = app
+	return f
+}
+
+func tokenFor(t *testing.T, userID string) string {
+	t.Helper()
+	u, err := services.GetUserByID(userID)
+	if err != nil {
+		t.Fatalf("user %s: %v", userID, err)
+	}
+	token, err := auth.GenerateToken(u.ID, u.Role, u.PostID, u.StationID)
+	if err != nil {
+		t.Fatalf("token for %s: %v", userID, err)
+	}
+	return token
+}
+
+// do performs an authenticated request and decodes the JSON response into out.
+func (f *scopeFixture) do(t *testing.T, method, path, userID string, out interface{}) int {
+	t.Helper()
+	req := httptest.NewRequest(method, path, nil)
+	req.Header.Set("Authorization", "Bearer "+tokenFor(t, userID))
+	resp, err := f.app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if out != nil && resp.StatusCode == 200 {
+		if err := json.Unmarshal(body, out); err != nil {
+			t.Fatalf("%s %s: decode %s: %v", method, path, body, err)
+		}
+	}
+	return resp.StatusCode
+}
+
+// testCameras keeps the test cameras of ids, sorted, so hierarchy units and
+// cameras registered by other tests do not matter.
+func testCameras(ids []string) []string {
+	known := make(map[string]bool)
+	for _, cam := range scopeCameras {
+		known[cam.ID] = true
+	}
+	out := []string{}
+	seen := make(map[string]bool)
+	for _, id := range ids {
+		if known[id] && !seen[id] {
+			out = append(out, id)
+			seen[id] = true
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func sameCameras(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestScopedHierarchy(t *testing.T) {
+	f := newScopeFixture(t)
+	want := map[string]string{"DAOP-7": "DAOP-7", "STA-JBG": "STA-JBG", "JPL-102": "JPL-102"}
+	for _, role := range scopeRoles {
+		t.Run(role.name, func(t *testing.T) {
+			var root struct {
+				ID string `json:"id"`
+			}
+			if code := f.do(t, "GET", "/api/hierarchy", role.userID, &root); code != 200 {
+				t.Fatalf("status %d", code)
+			}
+			if root.ID != want[role.userID] {
+				t.Errorf("hierarchy root %q, want %q", root.ID, want[role.userID])
+			}
+		})
+	}
+}
+
+func TestScopedCameras(t *testing.T) {
+	f := newScopeFixture(t)
+	for _, role := range scopeRoles {
+		t.Run(role.name, func(t *testing.T) {
+			var resp struct {
+				Cameras []models.Camera `json:"cameras"`
+			}
+			if code := f.do(t, "GET", "/api/cameras?limit=500", role.userID, &resp); code != 200 {
+				t.Fatalf("status %d", code)
+			}
+			var ids []string
+			for _, cam := range resp.Cameras {
+				ids = append(ids, cam.ID)
+			}
+			if got := testCameras(ids); !sameCameras(got, role.cameras) {
+				t.Errorf("cameras %v, want %v", got, role.cameras)
+			}
+		})
+	}
+}
+
+func TestScopedDetectionsAndHistory(t *testing.T) {
+	f := newScopeFixture(t)
+	for _, role := range scopeRoles {
+		t.Run(role.name, func(t *testing.T) {
+			var page struct {
+				Data []models.DetectionPayload `json:"data"`
+			}
+			if code := f.do(t, "GET", "/api/detections?limit=500", role.userID, &page); code != 200 {
+				t.Fatalf("detections status %d", code)
+			}
+			var hist struct {
+				History []models.DetectionPayload `json:"history"`
+			}
+			if code := f.do(t, "GET", "/api/history?limit=500", role.userID, &hist); code != 200 {
+				t.Fatalf("history status %d", code)
+			}
+			for name, list := range map[string][]models.DetectionPayload{"detections": page.Data, "history": hist.History} {
+				var ids []string
+				for _, d := range list {
+					ids = append(ids, d.CameraID)
+				}
+				if got := testCameras(ids); !sameCameras(got, role.cameras) {
+					t.Errorf("%s cameras %v, want %v", name, got, role.cameras)
+				}
+			}
+
+			code := f.do(t, "GET", "/api/detections?camera_id=brn-cam1", role.userID, nil)
+			if want := scopeStatus(role.cameras, "brn-cam1"); code != want {
+				t.Errorf("detections for brn-cam1: status %d, want %d", code, want)
+			}
+		})
+	}
+}
+
+func TestScopedIncidents(t *testing.T) {
+	for _, role := range scopeRoles {
+		t.Run(role.name, func(t *testing.T) {
+			f := newScopeFixture(t)
+			var resp struct {
+				Incidents []models.Incident `json:"incidents"`
+			}
+			if code := f.do(t, "GET", "/api/incidents", role.userID, &resp); code != 200 {
+				t.Fatalf("list status %d", code)
+			}
+			var ids []string
+			for _, inc := range resp.Incidents {
+				ids = append(ids, inc.CameraID)
+			}
+			if got := testCameras(ids); !sameCameras(got, role.cameras) {
+				t.Errorf("incident cameras %v, want %v", got, role.cameras)
+			}
+
+			for _, cam := range scopeCameras {
+				id := f.incidents[cam.ID].ID
+				want := scopeStatus(role.cameras, cam.ID)
+				if code := f.do(t, "GET", "/api/incidents/"+id, role.userID, nil); code != want {
+					t.Errorf("get %s incident: status %d, want %d", cam.ID, code, want)
+				}
+				if code := f.do(t, "POST", "/api/incidents/"+id+"/acknowledge", role.userID, nil); code != want {
+					t.Errorf("acknowledge %s incident: status %d, want %d", cam.ID, code, want)
+				}
+			}
+		})
+	}
+}
+
+func TestScopedWebSocket(t *testing.T) {
+	f := newScopeFixture(t)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = f.app.Listener(ln) }()
+	t.Cleanup(func() { _ = f.app.Shutdown() })
+
+	for _, role := range scopeRoles {
+		t.Run(role.name, func(t *testing.T) {
+			conn, _, err := fasthttpws.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/ws?token="+tokenFor(t, role.userID), nil)
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+			// The welcome message is queued after the client joins the hub
+			var msg struct {
+				Type     string `json:"type"`
+				CameraID string `json:"camera_id"`
+			}
+			if err := conn.ReadJSON(&msg); err != nil || msg.Type != "welcome" {
+				t.Fatalf("welcome: %+v, %v", msg, err)
+			}
+
+			for _, cam := range scopeCameras {
+				f.hub.Publish(cam.ID, models.DetectionObstacleStuck, map[string]string{"type": models.DetectionObstacleStuck, "camera_id": cam.ID})
+			}
+			f.hub.Publish("cam1", "", map[string]string{"type": "done"}) // every role sees cam1
+
+			var ids []string
+			for {
+				msg.Type, msg.CameraID = "", ""
+				if err := conn.ReadJSON(&msg); err != nil {
+					t.Fatalf("read: %v", err)
+				}
+				if msg.Type == "done" {
+					break
+				}
+				ids = append(ids, msg.CameraID)
+			}
+			if got := testCameras(ids); !sameCameras(got, role.cameras) {
+				t.Errorf("/ws cameras %v, want %v", got, role.cameras)
+			}
+		})
+	}
+}
+
+// scopeStatus is the status a scoped single-camera request should get.
+func scopeStatus(allowed []string, cameraID string) int {
+	for _, id := range allowed {
+		if id == cameraID {
+			return 200
+		}
+	}
+	return 403
+}
+
+func TestAlertCameraForPost(t *testing.T) {
+	newScopeFixture(t)
+	if got := services.AlertCameraForPost("JPL-102"); got != "cam1" {
+		t.Errorf("JPL-102 alert camera %q, want the registered stream camera cam1", got)
+	}
+	if got := services.AlertCameraForPost("NO-SUCH-POST"); got != "" {
+		t.Errorf("unknown post alert camera %q, want none", got)
+	}
+}
+
+func TestWSScopePicksUpNewCameras(t *testing.T) {
+	newScopeFixture(t)
+	scope, err := WSAuthorizer(tokenFor(t, "JPL-102"))
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if scope.Allows("late-cam1") {
+		t.Fatal("camera allowed before it was registered")
+	}
+
+	services.SetCameraPost("late-cam1", "JPL-102")
+	t.Cleanup(func() { services.RemoveCameraPost("late-cam1") })
+	fresh, err := scope.Refresh()
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if !fresh.Allows("late-cam1") {
+		t.Error("camera added to the post after connect is not in the refreshed scope")
+	}
+}
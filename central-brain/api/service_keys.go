@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+
+	"central-brain/auth"
+	"central-brain/middleware"
+	"central-brain/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleListServiceKeys returns issued AI engine keys without secrets
+// @Summary List Service Keys
+// @Tags service-keys
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.ServiceKey
+// @Router /api/service-keys [get]
+func HandleListServiceKeys(ring *auth.ServiceKeyRing) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		keys := ring.List()
+		return c.JSON(fiber.Map{
+			"keys":  keys,
+			"total": len(keys),
+		})
+	}
+}
+
+// HandleIssueServiceKey issues a new AI engine key bound to camera IDs
+// @Summary Issue Service Key
+// @Description The secret is only returned in this response
+// @Tags service-keys
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param key body models.ServiceKeyRequest true "Key name and cameras"
+// @Success 201 {object} models.ServiceKey
+// @Failure 400 {object} models.ErrorInfo
+// @Router /api/service-keys [post]
+func HandleIssueServiceKey(ring *auth.ServiceKeyRing) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var req models.ServiceKeyRequest
+		if err := c.BodyParser(&req); err != nil || req.Name == "" || len(req.CameraIDs) == 0 {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "name and camera_ids are required",
+			})
+		}
+
+		key, err := ring.Issue(c.Context(), req.Name, req.CameraIDs, middleware.GetUserID(c))
+		if err != nil {
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "server_error",
+				"message": "Failed to issue service key",
+			})
+		}
+		return c.Status(fiber.StatusCreated).JSON(key)
+	}
+}
+
+// HandleRevokeServiceKey revokes an AI engine key
+// @Summary Revoke Service Key
+// @Tags service-keys
+// @Security BearerAuth
+// @Param id path string true "Key ID"
+// @Success 204
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/service-keys/{id} [delete]
+func HandleRevokeServiceKey(ring *auth.ServiceKeyRing) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if err := ring.Revoke(c.Context(), c.Params("id")); err != nil {
+			if errors.Is(err, auth.ErrUnknownKey) {
+				return c.Status(404).JSON(fiber.Map{
+					"error":   "not_found",
+					"message": "Service key " + c.Params("id") + " not found",
+				})
+			}
+			return c.Status(500).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to revoke service key",
+			})
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	}
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"bytes"
+	"time"
+
+	"central-brain/middleware"
+	"central-brain/models"
+	"central-brain/services"
+	"central-brain/trains"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// HandleTrainEvent records an APPROACHING or PASSED event from a track
+// circuit or axle counter stand-in.
+// @Summary Report Train Event
+// @Description Internal endpoint for approach signals; HMAC-signed like detection pushes
+// @Tags trains
+// @Accept json
+// @Produce json
+// @Param body body models.TrainEvent true "Train event"
+// @Success 200 {object} models.TrainApproach
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 403 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/internal/trains [post]
+func HandleTrainEvent(tracker *trains.Tracker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		var ev models.TrainEvent
+		if err := c.BodyParser(&ev); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "Invalid JSON payload",
+			})
+		}
+		if !services.PostExists(ev.PostID) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   "not_found",
+				"message": "Unknown post: " + ev.PostID,
+			})
+		}
+		// Signed sensors may only report for posts with a camera bound to their key
+		if !serviceKeyAllowsPost(c, ev.PostID) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error":   "forbidden",
+				"message": "Service key is not allowed to report for post " + ev.PostID,
+			})
+		}
+		if ev.ETASeconds < 0 {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "eta_seconds must not be negative",
+			})
+		}
+
+		if err := tracker.Record(ev); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		}
+
+		a, ok := tracker.Approach(ev.PostID, time.Now())
+		if !ok {
+			return c.JSON(fiber.Map{"status": "ok", "post_id": ev.PostID})
+		}
+		return c.JSON(a)
+	}
+}
+
+func serviceKeyAllowsPost(c *fiber.Ctx, postID string) bool {
+	cameras, _ := services.CamerasForScope(models.RoleJPLOfficer, postID, "")
+	if len(cameras) == 0 {
+		return middleware.ServiceCameraAllowed(c, "")
+	}
+	for cameraID := range cameras {
+		if middleware.ServiceCameraAllowed(c, cameraID) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleGetTrains returns the next train of every post in the caller's scope
+// @Summary Get Train Approaches
+// @Description Next train per JPL post with time-to-arrival, from approach signals or the timetable
+// @Tags trains
+// @Security BearerAuth
+// @Produce json
+// @Param post_id query string false "Filter by post"
+// @Success 200 {array} models.TrainApproach
+// @Router /api/trains [get]
+func HandleGetTrains(tracker *trains.Tracker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		allowed := callerPosts(c)
+		postID := c.Query("post_id")
+		out := make([]models.TrainApproach, 0)
+		for _, a := range tracker.Approaches(time.Now()) {
+			if allowed != nil && !allowed[a.PostID] {
+				continue
+			}
+			if postID != "" && a.PostID != postID {
+				continue
+			}
+			out = append(out, a)
+		}
+		return c.JSON(fiber.Map{
+			"trains": out,
+			"total":  len(out),
+		})
+	}
+}
+
+// HandleGetTimetable returns a post's timetable ordered by time
+// @Summary Get Post Timetable
+// @Tags trains
+// @Security BearerAuth
+// @Produce json
+// @Param post_id path string true "JPL post ID"
+// @Success 200 {array} models.TimetableEntry
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/posts/{post_id}/timetable [get]
+func HandleGetTimetable(tracker *trains.Tracker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		postID := c.Params("post_id")
+		if !services.PostExists(postID) {
+			return unknownPost(c)
+		}
+		entries := tracker.Timetable(postID)
+		return c.JSON(fiber.Map{
+			"post_id":   postID,
+			"timetable": entries,
+			"total":     len(entries),
+		})
+	}
+}
+
+// HandleSetTimetable replaces a post's timetable with an uploaded CSV
+// @Summary Import Post Timetable
+// @Description CSV with a header row: train_id,time[,days] (GTFS stop_times.txt columns trip_id, arrival_time and stop_id are accepted)
+// @Tags trains
+// @Security BearerAuth
+// @Accept text/csv
+// @Produce json
+// @Param post_id path string true "JPL post ID"
+// @Success 200 {array} models.TimetableEntry
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/posts/{post_id}/timetable [put]
+func HandleSetTimetable(tracker *trains.Tracker) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		// Params point into the request buffer; the ID is kept by the tracker
+		postID := utils.CopyString(c.Params("post_id"))
+		if !services.PostExists(postID) {
+			return unknownPost(c)
+		}
+		entries, err := trains.ParseTimetable(bytes.NewReader(c.Body()), postID)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		}
+		if err := tracker.SetTimetable(c.Context(), postID, entries); err != nil {
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+				"error":   "db_error",
+				"message": "Failed to save timetable",
+			})
+		}
+		return c.JSON(fiber.Map{
+			"post_id":   postID,
+			"timetable": tracker.Timetable(postID),
+			"total":     len(entries),
+		})
+	}
+}
+
+func unknownPost(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error":   "not_found",
+		"message": "Unknown post: " + c.Params("post_id"),
+	})
+}
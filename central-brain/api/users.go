@@ -0,0 +1,188 @@
+package api
+
+import (
+	"errors"
+
+	"central-brain/middleware"
+	"central-brain/models"
+	"central-brain/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// HandleListUsers returns every user account
+// @Summary List Users
+// @Description List station masters, JPL officers and admins (DAOP_ADMIN only)
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Success 200 {array} models.User
+// @Router /api/users [get]
+func HandleListUsers(c *fiber.Ctx) error {
+	list := services.ListUsers()
+	return c.JSON(fiber.Map{
+		"users": list,
+		"total": len(list),
+	})
+}
+
+// HandleGetUser returns a single user account
+// @Summary Get User
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/users/{id} [get]
+func HandleGetUser(c *fiber.Ctx) error {
+	user, err := services.GetUserByID(c.Params("id"))
+	if err != nil {
+		return userError(c, err)
+	}
+	return c.JSON(user)
+}
+
+// HandleCreateUser creates a station master, JPL officer or admin account
+// @Summary Create User
+// @Description Post and station assignments are validated against the hierarchy (DAOP_ADMIN only)
+// @Tags users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param user body models.UserCreateRequest true "User account"
+// @Success 201 {object} models.User
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 409 {object} models.ErrorInfo
+// @Router /api/users [post]
+func HandleCreateUser(c *fiber.Ctx) error {
+	var req models.UserCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "bad_request",
+			"message": "Invalid request body",
+		})
+	}
+
+	user, err := services.CreateUser(c.Context(), req)
+	if err != nil {
+		return userError(c, err)
+	}
+	return c.Status(fiber.StatusCreated).JSON(user)
+}
+
+// HandleUpdateUser changes a user's name, role or assignment
+// @Summary Update User
+// @Tags users
+// @Security BearerAuth
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param user body models.UserUpdateRequest true "Fields to change"
+// @Success 200 {object} models.User
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/users/{id} [put]
+func HandleUpdateUser(c *fiber.Ctx) error {
+	var req models.UserUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "bad_request",
+			"message": "Invalid request body",
+		})
+	}
+
+	id := c.Params("id")
+	if id == middleware.GetUserID(c) && req.Role != nil && *req.Role != models.RoleDAOPAdmin {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "bad_request",
+			"message": "You cannot change your own role",
+		})
+	}
+
+	user, err := services.UpdateUser(c.Context(), id, req)
+	if err != nil {
+		return userError(c, err)
+	}
+	return c.JSON(user)
+}
+
+// HandleSetUserDisabled disables or re-enables an account
+// @Summary Disable / Enable User
+// @Description Disabled users cannot log in and their tokens are rejected
+// @Tags users
+// @Security BearerAuth
+// @Produce json
+// @Param id path string true "User ID"
+// @Success 200 {object} models.User
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/users/{id}/disable [post]
+// @Router /api/users/{id}/enable [post]
+func HandleSetUserDisabled(disabled bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		id := c.Params("id")
+		if disabled && id == middleware.GetUserID(c) {
+			return c.Status(400).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": "You cannot disable your own account",
+			})
+		}
+
+		user, err := services.SetUserDisabled(c.Context(), id, disabled)
+		if err != nil {
+			return userError(c, err)
+		}
+		return c.JSON(user)
+	}
+}
+
+// HandleResetPassword sets a new password for a user
+// @Summary Reset Password
+// @Tags users
+// @Security BearerAuth
+// @Accept json
+// @Param id path string true "User ID"
+// @Param password body models.PasswordResetRequest true "New password"
+// @Success 204
+// @Failure 400 {object} models.ErrorInfo
+// @Failure 404 {object} models.ErrorInfo
+// @Router /api/users/{id}/password [post]
+func HandleResetPassword(c *fiber.Ctx) error {
+	var req models.PasswordResetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "bad_request",
+			"message": "Invalid request body",
+		})
+	}
+
+	if err := services.ResetPassword(c.Context(), c.Params("id"), req.Password); err != nil {
+		return userError(c, err)
+	}
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func userError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrUserNotFound):
+		return c.Status(404).JSON(fiber.Map{
+			"error":   "not_found",
+			"message": "User " + c.Params("id") + " not found",
+		})
+	case errors.Is(err, services.ErrUserExists):
+		return c.Status(409).JSON(fiber.Map{
+			"error":   "conflict",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrInvalidUser):
+		return c.Status(400).JSON(fiber.Map{
+			"error":   "bad_request",
+			"message": err.Error(),
+		})
+	default:
+		return c.Status(500).JSON(fiber.Map{
+			"error":   "db_error",
+			"message": "Failed to save user",
+		})
+	}
+}
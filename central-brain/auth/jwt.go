@@ -6,8 +6,11 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// Change this in production!
-var jwtSecret = []byte("aeon-railguard-secret-key-change-in-production")
+func init() {
+	// iat is compared with user revocation cutoffs; at whole seconds a
+	// login right after a password reset would be revoked with the old tokens
+	jwt.TimePrecision = time.Microsecond
+}
 
 // Claims represents JWT claims
 type Claims struct {
@@ -18,35 +21,52 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-// GenerateToken creates a new JWT token
+// GenerateToken creates a new access token valid for AccessTokenTTL, signed
+// with the active key and tagged with its kid. Each token gets a unique ID
+// (jti) so it can be revoked on logout.
 func GenerateToken(userID, role, postID, stationID string) (string, error) {
+	key, err := signingKey()
+	if err != nil {
+		return "", err
+	}
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
 	claims := Claims{
 		UserID:    userID,
 		Role:      role,
 		PostID:    postID,
 		StationID: stationID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ID:        jti,
+			Subject:   userID,
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
 			Issuer:    "aeon-railguard",
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(jwtSecret)
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey())
 }
 
-// ValidateToken validates JWT and returns claims
+// ValidateToken validates JWT and returns claims. Revoked tokens are rejected
+// with ErrTokenRevoked.
 func ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
+	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, lookupKey,
+		jwt.WithValidMethods([]string{AlgHS256, AlgRS256, AlgEdDSA}))
 
 	if err != nil {
 		return nil, err
 	}
 
 	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+		if isRevoked(claims) {
+			return nil, ErrTokenRevoked
+		}
 		return claims, nil
 	}
 
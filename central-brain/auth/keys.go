@@ -0,0 +1,404 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Supported signing algorithms
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// Environment variables read by LoadSigningKeys
+const (
+	EnvKeysFile = "JWT_KEYS_FILE" // JSON key file, see keyFile
+	EnvSecret   = "JWT_SECRET"    // single HS256 secret when no key file is used
+)
+
+// devSecret is only used when neither JWT_KEYS_FILE nor JWT_SECRET is set.
+const (
+	devSecret = "aeon-railguard-secret-key-change-in-production"
+	devSource = "built-in development secret"
+)
+
+var (
+	ErrUnknownKeyID = errors.New("unknown signing key")
+	ErrNoSigningKey = errors.New("no active signing key")
+)
+
+// SigningKey is a key used to sign or verify access tokens. Keys without a
+// private part (or secret) only verify; they are kept around during rotation
+// so tokens signed before the switch stay valid until they expire.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	ExpiresAt time.Time // zero means the key does not expire
+
+	secret  []byte        // HS256
+	private crypto.Signer // RS256 / EdDSA
+	public  crypto.PublicKey
+}
+
+func (k *SigningKey) canSign() bool {
+	return k.secret != nil || k.private != nil
+}
+
+func (k *SigningKey) expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && now.After(k.ExpiresAt)
+}
+
+func (k *SigningKey) method() jwt.SigningMethod {
+	switch k.Algorithm {
+	case AlgRS256:
+		return jwt.SigningMethodRS256
+	case AlgEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+func (k *SigningKey) signKey() interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.private
+}
+
+func (k *SigningKey) verifyKey() interface{} {
+	if k.secret != nil {
+		return k.secret
+	}
+	return k.public
+}
+
+// keyFile is the format of JWT_KEYS_FILE. Relative paths are resolved
+// against the directory of the key file.
+//
+//	{
+//	  "active": "2026-10",
+//	  "keys": [
+//	    {"kid": "2026-10", "alg": "EdDSA", "private_key_file": "jwt-2026-10.pem"},
+//	    {"kid": "2026-07", "alg": "RS256", "public_key_file": "jwt-2026-07.pub.pem", "expires_at": "2026-10-20T00:00:00Z"},
+//	    {"kid": "shared", "alg": "HS256", "secret_env": "JWT_SECRET"}
+//	  ]
+//	}
+type keyFile struct {
+	Active string         `json:"active"`
+	Keys   []keyFileEntry `json:"keys"`
+}
+
+type keyFileEntry struct {
+	ID             string    `json:"kid"`
+	Algorithm      string    `json:"alg"`
+	PrivateKeyFile string    `json:"private_key_file,omitempty"`
+	PublicKeyFile  string    `json:"public_key_file,omitempty"`
+	Secret         string    `json:"secret,omitempty"`
+	SecretEnv      string    `json:"secret_env,omitempty"`
+	ExpiresAt      time.Time `json:"expires_at,omitempty"`
+}
+
+// Signing keys are package state like the session lists: the active key
+// signs new tokens, every unexpired key in byID verifies.
+var (
+	keysMu     sync.RWMutex
+	activeKey  *SigningKey
+	keysByID   map[string]*SigningKey
+	keysSource string
+)
+
+func init() {
+	dev := &SigningKey{ID: "dev", Algorithm: AlgHS256, secret: []byte(devSecret)}
+	activeKey, keysByID, keysSource = dev, map[string]*SigningKey{dev.ID: dev}, devSource
+}
+
+// LoadSigningKeys configures keys from JWT_KEYS_FILE or JWT_SECRET. Without
+// either, the built-in development secret stays active. Keys that were active
+// before and are missing from the new configuration keep verifying for
+// AccessTokenTTL, so calling it again (e.g. on SIGHUP) rotates keys without
+// logging anyone out.
+func LoadSigningKeys() error {
+	var (
+		active *SigningKey
+		byID   map[string]*SigningKey
+		source string
+		err    error
+	)
+	switch {
+	case os.Getenv(EnvKeysFile) != "":
+		source = os.Getenv(EnvKeysFile)
+		active, byID, err = readKeyFile(source)
+		if err != nil {
+			return fmt.Errorf("%s: %w", source, err)
+		}
+	case os.Getenv(EnvSecret) != "":
+		source = EnvSecret
+		key := &SigningKey{ID: "default", Algorithm: AlgHS256, secret: []byte(os.Getenv(EnvSecret))}
+		active, byID = key, map[string]*SigningKey{key.ID: key}
+	default:
+		return nil
+	}
+
+	keysMu.Lock()
+	defer keysMu.Unlock()
+
+	// The development secret is public, so it is never kept around.
+	retireAt := time.Now().Add(AccessTokenTTL)
+	for id, old := range keysByID {
+		if _, kept := byID[id]; kept || old.expired(time.Now()) || keysSource == devSource {
+			continue
+		}
+		retired := *old
+		retired.private = nil // HS256 keeps its secret, it is needed to verify
+		if retired.ExpiresAt.IsZero() || retired.ExpiresAt.After(retireAt) {
+			retired.ExpiresAt = retireAt
+		}
+		byID[id] = &retired
+	}
+	activeKey, keysByID, keysSource = active, byID, source
+	return nil
+}
+
+// ActiveKeyInfo describes the key currently signing tokens, for startup logs.
+func ActiveKeyInfo() (kid, alg, source string) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return activeKey.ID, activeKey.Algorithm, keysSource
+}
+
+// UsingDevSecret reports whether tokens are signed with the built-in secret.
+func UsingDevSecret() bool {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	return keysSource == devSource
+}
+
+func signingKey() (*SigningKey, error) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+	if activeKey == nil || !activeKey.canSign() {
+		return nil, ErrNoSigningKey
+	}
+	return activeKey, nil
+}
+
+// lookupKey is the jwt.Keyfunc: it picks the key named by the kid header
+// and refuses tokens whose alg does not match that key.
+func lookupKey(token *jwt.Token) (interface{}, error) {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	key := activeKey // tokens issued before kid headers were added
+	if kid, ok := token.Header["kid"].(string); ok {
+		key = keysByID[kid]
+	}
+	if key == nil || key.expired(time.Now()) {
+		return nil, ErrUnknownKeyID
+	}
+	if token.Method.Alg() != key.Algorithm {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+	return key.verifyKey(), nil
+}
+
+func readKeyFile(path string) (*SigningKey, map[string]*SigningKey, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cfg keyFile
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return nil, nil, err
+	}
+
+	dir := filepath.Dir(path)
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+
+	byID := make(map[string]*SigningKey, len(cfg.Keys))
+	for _, e := range cfg.Keys {
+		if e.ID == "" {
+			return nil, nil, errors.New("every key needs a kid")
+		}
+		if _, dup := byID[e.ID]; dup {
+			return nil, nil, fmt.Errorf("duplicate kid %q", e.ID)
+		}
+		key, err := parseKeyEntry(e, resolve)
+		if err != nil {
+			return nil, nil, fmt.Errorf("key %q: %w", e.ID, err)
+		}
+		byID[e.ID] = key
+	}
+
+	active, ok := byID[cfg.Active]
+	if !ok {
+		return nil, nil, fmt.Errorf("active key %q is not listed", cfg.Active)
+	}
+	if !active.canSign() {
+		return nil, nil, fmt.Errorf("active key %q has no private key or secret", cfg.Active)
+	}
+	return active, byID, nil
+}
+
+func parseKeyEntry(e keyFileEntry, resolve func(string) string) (*SigningKey, error) {
+	key := &SigningKey{ID: e.ID, Algorithm: e.Algorithm, ExpiresAt: e.ExpiresAt}
+
+	switch e.Algorithm {
+	case AlgHS256:
+		secret := e.Secret
+		if e.SecretEnv != "" {
+			secret = os.Getenv(e.SecretEnv)
+		}
+		if len(secret) < 32 {
+			return nil, errors.New("HS256 secret must be at least 32 bytes")
+		}
+		key.secret = []byte(secret)
+		return key, nil
+	case AlgRS256, AlgEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported alg %q (want HS256, RS256 or EdDSA)", e.Algorithm)
+	}
+
+	switch {
+	case e.PrivateKeyFile != "":
+		priv, err := readPrivateKey(resolve(e.PrivateKeyFile))
+		if err != nil {
+			return nil, err
+		}
+		key.private, key.public = priv, priv.Public()
+	case e.PublicKeyFile != "":
+		pub, err := readPublicKey(resolve(e.PublicKeyFile))
+		if err != nil {
+			return nil, err
+		}
+		key.public = pub
+	default:
+		return nil, errors.New("private_key_file or public_key_file is required")
+	}
+
+	switch key.public.(type) {
+	case *rsa.PublicKey:
+		if key.Algorithm != AlgRS256 {
+			return nil, errors.New("RSA key needs alg RS256")
+		}
+	case ed25519.PublicKey:
+		if key.Algorithm != AlgEdDSA {
+			return nil, errors.New("Ed25519 key needs alg EdDSA")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", key.public)
+	}
+	return key, nil
+}
+
+func readPEM(path string) (*pem.Block, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM block found", path)
+	}
+	return block, nil
+}
+
+func readPrivateKey(path string) (crypto.Signer, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PRIVATE KEY" {
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported private key type %T", path, parsed)
+	}
+	return signer, nil
+}
+
+func readPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "RSA PUBLIC KEY" {
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`   // RSA modulus
+	E   string `json:"e,omitempty"`   // RSA exponent
+	Crv string `json:"crv,omitempty"` // OKP curve
+	X   string `json:"x,omitempty"`   // OKP public key
+}
+
+// JWKSet is the body of /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// PublicJWKS returns every unexpired asymmetric key. HS256 secrets are never published.
+func PublicJWKS() JWKSet {
+	keysMu.RLock()
+	defer keysMu.RUnlock()
+
+	now := time.Now()
+	set := JWKSet{Keys: []JWK{}}
+	for _, k := range keysByID {
+		if k.expired(now) {
+			continue
+		}
+		b64 := base64.RawURLEncoding.EncodeToString
+		switch pub := k.public.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA", Kid: k.ID, Use: "sig", Alg: k.Algorithm,
+				N: b64(pub.N.Bytes()),
+				E: b64(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP", Kid: k.ID, Use: "sig", Alg: k.Algorithm,
+				Crv: "Ed25519",
+				X:   b64(pub),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
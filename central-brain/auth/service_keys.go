@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"central-brain/models"
+)
+
+// Signed ingest requests carry these headers.
+const (
+	HeaderKeyID     = "X-Engine-Key"
+	HeaderTimestamp = "X-Timestamp" // unix seconds
+	HeaderNonce     = "X-Nonce"     // unique per request and key
+	HeaderSignature = "X-Signature" // hex HMAC-SHA256 of CanonicalRequest
+)
+
+// SignedHeaders are the request headers that change how a request is handled
+// (body format, frame metadata); they are part of the signature.
+var SignedHeaders = []string{"Content-Type", "X-Capture-Timestamp", "X-Sequence", "X-Engine-ID", "X-Resolution"}
+
+// maxNonceLength bounds the replay cache entries a client can create.
+const maxNonceLength = 64
+
+// MaxClockSkew is how far a request timestamp may be from server time.
+const MaxClockSkew = 5 * time.Minute
+
+var (
+	ErrUnknownKey       = errors.New("unknown or revoked service key")
+	ErrStaleRequest     = errors.New("request timestamp outside allowed window")
+	ErrBadSignature     = errors.New("invalid request signature")
+	ErrMissingNonce     = errors.New("request nonce missing or too long")
+	ErrReplayedRequest  = errors.New("request nonce already used")
+	ErrServiceKeyExists = errors.New("service key already exists")
+)
+
+// ServiceKeyStore persists issued service keys.
+type ServiceKeyStore interface {
+	ListServiceKeys(ctx context.Context) ([]models.ServiceKey, error)
+	InsertServiceKey(ctx context.Context, key models.ServiceKey) error
+	RevokeServiceKey(ctx context.Context, id string, at time.Time) error
+}
+
+// ServiceKeyRing verifies HMAC-signed requests from AI engines.
+type ServiceKeyRing struct {
+	mu    sync.Mutex
+	keys  map[string]models.ServiceKey
+	seen  map[string]time.Time // key ID + nonce -> expiry, for replay protection
+	prune time.Time            // last time expired nonces were dropped
+	store ServiceKeyStore
+}
+
+// NewServiceKeyRing creates a key ring. store may be nil for memory-only operation.
+func NewServiceKeyRing(store ServiceKeyStore) *ServiceKeyRing {
+	return &ServiceKeyRing{
+		keys:  make(map[string]models.ServiceKey),
+		seen:  make(map[string]time.Time),
+		store: store,
+	}
+}
+
+// Load reads persisted keys into memory.
+func (r *ServiceKeyRing) Load(ctx context.Context) error {
+	if r.store == nil {
+		return nil
+	}
+	list, err := r.store.ListServiceKeys(ctx)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, k := range list {
+		r.keys[k.ID] = k
+	}
+	return nil
+}
+
+// Issue creates a new key bound to cameraIDs. The returned key includes the
+// secret; it is never returned again.
+func (r *ServiceKeyRing) Issue(ctx context.Context, name string, cameraIDs []string, createdBy string) (models.ServiceKey, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return models.ServiceKey{}, err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		return models.ServiceKey{}, err
+	}
+
+	key := models.ServiceKey{
+		ID:        "eng_" + id,
+		Name:      name,
+		Secret:    secret,
+		CameraIDs: cameraIDs,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, exists := r.keys[key.ID]; exists {
+		return models.ServiceKey{}, ErrServiceKeyExists
+	}
+	if r.store != nil {
+		if err := r.store.InsertServiceKey(ctx, key); err != nil {
+			return models.ServiceKey{}, err
+		}
+	}
+	r.keys[key.ID] = key
+	return key, nil
+}
+
+// List returns all keys without their secrets, newest first.
+func (r *ServiceKeyRing) List() []models.ServiceKey {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]models.ServiceKey, 0, len(r.keys))
+	for _, k := range r.keys {
+		k.Secret = ""
+		out = append(out, k)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.After(out[j].CreatedAt) })
+	return out
+}
+
+// Revoke disables a key immediately.
+func (r *ServiceKeyRing) Revoke(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key, ok := r.keys[id]
+	if !ok || key.RevokedAt != nil {
+		return ErrUnknownKey
+	}
+	now := time.Now().UTC()
+	if r.store != nil {
+		if err := r.store.RevokeServiceKey(ctx, id, now); err != nil {
+			return err
+		}
+	}
+	key.RevokedAt = &now
+	r.keys[id] = key
+	return nil
+}
+
+// SignedRequest is the part of a request covered by its signature.
+type SignedRequest struct {
+	Method    string
+	Path      string
+	Timestamp string
+	Nonce     string
+	Header    func(name string) string // values of SignedHeaders; nil for none
+	Body      []byte
+}
+
+// Verify checks a request signed with keyID and returns the key that signed
+// it. Each nonce is accepted once per key within the clock-skew window.
+func (r *ServiceKeyRing) Verify(keyID, signature string, req SignedRequest) (models.ServiceKey, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.keys[keyID]
+	if !ok || key.RevokedAt != nil {
+		return models.ServiceKey{}, ErrUnknownKey
+	}
+	if req.Nonce == "" || len(req.Nonce) > maxNonceLength {
+		return models.ServiceKey{}, ErrMissingNonce
+	}
+
+	unix, err := strconv.ParseInt(req.Timestamp, 10, 64)
+	if err != nil {
+		return models.ServiceKey{}, ErrStaleRequest
+	}
+	now := time.Now()
+	sent := time.Unix(unix, 0)
+	if sent.Before(now.Add(-MaxClockSkew)) || sent.After(now.Add(MaxClockSkew)) {
+		return models.ServiceKey{}, ErrStaleRequest
+	}
+
+	expected := SignRequest(key.Secret, req)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return models.ServiceKey{}, ErrBadSignature
+	}
+
+	r.pruneSeenLocked(now)
+	seenKey := keyID + "\n" + req.Nonce
+	if _, replay := r.seen[seenKey]; replay {
+		return models.ServiceKey{}, ErrReplayedRequest
+	}
+	r.seen[strings.Clone(seenKey)] = sent.Add(MaxClockSkew) // header values may be reused by the caller
+
+	key.Secret = ""
+	return key, nil
+}
+
+func (r *ServiceKeyRing) pruneSeenLocked(now time.Time) {
+	if now.Sub(r.prune) < time.Second {
+		return
+	}
+	r.prune = now
+	for k, expiry := range r.seen {
+		if now.After(expiry) {
+			delete(r.seen, k)
+		}
+	}
+}
+
+// CanonicalRequest is the string signed by engines: METHOD, PATH, TIMESTAMP,
+// NONCE, one lowercase "name:value" line per SignedHeaders entry (empty when
+// absent) and hex(sha256(body)), joined by newlines.
+func CanonicalRequest(r SignedRequest) string {
+	var b strings.Builder
+	b.WriteString(r.Method + "\n" + r.Path + "\n" + r.Timestamp + "\n" + r.Nonce + "\n")
+	for _, name := range SignedHeaders {
+		value := ""
+		if r.Header != nil {
+			value = r.Header(name)
+		}
+		b.WriteString(strings.ToLower(name) + ":" + value + "\n")
+	}
+	sum := sha256.Sum256(r.Body)
+	b.WriteString(hex.EncodeToString(sum[:]))
+	return b.String()
+}
+
+// SignRequest returns the hex HMAC-SHA256 signature of a request.
+func SignRequest(secret string, r SignedRequest) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(CanonicalRequest(r)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewNonce returns a random request nonce.
+func NewNonce() (string, error) {
+	return randomHex(16)
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"central-brain/models"
+)
+
+// TestSignRequestMatchesEngine pins the canonical form the AI engine signs
+// (ai-engine/app.py sign_headers).
+func TestSignRequestMatchesEngine(t *testing.T) {
+	headers := map[string]string{"Content-Type": "image/jpeg", "X-Sequence": "7"}
+	got := SignRequest("s3cret", SignedRequest{
+		Method:    "POST",
+		Path:      "/api/internal/stream/cam1",
+		Timestamp: "1792247387",
+		Nonce:     "n1",
+		Header:    func(name string) string { return headers[name] },
+		Body:      []byte("jpeg"),
+	})
+	if want := "56a540ee045676781336ee33ea0af94f0858d62620d52fdc638d5492fc892d43"; got != want {
+		t.Errorf("signature %s, want %s", got, want)
+	}
+}
+
+func TestServiceKeyVerify(t *testing.T) {
+	ring := NewServiceKeyRing(nil)
+	key, err := ring.Issue(context.Background(), "engine-jbg", []string{"cam1"}, "DAOP-7")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	revoked, err := ring.Issue(context.Background(), "old engine", []string{"*"}, "DAOP-7")
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if err := ring.Revoke(context.Background(), revoked.ID); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+
+	now := strconv.FormatInt(time.Now().Unix(), 10)
+	frame := []byte("same jpeg")
+	request := func(nonce, ts, sequence string) SignedRequest {
+		headers := map[string]string{"Content-Type": "image/jpeg", "X-Sequence": sequence}
+		return SignedRequest{
+			Method:    "POST",
+			Path:      "/api/internal/stream/cam1",
+			Timestamp: ts,
+			Nonce:     nonce,
+			Header:    func(name string) string { return headers[name] },
+			Body:      frame,
+		}
+	}
+
+	tests := []struct {
+		name    string
+		keyID   string
+		secret  string
+		signed  SignedRequest // what the client signed
+		sent    SignedRequest // what arrived
+		wantErr error
+	}{
+		{name: "valid", keyID: key.ID, secret: key.Secret, signed: request("a1", now, "1"), sent: request("a1", now, "1")},
+		// A frozen camera resends the same JPEG in the same second
+		{name: "identical body, new nonce", keyID: key.ID, secret: key.Secret, signed: request("a2", now, "1"), sent: request("a2", now, "1")},
+		{name: "replayed nonce", keyID: key.ID, secret: key.Secret, signed: request("a1", now, "2"), sent: request("a1", now, "2"), wantErr: ErrReplayedRequest},
+		{name: "missing nonce", keyID: key.ID, secret: key.Secret, signed: request("", now, "1"), sent: request("", now, "1"), wantErr: ErrMissingNonce},
+		{name: "tampered signed header", keyID: key.ID, secret: key.Secret, signed: request("a3", now, "3"), sent: request("a3", now, "99"), wantErr: ErrBadSignature},
+		{name: "wrong secret", keyID: key.ID, secret: "guess", signed: request("a4", now, "1"), sent: request("a4", now, "1"), wantErr: ErrBadSignature},
+		{name: "stale", keyID: key.ID, secret: key.Secret, signed: request("a5", "1000", "1"), sent: request("a5", "1000", "1"), wantErr: ErrStaleRequest},
+		{name: "bad timestamp", keyID: key.ID, secret: key.Secret, signed: request("a6", "soon", "1"), sent: request("a6", "soon", "1"), wantErr: ErrStaleRequest},
+		{name: "unknown key", keyID: "eng_nope", secret: key.Secret, signed: request("a7", now, "1"), sent: request("a7", now, "1"), wantErr: ErrUnknownKey},
+		{name: "revoked key", keyID: revoked.ID, secret: revoked.Secret, signed: request("a8", now, "1"), sent: request("a8", now, "1"), wantErr: ErrUnknownKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ring.Verify(tt.keyID, SignRequest(tt.secret, tt.signed), tt.sent)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (got.ID != key.ID || got.Secret != "" || !got.AllowsCamera("cam1")) {
+				t.Errorf("key %+v", got)
+			}
+		})
+	}
+}
+
+func TestServiceKeyNonceIsPerKey(t *testing.T) {
+	ring := NewServiceKeyRing(nil)
+	var keys []models.ServiceKey
+	for _, name := range []string{"engine-a", "engine-b"} {
+		k, err := ring.Issue(context.Background(), name, []string{"*"}, "DAOP-7")
+		if err != nil {
+			t.Fatalf("issue: %v", err)
+		}
+		keys = append(keys, k)
+	}
+	req := SignedRequest{Method: "POST", Path: "/api/internal/push", Timestamp: strconv.FormatInt(time.Now().Unix(), 10), Nonce: "shared", Body: []byte("{}")}
+	for _, k := range keys {
+		if _, err := ring.Verify(k.ID, SignRequest(k.Secret, req), req); err != nil {
+			t.Errorf("%s: %v", k.Name, err)
+		}
+	}
+}
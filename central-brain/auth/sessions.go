@@ -0,0 +1,304 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"central-brain/models"
+)
+
+// Token lifetimes. Access tokens are short so that role changes and
+// revocations take effect quickly; dashboards renew them with a refresh token.
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+)
+
+// SessionStore persists refresh tokens and access token revocations.
+type SessionStore interface {
+	ListRefreshTokens(ctx context.Context, now time.Time) ([]models.RefreshToken, error)
+	UpsertRefreshToken(ctx context.Context, t models.RefreshToken) error
+	ListTokenRevocations(ctx context.Context, now time.Time) ([]models.TokenRevocation, error)
+	InsertTokenRevocation(ctx context.Context, r models.TokenRevocation) error
+}
+
+// Session state is kept in memory and written through to the store, so
+// AuthRequired can check revocations without a database round trip.
+var (
+	sessionMu     sync.Mutex
+	sessionStore  SessionStore
+	refreshTokens = make(map[string]models.RefreshToken) // by ID (token hash)
+	revokedJTIs   = make(map[string]time.Time)           // jti -> access token expiry
+	userCutoffs   = make(map[string]time.Time)           // user ID -> tokens issued before are revoked
+	lastPrune     time.Time
+)
+
+// LoadSessions reads unexpired refresh tokens and revocations from store.
+// store may be nil for memory-only operation.
+func LoadSessions(ctx context.Context, store SessionStore) error {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	sessionStore = store
+	return syncSessionsLocked(ctx)
+}
+
+// SyncSessions re-reads refresh tokens and revocations from the store, so
+// sessions ended outside the server (the user CLI) end here too.
+func SyncSessions(ctx context.Context) error {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	return syncSessionsLocked(ctx)
+}
+
+func syncSessionsLocked(ctx context.Context) error {
+	if sessionStore == nil {
+		return nil
+	}
+	now := time.Now().UTC()
+
+	tokens, err := sessionStore.ListRefreshTokens(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, t := range tokens {
+		refreshTokens[t.ID] = t
+	}
+
+	revocations, err := sessionStore.ListTokenRevocations(ctx, now)
+	if err != nil {
+		return err
+	}
+	for _, r := range revocations {
+		applyRevocationLocked(r)
+	}
+	return nil
+}
+
+// IssueSession creates an access token and a new refresh token family for a user.
+func IssueSession(ctx context.Context, user *models.User) (access, refresh string, err error) {
+	access, err = GenerateToken(user.ID, user.Role, user.PostID, user.StationID)
+	if err != nil {
+		return "", "", err
+	}
+
+	familyID, err := randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+	refresh, err = issueRefreshLocked(ctx, user.ID, familyID)
+	if err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one in the same family
+// and returns the user it belongs to. Presenting a token that was already
+// rotated revokes the whole family, since it means the token was copied.
+func RotateRefreshToken(ctx context.Context, token string) (userID, refresh string, err error) {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	now := time.Now().UTC()
+	current, ok := refreshTokens[hashToken(token)]
+	if !ok || now.After(current.ExpiresAt) {
+		return "", "", ErrInvalidRefreshToken
+	}
+	if current.RevokedAt != nil {
+		if current.ReplacedBy != "" {
+			if err := revokeRefreshLocked(ctx, now, func(t models.RefreshToken) bool {
+				return t.FamilyID == current.FamilyID
+			}); err != nil {
+				return "", "", err
+			}
+		}
+		return "", "", ErrInvalidRefreshToken
+	}
+
+	refresh, err = issueRefreshLocked(ctx, current.UserID, current.FamilyID)
+	if err != nil {
+		return "", "", err
+	}
+	current.RevokedAt = &now
+	current.ReplacedBy = hashToken(refresh)
+	if err := saveRefreshLocked(ctx, current); err != nil {
+		return "", "", err
+	}
+	return current.UserID, refresh, nil
+}
+
+// RevokeRefreshToken ends the session a refresh token belongs to (logout).
+// Unknown tokens are ignored.
+func RevokeRefreshToken(ctx context.Context, token string) error {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	t, ok := refreshTokens[hashToken(token)]
+	if !ok {
+		return nil
+	}
+	return revokeRefreshLocked(ctx, time.Now().UTC(), func(other models.RefreshToken) bool {
+		return other.FamilyID == t.FamilyID
+	})
+}
+
+// RevokeAccessToken invalidates a single access token until it expires.
+func RevokeAccessToken(ctx context.Context, claims *Claims) error {
+	if claims.ID == "" || claims.ExpiresAt == nil {
+		return nil
+	}
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	return addRevocationLocked(ctx, models.TokenRevocation{
+		Kind:      models.RevokeToken,
+		Subject:   claims.ID,
+		RevokedAt: time.Now().UTC(),
+		ExpiresAt: claims.ExpiresAt.Time,
+	})
+}
+
+// RevokeUser ends every session of a user: all refresh tokens are revoked and
+// access tokens issued until now are rejected.
+func RevokeUser(ctx context.Context, userID string) error {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	now := time.Now().UTC()
+	if err := revokeRefreshLocked(ctx, now, func(t models.RefreshToken) bool {
+		return t.UserID == userID
+	}); err != nil {
+		return err
+	}
+	return addRevocationLocked(ctx, models.TokenRevocation{
+		Kind:      models.RevokeUser,
+		Subject:   userID,
+		RevokedAt: now,
+		ExpiresAt: now.Add(AccessTokenTTL),
+	})
+}
+
+// isRevoked reports whether validly signed claims were revoked.
+func isRevoked(claims *Claims) bool {
+	sessionMu.Lock()
+	defer sessionMu.Unlock()
+
+	if _, ok := revokedJTIs[claims.ID]; ok && claims.ID != "" {
+		return true
+	}
+	cutoff, ok := userCutoffs[claims.UserID]
+	if !ok || claims.IssuedAt == nil {
+		return ok
+	}
+	// iat is truncated to the microsecond (see TimePrecision in jwt.go), so
+	// it never postdates the real issue time and a login right after the
+	// revocation is kept.
+	return !claims.IssuedAt.Time.After(cutoff)
+}
+
+func issueRefreshLocked(ctx context.Context, userID, familyID string) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now().UTC()
+	err = saveRefreshLocked(ctx, models.RefreshToken{
+		ID:        hashToken(token),
+		UserID:    userID,
+		FamilyID:  familyID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(RefreshTokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+	pruneSessionsLocked(now)
+	return token, nil
+}
+
+func saveRefreshLocked(ctx context.Context, t models.RefreshToken) error {
+	if sessionStore != nil {
+		if err := sessionStore.UpsertRefreshToken(ctx, t); err != nil {
+			return err
+		}
+	}
+	refreshTokens[t.ID] = t
+	return nil
+}
+
+func revokeRefreshLocked(ctx context.Context, now time.Time, match func(models.RefreshToken) bool) error {
+	for _, t := range refreshTokens {
+		if t.RevokedAt != nil || !match(t) {
+			continue
+		}
+		t.RevokedAt = &now
+		if err := saveRefreshLocked(ctx, t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func addRevocationLocked(ctx context.Context, r models.TokenRevocation) error {
+	if sessionStore != nil {
+		if err := sessionStore.InsertTokenRevocation(ctx, r); err != nil {
+			return err
+		}
+	}
+	applyRevocationLocked(r)
+	pruneSessionsLocked(r.RevokedAt)
+	return nil
+}
+
+func applyRevocationLocked(r models.TokenRevocation) {
+	switch r.Kind {
+	case models.RevokeToken:
+		revokedJTIs[r.Subject] = r.ExpiresAt
+	case models.RevokeUser:
+		if r.RevokedAt.After(userCutoffs[r.Subject]) {
+			userCutoffs[r.Subject] = r.RevokedAt
+		}
+	}
+}
+
+// pruneSessionsLocked forgets expired entries, at most once a minute.
+// Store rows are left alone; they are skipped on the next load.
+func pruneSessionsLocked(now time.Time) {
+	if now.Sub(lastPrune) < time.Minute {
+		return
+	}
+	lastPrune = now
+	for jti, exp := range revokedJTIs {
+		if now.After(exp) {
+			delete(revokedJTIs, jti)
+		}
+	}
+	for userID, cutoff := range userCutoffs {
+		if now.After(cutoff.Add(AccessTokenTTL)) {
+			delete(userCutoffs, userID)
+		}
+	}
+	for id, t := range refreshTokens {
+		if now.After(t.ExpiresAt) {
+			delete(refreshTokens, id)
+		}
+	}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
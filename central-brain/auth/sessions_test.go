@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"central-brain/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestIsRevoked(t *testing.T) {
+	cutoff := time.Date(2026, 3, 1, 8, 0, 0, 500_000_000, time.UTC)
+	sessionMu.Lock()
+	userCutoffs["JPL-102"] = cutoff
+	revokedJTIs["logged-out"] = cutoff.Add(AccessTokenTTL)
+	sessionMu.Unlock()
+	t.Cleanup(func() {
+		sessionMu.Lock()
+		delete(userCutoffs, "JPL-102")
+		delete(revokedJTIs, "logged-out")
+		sessionMu.Unlock()
+	})
+
+	tests := []struct {
+		name   string
+		jti    string
+		userID string
+		iat    *jwt.NumericDate
+		want   bool
+	}{
+		{name: "issued before cutoff", userID: "JPL-102", iat: jwt.NewNumericDate(cutoff.Add(-time.Minute)), want: true},
+		{name: "issued earlier in the same second", userID: "JPL-102", iat: jwt.NewNumericDate(cutoff.Add(-200 * time.Millisecond)), want: true},
+		{name: "issued at cutoff", userID: "JPL-102", iat: jwt.NewNumericDate(cutoff), want: true},
+		{name: "re-login in the same second", userID: "JPL-102", iat: jwt.NewNumericDate(cutoff.Add(time.Millisecond))},
+		{name: "issued after cutoff", userID: "JPL-102", iat: jwt.NewNumericDate(cutoff.Add(time.Minute))},
+		{name: "whole-second iat of the cutoff second", userID: "JPL-102", iat: jwt.NewNumericDate(cutoff.Truncate(time.Second)), want: true},
+		{name: "no iat", userID: "JPL-102", want: true},
+		{name: "other user", userID: "JPL-105", iat: jwt.NewNumericDate(cutoff.Add(-time.Minute))},
+		{name: "revoked jti", jti: "logged-out", userID: "JPL-105", iat: jwt.NewNumericDate(cutoff.Add(time.Minute)), want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &Claims{UserID: tt.userID, RegisteredClaims: jwt.RegisteredClaims{ID: tt.jti, IssuedAt: tt.iat}}
+			if got := isRevoked(claims); got != tt.want {
+				t.Errorf("isRevoked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReloginAfterRevokeUser(t *testing.T) {
+	ctx := context.Background()
+	before, err := GenerateToken("STA-KTS", models.RoleStationMaster, "", "STA-KTS")
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	if err := RevokeUser(ctx, "STA-KTS"); err != nil {
+		t.Fatalf("revoke: %v", err)
+	}
+	t.Cleanup(func() {
+		sessionMu.Lock()
+		delete(userCutoffs, "STA-KTS")
+		sessionMu.Unlock()
+	})
+	after, err := GenerateToken("STA-KTS", models.RoleStationMaster, "", "STA-KTS")
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+
+	if _, err := ValidateToken(before); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("token from before the revocation: %v, want ErrTokenRevoked", err)
+	}
+	if _, err := ValidateToken(after); err != nil {
+		t.Errorf("token issued right after the revocation: %v", err)
+	}
+}
+
+func TestRotateRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	user := &models.User{ID: "JPL-98", Role: models.RoleJPLOfficer, PostID: "JPL-98"}
+	_, first, err := IssueSession(ctx, user)
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+
+	userID, second, err := RotateRefreshToken(ctx, first)
+	if err != nil || userID != "JPL-98" || second == first {
+		t.Fatalf("rotate: %q, %v", userID, err)
+	}
+	// Presenting the rotated token again means it was copied: the family ends
+	if _, _, err := RotateRefreshToken(ctx, first); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("reused token: %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, err := RotateRefreshToken(ctx, second); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("token of a revoked family: %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, _, err := RotateRefreshToken(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("unknown token: %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	_, refresh, err := IssueSession(ctx, &models.User{ID: "JPL-105", Role: models.RoleJPLOfficer})
+	if err != nil {
+		t.Fatalf("issue: %v", err)
+	}
+	if err := RevokeRefreshToken(ctx, refresh); err != nil {
+		t.Fatalf("logout: %v", err)
+	}
+	if _, _, err := RotateRefreshToken(ctx, refresh); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after logout: %v, want ErrInvalidRefreshToken", err)
+	}
+}
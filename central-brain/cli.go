@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"central-brain/auth"
+	"central-brain/models"
+	"central-brain/services"
+)
+
+const userUsage = `Usage:
+  central-brain user add    -id ID -name NAME [-role ROLE] [-post POST_ID] [-station STATION_ID] [-password PW]
+  central-brain user passwd -id ID [-password PW]
+  central-brain user enable  -id ID
+  central-brain user disable -id ID
+  central-brain user list
+
+ROLE is DAOP_ADMIN (default), STATION_MASTER or JPL_OFFICER.
+When -password is omitted it is read from RAILGUARD_PASSWORD or stdin.
+The database is taken from DB_DSN, like the server.`
+
+// runUserCommand manages accounts directly in the database, e.g. to bootstrap
+// the first DAOP admin before the server has any users. It returns an exit code.
+func runUserCommand(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("user "+args[0], flag.ContinueOnError)
+	id := fs.String("id", "", "user ID")
+	name := fs.String("name", "", "display name")
+	role := fs.String("role", models.RoleDAOPAdmin, "role")
+	post := fs.String("post", "", "JPL post ID (JPL_OFFICER)")
+	station := fs.String("station", "", "station ID (STATION_MASTER)")
+	password := fs.String("password", "", "password")
+	if err := fs.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	db, err := NewDatabase(os.Getenv("DB_DSN"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "open database: %v\n", err)
+		return 1
+	}
+	ctx := context.Background()
+	if err := auth.LoadSessions(ctx, db); err != nil {
+		fmt.Fprintf(os.Stderr, "load sessions: %v\n", err)
+		return 1
+	}
+	if err := services.LoadUsers(ctx, db, false); err != nil {
+		fmt.Fprintf(os.Stderr, "load users: %v\n", err)
+		return 1
+	}
+
+	switch args[0] {
+	case "add":
+		pw, err := readPassword(*password)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		u, err := services.CreateUser(ctx, models.UserCreateRequest{
+			ID:        *id,
+			Password:  pw,
+			Role:      *role,
+			Name:      *name,
+			PostID:    *post,
+			StationID: *station,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "add user: %v\n", err)
+			return 1
+		}
+		fmt.Printf("created %s (%s)\n", u.ID, u.Role)
+	case "passwd":
+		pw, err := readPassword(*password)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if err := services.ResetPassword(ctx, *id, pw); err != nil {
+			fmt.Fprintf(os.Stderr, "reset password: %v\n", err)
+			return 1
+		}
+		fmt.Printf("password updated for %s\n", *id)
+	case "enable", "disable":
+		if _, err := services.SetUserDisabled(ctx, *id, args[0] == "disable"); err != nil {
+			fmt.Fprintf(os.Stderr, "%s user: %v\n", args[0], err)
+			return 1
+		}
+		fmt.Printf("%sd %s\n", args[0], *id)
+	case "list":
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tROLE\tNAME\tPOST\tSTATION\tDISABLED")
+		for _, u := range services.ListUsers() {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%v\n", u.ID, u.Role, u.Name, u.PostID, u.StationID, u.Disabled)
+		}
+		w.Flush()
+	default:
+		fmt.Fprintln(os.Stderr, userUsage)
+		return 2
+	}
+	return 0
+}
+
+// readPassword falls back to RAILGUARD_PASSWORD, then to one line of stdin.
+func readPassword(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if env := os.Getenv("RAILGUARD_PASSWORD"); env != "" {
+		return env, nil
+	}
+	fmt.Fprint(os.Stderr, "Password: ")
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("read password: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -3,7 +3,10 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/base64"
 	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
 	"central-brain/models"
@@ -11,7 +14,7 @@ import (
 	_ "modernc.org/sqlite" // pure Go SQLite driver
 )
 
-// Database wraps SQL access for detection logs.
+// Database wraps SQL access for detection logs, cameras, users and settings.
 type Database struct {
 	conn *sql.DB
 }
@@ -52,7 +55,151 @@ CREATE TABLE IF NOT EXISTS detection_logs (
 	timestamp DATETIME,
 	camera_id TEXT,
 	detail TEXT,
-	image_url TEXT
+	image_url TEXT,
+	incident_id TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_detection_logs_ts ON detection_logs(timestamp DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_detection_logs_camera_ts ON detection_logs(camera_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_detection_logs_type_ts ON detection_logs(type, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_detection_logs_class_ts ON detection_logs(object_class, timestamp DESC);
+CREATE TABLE IF NOT EXISTS cameras (
+	id TEXT PRIMARY KEY,
+	name TEXT,
+	type TEXT,
+	status TEXT,
+	post_id TEXT,
+	unit_id TEXT,
+	lat REAL,
+	long REAL,
+	resolution TEXT,
+	fps INTEGER,
+	thermal_mode BOOLEAN,
+	created_at DATETIME,
+	updated_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS incidents (
+	id TEXT PRIMARY KEY,
+	camera_id TEXT,
+	object_id INTEGER,
+	object_class TEXT,
+	type TEXT,
+	status TEXT,
+	first_seen DATETIME,
+	last_seen DATETIME,
+	peak_confidence REAL,
+	max_duration_seconds REAL,
+	detection_count INTEGER,
+	image_url TEXT,
+	acknowledged_by TEXT,
+	acknowledged_at DATETIME,
+	closed_by TEXT,
+	closed_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_incidents_status ON incidents(status);
+CREATE TABLE IF NOT EXISTS incident_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	incident_id TEXT,
+	action TEXT,
+	actor TEXT,
+	note TEXT,
+	timestamp DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_incident_events_incident ON incident_events(incident_id);
+CREATE TABLE IF NOT EXISTS service_keys (
+	id TEXT PRIMARY KEY,
+	name TEXT,
+	secret TEXT,
+	camera_ids TEXT,
+	created_by TEXT,
+	created_at DATETIME,
+	revoked_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY,
+	password_hash TEXT,
+	role TEXT,
+	name TEXT,
+	post_id TEXT,
+	station_id TEXT,
+	disabled BOOLEAN DEFAULT 0,
+	created_at DATETIME,
+	updated_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+	id TEXT PRIMARY KEY,
+	user_id TEXT,
+	family_id TEXT,
+	created_at DATETIME,
+	expires_at DATETIME,
+	revoked_at DATETIME,
+	replaced_by TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_user ON refresh_tokens(user_id);
+CREATE TABLE IF NOT EXISTS token_revocations (
+	kind TEXT,
+	subject TEXT,
+	revoked_at DATETIME,
+	expires_at DATETIME,
+	PRIMARY KEY (kind, subject)
+);
+CREATE TABLE IF NOT EXISTS train_timetable (
+	post_id TEXT,
+	train_id TEXT,
+	time TEXT,
+	days TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_train_timetable_post ON train_timetable(post_id);
+CREATE TABLE IF NOT EXISTS gate_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	post_id TEXT,
+	state TEXT,
+	source TEXT,
+	detail TEXT,
+	timestamp DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_gate_events_post ON gate_events(post_id, timestamp);
+CREATE TABLE IF NOT EXISTS notification_routes (
+	id TEXT PRIMARY KEY,
+	name TEXT,
+	channel TEXT,
+	recipient TEXT,
+	min_severity TEXT,
+	post_ids TEXT,
+	station_ids TEXT,
+	types TEXT,
+	disabled BOOLEAN DEFAULT 0,
+	created_by TEXT,
+	created_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS escalation_policies (
+	post_id TEXT PRIMARY KEY,
+	min_severity TEXT,
+	types TEXT,
+	steps TEXT,
+	updated_by TEXT,
+	updated_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS notification_dead_letters (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	route_id TEXT,
+	channel TEXT,
+	recipient TEXT,
+	notification TEXT,
+	error TEXT,
+	attempts INTEGER,
+	created_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS detection_rules (
+	id TEXT PRIMARY KEY,
+	name TEXT,
+	priority INTEGER,
+	disabled BOOLEAN DEFAULT 0,
+	stop BOOLEAN DEFAULT 0,
+	match TEXT,
+	actions TEXT,
+	created_by TEXT,
+	created_at DATETIME,
+	updated_at DATETIME
 );
 CREATE TABLE IF NOT EXISTS settings (
 	key TEXT PRIMARY KEY,
@@ -60,7 +207,71 @@ CREATE TABLE IF NOT EXISTS settings (
 	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 `
-	_, err := db.Exec(ddl)
+	if _, err := db.Exec(ddl); err != nil {
+		return err
+	}
+
+	// Columns added after the first release; CREATE TABLE IF NOT EXISTS
+	// does not touch existing tables.
+	if err := ensureColumn(db, "detection_logs", "incident_id", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "incidents", "clip_url", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "cameras", "roi", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "cameras", "privacy_masks", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "cameras", "privacy_mask_mode", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "incidents", "severity", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "incidents", "train", "TEXT"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "incidents", "escalation_level", "INTEGER"); err != nil {
+		return err
+	}
+	if err := ensureColumn(db, "detection_logs", "severity", "TEXT"); err != nil {
+		return err
+	}
+	return ensureColumn(db, "detection_logs", "tags", "TEXT")
+}
+
+// ensureColumn adds a column to an existing table when it is missing.
+func ensureColumn(db *sql.DB, table, column, decl string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			ctype     string
+			notNull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, decl))
 	return err
 }
 
@@ -71,14 +282,16 @@ func (d *Database) InsertDetection(ctx context.Context, payload models.Detection
 	}
 
 	if payload.Timestamp.IsZero() {
-		payload.Timestamp = time.Now().UTC()
+		payload.Timestamp = time.Now()
 	}
+	// Stored as text; keep one zone so range filters and ordering compare correctly.
+	payload.Timestamp = payload.Timestamp.UTC()
 
 	_, err := d.conn.ExecContext(
 		ctx,
 		`INSERT INTO detection_logs
-		(type, object_class, confidence, in_roi, object_id, duration_seconds, timestamp, camera_id, detail, image_url)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		(type, object_class, confidence, in_roi, object_id, duration_seconds, timestamp, camera_id, detail, image_url, incident_id, severity, tags)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		payload.Type,
 		payload.ObjectClass,
 		payload.Confidence,
@@ -89,53 +302,200 @@ func (d *Database) InsertDetection(ctx context.Context, payload models.Detection
 		payload.CameraID,
 		payload.AdditionalDetail,
 		payload.ImageURL,
+		payload.IncidentID,
+		payload.Severity,
+		strings.Join(payload.Tags, ","),
 	)
 	return err
 }
 
 // ListDetections returns latest detections ordered by timestamp desc.
 func (d *Database) ListDetections(ctx context.Context, limit int) ([]models.DetectionPayload, error) {
+	page, err := d.QueryDetections(ctx, models.DetectionFilter{Limit: limit})
+	return page.Detections, err
+}
+
+const detectionColumns = `id, type, object_class, confidence, in_roi, object_id, duration_seconds, timestamp, camera_id, detail, image_url, COALESCE(incident_id, ''), COALESCE(severity, ''), COALESCE(tags, '')`
+
+// QueryDetections returns one page of detections matching f, newest first.
+// Pagination is keyed on (timestamp, id) so pages stay stable while new rows arrive.
+func (d *Database) QueryDetections(ctx context.Context, f models.DetectionFilter) (models.DetectionPage, error) {
+	var page models.DetectionPage
 	if d == nil || d.conn == nil {
-		return nil, nil
+		return page, nil
 	}
-	if limit <= 0 || limit > 500 {
-		limit = 100
+	if f.Limit <= 0 || f.Limit > 500 {
+		f.Limit = 100
+	}
+
+	where, args := detectionWhere(f)
+
+	if err := d.conn.QueryRowContext(ctx, `SELECT COUNT(*) FROM detection_logs`+where, args...).Scan(&page.Total); err != nil {
+		return page, err
+	}
+
+	if f.Cursor != "" {
+		afterID, err := decodeCursor(f.Cursor)
+		if err != nil {
+			return page, err
+		}
+		where = andWhere(where, `(timestamp, id) < (SELECT timestamp, id FROM detection_logs WHERE id = ?)`)
+		args = append(args, afterID)
 	}
 
 	rows, err := d.conn.QueryContext(
 		ctx,
-		`SELECT type, object_class, confidence, in_roi, object_id, duration_seconds, timestamp, camera_id, detail, image_url
-		FROM detection_logs
-		ORDER BY timestamp DESC
-		LIMIT ?`, limit,
+		`SELECT `+detectionColumns+` FROM detection_logs`+where+`
+		ORDER BY timestamp DESC, id DESC
+		LIMIT ?`, append(args, f.Limit+1)...,
 	)
 	if err != nil {
-		return nil, err
+		return page, err
 	}
 	defer rows.Close()
 
-	var out []models.DetectionPayload
 	for rows.Next() {
-		var p models.DetectionPayload
-		var ts time.Time
+		var (
+			p    models.DetectionPayload
+			tags string
+		)
 		if err := rows.Scan(
+			&p.ID,
 			&p.Type,
 			&p.ObjectClass,
 			&p.Confidence,
 			&p.InROI,
 			&p.ObjectID,
 			&p.DurationSeconds,
-			&ts,
+			&p.Timestamp,
 			&p.CameraID,
 			&p.AdditionalDetail,
 			&p.ImageURL,
+			&p.IncidentID,
+			&p.Severity,
+			&tags,
 		); err != nil {
-			return nil, err
+			return page, err
+		}
+		p.Tags = splitList(tags)
+		page.Detections = append(page.Detections, p)
+	}
+	if err := rows.Err(); err != nil {
+		return page, err
+	}
+
+	if len(page.Detections) > f.Limit {
+		page.Detections = page.Detections[:f.Limit]
+		page.NextCursor = encodeCursor(page.Detections[f.Limit-1].ID)
+	}
+	return page, nil
+}
+
+// detectionWhere builds the WHERE clause for every filter except the cursor.
+func detectionWhere(f models.DetectionFilter) (string, []interface{}) {
+	var (
+		where string
+		args  []interface{}
+	)
+	add := func(cond string, arg interface{}) {
+		where = andWhere(where, cond)
+		args = append(args, arg)
+	}
+
+	if f.AllowedCameras != nil {
+		if len(f.AllowedCameras) == 0 {
+			where = andWhere(where, "1 = 0")
+		} else {
+			placeholders := ""
+			for id := range f.AllowedCameras {
+				if placeholders != "" {
+					placeholders += ", "
+				}
+				placeholders += "?"
+				args = append(args, id)
+			}
+			where = andWhere(where, "camera_id IN ("+placeholders+")")
+		}
+	}
+	if f.CameraID != "" {
+		add("camera_id = ?", f.CameraID)
+	}
+	if f.ObjectClass != "" {
+		add("object_class = ?", f.ObjectClass)
+	}
+	if f.Type != "" {
+		add("type = ?", f.Type)
+	}
+	if !f.From.IsZero() {
+		add("timestamp >= ?", f.From.UTC())
+	}
+	if !f.To.IsZero() {
+		add("timestamp < ?", f.To.UTC())
+	}
+	if f.MinConfidence > 0 {
+		add("confidence >= ?", f.MinConfidence)
+	}
+	if f.InROI != nil {
+		add("in_roi = ?", *f.InROI)
+	}
+	if f.MinDuration > 0 {
+		add("duration_seconds >= ?", f.MinDuration)
+	}
+	return where, args
+}
+
+func andWhere(where, cond string) string {
+	if where == "" {
+		return " WHERE " + cond
+	}
+	return where + " AND " + cond
+}
+
+func encodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, models.ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		return 0, models.ErrInvalidCursor
+	}
+	return id, nil
+}
+
+// FindDetectionCamera returns the camera of the detection whose image_url ends
+// with the given evidence file name.
+func (d *Database) FindDetectionCamera(ctx context.Context, file string) (string, bool, error) {
+	if d == nil || d.conn == nil {
+		return "", false, nil
+	}
+	var cameraID string
+	err := d.conn.QueryRowContext(ctx,
+		`SELECT camera_id FROM detection_logs WHERE image_url LIKE ? ESCAPE '\' ORDER BY id DESC LIMIT 1`,
+		"%/"+escapeLike(file),
+	).Scan(&cameraID)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return cameraID, true, nil
+}
+
+func escapeLike(s string) string {
+	out := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' || s[i] == '_' || s[i] == '\\' {
+			out = append(out, '\\')
 		}
-		p.Timestamp = ts
-		out = append(out, p)
+		out = append(out, s[i])
 	}
-	return out, rows.Err()
+	return string(out)
 }
 
 // UpsertSetting stores a simple string setting.
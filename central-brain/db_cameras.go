@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"central-brain/models"
+)
+
+// ListCameras returns all registered cameras.
+func (d *Database) ListCameras(ctx context.Context) ([]models.Camera, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+
+	rows, err := d.conn.QueryContext(ctx, `
+		SELECT id, name, type, status, post_id, unit_id, lat, long, resolution, fps, thermal_mode, roi,
+			privacy_masks, COALESCE(privacy_mask_mode, ''), created_at, updated_at
+		FROM cameras
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Camera
+	for rows.Next() {
+		var (
+			cam        models.Camera
+			roi, masks sql.NullString
+		)
+		if err := rows.Scan(
+			&cam.ID,
+			&cam.Name,
+			&cam.Type,
+			&cam.Status,
+			&cam.PostID,
+			&cam.UnitID,
+			&cam.Location.Lat,
+			&cam.Location.Long,
+			&cam.Resolution,
+			&cam.FPS,
+			&cam.ThermalMode,
+			&roi,
+			&masks,
+			&cam.PrivacyMaskMode,
+			&cam.CreatedAt,
+			&cam.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		if roi.String != "" {
+			if err := json.Unmarshal([]byte(roi.String), &cam.ROI); err != nil {
+				return nil, err
+			}
+		}
+		if masks.String != "" {
+			if err := json.Unmarshal([]byte(masks.String), &cam.PrivacyMasks); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, cam)
+	}
+	return out, rows.Err()
+}
+
+// UpsertCamera inserts or replaces a camera configuration.
+func (d *Database) UpsertCamera(ctx context.Context, cam models.Camera) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	var roi, masks []byte
+	var err error
+	if len(cam.ROI) > 0 {
+		if roi, err = json.Marshal(cam.ROI); err != nil {
+			return err
+		}
+	}
+	if len(cam.PrivacyMasks) > 0 {
+		if masks, err = json.Marshal(cam.PrivacyMasks); err != nil {
+			return err
+		}
+	}
+	_, err = d.conn.ExecContext(ctx, `
+		INSERT INTO cameras (id, name, type, status, post_id, unit_id, lat, long, resolution, fps, thermal_mode, roi,
+			privacy_masks, privacy_mask_mode, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name=excluded.name, type=excluded.type, status=excluded.status, post_id=excluded.post_id,
+			unit_id=excluded.unit_id, lat=excluded.lat, long=excluded.long, resolution=excluded.resolution,
+			fps=excluded.fps, thermal_mode=excluded.thermal_mode, roi=excluded.roi,
+			privacy_masks=excluded.privacy_masks, privacy_mask_mode=excluded.privacy_mask_mode, updated_at=excluded.updated_at
+	`,
+		cam.ID,
+		cam.Name,
+		cam.Type,
+		cam.Status,
+		cam.PostID,
+		cam.UnitID,
+		cam.Location.Lat,
+		cam.Location.Long,
+		cam.Resolution,
+		cam.FPS,
+		cam.ThermalMode,
+		string(roi),
+		string(masks),
+		cam.PrivacyMaskMode,
+		cam.CreatedAt,
+		cam.UpdatedAt,
+	)
+	return err
+}
+
+// DeleteCamera removes a camera configuration.
+func (d *Database) DeleteCamera(ctx context.Context, id string) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx, `DELETE FROM cameras WHERE id=?`, id)
+	return err
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"central-brain/models"
+)
+
+// ListEscalationPolicies returns the escalation policy of every post.
+func (d *Database) ListEscalationPolicies(ctx context.Context) ([]models.EscalationPolicy, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	rows, err := d.conn.QueryContext(ctx, `
+		SELECT post_id, COALESCE(min_severity, ''), COALESCE(types, ''), steps, COALESCE(updated_by, ''), updated_at
+		FROM escalation_policies`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.EscalationPolicy
+	for rows.Next() {
+		var (
+			p            models.EscalationPolicy
+			types, steps string
+		)
+		if err := rows.Scan(&p.PostID, &p.MinSeverity, &types, &steps, &p.UpdatedBy, &p.UpdatedAt); err != nil {
+			return nil, err
+		}
+		p.Types = splitList(types)
+		if err := json.Unmarshal([]byte(steps), &p.Steps); err != nil {
+			return nil, err
+		}
+		out = append(out, p)
+	}
+	return out, rows.Err()
+}
+
+// SaveEscalationPolicy inserts or replaces a post's escalation policy.
+func (d *Database) SaveEscalationPolicy(ctx context.Context, p models.EscalationPolicy) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	steps, err := json.Marshal(p.Steps)
+	if err != nil {
+		return err
+	}
+	_, err = d.conn.ExecContext(ctx, `
+		INSERT INTO escalation_policies (post_id, min_severity, types, steps, updated_by, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(post_id) DO UPDATE SET
+			min_severity=excluded.min_severity,
+			types=excluded.types,
+			steps=excluded.steps,
+			updated_by=excluded.updated_by,
+			updated_at=excluded.updated_at`,
+		p.PostID, p.MinSeverity, strings.Join(p.Types, ","), string(steps), p.UpdatedBy, p.UpdatedAt,
+	)
+	return err
+}
+
+// DeleteEscalationPolicy removes a post's escalation policy.
+func (d *Database) DeleteEscalationPolicy(ctx context.Context, postID string) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx, `DELETE FROM escalation_policies WHERE post_id=?`, postID)
+	return err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"central-brain/models"
+)
+
+// AppendGateEvent stores a gate state report.
+func (d *Database) AppendGateEvent(ctx context.Context, ev models.GateEvent) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx,
+		`INSERT INTO gate_events (post_id, state, source, detail, timestamp) VALUES (?, ?, ?, ?, ?)`,
+		ev.PostID, ev.State, ev.Source, ev.Detail, ev.Timestamp,
+	)
+	return err
+}
+
+// ListGateEvents returns a post's gate events, newest first. Zero from/to
+// leave the range open.
+func (d *Database) ListGateEvents(ctx context.Context, postID string, from, to time.Time, limit int) ([]models.GateEvent, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	query := `SELECT id, post_id, state, COALESCE(source, ''), COALESCE(detail, ''), timestamp FROM gate_events WHERE post_id = ?`
+	args := []interface{}{postID}
+	if !from.IsZero() {
+		query += ` AND timestamp >= ?`
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		query += ` AND timestamp <= ?`
+		args = append(args, to)
+	}
+	query += ` ORDER BY timestamp DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := d.conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanGateEvents(rows)
+}
+
+// LatestGateEvents returns the last gate event of every post.
+func (d *Database) LatestGateEvents(ctx context.Context) ([]models.GateEvent, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	rows, err := d.conn.QueryContext(ctx, `
+		SELECT id, post_id, state, COALESCE(source, ''), COALESCE(detail, ''), timestamp FROM gate_events
+		WHERE id IN (SELECT MAX(id) FROM gate_events GROUP BY post_id)`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanGateEvents(rows)
+}
+
+func scanGateEvents(rows *sql.Rows) ([]models.GateEvent, error) {
+	var out []models.GateEvent
+	for rows.Next() {
+		var ev models.GateEvent
+		if err := rows.Scan(&ev.ID, &ev.PostID, &ev.State, &ev.Source, &ev.Detail, &ev.Timestamp); err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"central-brain/models"
+)
+
+const incidentColumns = `id, camera_id, object_id, object_class, type, status, first_seen, last_seen,
+	peak_confidence, max_duration_seconds, detection_count, image_url,
+	acknowledged_by, acknowledged_at, closed_by, closed_at`
+
+// clip_url, severity, train and escalation_level were added later and are NULL in older rows
+const incidentSelect = `SELECT ` + incidentColumns + `,
+	COALESCE(clip_url, ''), COALESCE(severity, ''), COALESCE(train, ''), COALESCE(escalation_level, 0) FROM incidents`
+
+// SaveIncident inserts or updates an incident row.
+func (d *Database) SaveIncident(ctx context.Context, inc models.Incident) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	train, err := marshalTrain(inc.Train)
+	if err != nil {
+		return err
+	}
+	_, err = d.conn.ExecContext(ctx, `
+		INSERT INTO incidents (`+incidentColumns+`, clip_url, severity, train, escalation_level)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			status=excluded.status, last_seen=excluded.last_seen,
+			peak_confidence=excluded.peak_confidence, max_duration_seconds=excluded.max_duration_seconds,
+			detection_count=excluded.detection_count, image_url=excluded.image_url,
+			acknowledged_by=excluded.acknowledged_by, acknowledged_at=excluded.acknowledged_at,
+			closed_by=excluded.closed_by, closed_at=excluded.closed_at,
+			clip_url=excluded.clip_url, severity=excluded.severity, train=excluded.train,
+			escalation_level=excluded.escalation_level
+	`,
+		inc.ID,
+		inc.CameraID,
+		inc.ObjectID,
+		inc.ObjectClass,
+		inc.Type,
+		inc.Status,
+		inc.FirstSeen,
+		inc.LastSeen,
+		inc.PeakConfidence,
+		inc.MaxDurationSeconds,
+		inc.DetectionCount,
+		inc.ImageURL,
+		inc.AcknowledgedBy,
+		inc.AcknowledgedAt,
+		inc.ClosedBy,
+		inc.ClosedAt,
+		inc.ClipURL,
+		inc.Severity,
+		train,
+		inc.EscalationLevel,
+	)
+	return err
+}
+
+// AppendIncidentEvent adds an entry to an incident's timeline.
+func (d *Database) AppendIncidentEvent(ctx context.Context, ev models.IncidentEvent) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx,
+		`INSERT INTO incident_events (incident_id, action, actor, note, timestamp) VALUES (?, ?, ?, ?, ?)`,
+		ev.IncidentID, ev.Action, ev.Actor, ev.Note, ev.Timestamp,
+	)
+	return err
+}
+
+// ListActiveIncidents returns incidents that are still OPEN or ACKNOWLEDGED, with timelines.
+func (d *Database) ListActiveIncidents(ctx context.Context) ([]models.Incident, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	rows, err := d.conn.QueryContext(ctx,
+		incidentSelect+` WHERE status IN (?, ?) ORDER BY last_seen DESC`,
+		models.IncidentOpen, models.IncidentAcknowledged,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Incident
+	for rows.Next() {
+		inc, err := scanIncident(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, inc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range out {
+		if out[i].Timeline, err = d.listIncidentEvents(ctx, out[i].ID); err != nil {
+			return nil, err
+		}
+	}
+	return out, nil
+}
+
+// GetIncident returns a single incident with its timeline, or nil if missing.
+func (d *Database) GetIncident(ctx context.Context, id string) (*models.Incident, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	row := d.conn.QueryRowContext(ctx, incidentSelect+` WHERE id=?`, id)
+	inc, err := scanIncident(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if inc.Timeline, err = d.listIncidentEvents(ctx, id); err != nil {
+		return nil, err
+	}
+	return &inc, nil
+}
+
+func (d *Database) listIncidentEvents(ctx context.Context, incidentID string) ([]models.IncidentEvent, error) {
+	rows, err := d.conn.QueryContext(ctx,
+		`SELECT incident_id, action, actor, note, timestamp FROM incident_events WHERE incident_id=? ORDER BY id`,
+		incidentID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.IncidentEvent
+	for rows.Next() {
+		var ev models.IncidentEvent
+		if err := rows.Scan(&ev.IncidentID, &ev.Action, &ev.Actor, &ev.Note, &ev.Timestamp); err != nil {
+			return nil, err
+		}
+		out = append(out, ev)
+	}
+	return out, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanIncident(row rowScanner) (models.Incident, error) {
+	var inc models.Incident
+	var train string
+	err := row.Scan(
+		&inc.ID,
+		&inc.CameraID,
+		&inc.ObjectID,
+		&inc.ObjectClass,
+		&inc.Type,
+		&inc.Status,
+		&inc.FirstSeen,
+		&inc.LastSeen,
+		&inc.PeakConfidence,
+		&inc.MaxDurationSeconds,
+		&inc.DetectionCount,
+		&inc.ImageURL,
+		&inc.AcknowledgedBy,
+		&inc.AcknowledgedAt,
+		&inc.ClosedBy,
+		&inc.ClosedAt,
+		&inc.ClipURL,
+		&inc.Severity,
+		&train,
+		&inc.EscalationLevel,
+	)
+	if err == nil && train != "" {
+		inc.Train = &models.TrainApproach{}
+		err = json.Unmarshal([]byte(train), inc.Train)
+	}
+	return inc, err
+}
+
+// marshalTrain stores an incident's train approach as JSON, or "" without one.
+func marshalTrain(a *models.TrainApproach) (string, error) {
+	if a == nil {
+		return "", nil
+	}
+	b, err := json.Marshal(a)
+	return string(b), err
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"central-brain/models"
+)
+
+// ListNotificationRoutes returns every notification route.
+func (d *Database) ListNotificationRoutes(ctx context.Context) ([]models.NotificationRoute, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	rows, err := d.conn.QueryContext(ctx, `
+		SELECT id, name, channel, recipient, COALESCE(min_severity, ''), COALESCE(post_ids, ''),
+			COALESCE(station_ids, ''), COALESCE(types, ''), disabled, COALESCE(created_by, ''), created_at
+		FROM notification_routes`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.NotificationRoute
+	for rows.Next() {
+		var (
+			r                      models.NotificationRoute
+			posts, stations, types string
+		)
+		if err := rows.Scan(&r.ID, &r.Name, &r.Channel, &r.Recipient, &r.MinSeverity, &posts,
+			&stations, &types, &r.Disabled, &r.CreatedBy, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		r.PostIDs = splitList(posts)
+		r.StationIDs = splitList(stations)
+		r.Types = splitList(types)
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// SaveNotificationRoute inserts or replaces a notification route.
+func (d *Database) SaveNotificationRoute(ctx context.Context, r models.NotificationRoute) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx, `
+		INSERT INTO notification_routes (id, name, channel, recipient, min_severity, post_ids, station_ids, types, disabled, created_by, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name=excluded.name,
+			channel=excluded.channel,
+			recipient=excluded.recipient,
+			min_severity=excluded.min_severity,
+			post_ids=excluded.post_ids,
+			station_ids=excluded.station_ids,
+			types=excluded.types,
+			disabled=excluded.disabled`,
+		r.ID, r.Name, r.Channel, r.Recipient, r.MinSeverity, strings.Join(r.PostIDs, ","),
+		strings.Join(r.StationIDs, ","), strings.Join(r.Types, ","), r.Disabled, r.CreatedBy, r.CreatedAt,
+	)
+	return err
+}
+
+// DeleteNotificationRoute removes a notification route.
+func (d *Database) DeleteNotificationRoute(ctx context.Context, id string) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx, `DELETE FROM notification_routes WHERE id=?`, id)
+	return err
+}
+
+// InsertDeadLetter stores a notification that could not be delivered.
+func (d *Database) InsertDeadLetter(ctx context.Context, dl models.DeadLetter) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	payload, err := json.Marshal(dl.Notification)
+	if err != nil {
+		return err
+	}
+	_, err = d.conn.ExecContext(ctx, `
+		INSERT INTO notification_dead_letters (route_id, channel, recipient, notification, error, attempts, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		dl.RouteID, dl.Channel, dl.Recipient, string(payload), dl.Error, dl.Attempts, dl.CreatedAt,
+	)
+	return err
+}
+
+// ListDeadLetters returns the newest undeliverable notifications.
+func (d *Database) ListDeadLetters(ctx context.Context, limit int) ([]models.DeadLetter, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	rows, err := d.conn.QueryContext(ctx, `
+		SELECT id, route_id, channel, recipient, notification, error, attempts, created_at
+		FROM notification_dead_letters ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.DeadLetter
+	for rows.Next() {
+		var (
+			dl      models.DeadLetter
+			payload string
+		)
+		if err := rows.Scan(&dl.ID, &dl.RouteID, &dl.Channel, &dl.Recipient, &payload, &dl.Error, &dl.Attempts, &dl.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(payload), &dl.Notification); err != nil {
+			return nil, err
+		}
+		out = append(out, dl)
+	}
+	return out, rows.Err()
+}
+
+func splitList(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
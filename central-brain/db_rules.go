@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+
+	"central-brain/models"
+)
+
+// ListRules returns every detection rule.
+func (d *Database) ListRules(ctx context.Context) ([]models.Rule, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	rows, err := d.conn.QueryContext(ctx, `
+		SELECT id, COALESCE(name, ''), COALESCE(priority, 0), COALESCE(disabled, 0), COALESCE(stop, 0),
+			match, actions, COALESCE(created_by, ''), created_at, updated_at
+		FROM detection_rules`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.Rule
+	for rows.Next() {
+		var (
+			r              models.Rule
+			match, actions string
+		)
+		if err := rows.Scan(&r.ID, &r.Name, &r.Priority, &r.Disabled, &r.Stop,
+			&match, &actions, &r.CreatedBy, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(match), &r.Match); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(actions), &r.Actions); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// SaveRule inserts or replaces a detection rule.
+func (d *Database) SaveRule(ctx context.Context, r models.Rule) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	match, err := json.Marshal(r.Match)
+	if err != nil {
+		return err
+	}
+	actions, err := json.Marshal(r.Actions)
+	if err != nil {
+		return err
+	}
+	_, err = d.conn.ExecContext(ctx, `
+		INSERT INTO detection_rules (id, name, priority, disabled, stop, match, actions, created_by, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			name=excluded.name,
+			priority=excluded.priority,
+			disabled=excluded.disabled,
+			stop=excluded.stop,
+			match=excluded.match,
+			actions=excluded.actions,
+			updated_at=excluded.updated_at`,
+		r.ID, r.Name, r.Priority, r.Disabled, r.Stop, string(match), string(actions), r.CreatedBy, r.CreatedAt, r.UpdatedAt,
+	)
+	return err
+}
+
+// DeleteRule removes a detection rule.
+func (d *Database) DeleteRule(ctx context.Context, id string) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx, `DELETE FROM detection_rules WHERE id=?`, id)
+	return err
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"central-brain/models"
+)
+
+// ListServiceKeys returns all issued AI engine keys, including secrets.
+func (d *Database) ListServiceKeys(ctx context.Context) ([]models.ServiceKey, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	rows, err := d.conn.QueryContext(ctx,
+		`SELECT id, name, secret, camera_ids, created_by, created_at, revoked_at FROM service_keys`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.ServiceKey
+	for rows.Next() {
+		var (
+			k       models.ServiceKey
+			cameras string
+		)
+		if err := rows.Scan(&k.ID, &k.Name, &k.Secret, &cameras, &k.CreatedBy, &k.CreatedAt, &k.RevokedAt); err != nil {
+			return nil, err
+		}
+		if cameras != "" {
+			k.CameraIDs = strings.Split(cameras, ",")
+		}
+		out = append(out, k)
+	}
+	return out, rows.Err()
+}
+
+// InsertServiceKey stores a newly issued key.
+func (d *Database) InsertServiceKey(ctx context.Context, k models.ServiceKey) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx,
+		`INSERT INTO service_keys (id, name, secret, camera_ids, created_by, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		k.ID, k.Name, k.Secret, strings.Join(k.CameraIDs, ","), k.CreatedBy, k.CreatedAt,
+	)
+	return err
+}
+
+// RevokeServiceKey marks a key as revoked.
+func (d *Database) RevokeServiceKey(ctx context.Context, id string, at time.Time) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx, `UPDATE service_keys SET revoked_at=? WHERE id=?`, at, id)
+	return err
+}
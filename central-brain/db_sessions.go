@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"central-brain/models"
+)
+
+// ListRefreshTokens returns refresh tokens that have not expired yet.
+func (d *Database) ListRefreshTokens(ctx context.Context, now time.Time) ([]models.RefreshToken, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	rows, err := d.conn.QueryContext(ctx, `
+		SELECT id, user_id, family_id, created_at, expires_at, revoked_at, replaced_by
+		FROM refresh_tokens
+		WHERE expires_at > ?`, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.RefreshToken
+	for rows.Next() {
+		var t models.RefreshToken
+		if err := rows.Scan(&t.ID, &t.UserID, &t.FamilyID, &t.CreatedAt, &t.ExpiresAt, &t.RevokedAt, &t.ReplacedBy); err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+	return out, rows.Err()
+}
+
+// UpsertRefreshToken stores a refresh token or updates its revocation state.
+func (d *Database) UpsertRefreshToken(ctx context.Context, t models.RefreshToken) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (id, user_id, family_id, created_at, expires_at, revoked_at, replaced_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET revoked_at=excluded.revoked_at, replaced_by=excluded.replaced_by`,
+		t.ID, t.UserID, t.FamilyID, t.CreatedAt, t.ExpiresAt, t.RevokedAt, t.ReplacedBy,
+	)
+	return err
+}
+
+// ListTokenRevocations returns access token revocations that still matter.
+func (d *Database) ListTokenRevocations(ctx context.Context, now time.Time) ([]models.TokenRevocation, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	rows, err := d.conn.QueryContext(ctx, `
+		SELECT kind, subject, revoked_at, expires_at
+		FROM token_revocations
+		WHERE expires_at > ?`, now.UTC())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.TokenRevocation
+	for rows.Next() {
+		var r models.TokenRevocation
+		if err := rows.Scan(&r.Kind, &r.Subject, &r.RevokedAt, &r.ExpiresAt); err != nil {
+			return nil, err
+		}
+		out = append(out, r)
+	}
+	return out, rows.Err()
+}
+
+// InsertTokenRevocation records a revoked access token or user cutoff.
+// Expired rows are removed on the way.
+func (d *Database) InsertTokenRevocation(ctx context.Context, r models.TokenRevocation) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	if _, err := d.conn.ExecContext(ctx, `DELETE FROM token_revocations WHERE expires_at <= ?`, r.RevokedAt.UTC()); err != nil {
+		return err
+	}
+	_, err := d.conn.ExecContext(ctx, `
+		INSERT INTO token_revocations (kind, subject, revoked_at, expires_at)
+		VALUES (?, ?, ?, ?)
+		ON CONFLICT(kind, subject) DO UPDATE SET revoked_at=excluded.revoked_at, expires_at=excluded.expires_at`,
+		r.Kind, r.Subject, r.RevokedAt.UTC(), r.ExpiresAt.UTC(),
+	)
+	return err
+}
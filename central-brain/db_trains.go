@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+
+	"central-brain/models"
+)
+
+// SaveTimetable replaces the timetable of a post.
+func (d *Database) SaveTimetable(ctx context.Context, postID string, entries []models.TimetableEntry) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	tx, err := d.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM train_timetable WHERE post_id=?`, postID); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, err := tx.ExecContext(ctx,
+			`INSERT INTO train_timetable (post_id, train_id, time, days) VALUES (?, ?, ?, ?)`,
+			postID, e.TrainID, e.Time, e.Days,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// ListTimetables returns the timetable entries of every post.
+func (d *Database) ListTimetables(ctx context.Context) ([]models.TimetableEntry, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+	rows, err := d.conn.QueryContext(ctx, `SELECT post_id, train_id, time, days FROM train_timetable`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.TimetableEntry
+	for rows.Next() {
+		var e models.TimetableEntry
+		if err := rows.Scan(&e.PostID, &e.TrainID, &e.Time, &e.Days); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
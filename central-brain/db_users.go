@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+
+	"central-brain/models"
+)
+
+// ListUsers returns all user accounts.
+func (d *Database) ListUsers(ctx context.Context) ([]models.User, error) {
+	if d == nil || d.conn == nil {
+		return nil, nil
+	}
+
+	rows, err := d.conn.QueryContext(ctx, `
+		SELECT id, password_hash, role, name, post_id, station_id, disabled, created_at, updated_at
+		FROM users
+		ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var out []models.User
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(
+			&u.ID,
+			&u.PasswordHash,
+			&u.Role,
+			&u.Name,
+			&u.PostID,
+			&u.StationID,
+			&u.Disabled,
+			&u.CreatedAt,
+			&u.UpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		out = append(out, u)
+	}
+	return out, rows.Err()
+}
+
+// UpsertUser inserts or replaces a user account.
+func (d *Database) UpsertUser(ctx context.Context, u models.User) error {
+	if d == nil || d.conn == nil {
+		return nil
+	}
+	_, err := d.conn.ExecContext(ctx, `
+		INSERT INTO users (id, password_hash, role, name, post_id, station_id, disabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET
+			password_hash=excluded.password_hash, role=excluded.role, name=excluded.name,
+			post_id=excluded.post_id, station_id=excluded.station_id, disabled=excluded.disabled,
+			updated_at=excluded.updated_at`,
+		u.ID, u.PasswordHash, u.Role, u.Name, u.PostID, u.StationID, u.Disabled, u.CreatedAt, u.UpdatedAt,
+	)
+	return err
+}
@@ -0,0 +1,223 @@
+// Package escalation tells the next person in line when an incident stays
+// unacknowledged: a post's JPL officer first, then its station master, then
+// the DAOP control room, as the post's policy lists.
+package escalation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"central-brain/incident"
+	"central-brain/models"
+	"central-brain/notify"
+	"central-brain/services"
+)
+
+const checkInterval = time.Second
+
+var (
+	ErrNoPolicy      = errors.New("post has no escalation policy")
+	ErrInvalidPolicy = errors.New("invalid escalation policy")
+)
+
+// Store persists escalation policies.
+type Store interface {
+	ListEscalationPolicies(ctx context.Context) ([]models.EscalationPolicy, error)
+	SaveEscalationPolicy(ctx context.Context, p models.EscalationPolicy) error
+	DeleteEscalationPolicy(ctx context.Context, postID string) error
+}
+
+// Scheduler walks OPEN incidents through their post's escalation steps.
+type Scheduler struct {
+	store      Store
+	incidents  *incident.Manager
+	dispatcher *notify.Dispatcher
+
+	mu       sync.Mutex
+	policies map[string]models.EscalationPolicy // post -> policy
+}
+
+// NewScheduler creates a scheduler. store may be nil.
+func NewScheduler(store Store, incidents *incident.Manager, dispatcher *notify.Dispatcher) *Scheduler {
+	return &Scheduler{
+		store:      store,
+		incidents:  incidents,
+		dispatcher: dispatcher,
+		policies:   make(map[string]models.EscalationPolicy),
+	}
+}
+
+// Load restores the policies from the store.
+func (s *Scheduler) Load(ctx context.Context) error {
+	if s.store == nil {
+		return nil
+	}
+	list, err := s.store.ListEscalationPolicies(ctx)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range list {
+		s.policies[p.PostID] = p
+	}
+	return nil
+}
+
+// Policies returns every post's policy, by post.
+func (s *Scheduler) Policies() []models.EscalationPolicy {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]models.EscalationPolicy, 0, len(s.policies))
+	for _, p := range s.policies {
+		out = append(out, p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PostID < out[j].PostID })
+	return out
+}
+
+// Policy returns a post's policy.
+func (s *Scheduler) Policy(postID string) (models.EscalationPolicy, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.policies[postID]
+	return p, ok
+}
+
+// SetPolicy replaces a post's policy. Steps are taken in delay order.
+func (s *Scheduler) SetPolicy(ctx context.Context, p models.EscalationPolicy) (models.EscalationPolicy, error) {
+	if err := s.validate(p); err != nil {
+		return models.EscalationPolicy{}, err
+	}
+	p.Steps = append([]models.EscalationStep(nil), p.Steps...)
+	sort.SliceStable(p.Steps, func(i, j int) bool { return p.Steps[i].DelaySeconds < p.Steps[j].DelaySeconds })
+	p.UpdatedAt = time.Now().UTC()
+
+	if s.store != nil {
+		if err := s.store.SaveEscalationPolicy(ctx, p); err != nil {
+			return models.EscalationPolicy{}, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policies[p.PostID] = p
+	return p, nil
+}
+
+// DeletePolicy removes a post's policy; its incidents are no longer escalated.
+func (s *Scheduler) DeletePolicy(ctx context.Context, postID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.policies[postID]; !ok {
+		return ErrNoPolicy
+	}
+	if s.store != nil {
+		if err := s.store.DeleteEscalationPolicy(ctx, postID); err != nil {
+			return err
+		}
+	}
+	delete(s.policies, postID)
+	return nil
+}
+
+func (s *Scheduler) validate(p models.EscalationPolicy) error {
+	if len(p.Steps) == 0 {
+		return fmt.Errorf("%w: at least one step is required", ErrInvalidPolicy)
+	}
+	if _, ok := models.SeverityRank[p.MinSeverity]; p.MinSeverity != "" && !ok {
+		return fmt.Errorf("%w: min_severity must be LOW, MEDIUM, HIGH or CRITICAL", ErrInvalidPolicy)
+	}
+	for i, step := range p.Steps {
+		if step.DelaySeconds < 0 {
+			return fmt.Errorf("%w: step %d: delay_seconds must not be negative", ErrInvalidPolicy, i+1)
+		}
+		if step.Recipient == "" {
+			return fmt.Errorf("%w: step %d: recipient is required", ErrInvalidPolicy, i+1)
+		}
+		if !s.dispatcher.HasChannel(step.Channel) {
+			return fmt.Errorf("%w: step %d: channel %q is not configured (configured: %s)",
+				ErrInvalidPolicy, i+1, step.Channel, strings.Join(s.dispatcher.Channels(), ", "))
+		}
+		if err := notify.CheckRecipient(step.Channel, step.Recipient); err != nil {
+			return fmt.Errorf("%w: step %d: %v", ErrInvalidPolicy, i+1, err)
+		}
+	}
+	return nil
+}
+
+// Run checks the open incidents every second until the process exits.
+func (s *Scheduler) Run() {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.check(now)
+	}
+}
+
+// check takes every step that is due for each OPEN incident. Steps missed
+// while the server was down are taken at once, in order.
+func (s *Scheduler) check(now time.Time) {
+	for _, inc := range s.incidents.List(incident.Filter{Status: models.IncidentOpen}) {
+		postID := services.CameraPostID(inc.CameraID)
+		p, ok := s.Policy(postID)
+		if !ok || !applies(p, inc) {
+			continue
+		}
+		open := now.Sub(inc.FirstSeen)
+		for level := inc.EscalationLevel + 1; level <= len(p.Steps); level++ {
+			step := p.Steps[level-1]
+			if open < time.Duration(step.DelaySeconds)*time.Second {
+				break
+			}
+			s.escalate(inc, postID, level, step)
+		}
+	}
+}
+
+func (s *Scheduler) escalate(inc models.Incident, postID string, level int, step models.EscalationStep) {
+	who := step.Notify
+	if who == "" {
+		who = step.Recipient
+	}
+	note := fmt.Sprintf("Step %d: %s notified via %s after %ds unacknowledged", level, who, step.Channel, step.DelaySeconds)
+	taken, err := s.incidents.Escalate(context.Background(), inc.ID, level, note)
+	if err != nil {
+		log.Printf("[ESCALATION] failed to escalate %s: %v", inc.ID, err)
+		return
+	}
+	if !taken {
+		return
+	}
+	log.Printf("[ESCALATION] %s: %s", inc.ID, note)
+
+	n := notify.IncidentNotification(models.NotifyEscalated, inc, postID)
+	n.Title = fmt.Sprintf("Unacknowledged for %ds, %s", step.DelaySeconds, n.Title)
+	n.Message = note
+	n.Timestamp = time.Now().UTC()
+	s.dispatcher.DispatchTo("escalation:"+postID, step.Channel, step.Recipient, n)
+}
+
+func applies(p models.EscalationPolicy, inc models.Incident) bool {
+	severity := inc.Severity
+	if severity == "" {
+		severity = models.SeverityLow
+	}
+	if p.MinSeverity != "" && models.SeverityRank[severity] < models.SeverityRank[p.MinSeverity] {
+		return false
+	}
+	if len(p.Types) == 0 {
+		return true
+	}
+	for _, t := range p.Types {
+		if t == inc.Type {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,142 @@
+package escalation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"central-brain/incident"
+	"central-brain/models"
+	"central-brain/notify"
+	"central-brain/services"
+)
+
+// pager is a notifier that hands every send to the test.
+type pager struct {
+	sent chan string
+}
+
+func (p *pager) Channel() string { return "pager" }
+
+func (p *pager) Send(ctx context.Context, recipient string, n models.Notification) error {
+	p.sent <- recipient
+	return nil
+}
+
+var t0 = time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+
+func newTestScheduler(t *testing.T) (*Scheduler, *incident.Manager, *pager) {
+	t.Helper()
+	p := &pager{sent: make(chan string, 16)}
+	d := notify.NewDispatcher(nil, p)
+	go d.Run()
+	m := incident.NewManager(0, 0, nil, nil)
+	return NewScheduler(nil, m, d), m, p
+}
+
+func TestSchedulerCheck(t *testing.T) {
+	postID := services.CameraPostID("CCTV-JBG-01")
+	if postID == "" {
+		t.Fatal("CCTV-JBG-01 has no post")
+	}
+	steps := []models.EscalationStep{
+		{DelaySeconds: 300, Channel: "pager", Recipient: "daop"},
+		{DelaySeconds: 0, Channel: "pager", Recipient: "jpl"},
+		{DelaySeconds: 60, Channel: "pager", Recipient: "station"},
+	}
+
+	tests := []struct {
+		name     string
+		policy   models.EscalationPolicy
+		severity string
+		ack      bool
+		checks   []time.Duration // since the incident opened
+		want     []string        // recipients, in order
+	}{
+		{name: "first step right away", checks: []time.Duration{0}, want: []string{"jpl"}},
+		{name: "second step not yet due", checks: []time.Duration{0, 59 * time.Second}, want: []string{"jpl"}},
+		{name: "second step on time", checks: []time.Duration{0, 60 * time.Second}, want: []string{"jpl", "station"}},
+		{name: "missed steps at once", checks: []time.Duration{10 * time.Minute}, want: []string{"jpl", "station", "daop"}},
+		{name: "steps are taken once", checks: []time.Duration{0, time.Second, 2 * time.Second}, want: []string{"jpl"}},
+		{name: "acknowledged", ack: true, checks: []time.Duration{10 * time.Minute}},
+		{name: "below min severity", severity: models.SeverityLow, checks: []time.Duration{10 * time.Minute},
+			policy: models.EscalationPolicy{MinSeverity: models.SeverityHigh}},
+		{name: "at min severity", severity: models.SeverityHigh, checks: []time.Duration{0},
+			policy: models.EscalationPolicy{MinSeverity: models.SeverityHigh}, want: []string{"jpl"}},
+		{name: "other type", checks: []time.Duration{10 * time.Minute},
+			policy: models.EscalationPolicy{Types: []string{models.DetectionGateViolation}}},
+		{name: "no policy", policy: models.EscalationPolicy{PostID: "JPL-none"}, checks: []time.Duration{10 * time.Minute}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, m, p := newTestScheduler(t)
+			policy := tt.policy
+			if policy.PostID == "" {
+				policy.PostID = postID
+			}
+			policy.Steps = steps
+			if _, err := s.SetPolicy(context.Background(), policy); err != nil {
+				t.Fatalf("set policy: %v", err)
+			}
+
+			inc := m.Observe(models.DetectionPayload{
+				Type:        models.DetectionObstacleStuck,
+				CameraID:    "CCTV-JBG-01",
+				ObjectID:    7,
+				ObjectClass: "car",
+				Severity:    tt.severity,
+				Timestamp:   t0,
+			})
+			if tt.ack {
+				if _, err := m.Transition(context.Background(), inc.ID, models.IncidentAcknowledged, "JPL-102", ""); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for _, at := range tt.checks {
+				s.check(t0.Add(at))
+			}
+
+			for i, want := range tt.want {
+				select {
+				case got := <-p.sent:
+					if got != want {
+						t.Errorf("send %d went to %s, want %s", i+1, got, want)
+					}
+				case <-time.After(2 * time.Second):
+					t.Fatalf("send %d to %s never came", i+1, want)
+				}
+			}
+			got, err := m.Get(context.Background(), inc.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.EscalationLevel != len(tt.want) {
+				t.Errorf("escalation level %d, want %d", got.EscalationLevel, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSetPolicyValidates(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []models.EscalationStep
+		min   string
+	}{
+		{name: "no steps"},
+		{name: "negative delay", steps: []models.EscalationStep{{DelaySeconds: -1, Channel: "pager", Recipient: "jpl"}}},
+		{name: "no recipient", steps: []models.EscalationStep{{Channel: "pager"}}},
+		{name: "unconfigured channel", steps: []models.EscalationStep{{Channel: "telegram", Recipient: "1234"}}},
+		{name: "unknown severity", min: "URGENT", steps: []models.EscalationStep{{Channel: "pager", Recipient: "jpl"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _, _ := newTestScheduler(t)
+			_, err := s.SetPolicy(context.Background(), models.EscalationPolicy{PostID: "JPL-102", MinSeverity: tt.min, Steps: tt.steps})
+			if !errors.Is(err, ErrInvalidPolicy) {
+				t.Errorf("error %v, want ErrInvalidPolicy", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,259 @@
+// Package gates follows the level-crossing gates of the JPL posts and raises
+// alerts when a gate faults or is still open as a train comes.
+package gates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"central-brain/models"
+)
+
+const (
+	// DefaultCloseLead is how long before a train's ETA the gate must be CLOSED.
+	DefaultCloseLead = 60 * time.Second
+
+	checkInterval = 2 * time.Second
+)
+
+// ErrInvalidState is returned for gate states other than OPEN, CLOSING, CLOSED or FAULT.
+var ErrInvalidState = errors.New("state must be OPEN, CLOSING, CLOSED or FAULT")
+
+// vehicleClasses are the detector classes that must stop for a closed gate.
+var vehicleClasses = map[string]bool{
+	"car":        true,
+	"motorcycle": true,
+	"truck":      true,
+	"bus":        true,
+	"bicycle":    true,
+}
+
+// Store persists gate events.
+type Store interface {
+	AppendGateEvent(ctx context.Context, ev models.GateEvent) error
+	ListGateEvents(ctx context.Context, postID string, from, to time.Time, limit int) ([]models.GateEvent, error)
+	LatestGateEvents(ctx context.Context) ([]models.GateEvent, error)
+}
+
+// Approach returns the next train expected at a post.
+type Approach func(postID string, now time.Time) (models.TrainApproach, bool)
+
+// Monitor keeps each post's gate state and reports GATE_FAULT detections.
+type Monitor struct {
+	store     Store
+	approach  Approach
+	closeLead time.Duration
+
+	mu      sync.Mutex
+	status  map[string]models.GateStatus
+	faulted map[string]string // post -> train pass already reported as not closed
+	alert   []func(models.DetectionPayload)
+}
+
+// NewMonitor creates a gate monitor. store and approach may be nil.
+func NewMonitor(store Store, approach Approach, closeLead time.Duration) *Monitor {
+	if closeLead <= 0 {
+		closeLead = DefaultCloseLead
+	}
+	return &Monitor{
+		store:     store,
+		approach:  approach,
+		closeLead: closeLead,
+		status:    make(map[string]models.GateStatus),
+		faulted:   make(map[string]string),
+	}
+}
+
+// OnAlert registers fn to receive GATE_FAULT detections.
+func (m *Monitor) OnAlert(fn func(models.DetectionPayload)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alert = append(m.alert, fn)
+}
+
+// Load restores each post's last gate state from the store.
+func (m *Monitor) Load(ctx context.Context) error {
+	if m.store == nil {
+		return nil
+	}
+	list, err := m.store.LatestGateEvents(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, ev := range list {
+		m.status[ev.PostID] = statusOf(ev)
+	}
+	return nil
+}
+
+// Record stores a gate event and makes it the post's current state. A FAULT
+// is reported as a GATE_FAULT detection.
+func (m *Monitor) Record(ctx context.Context, ev models.GateEvent) (models.GateStatus, error) {
+	switch ev.State {
+	case models.GateOpen, models.GateClosing, models.GateClosed, models.GateFault:
+	default:
+		return models.GateStatus{}, ErrInvalidState
+	}
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now().UTC()
+	}
+	if m.store != nil {
+		if err := m.store.AppendGateEvent(ctx, ev); err != nil {
+			return models.GateStatus{}, err
+		}
+	}
+
+	m.mu.Lock()
+	prev, known := m.status[ev.PostID]
+	st := statusOf(ev)
+	if known && prev.State == ev.State {
+		// A repeated report keeps the time the gate entered the state
+		st.Since = prev.Since
+	}
+	m.status[ev.PostID] = st
+	m.mu.Unlock()
+
+	if ev.State == models.GateFault && (!known || prev.State != models.GateFault) {
+		detail := "Gate sensor reported FAULT"
+		if ev.Detail != "" {
+			detail += ": " + ev.Detail
+		}
+		m.raise(ev.PostID, detail, ev.Timestamp)
+	}
+	return st, nil
+}
+
+// Status returns a post's current gate state.
+func (m *Monitor) Status(postID string) (models.GateStatus, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, ok := m.status[postID]
+	return st, ok
+}
+
+// Statuses returns the gate state of every post that reported one, by post.
+func (m *Monitor) Statuses() []models.GateStatus {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]models.GateStatus, 0, len(m.status))
+	for _, st := range m.status {
+		out = append(out, st)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PostID < out[j].PostID })
+	return out
+}
+
+// History returns a post's gate events, newest first.
+func (m *Monitor) History(ctx context.Context, postID string, from, to time.Time, limit int) ([]models.GateEvent, error) {
+	if m.store == nil {
+		return nil, nil
+	}
+	return m.store.ListGateEvents(ctx, postID, from, to, limit)
+}
+
+// Run checks the gates ahead of trains until the process exits.
+func (m *Monitor) Run() {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.check(now)
+	}
+}
+
+// check reports a GATE_FAULT once per train pass when a post's gate is not
+// CLOSED within closeLead of the train's arrival. Posts whose gate never
+// reported have no sensor and are not checked.
+func (m *Monitor) check(now time.Time) {
+	if m.approach == nil {
+		return
+	}
+	for _, st := range m.Statuses() {
+		a, ok := m.approach(st.PostID, now)
+		if !ok || !a.Inbound || a.ETASeconds > m.closeLead.Seconds() {
+			continue
+		}
+		pass := a.TrainID + "@" + a.ExpectedAt.Format(time.RFC3339)
+
+		m.mu.Lock()
+		st = m.status[st.PostID]
+		if st.State == models.GateClosed || m.faulted[st.PostID] == pass {
+			m.mu.Unlock()
+			continue
+		}
+		m.faulted[st.PostID] = pass
+		m.mu.Unlock()
+
+		train := "train"
+		if a.TrainID != "" {
+			train += " " + a.TrainID
+		}
+		m.raise(st.PostID, fmt.Sprintf("Gate %s with %s %.0fs away (%s)", st.State, train, a.ETASeconds, strings.ToLower(a.Source)), now)
+	}
+}
+
+// raise reports a GATE_FAULT for a post, critical while a train is inbound.
+func (m *Monitor) raise(postID, detail string, at time.Time) {
+	p := models.DetectionPayload{
+		Type:             models.DetectionGateFault,
+		ObjectClass:      "gate",
+		Confidence:       1,
+		Timestamp:        at.UTC(),
+		PostID:           postID,
+		AdditionalDetail: detail,
+		Severity:         models.SeverityHigh,
+	}
+	if m.approach != nil {
+		if a, ok := m.approach(postID, at); ok && a.Inbound {
+			p.Severity = models.SeverityCritical
+			p.Train = &a
+		}
+	}
+	log.Printf("[GATE] %s: %s", postID, detail)
+
+	m.mu.Lock()
+	hooks := m.alert
+	m.mu.Unlock()
+	for _, fn := range hooks {
+		fn(p)
+	}
+}
+
+// Violation returns the GATE_VIOLATION raised by a detection of a vehicle in
+// the danger zone while its post's gate is CLOSED.
+func Violation(p models.DetectionPayload, st models.GateStatus) (models.DetectionPayload, bool) {
+	if !p.InROI || !vehicleClasses[strings.ToLower(p.ObjectClass)] || st.State != models.GateClosed {
+		return models.DetectionPayload{}, false
+	}
+	return models.DetectionPayload{
+		Type:             models.DetectionGateViolation,
+		ObjectClass:      p.ObjectClass,
+		Confidence:       p.Confidence,
+		InROI:            true,
+		ObjectID:         p.ObjectID,
+		DurationSeconds:  p.DurationSeconds,
+		Timestamp:        p.Timestamp,
+		CameraID:         p.CameraID,
+		PostID:           p.PostID,
+		AdditionalDetail: fmt.Sprintf("%s in the danger zone, gate CLOSED since %s", p.ObjectClass, st.Since.UTC().Format(time.RFC3339)),
+		ImageURL:         p.ImageURL,
+		Severity:         models.SeverityHigh,
+	}, true
+}
+
+func statusOf(ev models.GateEvent) models.GateStatus {
+	return models.GateStatus{
+		PostID: ev.PostID,
+		State:  ev.State,
+		Since:  ev.Timestamp,
+		Source: ev.Source,
+		Detail: ev.Detail,
+	}
+}
@@ -0,0 +1,186 @@
+package gates
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"central-brain/models"
+)
+
+var t0 = time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+
+func TestViolation(t *testing.T) {
+	closed := models.GateStatus{PostID: "JPL-102", State: models.GateClosed, Since: t0}
+	car := models.DetectionPayload{
+		Type:        models.DetectionObstacleStuck,
+		ObjectClass: "car",
+		InROI:       true,
+		ObjectID:    7,
+		CameraID:    "cam1",
+		PostID:      "JPL-102",
+		Timestamp:   t0.Add(time.Minute),
+	}
+	with := func(change func(*models.DetectionPayload)) models.DetectionPayload {
+		p := car
+		change(&p)
+		return p
+	}
+
+	tests := []struct {
+		name      string
+		p         models.DetectionPayload
+		state     string
+		violation bool
+	}{
+		{name: "car in the zone, gate closed", p: car, state: models.GateClosed, violation: true},
+		{name: "class in upper case", p: with(func(p *models.DetectionPayload) { p.ObjectClass = "Truck" }), state: models.GateClosed, violation: true},
+		{name: "gate open", p: car, state: models.GateOpen},
+		{name: "gate closing", p: car, state: models.GateClosing},
+		{name: "gate fault", p: car, state: models.GateFault},
+		{name: "outside the zone", p: with(func(p *models.DetectionPayload) { p.InROI = false }), state: models.GateClosed},
+		{name: "person", p: with(func(p *models.DetectionPayload) { p.ObjectClass = "person" }), state: models.GateClosed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := closed
+			st.State = tt.state
+			got, ok := Violation(tt.p, st)
+			if ok != tt.violation {
+				t.Fatalf("violation %v, want %v", ok, tt.violation)
+			}
+			if !ok {
+				return
+			}
+			if got.Type != models.DetectionGateViolation || got.Severity != models.SeverityHigh ||
+				got.CameraID != "cam1" || got.ObjectID != 7 || !got.Timestamp.Equal(tt.p.Timestamp) {
+				t.Errorf("violation %+v", got)
+			}
+			if !strings.Contains(got.AdditionalDetail, t0.Format(time.RFC3339)) {
+				t.Errorf("detail %q does not say since when the gate is closed", got.AdditionalDetail)
+			}
+		})
+	}
+}
+
+func TestRecord(t *testing.T) {
+	m := NewMonitor(nil, nil, 0)
+	var alerts []models.DetectionPayload
+	m.OnAlert(func(p models.DetectionPayload) { alerts = append(alerts, p) })
+	ctx := context.Background()
+
+	if _, err := m.Record(ctx, models.GateEvent{PostID: "JPL-102", State: "HALF_OPEN"}); err != ErrInvalidState {
+		t.Errorf("error %v, want ErrInvalidState", err)
+	}
+
+	steps := []struct {
+		state  string
+		at     time.Duration
+		since  time.Duration
+		alerts int
+	}{
+		{state: models.GateClosed, at: 0, since: 0},
+		{state: models.GateClosed, at: time.Minute, since: 0}, // repeated report
+		{state: models.GateFault, at: 2 * time.Minute, since: 2 * time.Minute, alerts: 1},
+		{state: models.GateFault, at: 3 * time.Minute, since: 2 * time.Minute, alerts: 1},
+		{state: models.GateOpen, at: 4 * time.Minute, since: 4 * time.Minute, alerts: 1},
+		{state: models.GateFault, at: 5 * time.Minute, since: 5 * time.Minute, alerts: 2},
+	}
+	for i, s := range steps {
+		st, err := m.Record(ctx, models.GateEvent{PostID: "JPL-102", State: s.state, Detail: "motor", Timestamp: t0.Add(s.at)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.State != s.state || !st.Since.Equal(t0.Add(s.since)) {
+			t.Errorf("step %d: status %+v, want %s since +%s", i+1, st, s.state, s.since)
+		}
+		if len(alerts) != s.alerts {
+			t.Errorf("step %d: %d alerts, want %d", i+1, len(alerts), s.alerts)
+		}
+	}
+	if a := alerts[0]; a.Type != models.DetectionGateFault || a.PostID != "JPL-102" ||
+		a.Severity != models.SeverityHigh || a.AdditionalDetail != "Gate sensor reported FAULT: motor" {
+		t.Errorf("alert %+v", a)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	approach := func(eta float64, inbound bool) Approach {
+		return func(postID string, now time.Time) (models.TrainApproach, bool) {
+			return models.TrainApproach{
+				PostID:     postID,
+				TrainID:    "KA-123",
+				Source:     models.TrainSourceSignal,
+				ExpectedAt: t0.Add(time.Duration(eta) * time.Second),
+				ETASeconds: eta,
+				Inbound:    inbound,
+			}, true
+		}
+	}
+
+	tests := []struct {
+		name     string
+		approach Approach
+		state    string // "" means the gate never reported
+		alerts   int
+	}{
+		{name: "open with the train close", approach: approach(45, true), state: models.GateOpen, alerts: 1},
+		{name: "closing with the train close", approach: approach(60, true), state: models.GateClosing, alerts: 1},
+		{name: "closed", approach: approach(45, true), state: models.GateClosed},
+		{name: "train still far", approach: approach(61, true), state: models.GateOpen},
+		{name: "train not inbound", approach: approach(45, false), state: models.GateOpen},
+		{name: "no sensor", approach: approach(45, true)},
+		{name: "no train source", state: models.GateOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewMonitor(nil, tt.approach, 0)
+			if tt.state != "" {
+				if _, err := m.Record(context.Background(), models.GateEvent{PostID: "JPL-102", State: tt.state, Timestamp: t0}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var alerts []models.DetectionPayload
+			m.OnAlert(func(p models.DetectionPayload) { alerts = append(alerts, p) })
+
+			// The same train pass is reported once
+			m.check(t0)
+			m.check(t0.Add(checkInterval))
+
+			if len(alerts) != tt.alerts {
+				t.Fatalf("%d alerts, want %d", len(alerts), tt.alerts)
+			}
+			if tt.alerts == 0 {
+				return
+			}
+			a := alerts[0]
+			if a.Severity != models.SeverityCritical || a.Train == nil || a.Train.TrainID != "KA-123" {
+				t.Errorf("alert %+v", a)
+			}
+			if !strings.Contains(a.AdditionalDetail, "Gate "+tt.state+" with train KA-123") {
+				t.Errorf("detail %q", a.AdditionalDetail)
+			}
+		})
+	}
+}
+
+func TestCheckReportsEachPass(t *testing.T) {
+	expected := t0.Add(45 * time.Second)
+	m := NewMonitor(nil, func(postID string, now time.Time) (models.TrainApproach, bool) {
+		return models.TrainApproach{PostID: postID, TrainID: "KA-123", ExpectedAt: expected, ETASeconds: 30, Inbound: true}, true
+	}, 0)
+	if _, err := m.Record(context.Background(), models.GateEvent{PostID: "JPL-102", State: models.GateOpen, Timestamp: t0}); err != nil {
+		t.Fatal(err)
+	}
+	alerts := 0
+	m.OnAlert(func(models.DetectionPayload) { alerts++ })
+
+	m.check(t0)
+	m.check(t0.Add(time.Second))
+	expected = expected.Add(24 * time.Hour) // the same train the next day
+	m.check(t0.Add(24 * time.Hour))
+	if alerts != 2 {
+		t.Errorf("%d alerts, want one per pass", alerts)
+	}
+}
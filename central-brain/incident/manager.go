@@ -0,0 +1,529 @@
+package incident
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"central-brain/models"
+)
+
+// DefaultWindow is how long after the last push a tracked object still belongs
+// to the same incident. The AI engine re-sends alerts every 5 seconds.
+const DefaultWindow = 30 * time.Second
+
+// DefaultIdleTimeout is how long an OPEN incident may go without a push
+// before it is closed as EXPIRED: the object left before anyone acknowledged it.
+const DefaultIdleTimeout = 10 * time.Minute
+
+// expireInterval is how often OPEN incidents are checked for idleness.
+const expireInterval = 10 * time.Second
+
+// maxClosed bounds how many resolved incidents are kept in memory.
+const maxClosed = 500
+
+var (
+	// ErrNotFound is returned when an incident does not exist.
+	ErrNotFound = errors.New("incident not found")
+	// ErrInvalidTransition is returned when a status change is not allowed.
+	ErrInvalidTransition = errors.New("invalid incident status transition")
+)
+
+// transitions lists the statuses reachable from each status.
+var transitions = map[string][]string{
+	models.IncidentOpen:         {models.IncidentAcknowledged},
+	models.IncidentAcknowledged: {models.IncidentResolved, models.IncidentFalsePositive},
+}
+
+// Store persists incidents and their timelines.
+type Store interface {
+	SaveIncident(ctx context.Context, inc models.Incident) error
+	AppendIncidentEvent(ctx context.Context, ev models.IncidentEvent) error
+	ListActiveIncidents(ctx context.Context) ([]models.Incident, error)
+	GetIncident(ctx context.Context, id string) (*models.Incident, error)
+}
+
+// Broadcaster sends incident changes to connected dashboards scoped to the camera.
+type Broadcaster interface {
+	Publish(cameraID, eventType string, v interface{})
+}
+
+// Filter narrows incident listings.
+type Filter struct {
+	Status         string
+	CameraID       string
+	AllowedCameras map[string]bool // nil means no restriction
+	SeenSince      time.Time       // only incidents pushed at or after this time
+	Limit          int
+}
+
+// Manager groups detection pushes into incidents and drives their lifecycle.
+type Manager struct {
+	mu        sync.Mutex
+	window    time.Duration
+	idle      time.Duration
+	incidents map[string]*models.Incident
+	timelines map[string][]models.IncidentEvent
+	active    map[string]string // camera_id/object_id -> incident ID
+	closed    []string          // closed incident IDs, oldest first
+	seq       uint64
+	store     Store
+	out       Broadcaster
+	opened    []func(models.Incident)
+}
+
+// NewManager creates an incident manager. OPEN incidents expire after idle
+// without a push (DefaultIdleTimeout when zero). store and out may be nil.
+func NewManager(window, idle time.Duration, store Store, out Broadcaster) *Manager {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	if idle <= 0 {
+		idle = DefaultIdleTimeout
+	}
+	if idle < window {
+		idle = window
+	}
+	return &Manager{
+		window:    window,
+		idle:      idle,
+		incidents: make(map[string]*models.Incident),
+		timelines: make(map[string][]models.IncidentEvent),
+		active:    make(map[string]string),
+		store:     store,
+		out:       out,
+	}
+}
+
+// Window returns how long after its last push an object still belongs to
+// its incident.
+func (m *Manager) Window() time.Duration {
+	return m.window
+}
+
+// OnOpened registers fn to be called after a new incident is opened.
+// Register hooks before the manager receives detections.
+func (m *Manager) OnOpened(fn func(models.Incident)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.opened = append(m.opened, fn)
+}
+
+// Load restores open and acknowledged incidents, with their timelines, from the store.
+func (m *Manager) Load(ctx context.Context) error {
+	if m.store == nil {
+		return nil
+	}
+	list, err := m.store.ListActiveIncidents(ctx)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := range list {
+		inc := list[i]
+		m.timelines[inc.ID] = inc.Timeline
+		inc.Timeline = nil
+		m.incidents[inc.ID] = &inc
+		m.active[groupKey(inc.CameraID, inc.ObjectID, inc.Type)] = inc.ID
+	}
+	return nil
+}
+
+// Observe merges a detection push into an active incident or opens a new one.
+// It returns a snapshot of the affected incident.
+func (m *Manager) Observe(p models.DetectionPayload) models.Incident {
+	m.mu.Lock()
+
+	key := groupKey(p.CameraID, p.ObjectID, p.Type)
+	if id, ok := m.active[key]; ok {
+		inc := m.incidents[id]
+		if inc != nil && p.Timestamp.Sub(inc.LastSeen) <= m.window {
+			merge(inc, p)
+			var events []models.IncidentEvent
+			if ev, raised := raiseSeverity(inc, p); raised {
+				m.timelines[id] = append(m.timelines[id], ev)
+				events = append(events, ev)
+			}
+			snapshot := *inc
+			m.mu.Unlock()
+
+			m.persist(context.Background(), snapshot, events...)
+			m.broadcast("INCIDENT_UPDATED", snapshot)
+			return snapshot
+		}
+		delete(m.active, key)
+	}
+
+	m.seq++
+	inc := &models.Incident{
+		ID:          fmt.Sprintf("INC-%s-%04d", p.Timestamp.UTC().Format("20060102150405"), m.seq),
+		CameraID:    p.CameraID,
+		ObjectID:    p.ObjectID,
+		ObjectClass: p.ObjectClass,
+		Type:        p.Type,
+		Status:      models.IncidentOpen,
+		FirstSeen:   p.Timestamp,
+	}
+	merge(inc, p)
+	opened := models.IncidentEvent{
+		IncidentID: inc.ID,
+		Action:     "OPENED",
+		Note:       p.AdditionalDetail,
+		Timestamp:  p.Timestamp,
+	}
+	events := []models.IncidentEvent{opened}
+	if ev, raised := raiseSeverity(inc, p); raised {
+		events = append(events, ev)
+	}
+	m.incidents[inc.ID] = inc
+	m.timelines[inc.ID] = events
+	m.active[key] = inc.ID
+	snapshot := *inc
+	hooks := m.opened
+	m.mu.Unlock()
+
+	m.persist(context.Background(), snapshot, events...)
+	m.broadcast("INCIDENT_OPENED", snapshot)
+	for _, fn := range hooks {
+		fn(snapshot)
+	}
+	return snapshot
+}
+
+// AttachClip records the video clip URL of an incident, which may already
+// have been evicted from memory.
+func (m *Manager) AttachClip(ctx context.Context, id, clipURL string) (models.Incident, error) {
+	m.mu.Lock()
+	inc, ok := m.incidents[id]
+	if ok {
+		inc.ClipURL = clipURL
+		snapshot := *inc
+		m.mu.Unlock()
+
+		m.persist(ctx, snapshot)
+		m.broadcast("INCIDENT_UPDATED", snapshot)
+		return snapshot, nil
+	}
+	m.mu.Unlock()
+
+	if m.store == nil {
+		return models.Incident{}, ErrNotFound
+	}
+	stored, err := m.store.GetIncident(ctx, id)
+	if err != nil {
+		return models.Incident{}, err
+	}
+	if stored == nil {
+		return models.Incident{}, ErrNotFound
+	}
+	stored.ClipURL = clipURL
+	stored.Timeline = nil
+	m.persist(ctx, *stored)
+	return *stored, nil
+}
+
+// RaiseSeverity raises an active incident to severity, recording the train
+// that caused it. It reports false when the incident already had that
+// severity or higher.
+func (m *Manager) RaiseSeverity(ctx context.Context, id, severity string, train *models.TrainApproach) (bool, error) {
+	m.mu.Lock()
+	inc, ok := m.incidents[id]
+	if !ok {
+		m.mu.Unlock()
+		return false, ErrNotFound
+	}
+	ev, raised := raiseSeverity(inc, models.DetectionPayload{
+		Severity:  severity,
+		Train:     train,
+		Timestamp: time.Now().UTC(),
+	})
+	if !raised {
+		m.mu.Unlock()
+		return false, nil
+	}
+	m.timelines[id] = append(m.timelines[id], ev)
+	snapshot := *inc
+	m.mu.Unlock()
+
+	m.persist(ctx, snapshot, ev)
+	m.broadcast("INCIDENT_UPDATED", snapshot)
+	return true, nil
+}
+
+// Escalate records that an incident nobody acknowledged reached escalation
+// step level, with an ESCALATED timeline entry. It reports false when the
+// incident is no longer OPEN or already reached that step, so a step racing
+// an acknowledgement is not taken.
+func (m *Manager) Escalate(ctx context.Context, id string, level int, note string) (bool, error) {
+	m.mu.Lock()
+	inc, ok := m.incidents[id]
+	if !ok {
+		m.mu.Unlock()
+		return false, ErrNotFound
+	}
+	if inc.Status != models.IncidentOpen || inc.EscalationLevel >= level {
+		m.mu.Unlock()
+		return false, nil
+	}
+	inc.EscalationLevel = level
+	ev := models.IncidentEvent{
+		IncidentID: id,
+		Action:     "ESCALATED",
+		Note:       note,
+		Timestamp:  time.Now().UTC(),
+	}
+	m.timelines[id] = append(m.timelines[id], ev)
+	snapshot := *inc
+	m.mu.Unlock()
+
+	m.persist(ctx, snapshot, ev)
+	m.broadcast("INCIDENT_ESCALATED", snapshot)
+	return true, nil
+}
+
+// Transition moves an incident to a new status on behalf of actor.
+func (m *Manager) Transition(ctx context.Context, id, status, actor, note string) (models.Incident, error) {
+	m.mu.Lock()
+	inc, ok := m.incidents[id]
+	if !ok {
+		m.mu.Unlock()
+		return models.Incident{}, ErrNotFound
+	}
+	if !allowed(inc.Status, status) {
+		m.mu.Unlock()
+		return models.Incident{}, ErrInvalidTransition
+	}
+
+	now := time.Now().UTC()
+	inc.Status = status
+	switch status {
+	case models.IncidentAcknowledged:
+		inc.AcknowledgedBy = actor
+		inc.AcknowledgedAt = &now
+	case models.IncidentResolved, models.IncidentFalsePositive:
+		inc.ClosedBy = actor
+		inc.ClosedAt = &now
+		key := groupKey(inc.CameraID, inc.ObjectID, inc.Type)
+		if m.active[key] == id {
+			delete(m.active, key)
+		}
+		m.trackClosedLocked(id)
+	}
+	ev := models.IncidentEvent{
+		IncidentID: id,
+		Action:     status,
+		Actor:      actor,
+		Note:       note,
+		Timestamp:  now,
+	}
+	m.timelines[id] = append(m.timelines[id], ev)
+	snapshot := *inc
+	m.mu.Unlock()
+
+	m.persist(ctx, snapshot, ev)
+	m.broadcast("INCIDENT_"+status, snapshot)
+	return snapshot, nil
+}
+
+// Run expires idle OPEN incidents until the process exits.
+func (m *Manager) Run() {
+	ticker := time.NewTicker(expireInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		m.ExpireIdle(context.Background(), now)
+	}
+}
+
+// ExpireIdle closes OPEN incidents whose last push is older than the idle
+// timeout as EXPIRED and returns how many it closed. Acknowledged incidents
+// stay with the officer who took them.
+func (m *Manager) ExpireIdle(ctx context.Context, now time.Time) int {
+	type expiry struct {
+		snapshot models.Incident
+		event    models.IncidentEvent
+	}
+	var expired []expiry
+
+	m.mu.Lock()
+	for id, inc := range m.incidents {
+		if inc.Status != models.IncidentOpen || now.Sub(inc.LastSeen) < m.idle {
+			continue
+		}
+		closedAt := now.UTC()
+		inc.Status = models.IncidentExpired
+		inc.ClosedBy = "system"
+		inc.ClosedAt = &closedAt
+		key := groupKey(inc.CameraID, inc.ObjectID, inc.Type)
+		if m.active[key] == id {
+			delete(m.active, key)
+		}
+		m.trackClosedLocked(id)
+		ev := models.IncidentEvent{
+			IncidentID: id,
+			Action:     models.IncidentExpired,
+			Actor:      "system",
+			Note:       fmt.Sprintf("No detection for %s", m.idle),
+			Timestamp:  closedAt,
+		}
+		m.timelines[id] = append(m.timelines[id], ev)
+		expired = append(expired, expiry{snapshot: *inc, event: ev})
+	}
+	m.mu.Unlock()
+
+	for _, e := range expired {
+		m.persist(ctx, e.snapshot, e.event)
+		m.broadcast("INCIDENT_"+models.IncidentExpired, e.snapshot)
+	}
+	return len(expired)
+}
+
+// Get returns an incident with its timeline, consulting the store for
+// incidents that have been evicted from memory.
+func (m *Manager) Get(ctx context.Context, id string) (models.Incident, error) {
+	m.mu.Lock()
+	if inc, ok := m.incidents[id]; ok {
+		snapshot := *inc
+		snapshot.Timeline = append([]models.IncidentEvent(nil), m.timelines[id]...)
+		m.mu.Unlock()
+		return snapshot, nil
+	}
+	m.mu.Unlock()
+
+	if m.store != nil {
+		stored, err := m.store.GetIncident(ctx, id)
+		if err != nil {
+			return models.Incident{}, err
+		}
+		if stored != nil {
+			return *stored, nil
+		}
+	}
+	return models.Incident{}, ErrNotFound
+}
+
+// List returns incidents held in memory, newest first.
+func (m *Manager) List(f Filter) []models.Incident {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]models.Incident, 0, len(m.incidents))
+	for _, inc := range m.incidents {
+		if f.Status != "" && inc.Status != f.Status {
+			continue
+		}
+		if f.CameraID != "" && inc.CameraID != f.CameraID {
+			continue
+		}
+		if f.AllowedCameras != nil && !f.AllowedCameras[inc.CameraID] {
+			continue
+		}
+		if inc.LastSeen.Before(f.SeenSince) {
+			continue
+		}
+		out = append(out, *inc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out
+}
+
+func (m *Manager) trackClosedLocked(id string) {
+	m.closed = append(m.closed, id)
+	for len(m.closed) > maxClosed {
+		delete(m.incidents, m.closed[0])
+		delete(m.timelines, m.closed[0])
+		m.closed = m.closed[1:]
+	}
+}
+
+func (m *Manager) persist(ctx context.Context, inc models.Incident, events ...models.IncidentEvent) {
+	if m.store == nil {
+		return
+	}
+	if err := m.store.SaveIncident(ctx, inc); err != nil {
+		log.Printf("[INCIDENT] failed to persist %s: %v", inc.ID, err)
+	}
+	for _, ev := range events {
+		if err := m.store.AppendIncidentEvent(ctx, ev); err != nil {
+			log.Printf("[INCIDENT] failed to persist timeline for %s: %v", inc.ID, err)
+		}
+	}
+}
+
+func (m *Manager) broadcast(eventType string, inc models.Incident) {
+	if m.out == nil {
+		return
+	}
+	m.out.Publish(inc.CameraID, eventType, models.IncidentMessage{Type: eventType, Incident: inc})
+}
+
+func merge(inc *models.Incident, p models.DetectionPayload) {
+	if p.Timestamp.After(inc.LastSeen) {
+		inc.LastSeen = p.Timestamp
+	}
+	if p.Confidence > inc.PeakConfidence {
+		inc.PeakConfidence = p.Confidence
+	}
+	if p.DurationSeconds > inc.MaxDurationSeconds {
+		inc.MaxDurationSeconds = p.DurationSeconds
+	}
+	if p.ImageURL != "" {
+		inc.ImageURL = p.ImageURL
+	}
+	inc.DetectionCount++
+}
+
+// raiseSeverity adopts the push's severity when it is higher than the
+// incident's, together with the train that caused it, and returns the
+// timeline entry recording the change. A newer ETA of the same train is
+// kept without a timeline entry.
+func raiseSeverity(inc *models.Incident, p models.DetectionPayload) (models.IncidentEvent, bool) {
+	if p.Train != nil && inc.Train != nil && p.Train.TrainID == inc.Train.TrainID {
+		train := *p.Train
+		inc.Train = &train
+	}
+	if models.SeverityRank[p.Severity] <= models.SeverityRank[inc.Severity] {
+		return models.IncidentEvent{}, false
+	}
+	inc.Severity = p.Severity
+	note := ""
+	if p.Train != nil {
+		train := *p.Train
+		inc.Train = &train
+		note = train.Describe()
+	}
+	return models.IncidentEvent{
+		IncidentID: inc.ID,
+		Action:     "SEVERITY_" + p.Severity,
+		Note:       note,
+		Timestamp:  p.Timestamp,
+	}, true
+}
+
+func allowed(from, to string) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// groupKey keeps gate alerts apart from the incident of the vehicle that
+// caused them, so they can be handled on their own.
+func groupKey(cameraID string, objectID int, typ string) string {
+	key := cameraID + "/" + strconv.Itoa(objectID)
+	if typ == models.DetectionGateViolation || typ == models.DetectionGateFault {
+		key += "/" + typ
+	}
+	return key
+}
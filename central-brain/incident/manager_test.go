@@ -0,0 +1,308 @@
+package incident
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"central-brain/models"
+)
+
+// recorder collects published event types.
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) Publish(cameraID, eventType string, v interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, eventType)
+}
+
+var t0 = time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+
+func push(cameraID string, objectID int, typ string, at time.Duration) models.DetectionPayload {
+	return models.DetectionPayload{Type: typ, CameraID: cameraID, ObjectID: objectID, ObjectClass: "car", Timestamp: t0.Add(at)}
+}
+
+func TestObserveGrouping(t *testing.T) {
+	tests := []struct {
+		name      string
+		pushes    []models.DetectionPayload
+		incidents int
+	}{
+		{
+			name: "repeated pushes within the window",
+			pushes: []models.DetectionPayload{
+				push("cam1", 7, models.DetectionObstacleStuck, 0),
+				push("cam1", 7, models.DetectionObstacleStuck, 5*time.Second),
+				push("cam1", 7, models.DetectionObstacleStuck, 30*time.Second),
+			},
+			incidents: 1,
+		},
+		{
+			name: "gap longer than the window",
+			pushes: []models.DetectionPayload{
+				push("cam1", 7, models.DetectionObstacleStuck, 0),
+				push("cam1", 7, models.DetectionObstacleStuck, 31*time.Second),
+			},
+			incidents: 2,
+		},
+		{
+			name: "other object and other camera",
+			pushes: []models.DetectionPayload{
+				push("cam1", 7, models.DetectionObstacleStuck, 0),
+				push("cam1", 8, models.DetectionObstacleStuck, time.Second),
+				push("cam2", 7, models.DetectionObstacleStuck, time.Second),
+			},
+			incidents: 3,
+		},
+		{
+			name: "gate alert apart from the vehicle's incident",
+			pushes: []models.DetectionPayload{
+				push("cam1", 7, models.DetectionObstacleStuck, 0),
+				push("cam1", 7, models.DetectionGateViolation, time.Second),
+				push("cam1", 7, models.DetectionGateViolation, 2*time.Second),
+			},
+			incidents: 2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(0, 0, nil, nil)
+			for _, p := range tt.pushes {
+				m.Observe(p)
+			}
+			if got := len(m.List(Filter{})); got != tt.incidents {
+				t.Errorf("%d incidents, want %d", got, tt.incidents)
+			}
+		})
+	}
+}
+
+func TestObserveMergesAndRaisesSeverity(t *testing.T) {
+	out := &recorder{}
+	m := NewManager(0, 0, nil, out)
+
+	p := push("cam1", 7, models.DetectionObstacleStuck, 0)
+	p.Severity, p.Confidence = models.SeverityMedium, 0.6
+	first := m.Observe(p)
+
+	p = push("cam1", 7, models.DetectionObstacleStuck, 5*time.Second)
+	p.Severity, p.Confidence, p.DurationSeconds = models.SeverityLow, 0.9, 12
+	m.Observe(p)
+
+	p = push("cam1", 7, models.DetectionObstacleStuck, 10*time.Second)
+	p.Severity = models.SeverityCritical
+	p.Train = &models.TrainApproach{TrainID: "KA-123", Inbound: true, ETASeconds: 60}
+	got := m.Observe(p)
+
+	if got.ID != first.ID || got.DetectionCount != 3 || got.PeakConfidence != 0.9 || got.MaxDurationSeconds != 12 {
+		t.Errorf("merged incident %+v", got)
+	}
+	if got.Severity != models.SeverityCritical || got.Train == nil || got.Train.TrainID != "KA-123" {
+		t.Errorf("severity %s, train %+v", got.Severity, got.Train)
+	}
+	if !got.LastSeen.Equal(t0.Add(10 * time.Second)) {
+		t.Errorf("last seen %s", got.LastSeen)
+	}
+
+	full, err := m.Get(context.Background(), got.ID)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	var actions []string
+	for _, ev := range full.Timeline {
+		actions = append(actions, ev.Action)
+	}
+	want := []string{"OPENED", "SEVERITY_MEDIUM", "SEVERITY_CRITICAL"}
+	if len(actions) != len(want) {
+		t.Fatalf("timeline %v, want %v", actions, want)
+	}
+	for i := range want {
+		if actions[i] != want[i] {
+			t.Errorf("timeline %v, want %v", actions, want)
+		}
+	}
+	if len(out.events) != 3 || out.events[0] != "INCIDENT_OPENED" || out.events[2] != "INCIDENT_UPDATED" {
+		t.Errorf("published %v", out.events)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	tests := []struct {
+		name  string
+		steps []string
+		err   error
+	}{
+		{name: "acknowledge", steps: []string{models.IncidentAcknowledged}},
+		{name: "resolve", steps: []string{models.IncidentAcknowledged, models.IncidentResolved}},
+		{name: "false positive", steps: []string{models.IncidentAcknowledged, models.IncidentFalsePositive}},
+		{name: "resolve without acknowledging", steps: []string{models.IncidentResolved}, err: ErrInvalidTransition},
+		{name: "acknowledge twice", steps: []string{models.IncidentAcknowledged, models.IncidentAcknowledged}, err: ErrInvalidTransition},
+		{name: "reopen", steps: []string{models.IncidentAcknowledged, models.IncidentResolved, models.IncidentOpen}, err: ErrInvalidTransition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(0, 0, nil, nil)
+			inc := m.Observe(push("cam1", 7, models.DetectionObstacleStuck, 0))
+			var err error
+			var got models.Incident
+			for _, status := range tt.steps {
+				if got, err = m.Transition(context.Background(), inc.ID, status, "JPL-102", ""); err != nil {
+					break
+				}
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("error %v, want %v", err, tt.err)
+			}
+			if err != nil {
+				return
+			}
+			if got.Status != tt.steps[len(tt.steps)-1] || got.AcknowledgedBy != "JPL-102" {
+				t.Errorf("incident %+v", got)
+			}
+			closed := got.Status == models.IncidentResolved || got.Status == models.IncidentFalsePositive
+			if closed != (got.ClosedAt != nil) {
+				t.Errorf("closed at %v for status %s", got.ClosedAt, got.Status)
+			}
+		})
+	}
+
+	m := NewManager(0, 0, nil, nil)
+	if _, err := m.Transition(context.Background(), "INC-nope", models.IncidentAcknowledged, "JPL-102", ""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown incident: %v", err)
+	}
+}
+
+func TestClosedIncidentIsNotReused(t *testing.T) {
+	m := NewManager(0, 0, nil, nil)
+	first := m.Observe(push("cam1", 7, models.DetectionObstacleStuck, 0))
+	ctx := context.Background()
+	if _, err := m.Transition(ctx, first.ID, models.IncidentAcknowledged, "JPL-102", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Transition(ctx, first.ID, models.IncidentResolved, "JPL-102", ""); err != nil {
+		t.Fatal(err)
+	}
+	if next := m.Observe(push("cam1", 7, models.DetectionObstacleStuck, time.Second)); next.ID == first.ID {
+		t.Error("push after resolving joined the resolved incident")
+	}
+}
+
+func TestExpireIdle(t *testing.T) {
+	out := &recorder{}
+	m := NewManager(0, time.Minute, nil, out)
+	ctx := context.Background()
+	idle := m.Observe(push("cam1", 1, models.DetectionObstacleStuck, 0))
+	taken := m.Observe(push("cam1", 2, models.DetectionObstacleStuck, 0))
+	recent := m.Observe(push("cam1", 3, models.DetectionObstacleStuck, 50*time.Second))
+	if _, err := m.Transition(ctx, taken.ID, models.IncidentAcknowledged, "JPL-102", ""); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := m.ExpireIdle(ctx, t0.Add(70*time.Second)); n != 1 {
+		t.Fatalf("expired %d, want 1", n)
+	}
+	for id, want := range map[string]string{
+		idle.ID:   models.IncidentExpired,
+		taken.ID:  models.IncidentAcknowledged,
+		recent.ID: models.IncidentOpen,
+	} {
+		got, err := m.Get(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != want {
+			t.Errorf("%s: status %s, want %s", id, got.Status, want)
+		}
+	}
+	if n := m.ExpireIdle(ctx, t0.Add(70*time.Second)); n != 0 {
+		t.Errorf("expired %d again", n)
+	}
+	if next := m.Observe(push("cam1", 1, models.DetectionObstacleStuck, 80*time.Second)); next.ID == idle.ID {
+		t.Error("push after expiry joined the expired incident")
+	}
+}
+
+func TestListFilter(t *testing.T) {
+	m := NewManager(0, 0, nil, nil)
+	m.Observe(push("cam1", 1, models.DetectionObstacleStuck, 0))
+	m.Observe(push("cam2", 1, models.DetectionObstacleStuck, time.Minute))
+	m.Observe(push("cam3", 1, models.DetectionObstacleStuck, 2*time.Minute))
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   []string // cameras, newest first
+	}{
+		{name: "all", want: []string{"cam3", "cam2", "cam1"}},
+		{name: "camera", filter: Filter{CameraID: "cam2"}, want: []string{"cam2"}},
+		{name: "allowed cameras", filter: Filter{AllowedCameras: map[string]bool{"cam1": true, "cam3": true}}, want: []string{"cam3", "cam1"}},
+		{name: "seen since", filter: Filter{SeenSince: t0.Add(time.Minute)}, want: []string{"cam3", "cam2"}},
+		{name: "limit", filter: Filter{Limit: 1}, want: []string{"cam3"}},
+		{name: "status", filter: Filter{Status: models.IncidentAcknowledged}, want: []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			list := m.List(tt.filter)
+			got := make([]string, 0, len(list))
+			for _, inc := range list {
+				got = append(got, inc.CameraID)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("cameras %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("cameras %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRaiseSeverity(t *testing.T) {
+	train := &models.TrainApproach{PostID: "JPL-102", TrainID: "KA-123", Source: models.TrainSourceSignal, Inbound: true, ETASeconds: 90}
+	tests := []struct {
+		name     string
+		severity string
+		raised   bool
+	}{
+		{name: "higher", severity: models.SeverityCritical, raised: true},
+		{name: "same", severity: models.SeverityMedium},
+		{name: "lower", severity: models.SeverityLow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := NewManager(0, 0, nil, nil)
+			p := push("cam1", 7, models.DetectionObstacleStuck, 0)
+			p.Severity = models.SeverityMedium
+			inc := m.Observe(p)
+
+			raised, err := m.RaiseSeverity(context.Background(), inc.ID, tt.severity, train)
+			if err != nil || raised != tt.raised {
+				t.Fatalf("raised %v, %v; want %v", raised, err, tt.raised)
+			}
+			got, _ := m.Get(context.Background(), inc.ID)
+			want := models.SeverityMedium
+			if tt.raised {
+				want = tt.severity
+			}
+			if got.Severity != want {
+				t.Errorf("severity %s, want %s", got.Severity, want)
+			}
+			if tt.raised && (got.Train == nil || got.Train.TrainID != "KA-123") {
+				t.Errorf("train %+v", got.Train)
+			}
+		})
+	}
+
+	m := NewManager(0, 0, nil, nil)
+	if _, err := m.RaiseSeverity(context.Background(), "INC-nope", models.SeverityCritical, train); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown incident: %v", err)
+	}
+}
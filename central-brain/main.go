@@ -14,13 +14,25 @@ import (
 	"context"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"central-brain/api"
+	"central-brain/auth"
+	"central-brain/escalation"
+	"central-brain/gates"
+	"central-brain/incident"
 	"central-brain/middleware"
 	"central-brain/models"
+	"central-brain/notify"
 	"central-brain/realtime"
+	"central-brain/rules"
+	"central-brain/services"
 	"central-brain/storage"
 	"central-brain/stream"
+	"central-brain/trains"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -28,20 +40,15 @@ import (
 )
 
 func main() {
+	// Subcommands run against the database without starting the server
+	if len(os.Args) > 1 && os.Args[1] == "user" {
+		os.Exit(runUserCommand(os.Args[2:]))
+	}
+
 	// Initialize realtime hub and history store
 	hub := realtime.NewHub()
 	go hub.Run()
 	history := storage.NewHistoryStore()
-	
-	// Initialize multiple MJPEG hubs for 4 cameras
-	mjpeg1 := stream.NewMJPEGHub()
-	go mjpeg1.Run()
-	mjpeg2 := stream.NewMJPEGHub()
-	go mjpeg2.Run()
-	mjpeg3 := stream.NewMJPEGHub()
-	go mjpeg3.Run()
-	mjpeg4 := stream.NewMJPEGHub()
-	go mjpeg4.Run()
 
 	// Initialize SQLite database (optional, falls back to memory)
 	db, err := NewDatabase(os.Getenv("DB_DSN"))
@@ -51,6 +58,225 @@ func main() {
 		log.Printf("[DB] SQLite ready")
 	}
 
+	demoMode := os.Getenv("DEMO_MODE") == "true"
+
+	// JWT signing keys (JWT_KEYS_FILE or JWT_SECRET); SIGHUP reloads them for rotation
+	if err := auth.LoadSigningKeys(); err != nil {
+		log.Fatalf("[AUTH] failed to load signing keys: %v", err)
+	}
+	kid, alg, source := auth.ActiveKeyInfo()
+	log.Printf("[AUTH] signing tokens with kid=%s alg=%s (%s)", kid, alg, source)
+	if auth.UsingDevSecret() && !demoMode {
+		log.Printf("[AUTH] WARNING: using the built-in JWT secret; set JWT_KEYS_FILE or JWT_SECRET")
+	}
+	go reloadKeysOnSignal()
+
+	// Refresh tokens and revoked access tokens
+	if err := auth.LoadSessions(context.Background(), db); err != nil {
+		log.Printf("[AUTH] failed to load sessions: %v", err)
+	}
+
+	// User accounts (demo users are only seeded in demo mode)
+	if err := services.LoadUsers(context.Background(), db, demoMode); err != nil {
+		log.Printf("[AUTH] failed to load users: %v", err)
+	}
+	if len(services.ListUsers()) == 0 {
+		log.Printf("[AUTH] no users yet; create an admin with: central-brain user add -id ADMIN -name \"DAOP Admin\"")
+	}
+	// Changes made with the user CLI reach the running server on the next sync
+	accountSync, err := time.ParseDuration(os.Getenv("ACCOUNT_SYNC_INTERVAL"))
+	if err != nil || accountSync <= 0 {
+		accountSync = defaultAccountSync
+	}
+	go syncAccounts(accountSync)
+
+	// Camera registry: one MJPEG hub per registered camera
+	cameras := stream.NewRegistry(db)
+	cameras.OnChange(func(cam models.Camera, removed bool) {
+		if removed {
+			services.RemoveCameraPost(cam.ID)
+			return
+		}
+		services.SetCameraPost(cam.ID, cam.PostID)
+	})
+
+	// Continuous archive of every camera for playback (must attach before Load)
+	archiveDir := os.Getenv("ARCHIVE_DIR")
+	if archiveDir == "" {
+		archiveDir = "archive"
+	}
+	retention, err := time.ParseDuration(os.Getenv("ARCHIVE_RETENTION"))
+	if err != nil {
+		retention = stream.DefaultArchiveRetention
+	}
+	maxMB, _ := strconv.ParseInt(os.Getenv("ARCHIVE_MAX_MB"), 10, 64)
+	archiveFPS, _ := strconv.ParseFloat(os.Getenv("ARCHIVE_FPS"), 64)
+	archive, err := stream.NewArchive(archiveDir, retention, maxMB<<20, archiveFPS)
+	if err != nil {
+		log.Fatalf("[ARCHIVE] cannot open archive directory %s: %v", archiveDir, err)
+	}
+	archive.Attach(cameras)
+	go archive.Run()
+
+	if err := cameras.Load(context.Background(), defaultCameras()); err != nil {
+		log.Printf("[STREAM] failed to load cameras: %v", err)
+	}
+
+	// Watchdog flags dead engines and frozen cameras in the hierarchy and on /ws
+	watchdog := stream.NewWatchdog(cameras)
+	watchdog.OnChange(func(h models.CameraHealth) {
+		if h.UnitID != "" {
+			services.SetUnitStatus(h.UnitID, watchdog.UnitStatus(h.UnitID))
+		}
+		hub.Publish(h.CameraID, "CAMERA_HEALTH", models.CameraHealthMessage{Type: "CAMERA_HEALTH", Camera: h})
+	})
+	go watchdog.Run()
+
+	evidenceDir := "../ai-engine/evidence" // shared folder written by the AI engine
+	// Without SQLite the detection APIs read the in-memory history instead
+	var queryDetections func(f models.DetectionFilter) (models.DetectionPage, error)
+	if db != nil {
+		queryDetections = func(f models.DetectionFilter) (models.DetectionPage, error) {
+			return db.QueryDetections(context.Background(), f)
+		}
+	}
+
+	// Service keys authenticate AI engines on /api/internal
+	serviceKeys := auth.NewServiceKeyRing(db)
+	if err := serviceKeys.Load(context.Background()); err != nil {
+		log.Printf("[AUTH] failed to load service keys: %v", err)
+	}
+
+	// Incident manager groups repeated pushes into incidents; OPEN incidents
+	// expire after INCIDENT_IDLE_TIMEOUT without a push
+	incidentIdle, _ := time.ParseDuration(os.Getenv("INCIDENT_IDLE_TIMEOUT"))
+	incidents := incident.NewManager(incident.DefaultWindow, incidentIdle, db, hub)
+	if err := incidents.Load(context.Background()); err != nil {
+		log.Printf("[INCIDENT] failed to restore active incidents: %v", err)
+	}
+	go incidents.Run()
+
+	// Each new incident saves a clip from the camera's ring buffer plus the frames that follow
+	clipDir := os.Getenv("CLIP_DIR")
+	if clipDir == "" {
+		clipDir = "clips"
+	}
+	clips, err := stream.NewClipRecorder(cameras, clipDir, stream.DefaultPreRoll, stream.DefaultPostRoll)
+	if err != nil {
+		log.Fatalf("[CLIP] cannot create clip directory %s: %v", clipDir, err)
+	}
+	incidents.OnOpened(func(inc models.Incident) {
+		clips.Trigger(inc.CameraID, inc.ID)
+	})
+	clips.OnSaved(func(incidentID, path string) {
+		if _, err := incidents.AttachClip(context.Background(), incidentID, "/api/incidents/"+incidentID+"/clip"); err != nil {
+			log.Printf("[CLIP] failed to link clip to %s: %v", incidentID, err)
+		}
+	})
+
+	// Outbound alerts (webhooks always; email and Telegram when configured)
+	notifiers := []notify.Notifier{notify.NewWebhook(os.Getenv("NOTIFY_WEBHOOK_SECRET"))}
+	if addr := os.Getenv("SMTP_ADDR"); addr != "" {
+		notifiers = append(notifiers, notify.NewEmail(addr, os.Getenv("SMTP_FROM"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD")))
+	}
+	if token := os.Getenv("TELEGRAM_BOT_TOKEN"); token != "" {
+		notifiers = append(notifiers, notify.NewTelegram(os.Getenv("TELEGRAM_API_URL"), token))
+	}
+	notifier := notify.NewDispatcher(db, notifiers...)
+	if err := notifier.Load(context.Background()); err != nil {
+		log.Printf("[NOTIFY] failed to load notification routes: %v", err)
+	}
+	go notifier.Run()
+
+	// Escalation of incidents nobody acknowledged, per post
+	escalations := escalation.NewScheduler(db, incidents, notifier)
+	if err := escalations.Load(context.Background()); err != nil {
+		log.Printf("[ESCALATION] failed to load escalation policies: %v", err)
+	}
+	go escalations.Run()
+
+	// Train approaches per post, from timetables and track signals
+	inboundWindow, _ := time.ParseDuration(os.Getenv("TRAIN_INBOUND_WINDOW"))
+	trainTracker := trains.NewTracker(inboundWindow, db)
+	if err := trainTracker.Load(context.Background()); err != nil {
+		log.Printf("[TRAINS] failed to load timetables: %v", err)
+	}
+	// A train starting its approach makes the post's stuck obstacles and gate
+	// violations critical without waiting for the AI engine's next push.
+	// Only incidents still being pushed count; older ones are past.
+	trainTracker.OnApproach(func(a models.TrainApproach) {
+		seenSince := time.Now().Add(-incidents.Window())
+		for _, status := range []string{models.IncidentOpen, models.IncidentAcknowledged} {
+			for _, inc := range incidents.List(incident.Filter{Status: status, SeenSince: seenSince}) {
+				if inc.Type != models.DetectionObstacleStuck && inc.Type != models.DetectionGateViolation {
+					continue
+				}
+				if services.CameraPostID(inc.CameraID) != a.PostID {
+					continue
+				}
+				raised, err := incidents.RaiseSeverity(context.Background(), inc.ID, models.SeverityCritical, &a)
+				if err != nil {
+					log.Printf("[TRAINS] failed to escalate %s: %v", inc.ID, err)
+					continue
+				}
+				if raised {
+					if inc, err := incidents.Get(context.Background(), inc.ID); err == nil {
+						notifier.NotifyIncident(inc, models.DetectionPayload{PostID: a.PostID})
+					}
+				}
+			}
+		}
+	})
+
+	// Level-crossing gate state per post, checked ahead of every train
+	closeLead, _ := time.ParseDuration(os.Getenv("GATE_CLOSE_LEAD"))
+	gateMonitor := gates.NewMonitor(db, trainTracker.Approach, closeLead)
+	if err := gateMonitor.Load(context.Background()); err != nil {
+		log.Printf("[GATE] failed to load gate states: %v", err)
+	}
+
+	// Detection rules, applied to every detection before it is grouped and stored
+	ruleEngine := rules.NewEngine(db)
+	ruleEngine.RouteExists = notifier.HasRoute
+	if err := ruleEngine.Load(context.Background()); err != nil {
+		log.Printf("[RULES] failed to load detection rules: %v", err)
+	}
+
+	// Detection pipeline shared by AI engine pushes and central-brain's own frame analysis
+	detections := &api.DetectionPipeline{
+		Hub:     hub,
+		History: history,
+		Save: func(p models.DetectionPayload) error {
+			if db == nil {
+				return nil
+			}
+			return db.InsertDetection(context.Background(), p)
+		},
+		Incidents: incidents,
+		Trains: func(postID string) (models.TrainApproach, bool) {
+			return trainTracker.Approach(postID, time.Now())
+		},
+		Gates:  gateMonitor.Status,
+		Notify: notifier.NotifyIncident,
+		Rules:  ruleEngine,
+	}
+	gateMonitor.OnAlert(func(p models.DetectionPayload) {
+		detections.Process(p)
+	})
+	go gateMonitor.Run()
+
+	// Optional tamper / image quality analysis of sampled ingest frames
+	var analyzer *stream.Analyzer
+	if os.Getenv("FRAME_ANALYSIS") == "true" {
+		interval, _ := time.ParseDuration(os.Getenv("FRAME_ANALYSIS_INTERVAL"))
+		analyzer = stream.NewAnalyzer(interval)
+		analyzer.OnAlert(func(p models.DetectionPayload) {
+			detections.Process(p)
+		})
+		go analyzer.Run()
+		log.Printf("[QUALITY] frame analysis enabled")
+	}
+
 	// Initialize Fiber app
 	app := fiber.New(fiber.Config{
 		AppName:      "Aeon RailGuard Central Brain v2.1.0",
@@ -66,37 +292,29 @@ func main() {
 		AllowMethods: "GET, POST, PUT, DELETE, OPTIONS",
 	}))
 
-	// Serve evidence images saved by AI engine (shared folder ../ai-engine/evidence)
-	app.Static("/evidence", "../ai-engine/evidence")
-
 	// Root endpoint
 	app.Get("/", handleRoot)
-	app.Post("/api/internal/push", api.HandleInternalPush(hub, history, func(p models.DetectionPayload) error {
-		if db == nil {
-			return nil
-		}
-		return db.InsertDetection(context.Background(), p)
-	}))
-	app.Post("/api/internal/stream/cam1", stream.IngestFrame(mjpeg1))
-	app.Post("/api/internal/stream/cam2", stream.IngestFrame(mjpeg2))
-	app.Post("/api/internal/stream/cam3", stream.IngestFrame(mjpeg3))
-	app.Post("/api/internal/stream/cam4", stream.IngestFrame(mjpeg4))
-	app.Get("/api/history", api.HandleHistory(history, func(limit int) ([]models.DetectionPayload, error) {
-		if db == nil {
-			return nil, nil
-		}
-		return db.ListDetections(context.Background(), limit)
-	}))
-	app.Get("/api/detections", api.HandleDetections(history, func(limit int) ([]models.DetectionPayload, error) {
-		if db == nil {
-			return nil, nil
-		}
-		return db.ListDetections(context.Background(), limit)
-	}))
+	// AI engine ingest (HMAC-signed with a service key; unsigned allowed only in demo mode)
+	ingestAuth := middleware.ServiceAuth(serviceKeys, demoMode)
+	app.Post("/api/internal/push", ingestAuth, api.HandleInternalPush(detections))
+	app.Post("/api/internal/stream/:camera_id", ingestAuth, stream.IngestFrame(cameras, analyzer))
+	app.Post("/api/internal/trains", ingestAuth, api.HandleTrainEvent(trainTracker))
+	app.Post("/api/internal/gates", ingestAuth, api.HandleGateEvent(gateMonitor))
+
+	// Demo mode keeps the old unauthenticated, unscoped read routes for the hackathon dashboard
+	if demoMode {
+		log.Printf("[DEMO] DEMO_MODE enabled: /api/history, /api/detections, /evidence and /ws are public")
+		app.Get("/api/history", api.HandleHistory(history, queryDetections))
+		app.Get("/api/detections", api.HandleDetections(history, queryDetections))
+		app.Static("/evidence", evidenceDir)
+	}
 
 	// Public endpoints (no auth required)
 	app.Post("/api/auth/login", api.HandleLogin)
+	app.Post("/api/auth/refresh", api.HandleRefresh)
+	app.Post("/api/auth/logout", api.HandleLogout)
 	app.Get("/api/health", api.HandleHealth)
+	app.Get("/.well-known/jwks.json", api.HandleJWKS)
 	app.All("/api/config/ai", api.HandleAIConfig(func(ctx context.Context, key string) (string, error) {
 		if db == nil {
 			return "", nil
@@ -109,25 +327,48 @@ func main() {
 		return db.UpsertSetting(ctx, key, value)
 	}))
 
-	// Websocket endpoint (no auth for demo)
+	// Websocket endpoint (JWT via ?token= or first message, events scoped by role)
 	app.Use("/ws", func(c *fiber.Ctx) error {
 		if websocket.IsWebSocketUpgrade(c) {
 			return c.Next()
 		}
 		return fiber.ErrUpgradeRequired
 	})
-	app.Get("/ws", websocket.New(realtime.WSHandler(hub)))
+	wsAuthorizer := api.WSAuthorizer
+	if demoMode {
+		wsAuthorizer = api.DemoWSAuthorizer
+	}
+	app.Get("/ws", websocket.New(realtime.WSHandler(hub, wsAuthorizer)))
+
+	// Rendered streams are shared by MJPEG and WebSocket viewers
+	annotator := stream.NewAnnotator(cameras)
+	maskedAnnotator := stream.NewMaskedAnnotator(cameras)
+	masker := stream.NewMasker(cameras)
+	// JPEG frames as binary messages, several cameras per connection (masked below DAOP_ADMIN)
+	app.Get("/ws/stream/:camera_id", websocket.New(stream.WSFrames(cameras, map[string]*stream.Renderer{
+		stream.KindAnnotated:       annotator,
+		stream.KindMasked:          masker,
+		stream.KindMaskedAnnotated: maskedAnnotator,
+	}, wsAuthorizer, api.ScopeSeesUnmasked)))
 
+	// Evidence snapshots (scoped to the caller's cameras, privacy-masked below DAOP_ADMIN; ?token= accepted for <img> tags)
+	app.Get("/evidence/:file", middleware.AuthRequired(), middleware.RequireRole(models.RoleJPLOfficer), api.HandleEvidence(evidenceDir, cameras, history, db.FindDetectionCamera))
+
+	// Live views (scoped to the caller's cameras, privacy-masked below DAOP_ADMIN; ?token= accepted)
+	liveAuth := []fiber.Handler{middleware.AuthRequired(), middleware.RequireRole(models.RoleJPLOfficer), api.RequireCameraScope()}
 	// MJPEG stream endpoints (legacy multipart/x-mixed-replace)
-	app.Get("/stream/cam1", stream.StreamMJPEG(mjpeg1))
-	app.Get("/stream/cam2", stream.StreamMJPEG(mjpeg2))
-	app.Get("/stream/cam3", stream.StreamMJPEG(mjpeg3))
-	app.Get("/stream/cam4", stream.StreamMJPEG(mjpeg4))
-	// Latest frame endpoints (for polling - browser compatible)
-	app.Get("/stream/cam1/latest", stream.LatestFrame(mjpeg1))
-	app.Get("/stream/cam2/latest", stream.LatestFrame(mjpeg2))
-	app.Get("/stream/cam3/latest", stream.LatestFrame(mjpeg3))
-	app.Get("/stream/cam4/latest", stream.LatestFrame(mjpeg4))
+	app.Get("/stream/:camera_id", append(liveAuth, stream.ForViewer(api.SeesUnmasked, stream.StreamMJPEG(cameras), stream.StreamRendered(masker)))...)
+	// Latest frame endpoint (for polling - browser compatible)
+	app.Get("/stream/:camera_id/latest", append(liveAuth, stream.LatestFrame(cameras, api.SeesUnmasked))...)
+	// Cached small copy of the latest frame for map popups
+	app.Get("/stream/:camera_id/thumbnail", append(liveAuth, stream.Thumbnail(cameras, api.SeesUnmasked))...)
+	// Same stream with boxes, labels and the camera's ROI drawn server-side
+	app.Get("/stream/:camera_id/annotated", append(liveAuth, stream.ForViewer(api.SeesUnmasked, stream.StreamRendered(annotator), stream.StreamRendered(maskedAnnotator)))...)
+	// Privacy-masked stream for every role; refused for cameras without masks
+	app.Get("/stream/:camera_id/masked", append(liveAuth, stream.RequireMasks(cameras), stream.StreamRendered(masker))...)
+	// Archive playback (scoped to the caller's cameras; ?token= accepted for <img> tags)
+	app.Get("/stream/:camera_id/archive", middleware.AuthRequired(), middleware.RequireRole(models.RoleJPLOfficer), api.RequireCameraScope(), stream.ArchiveIndex(archive))
+	app.Get("/stream/:camera_id/playback", middleware.AuthRequired(), middleware.RequireRole(models.RoleJPLOfficer), api.RequireCameraScope(), stream.Playback(archive, cameras, api.SeesUnmasked))
 
 	// Protected endpoints (JWT required)
 	protected := app.Group("/api")
@@ -137,15 +378,76 @@ func main() {
 	protected.Get("/hierarchy", api.HandleGetHierarchy)
 
 	// Cameras (requires JPL_OFFICER or higher)
-	protected.Get("/cameras", middleware.RequireRole(models.RoleJPLOfficer), api.HandleGetCameras)
+	protected.Get("/cameras", middleware.RequireRole(models.RoleJPLOfficer), api.HandleGetCameras(cameras))
+	protected.Get("/cameras/health", middleware.RequireRole(models.RoleJPLOfficer), api.HandleCameraHealth(watchdog))
+	protected.Get("/cameras/:camera_id/latency", middleware.RequireRole(models.RoleJPLOfficer), api.RequireCameraScope(), api.HandleCameraLatency(cameras))
+	protected.Get("/cameras/:camera_id/quality", middleware.RequireRole(models.RoleJPLOfficer), api.RequireCameraScope(), api.HandleCameraQuality(analyzer))
+	protected.Post("/cameras/:camera_id/reference", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleResetReference(cameras, analyzer))
 
-	// Detections (requires JPL_OFFICER or higher)
-	protected.Get("/detections", middleware.RequireRole(models.RoleJPLOfficer), api.HandleDetections(history, func(limit int) ([]models.DetectionPayload, error) {
-		if db == nil {
-			return nil, nil
-		}
-		return db.ListDetections(context.Background(), limit)
-	}))
+	// Camera registry administration (DAOP_ADMIN only)
+	protected.Post("/cameras", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleCreateCamera(cameras))
+	protected.Put("/cameras/:camera_id", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleUpdateCamera(cameras))
+	protected.Delete("/cameras/:camera_id", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleDeleteCamera(cameras))
+
+	// Detections and history (requires JPL_OFFICER or higher, scoped to post/station)
+	protected.Get("/detections", middleware.RequireRole(models.RoleJPLOfficer), api.HandleDetections(history, queryDetections))
+	protected.Get("/history", middleware.RequireRole(models.RoleJPLOfficer), api.HandleHistory(history, queryDetections))
+
+	// Trains and timetables (scoped to post/station; imports DAOP_ADMIN only)
+	protected.Get("/trains", middleware.RequireRole(models.RoleJPLOfficer), api.HandleGetTrains(trainTracker))
+	protected.Get("/posts/:post_id/timetable", middleware.RequireRole(models.RoleJPLOfficer), api.RequirePostScope(), api.HandleGetTimetable(trainTracker))
+	protected.Put("/posts/:post_id/timetable", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleSetTimetable(trainTracker))
+
+	// Level-crossing gate state and history (scoped to post/station)
+	protected.Get("/gates", middleware.RequireRole(models.RoleJPLOfficer), api.HandleGetGates(gateMonitor))
+	protected.Get("/posts/:post_id/gate", middleware.RequireRole(models.RoleJPLOfficer), api.RequirePostScope(), api.HandleGetGate(gateMonitor))
+
+	// Escalation policies (read scoped to post/station; changes DAOP_ADMIN only)
+	protected.Get("/escalation-policies", middleware.RequireRole(models.RoleJPLOfficer), api.HandleListEscalationPolicies(escalations))
+	protected.Get("/posts/:post_id/escalation-policy", middleware.RequireRole(models.RoleJPLOfficer), api.RequirePostScope(), api.HandleGetEscalationPolicy(escalations))
+	protected.Put("/posts/:post_id/escalation-policy", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleSetEscalationPolicy(escalations))
+	protected.Delete("/posts/:post_id/escalation-policy", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleDeleteEscalationPolicy(escalations))
+
+	// Outbound notification routing (DAOP_ADMIN only)
+	protected.Get("/notifications/routes", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleListNotificationRoutes(notifier))
+	protected.Post("/notifications/routes", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleSaveNotificationRoute(notifier))
+	protected.Put("/notifications/routes/:id", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleSaveNotificationRoute(notifier))
+	protected.Delete("/notifications/routes/:id", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleDeleteNotificationRoute(notifier))
+	protected.Post("/notifications/routes/:id/test", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleTestNotificationRoute(notifier))
+	protected.Get("/notifications/dead-letters", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleListDeadLetters(notifier))
+
+	// Detection rules (DAOP_ADMIN only)
+	protected.Get("/rules", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleListRules(ruleEngine))
+	protected.Post("/rules", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleSaveRule(ruleEngine))
+	protected.Post("/rules/reload", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleReloadRules(ruleEngine))
+	protected.Post("/rules/dry-run", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleDryRunRules(ruleEngine, history, queryDetections, gateMonitor, trainTracker))
+	protected.Put("/rules/:id", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleSaveRule(ruleEngine))
+	protected.Delete("/rules/:id", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleDeleteRule(ruleEngine))
+
+	// User administration (DAOP_ADMIN only)
+	protected.Get("/users", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleListUsers)
+	protected.Post("/users", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleCreateUser)
+	protected.Get("/users/:id", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleGetUser)
+	protected.Put("/users/:id", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleUpdateUser)
+	protected.Post("/users/:id/disable", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleSetUserDisabled(true))
+	protected.Post("/users/:id/enable", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleSetUserDisabled(false))
+	protected.Post("/users/:id/password", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleResetPassword)
+
+	// AI engine service keys (DAOP_ADMIN only)
+	protected.Get("/service-keys", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleListServiceKeys(serviceKeys))
+	protected.Post("/service-keys", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleIssueServiceKey(serviceKeys))
+	protected.Delete("/service-keys/:id", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleRevokeServiceKey(serviceKeys))
+
+	// WebSocket client queue counters (DAOP_ADMIN only)
+	protected.Get("/ws/stats", middleware.RequireRole(models.RoleDAOPAdmin), api.HandleWSStats(hub))
+
+	// Incidents (requires JPL_OFFICER or higher)
+	protected.Get("/incidents", middleware.RequireRole(models.RoleJPLOfficer), api.HandleListIncidents(incidents))
+	protected.Get("/incidents/:id", middleware.RequireRole(models.RoleJPLOfficer), api.HandleGetIncident(incidents))
+	protected.Get("/incidents/:id/clip", middleware.RequireRole(models.RoleJPLOfficer), api.HandleIncidentClip(incidents, clips))
+	protected.Post("/incidents/:id/acknowledge", middleware.RequireRole(models.RoleJPLOfficer), api.HandleIncidentTransition(incidents, models.IncidentAcknowledged))
+	protected.Post("/incidents/:id/resolve", middleware.RequireRole(models.RoleJPLOfficer), api.HandleIncidentTransition(incidents, models.IncidentResolved))
+	protected.Post("/incidents/:id/false-positive", middleware.RequireRole(models.RoleJPLOfficer), api.HandleIncidentTransition(incidents, models.IncidentFalsePositive))
 
 	// ============================================
 	// HACKATHON FASE 1: JPL Camera Endpoints
@@ -159,7 +461,7 @@ func main() {
 	// app.Get("/api/docs/*", swagger.HandlerDefault)
 
 	// Print banner
-	printBanner()
+	printBanner(demoMode)
 
 	// Start server
 	log.Fatal(app.Listen(":8080"))
@@ -178,18 +480,71 @@ func handleRoot(c *fiber.Ctx) error {
 			"API Documentation",
 		},
 		"endpoints": fiber.Map{
-			"health":      "GET /api/health",
-			"login":       "POST /api/auth/login",
-			"hierarchy":   "GET /api/hierarchy (Protected)",
-			"cameras":     "GET /api/cameras (Protected)",
-			"detections":  "GET /api/detections (Protected)",
-			"jpl_list":    "GET /api/jpl (Public)",
-			"jpl_cameras": "GET /api/jpl/:jpl_id/cameras (Public)",
+			"health":       "GET /api/health",
+			"login":        "POST /api/auth/login",
+			"refresh":      "POST /api/auth/refresh",
+			"logout":       "POST /api/auth/logout",
+			"jwks":         "GET /.well-known/jwks.json",
+			"hierarchy":    "GET /api/hierarchy (Protected)",
+			"cameras":      "GET /api/cameras (Protected)",
+			"camera_admin": "POST/PUT/DELETE /api/cameras/:camera_id (DAOP_ADMIN)",
+			"users":        "GET/POST/PUT /api/users (DAOP_ADMIN)",
+			"stream":       "GET /stream/:camera_id",
+			"detections":   "GET /api/detections (Protected)",
+			"incidents":    "GET /api/incidents (Protected)",
+			"jpl_list":     "GET /api/jpl (Public)",
+			"jpl_cameras":  "GET /api/jpl/:jpl_id/cameras (Public)",
 		},
 	})
 }
 
-func printBanner() {
+// reloadKeysOnSignal re-reads the JWT signing keys on SIGHUP. Keys dropped
+// from the configuration keep verifying until tokens they signed expire.
+func reloadKeysOnSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	for range sig {
+		if err := auth.LoadSigningKeys(); err != nil {
+			log.Printf("[AUTH] key reload failed, keeping current keys: %v", err)
+			continue
+		}
+		kid, alg, _ := auth.ActiveKeyInfo()
+		log.Printf("[AUTH] signing keys reloaded, active kid=%s alg=%s", kid, alg)
+	}
+}
+
+// defaultAccountSync is how often users and sessions are re-read from the
+// database when ACCOUNT_SYNC_INTERVAL is not set.
+const defaultAccountSync = 30 * time.Second
+
+// syncAccounts re-reads users and sessions every interval, so accounts the
+// user CLI disables or changes stop working without a server restart.
+func syncAccounts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := services.SyncUsers(context.Background()); err != nil {
+			log.Printf("[AUTH] failed to sync users: %v", err)
+		}
+		if err := auth.SyncSessions(context.Background()); err != nil {
+			log.Printf("[AUTH] failed to sync sessions: %v", err)
+		}
+	}
+}
+
+// defaultCameras seeds the registry on a fresh install with the streams the
+// AI engine and dashboard already use.
+func defaultCameras() []models.Camera {
+	return []models.Camera{
+		{ID: "cam1", Name: "CAM-01 Arah Timur", Type: "RGB", PostID: "JPL-102", UnitID: "CCTV-JBG-01", Location: models.Location{Lat: -7.5456, Long: 112.2134}, Resolution: "1920x1080", FPS: 30},
+		{ID: "cam2", Name: "CAM-02 Arah Barat", Type: "RGB", PostID: "JPL-102", UnitID: "CCTV-JBG-02", Location: models.Location{Lat: -7.5456, Long: 112.2134}, Resolution: "1920x1080", FPS: 30},
+		{ID: "cam3", Name: "CAM-03 Thermal Utara", Type: "THERMAL", PostID: "JPL-102", Location: models.Location{Lat: -7.5456, Long: 112.2134}, Resolution: "640x480", FPS: 15, ThermalMode: true},
+		{ID: "cam4", Name: "CAM-04 Thermal Selatan", Type: "THERMAL", PostID: "JPL-102", Location: models.Location{Lat: -7.5456, Long: 112.2134}, Resolution: "640x480", FPS: 15, ThermalMode: true},
+		{ID: "peterongan-cam1", Name: "CAM-01 Peterongan Main", Type: "RGB", PostID: "JPL-105", UnitID: "CCTV-PTR-01", Location: models.Location{Lat: -7.5478, Long: 112.2156}, Resolution: "1920x1080", FPS: 30},
+	}
+}
+
+func printBanner(demoMode bool) {
 	log.Println("╔══════════════════════════════════════════════════════════╗")
 	log.Println("║     AEON RAILGUARD - CENTRAL BRAIN v2.1.0                ║")
 	log.Println("║     REST API with JWT Auth & RBAC                        ║")
@@ -202,6 +557,10 @@ func printBanner() {
 	log.Println("║  GET  /api/hierarchy     - Organization hierarchy (RBAC) ║")
 	log.Println("║  GET  /api/cameras       - Camera list                   ║")
 	log.Println("║  GET  /api/detections    - Detection records             ║")
+	if !demoMode {
+		log.Println("╚══════════════════════════════════════════════════════════╝")
+		return
+	}
 	log.Println("╠══════════════════════════════════════════════════════════╣")
 	log.Println("║  Demo Credentials:                                       ║")
 	log.Println("║    ID: DAOP-7    Password: 123456 (DAOP Admin)           ║")
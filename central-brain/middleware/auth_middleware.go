@@ -4,14 +4,20 @@ import (
 	"strings"
 
 	"central-brain/auth"
+	"central-brain/services"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-// AuthRequired validates JWT token from Authorization header
+// AuthRequired validates JWT token from Authorization header.
+// A "token" query parameter is accepted as a fallback for media URLs
+// (<img>, MJPEG) where browsers cannot set headers.
 func AuthRequired() fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		authHeader := c.Get("Authorization")
+		if authHeader == "" && c.Query("token") != "" {
+			authHeader = "Bearer " + c.Query("token")
+		}
 		if authHeader == "" {
 			return c.Status(401).JSON(fiber.Map{
 				"error":   "unauthorized",
@@ -36,6 +42,14 @@ func AuthRequired() fiber.Handler {
 			})
 		}
 
+		// Tokens outlive account changes; reject disabled or removed users
+		if err := services.CheckActive(claims.UserID); err != nil {
+			return c.Status(401).JSON(fiber.Map{
+				"error":   "unauthorized",
+				"message": "Account is disabled or no longer exists",
+			})
+		}
+
 		// Store claims in context for use in handlers
 		c.Locals("user_id", claims.UserID)
 		c.Locals("role", claims.Role)
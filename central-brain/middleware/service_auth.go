@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"errors"
+
+	"central-brain/auth"
+	"central-brain/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ServiceAuth verifies HMAC-signed requests from AI engines on /api/internal routes.
+// If the route has a :camera_id parameter, the key must be bound to that camera.
+// With allowUnsigned (demo mode), requests without signature headers pass through.
+func ServiceAuth(ring *auth.ServiceKeyRing, allowUnsigned bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		keyID := c.Get(auth.HeaderKeyID)
+		if keyID == "" && allowUnsigned {
+			return c.Next()
+		}
+		if keyID == "" {
+			return c.Status(401).JSON(fiber.Map{
+				"error":   "unauthorized",
+				"message": "Missing " + auth.HeaderKeyID + " header",
+			})
+		}
+
+		key, err := ring.Verify(keyID, c.Get(auth.HeaderSignature), auth.SignedRequest{
+			Method:    c.Method(),
+			Path:      c.Path(),
+			Timestamp: c.Get(auth.HeaderTimestamp),
+			Nonce:     c.Get(auth.HeaderNonce),
+			Header:    func(name string) string { return c.Get(name) },
+			Body:      c.Body(),
+		})
+		if err != nil {
+			status := 401
+			if errors.Is(err, auth.ErrReplayedRequest) {
+				status = 409
+			}
+			log.WithFields(map[string]interface{}{
+				"key_id": keyID,
+				"path":   c.Path(),
+				"ip":     c.IP(),
+				"error":  err.Error(),
+			}).Warn("Rejected service request")
+			return c.Status(status).JSON(fiber.Map{
+				"error":   "unauthorized",
+				"message": err.Error(),
+			})
+		}
+
+		c.Locals("service_key", key)
+
+		if cameraID := c.Params("camera_id"); cameraID != "" && !key.AllowsCamera(cameraID) {
+			return ForbiddenServiceCamera(c, cameraID)
+		}
+		return c.Next()
+	}
+}
+
+// ServiceCameraAllowed reports whether the calling engine may publish for cameraID.
+// Unsigned requests (demo mode) are allowed everything.
+func ServiceCameraAllowed(c *fiber.Ctx, cameraID string) bool {
+	key, ok := c.Locals("service_key").(models.ServiceKey)
+	if !ok {
+		return true
+	}
+	return key.AllowsCamera(cameraID)
+}
+
+// ForbiddenServiceCamera rejects a request for a camera the key is not bound to.
+func ForbiddenServiceCamera(c *fiber.Ctx, cameraID string) error {
+	return c.Status(403).JSON(fiber.Map{
+		"error":   "forbidden",
+		"message": "Service key is not allowed to publish for camera " + cameraID,
+	})
+}
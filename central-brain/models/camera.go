@@ -4,12 +4,54 @@ import "time"
 
 // Camera represents a CCTV camera
 type Camera struct {
-	ID       string   `json:"id"`
-	Name     string   `json:"name"`
-	Type     string   `json:"type"`
-	Status   string   `json:"status"`
-	Location Location `json:"location"`
-	PostID   string   `json:"post_id"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Type        string   `json:"type"`
+	Status      string   `json:"status"`
+	Location    Location `json:"location"`
+	PostID      string   `json:"post_id"`
+	UnitID      string   `json:"unit_id,omitempty"` // Hierarchy unit this stream belongs to
+	Resolution  string   `json:"resolution,omitempty"`
+	FPS         int      `json:"fps,omitempty"`
+	ThermalMode bool     `json:"thermal_mode"`
+	ROI         [][2]int `json:"roi,omitempty"` // Danger zone polygon in frame pixels, like danger_zone.json
+	// Areas (faces, windows) hidden on streams that leave the operations room
+	PrivacyMasks    [][][2]int `json:"privacy_masks,omitempty"`
+	PrivacyMaskMode string     `json:"privacy_mask_mode,omitempty"` // PIXELATE (default) or BLACK
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+// Privacy mask modes
+const (
+	PrivacyMaskPixelate = "PIXELATE"
+	PrivacyMaskBlack    = "BLACK"
+)
+
+// Camera health statuses set by the stream watchdog
+const (
+	CameraOnline  = "ONLINE"
+	CameraStale   = "STALE"   // frames stopped recently
+	CameraOffline = "OFFLINE" // no frames for a long time, or never
+	CameraFrozen  = "FROZEN"  // frames keep arriving but are identical
+)
+
+// CameraHealth is the watchdog's view of a camera's feed
+type CameraHealth struct {
+	CameraID       string     `json:"camera_id"`
+	UnitID         string     `json:"unit_id,omitempty"`
+	Status         string     `json:"status"`
+	PreviousStatus string     `json:"previous_status,omitempty"`
+	Since          time.Time  `json:"since"` // when the current status was detected
+	LastFrameAt    *time.Time `json:"last_frame_at,omitempty"`
+	FPS            float64    `json:"fps"`
+	FrozenSeconds  float64    `json:"frozen_seconds,omitempty"` // how long frames have been identical
+}
+
+// CameraHealthMessage is broadcast over WebSocket when a camera's health status changes
+type CameraHealthMessage struct {
+	Type   string       `json:"type"`
+	Camera CameraHealth `json:"camera"`
 }
 
 // Location represents GPS coordinates
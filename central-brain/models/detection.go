@@ -1,21 +1,92 @@
 package models
 
-import "time"
+import (
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// DetectionObstacleStuck is raised by the AI engine when an object stays in the danger zone
+const DetectionObstacleStuck = "OBSTACLE_STUCK"
+
+// Detection types raised by central-brain's own frame analysis
+const (
+	DetectionCameraTamper  = "CAMERA_TAMPER"  // lens covered or camera moved
+	DetectionImageDegraded = "IMAGE_DEGRADED" // too dark, glare or blurred
+)
 
 // DetectionPayload represents data sent from AI engine.
 type DetectionPayload struct {
-	Type             string    `json:"type"`
-	ObjectClass      string    `json:"object_class"`
-	Confidence       float64   `json:"confidence"`
-	InROI            bool      `json:"in_roi"`
-	ObjectID         int       `json:"object_id"`
-	DurationSeconds  float64   `json:"duration_seconds"`
-	Timestamp        time.Time `json:"timestamp"`
-	CameraID         string    `json:"camera_id,omitempty"`
-	AdditionalDetail string    `json:"detail,omitempty"`
-	ImageURL         string    `json:"image_url,omitempty"`
+	ID               int64          `json:"id,omitempty"` // detection_logs row ID, set when read back from DB
+	Type             string         `json:"type"`
+	ObjectClass      string         `json:"object_class"`
+	Confidence       float64        `json:"confidence"`
+	InROI            bool           `json:"in_roi"`
+	ObjectID         int            `json:"object_id"`
+	DurationSeconds  float64        `json:"duration_seconds"`
+	Timestamp        time.Time      `json:"timestamp"`
+	CameraID         string         `json:"camera_id,omitempty"`
+	PostID           string         `json:"post_id,omitempty"` // filled from the camera when not pushed
+	AdditionalDetail string         `json:"detail,omitempty"`
+	ImageURL         string         `json:"image_url,omitempty"`
+	IncidentID       string         `json:"incident_id,omitempty"`
+	Severity         string         `json:"severity,omitempty"`
+	Train            *TrainApproach `json:"train,omitempty"` // set while a train is inbound at the camera's post
+	Tags             []string       `json:"tags,omitempty"`  // added by detection rules
+	Routes           []string       `json:"-"`               // notification route IDs chosen by detection rules
+	Suppressed       bool           `json:"-"`               // dropped by a detection rule
 }
 
+// DetectionFilter narrows detection queries. Zero values mean "no filter".
+type DetectionFilter struct {
+	// AllowedCameras restricts results to the caller's cameras; nil means unrestricted.
+	AllowedCameras map[string]bool
+	CameraID       string
+	ObjectClass    string
+	Type           string
+	From           time.Time
+	To             time.Time
+	MinConfidence  float64
+	InROI          *bool
+	MinDuration    float64
+	Cursor         string // opaque cursor from a previous page
+	Limit          int
+}
 
+// Matches reports whether a payload passes the filter (cursor is not considered).
+func (f DetectionFilter) Matches(p DetectionPayload) bool {
+	if f.AllowedCameras != nil && !f.AllowedCameras[p.CameraID] {
+		return false
+	}
+	if f.CameraID != "" && p.CameraID != f.CameraID {
+		return false
+	}
+	if f.ObjectClass != "" && p.ObjectClass != f.ObjectClass {
+		return false
+	}
+	if f.Type != "" && p.Type != f.Type {
+		return false
+	}
+	if !f.From.IsZero() && p.Timestamp.Before(f.From) {
+		return false
+	}
+	if !f.To.IsZero() && !p.Timestamp.Before(f.To) {
+		return false
+	}
+	if p.Confidence < f.MinConfidence {
+		return false
+	}
+	if f.InROI != nil && p.InROI != *f.InROI {
+		return false
+	}
+	return p.DurationSeconds >= f.MinDuration
+}
 
-
+// DetectionPage is one page of a cursor-paginated detection query.
+type DetectionPage struct {
+	Detections []DetectionPayload
+	NextCursor string
+	Total      int
+}
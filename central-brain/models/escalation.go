@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// EscalationStep notifies someone once an incident stayed unacknowledged for DelaySeconds
+type EscalationStep struct {
+	DelaySeconds int    `json:"delay_seconds"`    // since the incident opened
+	Notify       string `json:"notify,omitempty"` // who is told, e.g. "Station master STA-JBG"
+	Channel      string `json:"channel"`          // webhook, email or telegram
+	Recipient    string `json:"recipient"`        // URL, email address or chat ID
+}
+
+// EscalationPolicy lists the escalation steps of a post's incidents.
+// Empty filters match every incident.
+type EscalationPolicy struct {
+	PostID      string           `json:"post_id"`
+	MinSeverity string           `json:"min_severity,omitempty"`
+	Types       []string         `json:"types,omitempty"` // detection types
+	Steps       []EscalationStep `json:"steps"`
+	UpdatedBy   string           `json:"updated_by,omitempty"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// FrameMeta is the envelope the AI engine may send with a stream frame: where
+// and when it was captured, and its detections so overlays can be drawn
+// server-side on a clean image.
+type FrameMeta struct {
+	CaptureTimestamp time.Time        `json:"capture_timestamp,omitempty"` // engine clock, when the frame was grabbed
+	Sequence         uint64           `json:"sequence,omitempty"`          // per engine, starting at 1
+	EngineID         string           `json:"engine_id,omitempty"`
+	Resolution       string           `json:"resolution,omitempty"` // WIDTHxHEIGHT
+	Detections       []FrameDetection `json:"detections,omitempty"`
+}
+
+// FrameDetection is one tracked object in a frame
+type FrameDetection struct {
+	TrackID         int     `json:"track_id"`
+	Class           string  `json:"class"`
+	Confidence      float64 `json:"confidence"`
+	BBox            [4]int  `json:"bbox"` // x1, y1, x2, y2 in frame pixels
+	InROI           bool    `json:"in_roi"`
+	DurationSeconds float64 `json:"duration_seconds,omitempty"` // time spent in the danger zone
+	Alert           bool    `json:"alert,omitempty"`            // alert threshold reached
+}
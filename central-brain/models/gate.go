@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// Level-crossing gate (palang) states reported by the post's gate sensor
+const (
+	GateOpen    = "OPEN"
+	GateClosing = "CLOSING"
+	GateClosed  = "CLOSED"
+	GateFault   = "FAULT"
+)
+
+// Detection types raised from gate state
+const (
+	DetectionGateViolation = "GATE_VIOLATION" // vehicle in the danger zone while the gate is closed
+	DetectionGateFault     = "GATE_FAULT"     // gate faulted or not closed before a train
+)
+
+// GateEvent is a state change of a post's gate, bells and lights
+type GateEvent struct {
+	ID        int64     `json:"id,omitempty"` // gate_events row ID, set when read back from DB
+	PostID    string    `json:"post_id"`
+	State     string    `json:"state"`            // OPEN, CLOSING, CLOSED or FAULT
+	Source    string    `json:"source,omitempty"` // sensor or operator that reported it
+	Detail    string    `json:"detail,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// GateStatus is a post's current gate state
+type GateStatus struct {
+	PostID string    `json:"post_id"`
+	State  string    `json:"state"`
+	Since  time.Time `json:"since"` // when the gate entered this state
+	Source string    `json:"source,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
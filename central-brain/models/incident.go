@@ -0,0 +1,73 @@
+package models
+
+import "time"
+
+// Incident status constants
+const (
+	IncidentOpen          = "OPEN"
+	IncidentAcknowledged  = "ACKNOWLEDGED"
+	IncidentResolved      = "RESOLVED"
+	IncidentFalsePositive = "FALSE_POSITIVE"
+	IncidentExpired       = "EXPIRED" // closed automatically after going idle while OPEN
+)
+
+// Severity levels, lowest first
+const (
+	SeverityLow      = "LOW"
+	SeverityMedium   = "MEDIUM"
+	SeverityHigh     = "HIGH"
+	SeverityCritical = "CRITICAL"
+)
+
+// SeverityRank orders severities; unknown or empty severities rank 0
+var SeverityRank = map[string]int{
+	SeverityLow:      1,
+	SeverityMedium:   2,
+	SeverityHigh:     3,
+	SeverityCritical: 4,
+}
+
+// Incident groups repeated detection pushes for the same tracked object
+type Incident struct {
+	ID                 string          `json:"id"`
+	CameraID           string          `json:"camera_id"`
+	ObjectID           int             `json:"object_id"`
+	ObjectClass        string          `json:"object_class"`
+	Type               string          `json:"type"`
+	Status             string          `json:"status"`
+	Severity           string          `json:"severity,omitempty"`
+	FirstSeen          time.Time       `json:"first_seen"`
+	LastSeen           time.Time       `json:"last_seen"`
+	PeakConfidence     float64         `json:"peak_confidence"`
+	MaxDurationSeconds float64         `json:"max_duration_seconds"`
+	DetectionCount     int             `json:"detection_count"`
+	ImageURL           string          `json:"image_url,omitempty"`
+	ClipURL            string          `json:"clip_url,omitempty"`
+	Train              *TrainApproach  `json:"train,omitempty"`            // train inbound when the severity was raised
+	EscalationLevel    int             `json:"escalation_level,omitempty"` // escalation steps taken while unacknowledged
+	AcknowledgedBy     string          `json:"acknowledged_by,omitempty"`
+	AcknowledgedAt     *time.Time      `json:"acknowledged_at,omitempty"`
+	ClosedBy           string          `json:"closed_by,omitempty"`
+	ClosedAt           *time.Time      `json:"closed_at,omitempty"`
+	Timeline           []IncidentEvent `json:"timeline,omitempty"`
+}
+
+// IncidentEvent is a single entry in an incident's timeline
+type IncidentEvent struct {
+	IncidentID string    `json:"incident_id"`
+	Action     string    `json:"action"`
+	Actor      string    `json:"actor,omitempty"`
+	Note       string    `json:"note,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// IncidentMessage is broadcast over WebSocket whenever an incident changes
+type IncidentMessage struct {
+	Type     string   `json:"type"`
+	Incident Incident `json:"incident"`
+}
+
+// IncidentActionRequest is the body for incident state transitions
+type IncidentActionRequest struct {
+	Note string `json:"note"`
+}
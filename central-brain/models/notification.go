@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Notification channels
+const (
+	ChannelWebhook  = "webhook"
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+)
+
+// Notification events
+const (
+	NotifyIncidentOpened = "INCIDENT_OPENED"
+	NotifySeverityRaised = "SEVERITY_RAISED"
+	NotifyEscalated      = "ESCALATED"
+	NotifyTest           = "TEST"
+)
+
+// Notification is an alert sent outside the dashboard
+type Notification struct {
+	Event       string         `json:"event"`
+	IncidentID  string         `json:"incident_id,omitempty"`
+	Type        string         `json:"type,omitempty"` // detection type of the incident
+	Severity    string         `json:"severity"`
+	ObjectClass string         `json:"object_class,omitempty"`
+	CameraID    string         `json:"camera_id,omitempty"`
+	PostID      string         `json:"post_id,omitempty"`
+	StationID   string         `json:"station_id,omitempty"`
+	Title       string         `json:"title"`
+	Message     string         `json:"message,omitempty"`
+	ImageURL    string         `json:"image_url,omitempty"`
+	Train       *TrainApproach `json:"train,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+	Routes      []string       `json:"-"` // routes chosen by detection rules, used besides matching ones
+	Timestamp   time.Time      `json:"timestamp"`
+}
+
+// NotificationRoute sends notifications matching its filters to one recipient.
+// Empty filters match everything.
+type NotificationRoute struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	Channel     string    `json:"channel"`   // webhook, email or telegram
+	Recipient   string    `json:"recipient"` // URL, email address or chat ID
+	MinSeverity string    `json:"min_severity,omitempty"`
+	PostIDs     []string  `json:"post_ids,omitempty"`
+	StationIDs  []string  `json:"station_ids,omitempty"`
+	Types       []string  `json:"types,omitempty"` // detection types
+	Disabled    bool      `json:"disabled,omitempty"`
+	CreatedBy   string    `json:"created_by,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// DeadLetter is a notification that failed every delivery attempt
+type DeadLetter struct {
+	ID           int64        `json:"id"`
+	RouteID      string       `json:"route_id"`
+	Channel      string       `json:"channel"`
+	Recipient    string       `json:"recipient"`
+	Notification Notification `json:"notification"`
+	Error        string       `json:"error"`
+	Attempts     int          `json:"attempts"`
+	CreatedAt    time.Time    `json:"created_at"`
+}
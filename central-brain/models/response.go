@@ -16,10 +16,11 @@ type ErrorInfo struct {
 
 // PaginatedResponse represents paginated data
 type PaginatedResponse struct {
-	Data   interface{} `json:"data"`
-	Total  int         `json:"total"`
-	Limit  int         `json:"limit"`
-	Offset int         `json:"offset"`
+	Data       interface{} `json:"data"`
+	Total      int         `json:"total"`
+	Limit      int         `json:"limit"`
+	Offset     int         `json:"offset"`
+	NextCursor string      `json:"next_cursor,omitempty"` // set for cursor-paginated endpoints
 }
 
 // HealthResponse represents health check response
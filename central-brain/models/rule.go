@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// GateUnknown matches detections at posts whose gate never reported a state
+const GateUnknown = "UNKNOWN"
+
+// RuleMatch selects detections. Every set condition must hold; zero values
+// match everything.
+type RuleMatch struct {
+	Types              []string `json:"types,omitempty"`
+	ObjectClasses      []string `json:"object_classes,omitempty"` // case-insensitive
+	CameraIDs          []string `json:"camera_ids,omitempty"`
+	PostIDs            []string `json:"post_ids,omitempty"`
+	InROI              *bool    `json:"in_roi,omitempty"`
+	MinDurationSeconds float64  `json:"min_duration_seconds,omitempty"`
+	MaxDurationSeconds float64  `json:"max_duration_seconds,omitempty"`
+	MinConfidence      float64  `json:"min_confidence,omitempty"`
+	MaxConfidence      float64  `json:"max_confidence,omitempty"`
+	TimeFrom           string   `json:"time_from,omitempty"`   // HH:MM server local time, inclusive
+	TimeTo             string   `json:"time_to,omitempty"`     // HH:MM, exclusive; before time_from wraps past midnight
+	GateStates         []string `json:"gate_states,omitempty"` // OPEN, CLOSING, CLOSED, FAULT or UNKNOWN
+	TrainInbound       *bool    `json:"train_inbound,omitempty"`
+	TrainWithinSeconds float64  `json:"train_within_seconds,omitempty"` // an inbound train at most this far away
+}
+
+// RuleActions are applied to matching detections
+type RuleActions struct {
+	Severity string   `json:"severity,omitempty"` // the first matching rule with a severity sets it
+	Suppress bool     `json:"suppress,omitempty"` // drop the detection before it is stored or broadcast
+	Tags     []string `json:"tags,omitempty"`
+	Routes   []string `json:"routes,omitempty"` // notification route IDs, regardless of their filters
+}
+
+// Rule classifies detections as they enter the pipeline
+type Rule struct {
+	ID        string      `json:"id"`
+	Name      string      `json:"name"`
+	Priority  int         `json:"priority"` // lower runs first
+	Disabled  bool        `json:"disabled,omitempty"`
+	Stop      bool        `json:"stop,omitempty"` // skip lower-priority rules after a match
+	Match     RuleMatch   `json:"match"`
+	Actions   RuleActions `json:"actions"`
+	CreatedBy string      `json:"created_by,omitempty"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// RuleResult is the outcome of running the rules on one detection
+type RuleResult struct {
+	Matched    []string `json:"matched"` // rule IDs, in evaluation order
+	Severity   string   `json:"severity,omitempty"`
+	Suppressed bool     `json:"suppressed,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+	Routes     []string `json:"routes,omitempty"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ServiceKey is a credential an AI engine uses to sign ingest requests
+type ServiceKey struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Secret    string     `json:"secret,omitempty"` // only returned when the key is issued
+	CameraIDs []string   `json:"camera_ids"`
+	CreatedBy string     `json:"created_by,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// ServiceKeyRequest is the body for issuing a new service key
+type ServiceKeyRequest struct {
+	Name      string   `json:"name"`
+	CameraIDs []string `json:"camera_ids"`
+}
+
+// AllowsCamera reports whether the key may publish for a camera
+func (k ServiceKey) AllowsCamera(cameraID string) bool {
+	for _, id := range k.CameraIDs {
+		if id == cameraID || id == "*" {
+			return true
+		}
+	}
+	return false
+}
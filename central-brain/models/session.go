@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// RefreshToken is the server-side record of an issued refresh token.
+// Only the SHA-256 of the token is stored; tokens issued by rotating one
+// another share a FamilyID.
+type RefreshToken struct {
+	ID         string     `json:"id"` // hex SHA-256 of the token
+	UserID     string     `json:"user_id"`
+	FamilyID   string     `json:"family_id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	ReplacedBy string     `json:"replaced_by,omitempty"`
+}
+
+// Token revocation kinds
+const (
+	RevokeToken = "token" // Subject is an access token ID (jti)
+	RevokeUser  = "user"  // Subject is a user ID; tokens issued before RevokedAt are invalid
+)
+
+// TokenRevocation invalidates access tokens before they expire.
+type TokenRevocation struct {
+	Kind      string    `json:"kind"`
+	Subject   string    `json:"subject"`
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"` // after this the entry can be forgotten
+}
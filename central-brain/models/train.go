@@ -0,0 +1,57 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Train event kinds sent by track circuits or axle counters
+const (
+	TrainApproaching = "APPROACHING"
+	TrainPassed      = "PASSED"
+)
+
+// Sources of a train approach
+const (
+	TrainSourceSignal    = "SIGNAL"    // an APPROACHING event from the track
+	TrainSourceTimetable = "TIMETABLE" // the post's imported timetable
+)
+
+// TimetableEntry is a scheduled pass of a train at a JPL post
+type TimetableEntry struct {
+	PostID  string `json:"post_id"`
+	TrainID string `json:"train_id"`
+	Time    string `json:"time"`           // HH:MM[:SS] server local time; hours past 23 belong to the previous service day, as in GTFS
+	Days    string `json:"days,omitempty"` // ISO weekdays the train runs (e.g. "12345"); empty means daily
+	Seconds int    `json:"-"`              // Time as seconds after midnight
+}
+
+// TrainEvent is a train approaching or passing a post's crossing
+type TrainEvent struct {
+	PostID     string    `json:"post_id"`
+	TrainID    string    `json:"train_id,omitempty"`
+	Event      string    `json:"event"`                 // APPROACHING or PASSED
+	ETASeconds float64   `json:"eta_seconds,omitempty"` // APPROACHING: seconds until the train reaches the crossing
+	Source     string    `json:"source,omitempty"`      // sensor that raised it, e.g. TRACK_CIRCUIT or AXLE_COUNTER
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// TrainApproach is the next train expected at a post
+type TrainApproach struct {
+	PostID     string    `json:"post_id"`
+	TrainID    string    `json:"train_id,omitempty"`
+	Source     string    `json:"source"` // SIGNAL or TIMETABLE
+	ExpectedAt time.Time `json:"expected_at"`
+	ETASeconds float64   `json:"eta_seconds"`
+	Inbound    bool      `json:"inbound"` // signalled, or due within the inbound window
+}
+
+// Describe formats the approach for incident timelines, e.g.
+// "Train KA-123 inbound at JPL-102, ETA 95s (SIGNAL)".
+func (a TrainApproach) Describe() string {
+	train := "Train"
+	if a.TrainID != "" {
+		train += " " + a.TrainID
+	}
+	return fmt.Sprintf("%s inbound at %s, ETA %.0fs (%s)", train, a.PostID, a.ETASeconds, a.Source)
+}
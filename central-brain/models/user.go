@@ -1,13 +1,41 @@
 package models
 
+import "time"
+
 // User represents a system user
 type User struct {
-	ID           string `json:"id"`
-	PasswordHash string `json:"-"` // Never expose in JSON
-	Role         string `json:"role"`
-	Name         string `json:"name"`
-	PostID       string `json:"post_id,omitempty"`
-	StationID    string `json:"station_id,omitempty"`
+	ID           string    `json:"id"`
+	PasswordHash string    `json:"-"` // Never expose in JSON
+	Role         string    `json:"role"`
+	Name         string    `json:"name"`
+	PostID       string    `json:"post_id,omitempty"`
+	StationID    string    `json:"station_id,omitempty"`
+	Disabled     bool      `json:"disabled"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// UserCreateRequest is the body of POST /api/users
+type UserCreateRequest struct {
+	ID        string `json:"id"`
+	Password  string `json:"password"`
+	Role      string `json:"role"`
+	Name      string `json:"name"`
+	PostID    string `json:"post_id,omitempty"`
+	StationID string `json:"station_id,omitempty"`
+}
+
+// UserUpdateRequest is the body of PUT /api/users/:id. Nil fields are left unchanged.
+type UserUpdateRequest struct {
+	Role      *string `json:"role,omitempty"`
+	Name      *string `json:"name,omitempty"`
+	PostID    *string `json:"post_id,omitempty"`
+	StationID *string `json:"station_id,omitempty"`
+}
+
+// PasswordResetRequest is the body of POST /api/users/:id/password
+type PasswordResetRequest struct {
+	Password string `json:"password"`
 }
 
 // LoginRequest represents login credentials
@@ -16,6 +44,18 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+// RefreshRequest is the body of POST /api/auth/refresh
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// LogoutRequest is the body of POST /api/auth/logout. All ends every
+// session of the caller and requires a valid access token.
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token,omitempty"`
+	All          bool   `json:"all,omitempty"`
+}
+
 // LoginResponse represents successful auth response
 type LoginResponse struct {
 	AccessToken  string   `json:"access_token"`
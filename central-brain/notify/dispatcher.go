@@ -0,0 +1,514 @@
+// Package notify sends incident alerts to people without a dashboard open:
+// webhooks, email and Telegram-style bots, chosen by routing rules.
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"central-brain/models"
+	"central-brain/services"
+)
+
+const (
+	queueSize    = 256
+	workers      = 8 // concurrent deliveries
+	maxAttempts  = 4
+	firstBackoff = 2 * time.Second
+	sendTimeout  = 15 * time.Second
+	// notifiedTTL is how long an incident's last notified severity is kept.
+	notifiedTTL = 24 * time.Hour
+)
+
+var (
+	ErrUnknownRoute   = errors.New("unknown notification route")
+	ErrUnknownChannel = errors.New("notification channel is not configured")
+	ErrInvalidRoute   = errors.New("invalid notification route")
+
+	errQueueFull = errors.New("notification queue full")
+)
+
+// Notifier delivers a notification to one recipient of its channel.
+type Notifier interface {
+	Channel() string
+	Send(ctx context.Context, recipient string, n models.Notification) error
+}
+
+// Store persists routes and undeliverable notifications.
+type Store interface {
+	ListNotificationRoutes(ctx context.Context) ([]models.NotificationRoute, error)
+	SaveNotificationRoute(ctx context.Context, r models.NotificationRoute) error
+	DeleteNotificationRoute(ctx context.Context, id string) error
+	InsertDeadLetter(ctx context.Context, dl models.DeadLetter) error
+	ListDeadLetters(ctx context.Context, limit int) ([]models.DeadLetter, error)
+}
+
+// delivery is one notification for one route.
+type delivery struct {
+	route models.NotificationRoute
+	n     models.Notification
+}
+
+type notified struct {
+	rank int
+	at   time.Time
+}
+
+// Dispatcher routes notifications to notifiers in the background, retrying
+// failed deliveries with backoff before dead-lettering them. A fixed pool of
+// workers sends, so a slow channel backs up the queue instead of piling up
+// goroutines.
+type Dispatcher struct {
+	store     Store
+	notifiers map[string]Notifier
+	queue     chan models.Notification
+	jobs      chan delivery
+	sleep     func(time.Duration) // waits between delivery attempts
+
+	mu       sync.Mutex
+	routes   map[string]models.NotificationRoute
+	notified map[string]notified // incident -> highest severity sent
+	pruned   time.Time
+}
+
+// NewDispatcher creates a dispatcher for the given notifiers. store may be nil.
+func NewDispatcher(store Store, notifiers ...Notifier) *Dispatcher {
+	d := &Dispatcher{
+		store:     store,
+		notifiers: make(map[string]Notifier),
+		queue:     make(chan models.Notification, queueSize),
+		jobs:      make(chan delivery, queueSize),
+		sleep:     time.Sleep,
+		routes:    make(map[string]models.NotificationRoute),
+		notified:  make(map[string]notified),
+	}
+	for _, n := range notifiers {
+		d.notifiers[n.Channel()] = n
+	}
+	return d
+}
+
+// Channels returns the configured channel names.
+func (d *Dispatcher) Channels() []string {
+	out := make([]string, 0, len(d.notifiers))
+	for ch := range d.notifiers {
+		out = append(out, ch)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Load restores the routes from the store.
+func (d *Dispatcher) Load(ctx context.Context) error {
+	if d.store == nil {
+		return nil
+	}
+	list, err := d.store.ListNotificationRoutes(ctx)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, r := range list {
+		d.routes[r.ID] = r
+	}
+	return nil
+}
+
+// Routes returns every route, oldest first.
+func (d *Dispatcher) Routes() []models.NotificationRoute {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]models.NotificationRoute, 0, len(d.routes))
+	for _, r := range d.routes {
+		out = append(out, r)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].CreatedAt.Equal(out[j].CreatedAt) {
+			return out[i].CreatedAt.Before(out[j].CreatedAt)
+		}
+		return out[i].ID < out[j].ID
+	})
+	return out
+}
+
+// SaveRoute creates a route when r.ID is empty and replaces it otherwise.
+func (d *Dispatcher) SaveRoute(ctx context.Context, r models.NotificationRoute) (models.NotificationRoute, error) {
+	if err := d.validate(r); err != nil {
+		return models.NotificationRoute{}, err
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if r.ID == "" {
+		id, err := randomHex(8)
+		if err != nil {
+			return models.NotificationRoute{}, err
+		}
+		r.ID = "rt_" + id
+		r.CreatedAt = time.Now().UTC()
+	} else {
+		prev, ok := d.routes[r.ID]
+		if !ok {
+			return models.NotificationRoute{}, ErrUnknownRoute
+		}
+		r.CreatedBy = prev.CreatedBy
+		r.CreatedAt = prev.CreatedAt
+	}
+	if d.store != nil {
+		if err := d.store.SaveNotificationRoute(ctx, r); err != nil {
+			return models.NotificationRoute{}, err
+		}
+	}
+	d.routes[r.ID] = r
+	return r, nil
+}
+
+// DeleteRoute removes a route.
+func (d *Dispatcher) DeleteRoute(ctx context.Context, id string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.routes[id]; !ok {
+		return ErrUnknownRoute
+	}
+	if d.store != nil {
+		if err := d.store.DeleteNotificationRoute(ctx, id); err != nil {
+			return err
+		}
+	}
+	delete(d.routes, id)
+	return nil
+}
+
+// DeadLetters returns the newest notifications that could not be delivered.
+func (d *Dispatcher) DeadLetters(ctx context.Context, limit int) ([]models.DeadLetter, error) {
+	if d.store == nil {
+		return nil, nil
+	}
+	return d.store.ListDeadLetters(ctx, limit)
+}
+
+func (d *Dispatcher) validate(r models.NotificationRoute) error {
+	if r.Name == "" || r.Recipient == "" {
+		return fmt.Errorf("%w: name and recipient are required", ErrInvalidRoute)
+	}
+	if !d.HasChannel(r.Channel) {
+		return fmt.Errorf("%w: %q (configured: %s)", ErrUnknownChannel, r.Channel, strings.Join(d.Channels(), ", "))
+	}
+	if err := CheckRecipient(r.Channel, r.Recipient); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRoute, err)
+	}
+	if _, ok := models.SeverityRank[r.MinSeverity]; r.MinSeverity != "" && !ok {
+		return fmt.Errorf("%w: min_severity must be LOW, MEDIUM, HIGH or CRITICAL", ErrInvalidRoute)
+	}
+	for _, postID := range r.PostIDs {
+		if !services.PostExists(postID) {
+			return fmt.Errorf("%w: unknown post %s", ErrInvalidRoute, postID)
+		}
+	}
+	for _, stationID := range r.StationIDs {
+		if !services.StationExists(stationID) {
+			return fmt.Errorf("%w: unknown station %s", ErrInvalidRoute, stationID)
+		}
+	}
+	return nil
+}
+
+// CheckRecipient reports whether recipient can be delivered to on channel:
+// an http(s) URL for webhooks and a mail address for email.
+func CheckRecipient(channel, recipient string) error {
+	switch channel {
+	case models.ChannelWebhook:
+		if _, err := webhookURL(recipient); err != nil {
+			return err
+		}
+	case models.ChannelEmail:
+		if _, err := mail.ParseAddress(recipient); err != nil {
+			return fmt.Errorf("email recipient %q: %v", recipient, err)
+		}
+	}
+	return nil
+}
+
+func webhookURL(recipient string) (*url.URL, error) {
+	u, err := url.Parse(recipient)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("webhook recipient %q must be an http(s) URL", recipient)
+	}
+	return u, nil
+}
+
+// NotifyIncident queues a notification for a new incident, or for one whose
+// severity rose since it was last notified. Repeated pushes of the same
+// incident are not notified again.
+func (d *Dispatcher) NotifyIncident(inc models.Incident, p models.DetectionPayload) {
+	severity := severityOf(inc.Severity)
+	rank := models.SeverityRank[severity]
+	now := time.Now()
+
+	d.mu.Lock()
+	prev, seen := d.notified[inc.ID]
+	if seen && rank <= prev.rank {
+		d.mu.Unlock()
+		return
+	}
+	d.notified[inc.ID] = notified{rank: rank, at: now}
+	if now.Sub(d.pruned) > time.Hour {
+		for id, v := range d.notified {
+			if now.Sub(v.at) > notifiedTTL {
+				delete(d.notified, id)
+			}
+		}
+		d.pruned = now
+	}
+	d.mu.Unlock()
+
+	event := models.NotifyIncidentOpened
+	if seen {
+		event = models.NotifySeverityRaised
+	}
+	n := IncidentNotification(event, inc, p.PostID)
+	n.Message = p.AdditionalDetail
+	n.Tags = p.Tags
+	n.Routes = p.Routes
+	d.Dispatch(n)
+}
+
+// IncidentNotification describes an incident. postID may be empty to look
+// it up from the incident's camera.
+func IncidentNotification(event string, inc models.Incident, postID string) models.Notification {
+	severity := severityOf(inc.Severity)
+	if postID == "" {
+		postID = services.CameraPostID(inc.CameraID)
+	}
+	where := postID
+	if where == "" {
+		where = inc.CameraID
+	}
+	return models.Notification{
+		Event:       event,
+		IncidentID:  inc.ID,
+		Type:        inc.Type,
+		Severity:    severity,
+		ObjectClass: inc.ObjectClass,
+		CameraID:    inc.CameraID,
+		PostID:      postID,
+		StationID:   services.StationForPost(postID),
+		Title:       fmt.Sprintf("%s: %s (%s) at %s", severity, inc.Type, inc.ObjectClass, where),
+		ImageURL:    inc.ImageURL,
+		Train:       inc.Train,
+		Timestamp:   inc.LastSeen,
+	}
+}
+
+// Dispatch queues a notification for every matching route without blocking.
+// While the queue is full the notification is stored as a dead letter for
+// each matching route instead.
+func (d *Dispatcher) Dispatch(n models.Notification) {
+	if n.Timestamp.IsZero() {
+		n.Timestamp = time.Now().UTC()
+	}
+	select {
+	case d.queue <- n:
+	default:
+		log.Printf("[NOTIFY] queue full, dead-lettering %s for %s", n.Event, n.IncidentID)
+		for _, r := range d.match(n) {
+			d.deadLetter(r, n, errQueueFull, 0)
+		}
+	}
+}
+
+// DispatchTo delivers n to one recipient in the background, with the same
+// retries and dead letters as routed notifications. source stands in for
+// the route ID in dead letters.
+func (d *Dispatcher) DispatchTo(source, channel, recipient string, n models.Notification) {
+	if n.Timestamp.IsZero() {
+		n.Timestamp = time.Now().UTC()
+	}
+	r := models.NotificationRoute{ID: source, Channel: channel, Recipient: recipient}
+	select {
+	case d.jobs <- delivery{route: r, n: n}:
+	default:
+		log.Printf("[NOTIFY] queue full, dead-lettering %s for %s", n.Event, n.IncidentID)
+		d.deadLetter(r, n, errQueueFull, 0)
+	}
+}
+
+// HasRoute reports whether a route exists.
+func (d *Dispatcher) HasRoute(id string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	_, ok := d.routes[id]
+	return ok
+}
+
+// HasChannel reports whether a notifier is configured for channel.
+func (d *Dispatcher) HasChannel(channel string) bool {
+	_, ok := d.notifiers[channel]
+	return ok
+}
+
+// Run delivers queued notifications until the process exits.
+func (d *Dispatcher) Run() {
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range d.jobs {
+				d.deliver(job.route, job.n)
+			}
+		}()
+	}
+	for n := range d.queue {
+		for _, r := range d.match(n) {
+			d.jobs <- delivery{route: r, n: n}
+		}
+	}
+}
+
+// Test sends a test notification through a route once and returns the
+// delivery error, if any.
+func (d *Dispatcher) Test(ctx context.Context, id string) error {
+	d.mu.Lock()
+	r, ok := d.routes[id]
+	d.mu.Unlock()
+	if !ok {
+		return ErrUnknownRoute
+	}
+	return d.send(ctx, r, models.Notification{
+		Event:     models.NotifyTest,
+		Severity:  models.SeverityLow,
+		Title:     "Test notification for route " + r.Name,
+		Message:   "This route is set up correctly.",
+		Timestamp: time.Now().UTC(),
+	})
+}
+
+func (d *Dispatcher) match(n models.Notification) []models.NotificationRoute {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []models.NotificationRoute
+	for _, r := range d.routes {
+		if r.Disabled {
+			continue
+		}
+		// Routes picked by a detection rule get the notification whatever their filters
+		if len(n.Routes) > 0 && allows(n.Routes, r.ID) {
+			out = append(out, r)
+			continue
+		}
+		if models.SeverityRank[n.Severity] < models.SeverityRank[severityOf(r.MinSeverity)] {
+			continue
+		}
+		if !allows(r.PostIDs, n.PostID) || !allows(r.StationIDs, n.StationID) || !allows(r.Types, n.Type) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// deliver sends n through r, doubling the wait between attempts, and stores
+// it as a dead letter when every attempt failed.
+func (d *Dispatcher) deliver(r models.NotificationRoute, n models.Notification) {
+	backoff := firstBackoff
+	var err error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+		err = d.send(ctx, r, n)
+		cancel()
+		if err == nil {
+			return
+		}
+		log.Printf("[NOTIFY] %s via %s to %s failed (attempt %d/%d): %v", n.IncidentID, r.Channel, r.Recipient, attempt, maxAttempts, err)
+		if attempt < maxAttempts {
+			d.sleep(backoff)
+			backoff *= 2
+		}
+	}
+	d.deadLetter(r, n, err, maxAttempts)
+}
+
+// deadLetter stores n as undeliverable through r after attempts tries.
+func (d *Dispatcher) deadLetter(r models.NotificationRoute, n models.Notification, cause error, attempts int) {
+	if d.store == nil {
+		log.Printf("[NOTIFY] no database, lost %s for %s via %s: %v", n.Event, n.IncidentID, r.Channel, cause)
+		return
+	}
+	dl := models.DeadLetter{
+		RouteID:      r.ID,
+		Channel:      r.Channel,
+		Recipient:    r.Recipient,
+		Notification: n,
+		Error:        cause.Error(),
+		Attempts:     attempts,
+		CreatedAt:    time.Now().UTC(),
+	}
+	if err := d.store.InsertDeadLetter(context.Background(), dl); err != nil {
+		log.Printf("[NOTIFY] failed to store dead letter: %v", err)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, r models.NotificationRoute, n models.Notification) error {
+	notifier, ok := d.notifiers[r.Channel]
+	if !ok {
+		return ErrUnknownChannel
+	}
+	return notifier.Send(ctx, r.Recipient, n)
+}
+
+// Text renders a notification as plain text for email and chat channels.
+func Text(n models.Notification) string {
+	var b strings.Builder
+	b.WriteString(n.Title)
+	if n.Message != "" {
+		b.WriteString("\n" + n.Message)
+	}
+	if n.Train != nil {
+		b.WriteString("\n" + n.Train.Describe())
+	}
+	if n.CameraID != "" {
+		b.WriteString("\nCamera: " + n.CameraID)
+	}
+	if n.IncidentID != "" {
+		b.WriteString("\nIncident: " + n.IncidentID)
+	}
+	b.WriteString("\nTime: " + n.Timestamp.UTC().Format(time.RFC3339))
+	return b.String()
+}
+
+// severityOf treats detections without a severity as LOW.
+func severityOf(severity string) string {
+	if severity == "" {
+		return models.SeverityLow
+	}
+	return severity
+}
+
+func allows(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
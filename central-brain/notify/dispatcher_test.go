@@ -0,0 +1,226 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"central-brain/models"
+)
+
+// memStore keeps routes and dead letters in memory.
+type memStore struct {
+	mu          sync.Mutex
+	deadLetters []models.DeadLetter
+}
+
+func (s *memStore) ListNotificationRoutes(ctx context.Context) ([]models.NotificationRoute, error) {
+	return nil, nil
+}
+
+func (s *memStore) SaveNotificationRoute(ctx context.Context, r models.NotificationRoute) error {
+	return nil
+}
+
+func (s *memStore) DeleteNotificationRoute(ctx context.Context, id string) error { return nil }
+
+func (s *memStore) InsertDeadLetter(ctx context.Context, dl models.DeadLetter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.deadLetters = append(s.deadLetters, dl)
+	return nil
+}
+
+func (s *memStore) ListDeadLetters(ctx context.Context, limit int) ([]models.DeadLetter, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.DeadLetter(nil), s.deadLetters...), nil
+}
+
+// flaky is a notifier that fails its first failures sends.
+type flaky struct {
+	mu       sync.Mutex
+	failures int
+	sends    int
+}
+
+func (f *flaky) Channel() string { return "flaky" }
+
+func (f *flaky) Send(ctx context.Context, recipient string, n models.Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sends++
+	if f.sends <= f.failures {
+		return errors.New("relay down")
+	}
+	return nil
+}
+
+// newTestDispatcher records the backoff waits instead of sleeping.
+func newTestDispatcher(store Store, notifiers ...Notifier) (*Dispatcher, *[]time.Duration) {
+	d := NewDispatcher(store, notifiers...)
+	var waits []time.Duration
+	d.sleep = func(wait time.Duration) { waits = append(waits, wait) }
+	return d, &waits
+}
+
+func TestDeliverRetriesWithBackoff(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int
+		sends       int
+		waits       []time.Duration
+		deadLetters int
+	}{
+		{name: "first attempt", failures: 0, sends: 1},
+		{name: "recovers", failures: 2, sends: 3, waits: []time.Duration{firstBackoff, 2 * firstBackoff}},
+		{name: "gives up", failures: maxAttempts, sends: maxAttempts, waits: []time.Duration{firstBackoff, 2 * firstBackoff, 4 * firstBackoff}, deadLetters: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &memStore{}
+			notifier := &flaky{failures: tt.failures}
+			d, waits := newTestDispatcher(store, notifier)
+			route := models.NotificationRoute{ID: "rt_1", Channel: "flaky", Recipient: "ops"}
+			d.deliver(route, models.Notification{Event: models.NotifyIncidentOpened, IncidentID: "inc_1"})
+
+			if notifier.sends != tt.sends {
+				t.Errorf("sends %d, want %d", notifier.sends, tt.sends)
+			}
+			if len(*waits) != len(tt.waits) {
+				t.Fatalf("waits %v, want %v", *waits, tt.waits)
+			}
+			for i := range tt.waits {
+				if (*waits)[i] != tt.waits[i] {
+					t.Errorf("wait %d: %v, want %v", i+1, (*waits)[i], tt.waits[i])
+				}
+			}
+			if len(store.deadLetters) != tt.deadLetters {
+				t.Fatalf("dead letters %d, want %d", len(store.deadLetters), tt.deadLetters)
+			}
+			if tt.deadLetters == 0 {
+				return
+			}
+			dl := store.deadLetters[0]
+			if dl.RouteID != "rt_1" || dl.Channel != "flaky" || dl.Recipient != "ops" ||
+				dl.Attempts != maxAttempts || dl.Error != "relay down" || dl.Notification.IncidentID != "inc_1" {
+				t.Errorf("dead letter %+v", dl)
+			}
+		})
+	}
+}
+
+func TestDeliverWebhookNon2xxIsDeadLettered(t *testing.T) {
+	var mu sync.Mutex
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	store := &memStore{}
+	d, waits := newTestDispatcher(store, NewWebhook(""))
+	d.deliver(models.NotificationRoute{ID: "rt_1", Channel: models.ChannelWebhook, Recipient: srv.URL}, models.Notification{IncidentID: "inc_1"})
+
+	if hits != maxAttempts {
+		t.Errorf("webhook hit %d times, want %d", hits, maxAttempts)
+	}
+	if len(*waits) != maxAttempts-1 {
+		t.Errorf("waits %v, want %d", *waits, maxAttempts-1)
+	}
+	if len(store.deadLetters) != 1 || !strings.Contains(store.deadLetters[0].Error, "503") {
+		t.Fatalf("dead letters %+v, want one with the 503 status", store.deadLetters)
+	}
+}
+
+func TestDispatchDeadLettersWhenQueueFull(t *testing.T) {
+	store := &memStore{}
+	d, _ := newTestDispatcher(store, NewWebhook(""))
+	if _, err := d.SaveRoute(context.Background(), models.NotificationRoute{
+		Name: "Control room", Channel: models.ChannelWebhook, Recipient: "http://127.0.0.1:1/hook",
+	}); err != nil {
+		t.Fatalf("save route: %v", err)
+	}
+
+	// Run is not started, so nothing drains the queue
+	for i := 0; i < queueSize; i++ {
+		d.Dispatch(models.Notification{Severity: models.SeverityHigh})
+	}
+	d.Dispatch(models.Notification{IncidentID: "inc_overflow", Severity: models.SeverityHigh})
+
+	if len(store.deadLetters) != 1 {
+		t.Fatalf("dead letters %+v, want the overflowed notification", store.deadLetters)
+	}
+	if dl := store.deadLetters[0]; dl.Notification.IncidentID != "inc_overflow" || dl.Attempts != 0 || dl.Error != errQueueFull.Error() {
+		t.Errorf("dead letter %+v", dl)
+	}
+}
+
+func TestRunDeliversThroughWorkers(t *testing.T) {
+	var mu sync.Mutex
+	hits := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		hits++
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	d, _ := newTestDispatcher(nil, NewWebhook(""))
+	if _, err := d.SaveRoute(context.Background(), models.NotificationRoute{Name: "Control room", Channel: models.ChannelWebhook, Recipient: srv.URL}); err != nil {
+		t.Fatalf("save route: %v", err)
+	}
+	go d.Run()
+	const sent = 3 * workers
+	for i := 0; i < sent; i++ {
+		d.Dispatch(models.Notification{Severity: models.SeverityHigh})
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		mu.Lock()
+		got := hits
+		mu.Unlock()
+		if got == sent {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivered %d, want %d", got, sent)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSaveRouteChecksRecipient(t *testing.T) {
+	d := NewDispatcher(nil, NewWebhook(""), NewEmail("127.0.0.1:1", "railguard@daop.test", "", ""))
+	tests := []struct {
+		channel   string
+		recipient string
+		ok        bool
+	}{
+		{models.ChannelWebhook, "https://ops.example/hooks/railguard", true},
+		{models.ChannelWebhook, "http://10.0.0.5:8080/hook", true},
+		{models.ChannelWebhook, "ftp://ops.example/hook", false},
+		{models.ChannelWebhook, "ops.example/hook", false},
+		{models.ChannelWebhook, "https://", false},
+		{models.ChannelEmail, "sm.jbg@daop.test", true},
+		{models.ChannelEmail, "sm.jbg", false},
+	}
+	for _, tt := range tests {
+		_, err := d.SaveRoute(context.Background(), models.NotificationRoute{Name: "r", Channel: tt.channel, Recipient: tt.recipient})
+		if tt.ok && err != nil {
+			t.Errorf("%s %q: %v", tt.channel, tt.recipient, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidRoute) {
+			t.Errorf("%s %q: error %v, want ErrInvalidRoute", tt.channel, tt.recipient, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package notify
+
+import (
+	"context"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"central-brain/models"
+)
+
+// Email sends notifications as plain-text mail through an SMTP relay.
+// STARTTLS is used when the server offers it.
+type Email struct {
+	Addr     string // host:port
+	From     string
+	Username string // optional, PLAIN auth
+	Password string
+}
+
+// NewEmail creates an email notifier for the relay at addr.
+func NewEmail(addr, from, username, password string) *Email {
+	return &Email{Addr: addr, From: from, Username: username, Password: password}
+}
+
+func (e *Email) Channel() string { return models.ChannelEmail }
+
+// Send mails n to recipient. smtp.SendMail takes no context, so a deadline
+// only applies before the connection is made.
+func (e *Email) Send(ctx context.Context, recipient string, n models.Notification) error {
+	if err := CheckRecipient(models.ChannelEmail, recipient); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var a smtp.Auth
+	if e.Username != "" {
+		host, _, _ := net.SplitHostPort(e.Addr)
+		a = smtp.PlainAuth("", e.Username, e.Password, host)
+	}
+	return smtp.SendMail(e.Addr, a, e.From, []string{recipient}, e.message(recipient, n))
+}
+
+func (e *Email) message(recipient string, n models.Notification) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + e.From + "\r\n")
+	b.WriteString("To: " + recipient + "\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", n.Title) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(Text(n), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
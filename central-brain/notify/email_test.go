@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"central-brain/models"
+)
+
+// smtpMessage is one mail received by the fake relay.
+type smtpMessage struct {
+	from, to, data string
+}
+
+// fakeSMTP accepts one mail per connection and answers every command with
+// success, or with reject for RCPT TO.
+func fakeSMTP(t *testing.T, reject bool) (string, <-chan smtpMessage) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	got := make(chan smtpMessage, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+		reply("220 relay.test ESMTP")
+
+		var msg smtpMessage
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.TrimSpace(line)
+			switch upper := strings.ToUpper(cmd); {
+			case strings.HasPrefix(upper, "EHLO"), strings.HasPrefix(upper, "HELO"):
+				reply("250 relay.test")
+			case strings.HasPrefix(upper, "MAIL FROM:"):
+				msg.from = cmd[len("MAIL FROM:"):]
+				reply("250 OK")
+			case strings.HasPrefix(upper, "RCPT TO:"):
+				if reject {
+					reply("550 no such user")
+					continue
+				}
+				msg.to = cmd[len("RCPT TO:"):]
+				reply("250 OK")
+			case upper == "DATA":
+				reply("354 go ahead")
+				var b strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					b.WriteString(l)
+				}
+				msg.data = b.String()
+				got <- msg
+				reply("250 queued")
+			case upper == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), got
+}
+
+func TestEmailSend(t *testing.T) {
+	addr, got := fakeSMTP(t, false)
+	e := NewEmail(addr, "railguard@daop.test", "", "")
+	n := models.Notification{Title: "CRITICAL: GATE_VIOLATION", IncidentID: "inc_1", CameraID: "cam1"}
+	if err := e.Send(context.Background(), "sm.jbg@daop.test", n); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	msg := <-got
+	if msg.from != "<railguard@daop.test>" || msg.to != "<sm.jbg@daop.test>" {
+		t.Errorf("envelope from %s to %s", msg.from, msg.to)
+	}
+	for _, want := range []string{"To: sm.jbg@daop.test\r\n", "Subject: CRITICAL: GATE_VIOLATION\r\n", "Camera: cam1\r\n", "Incident: inc_1\r\n"} {
+		if !strings.Contains(msg.data, want) {
+			t.Errorf("message lacks %q:\n%s", want, msg.data)
+		}
+	}
+}
+
+func TestEmailSendRejected(t *testing.T) {
+	addr, _ := fakeSMTP(t, true)
+	err := NewEmail(addr, "railguard@daop.test", "", "").Send(context.Background(), "nobody@daop.test", models.Notification{Title: "t"})
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("error %v, want the 550 rejection", err)
+	}
+}
+
+func TestEmailRejectsBadRecipient(t *testing.T) {
+	if err := NewEmail("127.0.0.1:1", "railguard@daop.test", "", "").Send(context.Background(), "not an address", models.Notification{}); err == nil {
+		t.Fatal("bad recipient accepted")
+	}
+}
@@ -0,0 +1,80 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"central-brain/models"
+)
+
+// DefaultTelegramURL is the Bot API base URL.
+const DefaultTelegramURL = "https://api.telegram.org"
+
+// Telegram sends notifications through a Telegram-style bot API: a JSON
+// POST of chat_id and text to {base}/bot{token}/sendMessage that answers
+// {"ok":true}. The recipient is the chat ID.
+type Telegram struct {
+	BaseURL string
+	Token   string
+	Client  *http.Client
+}
+
+// NewTelegram creates a bot notifier. An empty baseURL uses DefaultTelegramURL.
+func NewTelegram(baseURL, token string) *Telegram {
+	if baseURL == "" {
+		baseURL = DefaultTelegramURL
+	}
+	return &Telegram{
+		BaseURL: strings.TrimRight(baseURL, "/"),
+		Token:   token,
+		Client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (t *Telegram) Channel() string { return models.ChannelTelegram }
+
+// Send posts n to the chat.
+func (t *Telegram) Send(ctx context.Context, recipient string, n models.Notification) error {
+	body, err := json.Marshal(map[string]string{
+		"chat_id": recipient,
+		"text":    Text(n),
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.BaseURL+"/bot"+t.Token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		// The URL holds the token; keep it out of logs and dead letters
+		var uerr *url.Error
+		if errors.As(err, &uerr) {
+			return fmt.Errorf("telegram request failed: %v", uerr.Err)
+		}
+		return errors.New("telegram request failed")
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("telegram responded %s", resp.Status)
+	}
+	if !result.OK {
+		return fmt.Errorf("telegram responded %s: %s", resp.Status, result.Description)
+	}
+	return nil
+}
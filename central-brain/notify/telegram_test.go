@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"central-brain/models"
+)
+
+func TestTelegramSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		reply   string
+		wantErr string
+	}{
+		{name: "ok", status: http.StatusOK, reply: `{"ok":true}`},
+		{name: "rejected", status: http.StatusBadRequest, reply: `{"ok":false,"description":"chat not found"}`, wantErr: "chat not found"},
+		{name: "not json", status: http.StatusBadGateway, reply: `<html>bad gateway</html>`, wantErr: "502"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			var msg map[string]string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				_ = json.NewDecoder(r.Body).Decode(&msg)
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.reply))
+			}))
+			defer srv.Close()
+
+			tg := NewTelegram(srv.URL+"/", "123:abc")
+			err := tg.Send(context.Background(), "-100102", models.Notification{Title: "HIGH: GATE_FAULT", IncidentID: "inc_1"})
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("send: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Fatalf("error %v, want one containing %q", err, tt.wantErr)
+			}
+			if path != "/bot123:abc/sendMessage" {
+				t.Errorf("path %q", path)
+			}
+			if msg["chat_id"] != "-100102" || !strings.Contains(msg["text"], "HIGH: GATE_FAULT") || !strings.Contains(msg["text"], "Incident: inc_1") {
+				t.Errorf("message %v", msg)
+			}
+		})
+	}
+}
+
+func TestTelegramErrorHidesToken(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	url := srv.URL
+	srv.Close()
+
+	err := NewTelegram(url, "123:secret-token").Send(context.Background(), "-100102", models.Notification{})
+	if err == nil {
+		t.Fatal("send to a closed server succeeded")
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("error leaks the bot token: %v", err)
+	}
+}
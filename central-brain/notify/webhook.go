@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"central-brain/auth"
+	"central-brain/models"
+)
+
+// Webhook POSTs notifications as JSON to the route's URL. With a secret,
+// requests are signed like AI engine pushes (X-Timestamp, X-Signature).
+type Webhook struct {
+	Secret string
+	Client *http.Client
+}
+
+// NewWebhook creates a webhook notifier. secret may be empty.
+func NewWebhook(secret string) *Webhook {
+	return &Webhook{Secret: secret, Client: &http.Client{Timeout: 10 * time.Second}}
+}
+
+func (w *Webhook) Channel() string { return models.ChannelWebhook }
+
+// Send delivers n; any non-2xx response is an error.
+func (w *Webhook) Send(ctx context.Context, recipient string, n models.Notification) error {
+	u, err := webhookURL(recipient)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(n)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, recipient, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.Secret != "" {
+		nonce, err := auth.NewNonce()
+		if err != nil {
+			return err
+		}
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(auth.HeaderTimestamp, ts)
+		req.Header.Set(auth.HeaderNonce, nonce)
+		req.Header.Set(auth.HeaderSignature, auth.SignRequest(w.Secret, auth.SignedRequest{
+			Method:    http.MethodPost,
+			Path:      u.EscapedPath(),
+			Timestamp: ts,
+			Nonce:     nonce,
+			Header:    req.Header.Get,
+			Body:      body,
+		}))
+	}
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"central-brain/auth"
+	"central-brain/models"
+)
+
+func TestWebhookSend(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "accepted", status: http.StatusAccepted},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true},
+		{name: "not modified", status: http.StatusNotModified, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got models.Notification
+			var signature string
+			var signed auth.SignedRequest
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				_ = json.Unmarshal(body, &got)
+				signed = auth.SignedRequest{
+					Method:    r.Method,
+					Path:      r.URL.EscapedPath(),
+					Timestamp: r.Header.Get(auth.HeaderTimestamp),
+					Nonce:     r.Header.Get(auth.HeaderNonce),
+					Header:    r.Header.Get,
+					Body:      body,
+				}
+				signature = r.Header.Get(auth.HeaderSignature)
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			w := NewWebhook("s3cret")
+			err := w.Send(context.Background(), srv.URL+"/hooks/railguard", models.Notification{IncidentID: "inc_1", Title: "HIGH: OBSTACLE_STUCK"})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error %v, want error %v", err, tt.wantErr)
+			}
+			if got.IncidentID != "inc_1" || got.Title != "HIGH: OBSTACLE_STUCK" {
+				t.Errorf("posted %+v", got)
+			}
+			if signed.Path != "/hooks/railguard" || signed.Nonce == "" {
+				t.Errorf("signed path %q, nonce %q", signed.Path, signed.Nonce)
+			}
+			if want := auth.SignRequest("s3cret", signed); signature != want {
+				t.Errorf("signature %q, want %q", signature, want)
+			}
+		})
+	}
+}
+
+func TestWebhookRejectsNonHTTPRecipient(t *testing.T) {
+	if err := NewWebhook("").Send(context.Background(), "file:///etc/passwd", models.Notification{}); err == nil {
+		t.Fatal("file URL accepted")
+	}
+}
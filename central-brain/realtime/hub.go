@@ -3,53 +3,189 @@ package realtime
 import (
 	"encoding/json"
 	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gofiber/websocket/v2"
 )
 
+// SlowClientPolicy decides what happens when a client's send queue is full.
+type SlowClientPolicy int
+
+const (
+	// DropMessages discards the message for that client and counts it as dropped.
+	DropMessages SlowClientPolicy = iota
+	// DisconnectClient closes the connection of a client that cannot keep up.
+	DisconnectClient
+)
+
+// Options tunes per-client queuing and keepalive.
+type Options struct {
+	QueueSize  int              // messages buffered per client
+	Policy     SlowClientPolicy // action when the queue is full
+	MaxDropped uint64           // with DropMessages, disconnect after this many consecutive drops (0 = never)
+	WriteWait  time.Duration    // deadline for a single write
+	PongWait   time.Duration    // how long to wait for a pong before the client is considered dead
+}
+
+// DefaultOptions returns settings suited to dashboards on slow mobile links.
+func DefaultOptions() Options {
+	return Options{
+		QueueSize:  64,
+		Policy:     DropMessages,
+		MaxDropped: 256,
+		WriteWait:  10 * time.Second,
+		PongWait:   60 * time.Second,
+	}
+}
+
+// ClientStats reports queue counters for a single connection.
+type ClientStats struct {
+	ID          uint64    `json:"id"`
+	UserID      string    `json:"user_id"`
+	Role        string    `json:"role"`
+	RemoteAddr  string    `json:"remote_addr"`
+	ConnectedAt time.Time `json:"connected_at"`
+	Queued      int       `json:"queued"`
+	Sent        uint64    `json:"sent"`
+	Dropped     uint64    `json:"dropped"`
+}
+
+// Client is a websocket connection with its own bounded send queue and writer goroutine.
+type Client struct {
+	id          uint64
+	hub         *Hub
+	conn        *websocket.Conn
+	scope       Scope  // guarded by hub.mu; replaced when the client re-authenticates
+	scopeGen    uint64 // guarded by hub.mu; bumped by setScope
+	authorize   Authorizer
+	filter      *filter
+	send        chan []byte
+	done        chan struct{}
+	connectedAt time.Time
+	remoteAddr  string
+	sent        uint64 // atomic
+	dropped     uint64 // atomic
+	consecutive uint64 // consecutive drops, owned by Hub.Run
+}
+
 // Hub manages websocket clients and broadcasts detection events.
 type Hub struct {
-	clients    map[*websocket.Conn]bool
-	register   chan *websocket.Conn
-	unregister chan *websocket.Conn
-	broadcast  chan []byte
+	opts       Options
+	mu         sync.RWMutex
+	clients    map[*Client]bool
+	unregister chan *Client
+	broadcast  chan envelope
+	nextID     uint64
+}
+
+// envelope carries a marshalled message with the routing keys used for filtering.
+type envelope struct {
+	cameraID  string
+	eventType string
+	data      []byte
 }
 
-// NewHub creates a hub instance.
+// NewHub creates a hub instance with DefaultOptions.
 func NewHub() *Hub {
+	return NewHubWithOptions(DefaultOptions())
+}
+
+// NewHubWithOptions creates a hub instance with custom queue and keepalive settings.
+func NewHubWithOptions(opts Options) *Hub {
+	def := DefaultOptions()
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = def.QueueSize
+	}
+	if opts.WriteWait <= 0 {
+		opts.WriteWait = def.WriteWait
+	}
+	if opts.PongWait <= 0 {
+		opts.PongWait = def.PongWait
+	}
 	return &Hub{
-		clients:    make(map[*websocket.Conn]bool),
-		register:   make(chan *websocket.Conn),
-		unregister: make(chan *websocket.Conn),
-		broadcast:  make(chan []byte, 32),
+		opts:       opts,
+		clients:    make(map[*Client]bool),
+		unregister: make(chan *Client),
+		broadcast:  make(chan envelope, 32),
 	}
 }
 
-// Run listens for register/unregister/broadcast events.
+// Run listens for unregister/broadcast events. It never writes to a
+// socket itself, so one stalled client cannot hold up the others.
 func (h *Hub) Run() {
 	for {
 		select {
-		case conn := <-h.register:
-			h.clients[conn] = true
-		case conn := <-h.unregister:
-			if _, ok := h.clients[conn]; ok {
-				delete(h.clients, conn)
-				_ = conn.Close()
-			}
-		case msg := <-h.broadcast:
-			for conn := range h.clients {
-				if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
-					log.Printf("[WS] write error: %v", err)
-					delete(h.clients, conn)
-					_ = conn.Close()
+		case client := <-h.unregister:
+			h.remove(client)
+		case env := <-h.broadcast:
+			h.mu.RLock()
+			var slow []*Client
+			for client := range h.clients {
+				if !client.scope.Allows(env.cameraID) || !client.filter.matches(env.cameraID, env.eventType) {
+					continue
 				}
+				if !h.offer(client, env.data) {
+					slow = append(slow, client)
+				}
+			}
+			h.mu.RUnlock()
+			for _, client := range slow {
+				log.Printf("[WS] disconnecting slow client %d (%s), dropped=%d",
+					client.id, client.remoteAddr, atomic.LoadUint64(&client.dropped))
+				h.remove(client)
 			}
 		}
 	}
 }
 
-// BroadcastJSON marshals payload to JSON and sends to all clients.
+// offer queues msg for a client without blocking. It returns false when the
+// client should be disconnected under the configured policy.
+func (h *Hub) offer(client *Client, msg []byte) bool {
+	select {
+	case client.send <- msg:
+		client.consecutive = 0
+		return true
+	default:
+	}
+
+	atomic.AddUint64(&client.dropped, 1)
+	client.consecutive++
+	if h.opts.Policy == DisconnectClient {
+		return false
+	}
+	return h.opts.MaxDropped == 0 || client.consecutive < h.opts.MaxDropped
+}
+
+// add registers a client synchronously so messages can be queued to it right away.
+func (h *Hub) add(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.clients[client] = true
+}
+
+func (h *Hub) remove(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+// BroadcastJSON marshals payload to JSON and sends it to the clients that
+// see every camera.
 func (h *Hub) BroadcastJSON(v interface{}) {
+	h.Publish("", "", v)
+}
+
+// Publish marshals payload to JSON and sends it to clients whose scope covers
+// cameraID and whose subscriptions include both cameraID and eventType.
+// An empty eventType is not filtered on; an empty cameraID only reaches
+// clients that see every camera.
+func (h *Hub) Publish(cameraID, eventType string, v interface{}) {
 	if h == nil {
 		return
 	}
@@ -58,9 +194,134 @@ func (h *Hub) BroadcastJSON(v interface{}) {
 		log.Printf("[WS] marshal error: %v", err)
 		return
 	}
-	h.broadcast <- data
+	h.broadcast <- envelope{cameraID: cameraID, eventType: eventType, data: data}
 }
 
+// Stats returns per-client queue counters, ordered by connection ID.
+func (h *Hub) Stats() []ClientStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
+	out := make([]ClientStats, 0, len(h.clients))
+	for client := range h.clients {
+		out = append(out, ClientStats{
+			ID:          client.id,
+			UserID:      client.scope.UserID,
+			Role:        client.scope.Role,
+			RemoteAddr:  client.remoteAddr,
+			ConnectedAt: client.connectedAt,
+			Queued:      len(client.send),
+			Sent:        atomic.LoadUint64(&client.sent),
+			Dropped:     atomic.LoadUint64(&client.dropped),
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+func (h *Hub) newClient(conn *websocket.Conn, scope Scope, authorize Authorizer) *Client {
+	return &Client{
+		id:          atomic.AddUint64(&h.nextID, 1),
+		hub:         h,
+		conn:        conn,
+		scope:       scope,
+		authorize:   authorize,
+		filter:      newFilter(),
+		send:        make(chan []byte, h.opts.QueueSize),
+		done:        make(chan struct{}),
+		connectedAt: time.Now().UTC(),
+		remoteAddr:  conn.RemoteAddr().String(),
+	}
+}
+
+// currentScope returns the client's scope. Callers must not hold hub.mu.
+func (c *Client) currentScope() Scope {
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	return c.scope
+}
+
+func (c *Client) setScope(scope Scope) {
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	c.scope = scope
+	c.scopeGen++
+}
 
+// refreshScope re-resolves the client's scope. A scope replaced by
+// re-authentication meanwhile is kept.
+func (c *Client) refreshScope() (Scope, error) {
+	c.hub.mu.RLock()
+	scope, gen := c.scope, c.scopeGen
+	c.hub.mu.RUnlock()
+
+	fresh, err := scope.Refresh()
+	if err != nil {
+		return scope, err
+	}
+	c.hub.mu.Lock()
+	defer c.hub.mu.Unlock()
+	if c.scopeGen == gen {
+		c.scope = fresh
+	}
+	return c.scope, nil
+}
+
+// writePump drains the send queue and pings the client until the queue is
+// closed or a write fails. It is the only goroutine that writes to conn.
+// Before each ping the session is re-checked, so a revoked, expired or
+// disabled login does not keep receiving events, and the scope is resolved
+// again for cameras added to the user's post.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(c.hub.opts.PongWait * 9 / 10)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+		close(c.done)
+	}()
+
+	for {
+		select {
+		case msg, ok := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.hub.opts.WriteWait))
+			if !ok {
+				_ = c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				log.Printf("[WS] write error: %v", err)
+				return
+			}
+			atomic.AddUint64(&c.sent, 1)
+		case <-ticker.C:
+			if scope, err := c.refreshScope(); err != nil {
+				log.Printf("[WS] closing client %d (%s): session ended", c.id, scope.UserID)
+				WriteError(c.conn, "unauthorized", "Session ended; reconnect with a new token")
+				return
+			}
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.hub.opts.WriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
 
+// enqueue sends a message to this client only, dropping it if the queue is full.
+func (c *Client) enqueue(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		log.Printf("[WS] marshal error: %v", err)
+		return
+	}
+	c.hub.mu.RLock()
+	defer c.hub.mu.RUnlock()
+	if !c.hub.clients[c] {
+		return
+	}
+	select {
+	case c.send <- data:
+	default:
+		atomic.AddUint64(&c.dropped, 1)
+	}
+}
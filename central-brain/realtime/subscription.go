@@ -0,0 +1,144 @@
+package realtime
+
+import (
+	"sort"
+	"sync"
+)
+
+// Scope describes what an authenticated connection is allowed to receive.
+type Scope struct {
+	UserID  string
+	Role    string
+	Cameras map[string]bool // allowed camera IDs; ignored when AllCameras is set
+	// AllCameras grants every camera, including ones registered after connect.
+	AllCameras bool
+	// Recheck resolves the scope again, so cameras added to the user's post
+	// are picked up, and returns an error once the session behind the
+	// connection has ended: token expired or revoked, account disabled.
+	// nil never ends and never changes.
+	Recheck func() (Scope, error)
+}
+
+// Refresh returns the scope re-resolved for the same session, or an error
+// once the session has ended.
+func (s Scope) Refresh() (Scope, error) {
+	if s.Recheck == nil {
+		return s, nil
+	}
+	return s.Recheck()
+}
+
+// Allows reports whether the scope covers a camera. Events that are not tied
+// to a camera only reach scopes that cover every camera.
+func (s Scope) Allows(cameraID string) bool {
+	if cameraID == "" {
+		return s.AllCameras
+	}
+	return s.AllCameras || s.Cameras[cameraID]
+}
+
+// SubscriptionRequest is sent by clients to narrow or widen what they receive
+// within their scope. Empty lists leave that dimension unchanged.
+type SubscriptionRequest struct {
+	Type    string   `json:"type"` // "auth", "subscribe" or "unsubscribe"
+	Token   string   `json:"token,omitempty"`
+	Cameras []string `json:"cameras,omitempty"`
+	Events  []string `json:"events,omitempty"`
+}
+
+// filter is a client's camera and event subscription state. A nil include set
+// means "everything"; exclude is only consulted in that case.
+type filter struct {
+	mu             sync.RWMutex
+	cameras        map[string]bool
+	excludeCameras map[string]bool
+	events         map[string]bool
+	excludeEvents  map[string]bool
+}
+
+func newFilter() *filter {
+	return &filter{
+		excludeCameras: make(map[string]bool),
+		excludeEvents:  make(map[string]bool),
+	}
+}
+
+func (f *filter) matches(cameraID, eventType string) bool {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return matchSet(f.cameras, f.excludeCameras, cameraID) && matchSet(f.events, f.excludeEvents, eventType)
+}
+
+func (f *filter) subscribe(cameras, events []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.cameras = addToSet(f.cameras, f.excludeCameras, cameras)
+	f.events = addToSet(f.events, f.excludeEvents, events)
+}
+
+func (f *filter) unsubscribe(cameras, events []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	removeFromSet(f.cameras, f.excludeCameras, cameras)
+	removeFromSet(f.events, f.excludeEvents, events)
+}
+
+// snapshot describes the current filter for the client's benefit.
+func (f *filter) snapshot() map[string]interface{} {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return map[string]interface{}{
+		"type":            "subscriptions",
+		"cameras":         setKeys(f.cameras),
+		"exclude_cameras": setKeys(f.excludeCameras),
+		"events":          setKeys(f.events),
+		"exclude_events":  setKeys(f.excludeEvents),
+	}
+}
+
+// matchSet treats an empty key (event not tied to a camera/type) as always matching.
+func matchSet(include, exclude map[string]bool, key string) bool {
+	if key == "" {
+		return true
+	}
+	if include == nil {
+		return !exclude[key]
+	}
+	return include[key]
+}
+
+func addToSet(include, exclude map[string]bool, keys []string) map[string]bool {
+	if len(keys) == 0 {
+		return include
+	}
+	if include == nil {
+		include = make(map[string]bool)
+	}
+	for _, k := range keys {
+		include[k] = true
+		delete(exclude, k)
+	}
+	return include
+}
+
+func removeFromSet(include, exclude map[string]bool, keys []string) {
+	for _, k := range keys {
+		if include != nil {
+			delete(include, k)
+		} else {
+			exclude[k] = true
+		}
+	}
+}
+
+func setKeys(set map[string]bool) []string {
+	if set == nil {
+		return nil
+	}
+	out := make([]string, 0, len(set))
+	for k := range set {
+		out = append(out, k)
+	}
+	sort.Strings(out)
+	return out
+}
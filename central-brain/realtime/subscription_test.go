@@ -0,0 +1,49 @@
+package realtime
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestScopeAllows(t *testing.T) {
+	post := Scope{UserID: "JPL-102", Cameras: map[string]bool{"cam1": true}}
+	admin := Scope{UserID: "DAOP-7", AllCameras: true}
+	tests := []struct {
+		name   string
+		scope  Scope
+		camera string
+		want   bool
+	}{
+		{name: "own camera", scope: post, camera: "cam1", want: true},
+		{name: "other camera", scope: post, camera: "brn-cam1"},
+		{name: "no camera, scoped", scope: post, camera: ""},
+		{name: "no camera, all cameras", scope: admin, camera: "", want: true},
+		{name: "any camera, all cameras", scope: admin, camera: "brn-cam1", want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scope.Allows(tt.camera); got != tt.want {
+				t.Errorf("Allows(%q) = %v, want %v", tt.camera, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopeRefresh(t *testing.T) {
+	fixed := Scope{UserID: "anonymous", AllCameras: true}
+	if got, err := fixed.Refresh(); err != nil || !got.AllCameras {
+		t.Errorf("scope without Recheck: %+v, %v", got, err)
+	}
+
+	grown := Scope{UserID: "JPL-102", Cameras: map[string]bool{"cam1": true, "cam9": true}}
+	s := Scope{UserID: "JPL-102", Cameras: map[string]bool{"cam1": true}, Recheck: func() (Scope, error) { return grown, nil }}
+	if got, err := s.Refresh(); err != nil || !got.Allows("cam9") {
+		t.Errorf("refreshed scope %+v, %v; want cam9 added", got, err)
+	}
+
+	ended := errors.New("token revoked")
+	s.Recheck = func() (Scope, error) { return Scope{}, ended }
+	if _, err := s.Refresh(); !errors.Is(err, ended) {
+		t.Errorf("ended session: %v", err)
+	}
+}
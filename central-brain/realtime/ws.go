@@ -1,41 +1,196 @@
 package realtime
 
 import (
+	"encoding/json"
+	"errors"
+	"sort"
 	"time"
 
 	"github.com/gofiber/websocket/v2"
 )
 
-// WSHandler upgrades client connection and registers to hub.
-func WSHandler(hub *Hub) func(*websocket.Conn) {
+// maxMessageSize limits frames read from dashboards; they only send small control messages.
+const maxMessageSize = 4096
+
+// authWait is how long a client without a ?token= has to send its auth message.
+const authWait = 10 * time.Second
+
+// Authorizer turns a JWT into the scope of cameras a connection may receive.
+type Authorizer func(token string) (Scope, error)
+
+// WSHandler authenticates the client, registers it to the hub and applies
+// subscribe/unsubscribe requests until the connection closes.
+//
+// The JWT is taken from the "token" query parameter or, failing that, from a
+// first message of the form {"type":"auth","token":"..."}. The session is
+// re-checked on every ping; clients send another auth message with a
+// refreshed token before theirs expires to keep the connection.
+func WSHandler(hub *Hub, authorize Authorizer) func(*websocket.Conn) {
 	return func(c *websocket.Conn) {
-		hub.register <- c
+		c.SetReadLimit(maxMessageSize)
+
+		scope, ok := Authenticate(c, authorize)
+		if !ok {
+			return
+		}
+
+		client := hub.newClient(c, scope, authorize)
+		hub.add(client)
+		go client.writePump()
 		defer func() {
-			hub.unregister <- c
+			hub.unregister <- client
+			// The connection is recycled once this handler returns,
+			// so wait for the writer to finish with it.
+			<-client.done
 		}()
 
 		// Send initial hello payload
 		type helloPayload struct {
-			Type      string    `json:"type"`
-			Timestamp time.Time `json:"timestamp"`
-			Message   string    `json:"message"`
+			Type       string    `json:"type"`
+			Timestamp  time.Time `json:"timestamp"`
+			Message    string    `json:"message"`
+			UserID     string    `json:"user_id"`
+			Role       string    `json:"role"`
+			Cameras    []string  `json:"cameras,omitempty"`
+			AllCameras bool      `json:"all_cameras"`
 		}
 
-		_ = c.WriteJSON(helloPayload{
-			Type:      "welcome",
-			Timestamp: time.Now().UTC(),
-			Message:   "Connected to Aeon RailGuard WS",
+		client.enqueue(helloPayload{
+			Type:       "welcome",
+			Timestamp:  time.Now().UTC(),
+			Message:    "Connected to Aeon RailGuard WS",
+			UserID:     scope.UserID,
+			Role:       scope.Role,
+			Cameras:    setKeys(scope.Cameras),
+			AllCameras: scope.AllCameras,
+		})
+
+		// Keep connection alive; pongs extend the read deadline
+		_ = c.SetReadDeadline(time.Now().Add(hub.opts.PongWait))
+		c.SetPongHandler(func(string) error {
+			return c.SetReadDeadline(time.Now().Add(hub.opts.PongWait))
 		})
 
-		// Keep connection alive; discard incoming frames
 		for {
-			if _, _, err := c.ReadMessage(); err != nil {
+			_, msg, err := c.ReadMessage()
+			if err != nil {
 				break
 			}
+			client.handleMessage(msg)
 		}
 	}
 }
 
+// Authenticate resolves the connection's scope from ?token= or a first
+// {"type":"auth"} message. On failure an error message is written and false
+// is returned.
+func Authenticate(c *websocket.Conn, authorize Authorizer) (Scope, bool) {
+	token := c.Query("token")
+	if token == "" {
+		// Some deployments (demo mode) accept anonymous clients.
+		if scope, err := authorize(""); err == nil {
+			return scope, true
+		}
+		_ = c.SetReadDeadline(time.Now().Add(authWait))
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			return Scope{}, false
+		}
+		var req SubscriptionRequest
+		if err := json.Unmarshal(msg, &req); err != nil || req.Type != "auth" {
+			WriteError(c, "unauthorized", "First message must be {\"type\":\"auth\",\"token\":\"...\"}")
+			return Scope{}, false
+		}
+		token = req.Token
+	}
+
+	scope, err := authorize(token)
+	if err != nil {
+		WriteError(c, "unauthorized", "Invalid or expired token")
+		return Scope{}, false
+	}
+	return scope, true
+}
+
+// Reauthenticate resolves a refreshed token sent on an open connection. The
+// token must belong to the connection's user; the returned scope replaces
+// the current one.
+func Reauthenticate(current Scope, authorize Authorizer, token string) (Scope, error) {
+	scope, err := authorize(token)
+	if err != nil || token == "" {
+		return Scope{}, errors.New("invalid or expired token")
+	}
+	if scope.UserID != current.UserID {
+		return Scope{}, errors.New("token belongs to another user")
+	}
+	return scope, nil
+}
+
+// handleMessage applies an auth or subscribe/unsubscribe request and echoes the result.
+func (c *Client) handleMessage(msg []byte) {
+	var req SubscriptionRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		c.enqueue(errorPayload("bad_request", "Invalid JSON message"))
+		return
+	}
 
+	switch req.Type {
+	case "auth":
+		scope, err := Reauthenticate(c.currentScope(), c.authorize, req.Token)
+		if err != nil {
+			c.enqueue(errorPayload("unauthorized", err.Error()))
+			return
+		}
+		c.setScope(scope)
+		c.enqueue(map[string]interface{}{
+			"type":    "authenticated",
+			"user_id": scope.UserID,
+		})
+		return
+	case "subscribe":
+		scope := c.currentScope()
+		var denied []string
+		allowed := make([]string, 0, len(req.Cameras))
+		for _, cam := range req.Cameras {
+			if scope.Allows(cam) {
+				allowed = append(allowed, cam)
+			} else {
+				denied = append(denied, cam)
+			}
+		}
+		if len(denied) > 0 {
+			sort.Strings(denied)
+			c.enqueue(map[string]interface{}{
+				"type":    "error",
+				"error":   "forbidden",
+				"message": "Cameras outside your scope were ignored",
+				"cameras": denied,
+			})
+		}
+		if len(req.Cameras) > 0 && len(allowed) == 0 && len(req.Events) == 0 {
+			return
+		}
+		c.filter.subscribe(allowed, req.Events)
+	case "unsubscribe":
+		c.filter.unsubscribe(req.Cameras, req.Events)
+	default:
+		c.enqueue(errorPayload("bad_request", "Unknown message type: "+req.Type))
+		return
+	}
+	c.enqueue(c.filter.snapshot())
+}
 
+func errorPayload(code, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"type":    "error",
+		"error":   code,
+		"message": message,
+	}
+}
 
+// WriteError sends an error payload and closes the connection with a policy violation.
+func WriteError(c *websocket.Conn, code, message string) {
+	_ = c.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	_ = c.WriteJSON(errorPayload(code, message))
+	_ = c.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, code))
+}
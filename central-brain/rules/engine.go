@@ -0,0 +1,360 @@
+// Package rules classifies detections as they enter the pipeline: rules set
+// their severity, suppress them, tag them or send them to notification routes.
+package rules
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"central-brain/models"
+)
+
+var (
+	ErrUnknownRule = errors.New("unknown detection rule")
+	ErrInvalidRule = errors.New("invalid detection rule")
+)
+
+// Store persists rules.
+type Store interface {
+	ListRules(ctx context.Context) ([]models.Rule, error)
+	SaveRule(ctx context.Context, r models.Rule) error
+	DeleteRule(ctx context.Context, id string) error
+}
+
+// Context is what a rule may know about a detection besides its payload.
+type Context struct {
+	Gate  *models.GateStatus    // nil when the post's gate never reported
+	Train *models.TrainApproach // next train at the post, nil without one
+}
+
+// Engine holds the active rule set. Changes replace the set as a whole, so
+// evaluation never sees a half-applied update and needs no restart.
+type Engine struct {
+	store Store
+	// RouteExists validates rule routes; nil accepts any ID.
+	RouteExists func(id string) bool
+
+	mu    sync.RWMutex
+	rules []models.Rule // evaluation order; replaced, never modified in place
+}
+
+// NewEngine creates an engine. store may be nil.
+func NewEngine(store Store) *Engine {
+	return &Engine{store: store}
+}
+
+// Load replaces the active rules with the stored ones. It is also how rules
+// changed outside this process are picked up.
+func (e *Engine) Load(ctx context.Context) error {
+	if e.store == nil {
+		return nil
+	}
+	list, err := e.store.ListRules(ctx)
+	if err != nil {
+		return err
+	}
+	sortRules(list)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = list
+	return nil
+}
+
+// Rules returns the active rules in evaluation order.
+func (e *Engine) Rules() []models.Rule {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return append([]models.Rule(nil), e.rules...)
+}
+
+// Save creates a rule when r.ID is empty and replaces it otherwise. It takes
+// effect for the next detection.
+func (e *Engine) Save(ctx context.Context, r models.Rule) (models.Rule, error) {
+	if err := e.Validate(r); err != nil {
+		return models.Rule{}, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now().UTC()
+	idx := -1
+	if r.ID == "" {
+		id, err := randomHex(8)
+		if err != nil {
+			return models.Rule{}, err
+		}
+		r.ID = "rule_" + id
+		r.CreatedAt = now
+	} else {
+		for i, prev := range e.rules {
+			if prev.ID == r.ID {
+				idx = i
+				r.CreatedBy = prev.CreatedBy
+				r.CreatedAt = prev.CreatedAt
+			}
+		}
+		if idx < 0 {
+			return models.Rule{}, ErrUnknownRule
+		}
+	}
+	r.UpdatedAt = now
+	if e.store != nil {
+		if err := e.store.SaveRule(ctx, r); err != nil {
+			return models.Rule{}, err
+		}
+	}
+
+	next := append([]models.Rule(nil), e.rules...)
+	if idx >= 0 {
+		next[idx] = r
+	} else {
+		next = append(next, r)
+	}
+	sortRules(next)
+	e.rules = next
+	return r, nil
+}
+
+// Delete removes a rule.
+func (e *Engine) Delete(ctx context.Context, id string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	next := make([]models.Rule, 0, len(e.rules))
+	for _, r := range e.rules {
+		if r.ID != id {
+			next = append(next, r)
+		}
+	}
+	if len(next) == len(e.rules) {
+		return ErrUnknownRule
+	}
+	if e.store != nil {
+		if err := e.store.DeleteRule(ctx, id); err != nil {
+			return err
+		}
+	}
+	e.rules = next
+	return nil
+}
+
+// Evaluate runs the active rules on a detection.
+func (e *Engine) Evaluate(p models.DetectionPayload, c Context) models.RuleResult {
+	e.mu.RLock()
+	list := e.rules
+	e.mu.RUnlock()
+	return Evaluate(list, p, c)
+}
+
+// Validate checks a rule before it is saved or dry-run.
+func (e *Engine) Validate(r models.Rule) error {
+	if r.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidRule)
+	}
+	m, a := r.Match, r.Actions
+	if a.Severity == "" && !a.Suppress && len(a.Tags) == 0 && len(a.Routes) == 0 {
+		return fmt.Errorf("%w: actions need a severity, suppress, tags or routes", ErrInvalidRule)
+	}
+	if _, ok := models.SeverityRank[a.Severity]; a.Severity != "" && !ok {
+		return fmt.Errorf("%w: severity must be LOW, MEDIUM, HIGH or CRITICAL", ErrInvalidRule)
+	}
+	if e.RouteExists != nil {
+		for _, id := range a.Routes {
+			if !e.RouteExists(id) {
+				return fmt.Errorf("%w: unknown notification route %s", ErrInvalidRule, id)
+			}
+		}
+	}
+	if (m.TimeFrom == "") != (m.TimeTo == "") {
+		return fmt.Errorf("%w: time_from and time_to go together", ErrInvalidRule)
+	}
+	if m.TimeFrom != "" {
+		if _, err := minuteOfDay(m.TimeFrom); err != nil {
+			return fmt.Errorf("%w: time_from: %v", ErrInvalidRule, err)
+		}
+		if _, err := minuteOfDay(m.TimeTo); err != nil {
+			return fmt.Errorf("%w: time_to: %v", ErrInvalidRule, err)
+		}
+	}
+	for _, s := range m.GateStates {
+		switch s {
+		case models.GateOpen, models.GateClosing, models.GateClosed, models.GateFault, models.GateUnknown:
+		default:
+			return fmt.Errorf("%w: gate_states may only list OPEN, CLOSING, CLOSED, FAULT or UNKNOWN", ErrInvalidRule)
+		}
+	}
+	if m.MinDurationSeconds < 0 || m.MaxDurationSeconds < 0 || m.MinConfidence < 0 || m.MaxConfidence < 0 || m.TrainWithinSeconds < 0 {
+		return fmt.Errorf("%w: thresholds must not be negative", ErrInvalidRule)
+	}
+	return nil
+}
+
+// Evaluate runs rules, in order, on a detection. Tags and routes of every
+// matching rule are collected; the first matching rule with a severity sets
+// it, and a matching rule with stop ends the run.
+func Evaluate(list []models.Rule, p models.DetectionPayload, c Context) models.RuleResult {
+	res := models.RuleResult{Matched: []string{}}
+	for _, r := range list {
+		if r.Disabled || !matches(r.Match, p, c) {
+			continue
+		}
+		res.Matched = append(res.Matched, r.ID)
+		if res.Severity == "" {
+			res.Severity = r.Actions.Severity
+		}
+		if r.Actions.Suppress {
+			res.Suppressed = true
+		}
+		res.Tags = appendUnique(res.Tags, r.Actions.Tags...)
+		res.Routes = appendUnique(res.Routes, r.Actions.Routes...)
+		if r.Stop {
+			break
+		}
+	}
+	return res
+}
+
+// Apply copies a result onto the detection.
+func Apply(p *models.DetectionPayload, res models.RuleResult) {
+	if res.Severity != "" {
+		p.Severity = res.Severity
+	}
+	p.Suppressed = p.Suppressed || res.Suppressed
+	p.Tags = appendUnique(p.Tags, res.Tags...)
+	p.Routes = appendUnique(p.Routes, res.Routes...)
+}
+
+func matches(m models.RuleMatch, p models.DetectionPayload, c Context) bool {
+	if !listed(m.Types, p.Type) || !listed(m.CameraIDs, p.CameraID) || !listed(m.PostIDs, p.PostID) {
+		return false
+	}
+	if len(m.ObjectClasses) > 0 && !listedFold(m.ObjectClasses, p.ObjectClass) {
+		return false
+	}
+	if m.InROI != nil && *m.InROI != p.InROI {
+		return false
+	}
+	if (m.MinDurationSeconds > 0 && p.DurationSeconds < m.MinDurationSeconds) ||
+		(m.MaxDurationSeconds > 0 && p.DurationSeconds > m.MaxDurationSeconds) {
+		return false
+	}
+	if (m.MinConfidence > 0 && p.Confidence < m.MinConfidence) ||
+		(m.MaxConfidence > 0 && p.Confidence > m.MaxConfidence) {
+		return false
+	}
+	if m.TimeFrom != "" && !inWindow(m.TimeFrom, m.TimeTo, p.Timestamp) {
+		return false
+	}
+	if len(m.GateStates) > 0 {
+		state := models.GateUnknown
+		if c.Gate != nil {
+			state = c.Gate.State
+		}
+		if !listed(m.GateStates, state) {
+			return false
+		}
+	}
+	inbound := c.Train != nil && c.Train.Inbound
+	if m.TrainInbound != nil && *m.TrainInbound != inbound {
+		return false
+	}
+	if m.TrainWithinSeconds > 0 && (!inbound || c.Train.ETASeconds > m.TrainWithinSeconds) {
+		return false
+	}
+	return true
+}
+
+// inWindow reports whether t's local time of day is in [from, to). A window
+// whose end is before its start runs past midnight.
+func inWindow(from, to string, t time.Time) bool {
+	start, err1 := minuteOfDay(from)
+	end, err2 := minuteOfDay(to)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	local := t.Local()
+	now := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// minuteOfDay parses HH:MM.
+func minuteOfDay(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	hh, err1 := strconv.Atoi(h)
+	mm, err2 := strconv.Atoi(m)
+	if !ok || err1 != nil || err2 != nil || hh < 0 || hh > 24 || mm < 0 || mm > 59 || (hh == 24 && mm != 0) {
+		return 0, fmt.Errorf("%q must be HH:MM", s)
+	}
+	return hh*60 + mm, nil
+}
+
+// Sorted returns a copy of list in evaluation order.
+func Sorted(list []models.Rule) []models.Rule {
+	out := append([]models.Rule(nil), list...)
+	sortRules(out)
+	return out
+}
+
+func sortRules(list []models.Rule) {
+	sort.SliceStable(list, func(i, j int) bool {
+		if list[i].Priority != list[j].Priority {
+			return list[i].Priority < list[j].Priority
+		}
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+}
+
+func listed(list []string, v string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, s := range list {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+func listedFold(list []string, v string) bool {
+	for _, s := range list {
+		if strings.EqualFold(s, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, s := range list {
+			if s == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
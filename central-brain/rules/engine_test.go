@@ -0,0 +1,219 @@
+package rules
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"central-brain/models"
+)
+
+func TestMinuteOfDay(t *testing.T) {
+	tests := []struct {
+		in   string
+		want int
+		ok   bool
+	}{
+		{"00:00", 0, true},
+		{"06:30", 390, true},
+		{"23:59", 1439, true},
+		{"24:00", 1440, true},
+		{"7:05", 425, true},
+		{"24:01", 0, false},
+		{"25:00", 0, false},
+		{"12:60", 0, false},
+		{"-1:00", 0, false},
+		{"1200", 0, false},
+		{"ab:cd", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := minuteOfDay(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("minuteOfDay(%q) = %d, %v; want %d, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestInWindow(t *testing.T) {
+	at := func(h, m int) time.Time { return time.Date(2026, 3, 1, h, m, 0, 0, time.Local) }
+	tests := []struct {
+		name     string
+		from, to string
+		t        time.Time
+		want     bool
+	}{
+		{"inside", "08:00", "17:00", at(12, 0), true},
+		{"start is inclusive", "08:00", "17:00", at(8, 0), true},
+		{"end is exclusive", "08:00", "17:00", at(17, 0), false},
+		{"before", "08:00", "17:00", at(7, 59), false},
+		{"overnight, evening", "22:00", "05:00", at(23, 30), true},
+		{"overnight, early morning", "22:00", "05:00", at(4, 59), true},
+		{"overnight, daytime", "22:00", "05:00", at(12, 0), false},
+		{"overnight, end", "22:00", "05:00", at(5, 0), false},
+		{"until midnight", "18:00", "24:00", at(23, 59), true},
+		{"empty window", "08:00", "08:00", at(8, 0), false},
+		{"invalid bound", "08:00", "late", at(12, 0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inWindow(tt.from, tt.to, tt.t); got != tt.want {
+				t.Errorf("inWindow(%s, %s, %s) = %v, want %v", tt.from, tt.to, tt.t.Format("15:04"), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	yes, no := true, false
+	night := time.Date(2026, 3, 1, 23, 0, 0, 0, time.Local)
+	day := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	stuck := models.DetectionPayload{
+		Type: models.DetectionObstacleStuck, ObjectClass: "car", CameraID: "cam1", PostID: "JPL-102",
+		InROI: true, DurationSeconds: 12, Confidence: 0.8, Timestamp: day,
+	}
+	closed := &models.GateStatus{State: models.GateClosed}
+	train := &models.TrainApproach{Inbound: true, ETASeconds: 90}
+
+	tests := []struct {
+		name  string
+		rules []models.Rule
+		p     models.DetectionPayload
+		c     Context
+		want  models.RuleResult
+	}{
+		{
+			name: "no rules",
+			p:    stuck,
+			want: models.RuleResult{Matched: []string{}},
+		},
+		{
+			name: "first severity wins, tags and routes collect",
+			rules: []models.Rule{
+				{ID: "r1", Match: models.RuleMatch{Types: []string{models.DetectionObstacleStuck}}, Actions: models.RuleActions{Severity: models.SeverityHigh, Tags: []string{"stuck"}}},
+				{ID: "r2", Match: models.RuleMatch{ObjectClasses: []string{"CAR"}}, Actions: models.RuleActions{Severity: models.SeverityLow, Tags: []string{"stuck", "vehicle"}, Routes: []string{"rt_1"}}},
+			},
+			p:    stuck,
+			want: models.RuleResult{Matched: []string{"r1", "r2"}, Severity: models.SeverityHigh, Tags: []string{"stuck", "vehicle"}, Routes: []string{"rt_1"}},
+		},
+		{
+			name: "stop ends the run",
+			rules: []models.Rule{
+				{ID: "r1", Stop: true, Actions: models.RuleActions{Tags: []string{"first"}}},
+				{ID: "r2", Actions: models.RuleActions{Suppress: true}},
+			},
+			p:    stuck,
+			want: models.RuleResult{Matched: []string{"r1"}, Tags: []string{"first"}},
+		},
+		{
+			name: "disabled and non-matching rules are skipped",
+			rules: []models.Rule{
+				{ID: "off", Disabled: true, Actions: models.RuleActions{Suppress: true}},
+				{ID: "other-post", Match: models.RuleMatch{PostIDs: []string{"JPL-98"}}, Actions: models.RuleActions{Suppress: true}},
+				{ID: "outside-roi", Match: models.RuleMatch{InROI: &no}, Actions: models.RuleActions{Suppress: true}},
+				{ID: "short", Match: models.RuleMatch{MaxDurationSeconds: 5}, Actions: models.RuleActions{Suppress: true}},
+				{ID: "unsure", Match: models.RuleMatch{MaxConfidence: 0.5}, Actions: models.RuleActions{Suppress: true}},
+			},
+			p:    stuck,
+			want: models.RuleResult{Matched: []string{}},
+		},
+		{
+			name: "suppress low-confidence detections",
+			rules: []models.Rule{
+				{ID: "r1", Match: models.RuleMatch{MaxConfidence: 0.5}, Actions: models.RuleActions{Suppress: true}},
+			},
+			p:    models.DetectionPayload{Type: models.DetectionObstacleStuck, Confidence: 0.3, Timestamp: day},
+			want: models.RuleResult{Matched: []string{"r1"}, Suppressed: true},
+		},
+		{
+			name: "time window",
+			rules: []models.Rule{
+				{ID: "night", Match: models.RuleMatch{TimeFrom: "22:00", TimeTo: "05:00"}, Actions: models.RuleActions{Tags: []string{"night"}}},
+			},
+			p:    func() models.DetectionPayload { p := stuck; p.Timestamp = night; return p }(),
+			want: models.RuleResult{Matched: []string{"night"}, Tags: []string{"night"}},
+		},
+		{
+			name: "outside time window",
+			rules: []models.Rule{
+				{ID: "night", Match: models.RuleMatch{TimeFrom: "22:00", TimeTo: "05:00"}, Actions: models.RuleActions{Tags: []string{"night"}}},
+			},
+			p:    stuck,
+			want: models.RuleResult{Matched: []string{}},
+		},
+		{
+			name: "gate and train context",
+			rules: []models.Rule{
+				{ID: "closed", Match: models.RuleMatch{GateStates: []string{models.GateClosed}}, Actions: models.RuleActions{Tags: []string{"gate-closed"}}},
+				{ID: "inbound", Match: models.RuleMatch{TrainInbound: &yes, TrainWithinSeconds: 120}, Actions: models.RuleActions{Severity: models.SeverityCritical}},
+				{ID: "too-far", Match: models.RuleMatch{TrainWithinSeconds: 60}, Actions: models.RuleActions{Tags: []string{"imminent"}}},
+			},
+			p:    stuck,
+			c:    Context{Gate: closed, Train: train},
+			want: models.RuleResult{Matched: []string{"closed", "inbound"}, Severity: models.SeverityCritical, Tags: []string{"gate-closed"}},
+		},
+		{
+			name: "unreported gate is UNKNOWN, no train is not inbound",
+			rules: []models.Rule{
+				{ID: "unknown", Match: models.RuleMatch{GateStates: []string{models.GateUnknown}}, Actions: models.RuleActions{Tags: []string{"no-gate"}}},
+				{ID: "quiet", Match: models.RuleMatch{TrainInbound: &no}, Actions: models.RuleActions{Severity: models.SeverityLow}},
+				{ID: "inbound", Match: models.RuleMatch{TrainWithinSeconds: 600}, Actions: models.RuleActions{Severity: models.SeverityCritical}},
+			},
+			p:    stuck,
+			want: models.RuleResult{Matched: []string{"unknown", "quiet"}, Severity: models.SeverityLow, Tags: []string{"no-gate"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Evaluate(tt.rules, tt.p, tt.c)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Evaluate = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply(t *testing.T) {
+	p := models.DetectionPayload{Severity: models.SeverityLow, Tags: []string{"a"}}
+	Apply(&p, models.RuleResult{Severity: models.SeverityHigh, Suppressed: true, Tags: []string{"a", "b"}, Routes: []string{"rt_1"}})
+	if p.Severity != models.SeverityHigh || !p.Suppressed || !reflect.DeepEqual(p.Tags, []string{"a", "b"}) || !reflect.DeepEqual(p.Routes, []string{"rt_1"}) {
+		t.Errorf("applied %+v", p)
+	}
+
+	// A result without a severity keeps the detection's own
+	Apply(&p, models.RuleResult{})
+	if p.Severity != models.SeverityHigh || !p.Suppressed {
+		t.Errorf("empty result changed %+v", p)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	e := NewEngine(nil)
+	e.RouteExists = func(id string) bool { return id == "rt_1" }
+	tag := models.RuleActions{Tags: []string{"x"}}
+	tests := []struct {
+		name string
+		rule models.Rule
+		ok   bool
+	}{
+		{"valid", models.Rule{Name: "n", Actions: tag}, true},
+		{"no name", models.Rule{Actions: tag}, false},
+		{"no actions", models.Rule{Name: "n"}, false},
+		{"bad severity", models.Rule{Name: "n", Actions: models.RuleActions{Severity: "URGENT"}}, false},
+		{"unknown route", models.Rule{Name: "n", Actions: models.RuleActions{Routes: []string{"rt_2"}}}, false},
+		{"half a window", models.Rule{Name: "n", Match: models.RuleMatch{TimeFrom: "08:00"}, Actions: tag}, false},
+		{"bad window", models.Rule{Name: "n", Match: models.RuleMatch{TimeFrom: "08:00", TimeTo: "8pm"}, Actions: tag}, false},
+		{"bad gate state", models.Rule{Name: "n", Match: models.RuleMatch{GateStates: []string{"AJAR"}}, Actions: tag}, false},
+		{"negative threshold", models.Rule{Name: "n", Match: models.RuleMatch{MinConfidence: -1}, Actions: tag}, false},
+	}
+	for _, tt := range tests {
+		err := e.Validate(tt.rule)
+		if tt.ok && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrInvalidRule) {
+			t.Errorf("%s: error %v, want ErrInvalidRule", tt.name, err)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package services
 
 import (
 	"central-brain/models"
+	"sort"
 	"sync"
 )
 
@@ -9,6 +10,7 @@ import (
 var (
 	region          models.Region
 	unitStatuses    = make(map[string]string)
+	cameraPosts     = make(map[string]string) // stream camera ID -> post ID
 	hierarchyMutex  sync.RWMutex
 	unitStatusMutex sync.RWMutex
 )
@@ -73,6 +75,13 @@ func initHierarchyData() {
 	unitStatuses["CCTV-BRN-01"] = "ONLINE"
 }
 
+// SetUnitStatus records the live status of a hierarchy unit (ONLINE, STALE, OFFLINE, FROZEN)
+func SetUnitStatus(unitID, status string) {
+	unitStatusMutex.Lock()
+	defer unitStatusMutex.Unlock()
+	unitStatuses[unitID] = status
+}
+
 // GetHierarchy returns the full hierarchy
 func GetHierarchy() models.Region {
 	hierarchyMutex.RLock()
@@ -98,6 +107,174 @@ func GetHierarchyForRole(role, postID, stationID string) interface{} {
 	}
 }
 
+// SetCameraPost records which JPL post a registered stream camera belongs to
+func SetCameraPost(cameraID, postID string) {
+	hierarchyMutex.Lock()
+	defer hierarchyMutex.Unlock()
+	if postID == "" {
+		delete(cameraPosts, cameraID)
+		return
+	}
+	cameraPosts[cameraID] = postID
+}
+
+// RemoveCameraPost forgets the post mapping of a removed camera
+func RemoveCameraPost(cameraID string) {
+	SetCameraPost(cameraID, "")
+}
+
+// CameraPostID returns the post a camera or unit belongs to, or "" if unknown
+func CameraPostID(cameraID string) string {
+	hierarchyMutex.RLock()
+	defer hierarchyMutex.RUnlock()
+	if postID, ok := cameraPosts[cameraID]; ok {
+		return postID
+	}
+	for _, station := range region.Stations {
+		for _, post := range station.Posts {
+			for _, unit := range post.Units {
+				if unit.ID == cameraID {
+					return post.ID
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// CamerasForScope returns the unit and stream camera IDs visible to a role,
+// using the same post/station scoping as GetHierarchyForRole.
+// all is true for DAOP admins, who may see every camera.
+func CamerasForScope(role, postID, stationID string) (ids map[string]bool, all bool) {
+	hierarchyMutex.RLock()
+	defer hierarchyMutex.RUnlock()
+
+	if role == models.RoleDAOPAdmin {
+		return nil, true
+	}
+	posts := scopePosts(role, postID, stationID)
+
+	ids = make(map[string]bool)
+	for _, station := range region.Stations {
+		for _, post := range station.Posts {
+			if !posts[post.ID] {
+				continue
+			}
+			for _, unit := range post.Units {
+				ids[unit.ID] = true
+			}
+		}
+	}
+	for cameraID, p := range cameraPosts {
+		if posts[p] {
+			ids[cameraID] = true
+		}
+	}
+	return ids, false
+}
+
+// PostsForScope returns the JPL post IDs visible to a role, using the same
+// scoping as CamerasForScope. all is true for DAOP admins.
+func PostsForScope(role, postID, stationID string) (ids map[string]bool, all bool) {
+	hierarchyMutex.RLock()
+	defer hierarchyMutex.RUnlock()
+	if role == models.RoleDAOPAdmin {
+		return nil, true
+	}
+	return scopePosts(role, postID, stationID), false
+}
+
+// AlertCameraForPost returns the camera that post-wide alerts (gate faults)
+// are filed under: the post's first registered stream camera, so clips,
+// images and viewer subscriptions work, or its first hierarchy unit when no
+// stream camera is registered. It returns "" for an unknown post.
+func AlertCameraForPost(postID string) string {
+	hierarchyMutex.RLock()
+	defer hierarchyMutex.RUnlock()
+	var streams []string
+	for cameraID, p := range cameraPosts {
+		if p == postID {
+			streams = append(streams, cameraID)
+		}
+	}
+	if len(streams) > 0 {
+		sort.Strings(streams)
+		return streams[0]
+	}
+	if post := getPostByID(postID); post != nil && len(post.Units) > 0 {
+		return post.Units[0].ID
+	}
+	return ""
+}
+
+// PostIDs returns the IDs of every JPL post in the hierarchy
+func PostIDs() []string {
+	hierarchyMutex.RLock()
+	defer hierarchyMutex.RUnlock()
+	var ids []string
+	for _, station := range region.Stations {
+		for _, post := range station.Posts {
+			ids = append(ids, post.ID)
+		}
+	}
+	return ids
+}
+
+// scopePosts returns the posts of a JPL officer or station master. The
+// caller holds hierarchyMutex.
+func scopePosts(role, postID, stationID string) map[string]bool {
+	posts := make(map[string]bool)
+	switch role {
+	case models.RoleJPLOfficer:
+		if getPostByID(postID) != nil {
+			posts[postID] = true
+		}
+	case models.RoleStationMaster:
+		for _, station := range region.Stations {
+			if station.ID != stationID {
+				continue
+			}
+			for _, post := range station.Posts {
+				posts[post.ID] = true
+			}
+		}
+	}
+	return posts
+}
+
+// PostExists reports whether a JPL post with the given ID is part of the hierarchy
+func PostExists(postID string) bool {
+	hierarchyMutex.RLock()
+	defer hierarchyMutex.RUnlock()
+	return getPostByID(postID) != nil
+}
+
+// StationExists reports whether a station with the given ID is part of the hierarchy
+func StationExists(stationID string) bool {
+	hierarchyMutex.RLock()
+	defer hierarchyMutex.RUnlock()
+	for _, station := range region.Stations {
+		if station.ID == stationID {
+			return true
+		}
+	}
+	return false
+}
+
+// StationForPost returns the station a JPL post belongs to, or "" if the post is unknown
+func StationForPost(postID string) string {
+	hierarchyMutex.RLock()
+	defer hierarchyMutex.RUnlock()
+	for _, station := range region.Stations {
+		for _, post := range station.Posts {
+			if post.ID == postID {
+				return station.ID
+			}
+		}
+	}
+	return ""
+}
+
 func getPostByID(postID string) *models.Post {
 	for _, station := range region.Stations {
 		for _, post := range station.Posts {
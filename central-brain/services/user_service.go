@@ -1,75 +1,119 @@
 package services
 
 import (
-	"central-brain/auth"
-	"central-brain/models"
+	"context"
 	"errors"
+	"fmt"
+	"regexp"
+	"sort"
 	"sync"
+	"time"
+
+	"central-brain/auth"
+	"central-brain/models"
 )
 
-// In-memory user data (replace with database in production)
+// MinPasswordLength is enforced when users are created or passwords are reset.
+const MinPasswordLength = 8
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	ErrUserExists   = errors.New("user already exists")
+	ErrUserDisabled = errors.New("user is disabled")
+	ErrInvalidUser  = errors.New("invalid user")
+)
+
+var userIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// UserStore persists user accounts.
+type UserStore interface {
+	ListUsers(ctx context.Context) ([]models.User, error)
+	UpsertUser(ctx context.Context, u models.User) error
+}
+
+// Users are cached in memory and written through to the store on every change.
+// Entries are replaced, never mutated, so pointers handed out stay consistent.
 var (
 	users      = make(map[string]*models.User)
 	usersMutex sync.RWMutex
+	userStore  UserStore
 )
 
-func init() {
-	// Initialize demo users with hashed passwords
-	password := "123456" // Demo password
-	hash, _ := auth.HashPassword(password)
+// LoadUsers reads persisted users into memory. With seedDemo, the demo
+// accounts (password "123456") are added when they do not exist yet.
+func LoadUsers(ctx context.Context, store UserStore, seedDemo bool) error {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
 
-	// DAOP Admin
-	users["DAOP-7"] = &models.User{
-		ID:           "DAOP-7",
-		PasswordHash: hash,
-		Role:         models.RoleDAOPAdmin,
-		Name:         "Admin DAOP 7 Madiun",
+	userStore = store
+	if store != nil {
+		list, err := store.ListUsers(ctx)
+		if err != nil {
+			return err
+		}
+		for i := range list {
+			u := list[i]
+			users[u.ID] = &u
+		}
 	}
 
-	// Station Masters
-	users["STA-JBG"] = &models.User{
-		ID:           "STA-JBG",
-		PasswordHash: hash,
-		Role:         models.RoleStationMaster,
-		Name:         "Kepala Stasiun Jombang",
-		StationID:    "STA-JBG",
+	if !seedDemo {
+		return nil
 	}
-
-	users["STA-KTS"] = &models.User{
-		ID:           "STA-KTS",
-		PasswordHash: hash,
-		Role:         models.RoleStationMaster,
-		Name:         "Kepala Stasiun Kertosono",
-		StationID:    "STA-KTS",
+	for _, u := range demoUsers() {
+		if _, exists := users[u.ID]; exists {
+			continue
+		}
+		if err := saveUserLocked(ctx, u); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	// JPL Officers
-	users["JPL-102"] = &models.User{
-		ID:           "JPL-102",
-		PasswordHash: hash,
-		Role:         models.RoleJPLOfficer,
-		Name:         "Petugas JPL 102",
-		PostID:       "JPL-102",
-		StationID:    "STA-JBG",
-	}
+// SyncUsers re-reads every user from the store, so accounts changed outside
+// the server (the user CLI) take effect without a restart. Without a store
+// it does nothing.
+func SyncUsers(ctx context.Context) error {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
 
-	users["JPL-105"] = &models.User{
-		ID:           "JPL-105",
-		PasswordHash: hash,
-		Role:         models.RoleJPLOfficer,
-		Name:         "Petugas JPL 105",
-		PostID:       "JPL-105",
-		StationID:    "STA-JBG",
+	if userStore == nil {
+		return nil
 	}
+	list, err := userStore.ListUsers(ctx)
+	if err != nil {
+		return err
+	}
+	fresh := make(map[string]*models.User, len(list))
+	for i := range list {
+		u := list[i]
+		fresh[u.ID] = &u
+	}
+	users = fresh
+	return nil
+}
 
-	users["JPL-98"] = &models.User{
-		ID:           "JPL-98",
-		PasswordHash: hash,
-		Role:         models.RoleJPLOfficer,
-		Name:         "Petugas JPL 98",
-		PostID:       "JPL-98",
-		StationID:    "STA-KTS",
+func demoUsers() []models.User {
+	hash, _ := auth.HashPassword("123456") // Demo password
+	now := time.Now().UTC()
+	list := []models.User{
+		// DAOP Admin
+		{ID: "DAOP-7", Role: models.RoleDAOPAdmin, Name: "Admin DAOP 7 Madiun"},
+		// Station Masters
+		{ID: "STA-JBG", Role: models.RoleStationMaster, Name: "Kepala Stasiun Jombang", StationID: "STA-JBG"},
+		{ID: "STA-KTS", Role: models.RoleStationMaster, Name: "Kepala Stasiun Kertosono", StationID: "STA-KTS"},
+		// JPL Officers
+		{ID: "JPL-102", Role: models.RoleJPLOfficer, Name: "Petugas JPL 102", PostID: "JPL-102", StationID: "STA-JBG"},
+		{ID: "JPL-105", Role: models.RoleJPLOfficer, Name: "Petugas JPL 105", PostID: "JPL-105", StationID: "STA-JBG"},
+		{ID: "JPL-98", Role: models.RoleJPLOfficer, Name: "Petugas JPL 98", PostID: "JPL-98", StationID: "STA-KTS"},
+	}
+	for i := range list {
+		list[i].PasswordHash = hash
+		list[i].CreatedAt = now
+		list[i].UpdatedAt = now
 	}
+	return list
 }
 
 // GetUserByID retrieves a user by ID
@@ -79,12 +123,25 @@ func GetUserByID(id string) (*models.User, error) {
 
 	user, exists := users[id]
 	if !exists {
-		return nil, errors.New("user not found")
+		return nil, ErrUserNotFound
 	}
 
 	return user, nil
 }
 
+// CheckActive returns an error if the user no longer exists or is disabled.
+// Used to reject tokens issued before an account was disabled.
+func CheckActive(id string) error {
+	user, err := GetUserByID(id)
+	if err != nil {
+		return err
+	}
+	if user.Disabled {
+		return ErrUserDisabled
+	}
+	return nil
+}
+
 // ValidateLogin checks credentials and returns user
 func ValidateLogin(id, password string) (*models.User, error) {
 	user, err := GetUserByID(id)
@@ -97,5 +154,193 @@ func ValidateLogin(id, password string) (*models.User, error) {
 		return nil, errors.New("invalid password")
 	}
 
+	if user.Disabled {
+		return nil, ErrUserDisabled
+	}
+
 	return user, nil
 }
+
+// ListUsers returns all users ordered by ID
+func ListUsers() []models.User {
+	usersMutex.RLock()
+	defer usersMutex.RUnlock()
+
+	out := make([]models.User, 0, len(users))
+	for _, u := range users {
+		out = append(out, *u)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// CreateUser validates and stores a new account
+func CreateUser(ctx context.Context, req models.UserCreateRequest) (models.User, error) {
+	if !userIDPattern.MatchString(req.ID) {
+		return models.User{}, fmt.Errorf("%w: id must be 1-64 letters, digits, '-' or '_'", ErrInvalidUser)
+	}
+	if err := checkPassword(req.Password); err != nil {
+		return models.User{}, err
+	}
+
+	u := models.User{
+		ID:        req.ID,
+		Role:      req.Role,
+		Name:      req.Name,
+		PostID:    req.PostID,
+		StationID: req.StationID,
+	}
+	if err := validateAssignment(&u); err != nil {
+		return models.User{}, err
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		return models.User{}, err
+	}
+	u.PasswordHash = hash
+
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+	if _, exists := users[u.ID]; exists {
+		return models.User{}, ErrUserExists
+	}
+	u.CreatedAt = time.Now().UTC()
+	u.UpdatedAt = u.CreatedAt
+	if err := saveUserLocked(ctx, u); err != nil {
+		return models.User{}, err
+	}
+	return u, nil
+}
+
+// UpdateUser changes a user's name, role or post/station assignment.
+// A changed role or assignment ends the user's sessions so new scopes apply.
+func UpdateUser(ctx context.Context, id string, req models.UserUpdateRequest) (models.User, error) {
+	var rescoped bool
+	u, err := modifyUser(ctx, id, func(u *models.User) error {
+		before := *u
+		if req.Name != nil {
+			u.Name = *req.Name
+		}
+		if req.Role != nil {
+			u.Role = *req.Role
+		}
+		if req.PostID != nil {
+			u.PostID = *req.PostID
+		}
+		if req.StationID != nil {
+			u.StationID = *req.StationID
+		} else if req.PostID != nil {
+			// Station follows the post unless given explicitly
+			u.StationID = ""
+		}
+		if err := validateAssignment(u); err != nil {
+			return err
+		}
+		rescoped = u.Role != before.Role || u.PostID != before.PostID || u.StationID != before.StationID
+		return nil
+	})
+	if err != nil || !rescoped {
+		return u, err
+	}
+	return u, auth.RevokeUser(ctx, u.ID)
+}
+
+// SetUserDisabled disables or re-enables an account. Disabling ends all
+// of the user's sessions immediately.
+func SetUserDisabled(ctx context.Context, id string, disabled bool) (models.User, error) {
+	u, err := modifyUser(ctx, id, func(u *models.User) error {
+		u.Disabled = disabled
+		return nil
+	})
+	if err != nil || !disabled {
+		return u, err
+	}
+	return u, auth.RevokeUser(ctx, u.ID)
+}
+
+// ResetPassword replaces a user's password and ends their existing sessions
+func ResetPassword(ctx context.Context, id, password string) error {
+	if err := checkPassword(password); err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	u, err := modifyUser(ctx, id, func(u *models.User) error {
+		u.PasswordHash = hash
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return auth.RevokeUser(ctx, u.ID)
+}
+
+func modifyUser(ctx context.Context, id string, apply func(u *models.User) error) (models.User, error) {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	existing, ok := users[id]
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	u := *existing
+	if err := apply(&u); err != nil {
+		return models.User{}, err
+	}
+	u.UpdatedAt = time.Now().UTC()
+	if err := saveUserLocked(ctx, u); err != nil {
+		return models.User{}, err
+	}
+	return u, nil
+}
+
+func saveUserLocked(ctx context.Context, u models.User) error {
+	if userStore != nil {
+		if err := userStore.UpsertUser(ctx, u); err != nil {
+			return err
+		}
+	}
+	users[u.ID] = &u
+	return nil
+}
+
+func checkPassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return fmt.Errorf("%w: password must be at least %d characters", ErrInvalidUser, MinPasswordLength)
+	}
+	return nil
+}
+
+// validateAssignment checks the role and normalizes post/station against the hierarchy:
+// admins have neither, station masters need a station, JPL officers need a post
+// and inherit its station.
+func validateAssignment(u *models.User) error {
+	if u.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidUser)
+	}
+
+	switch u.Role {
+	case models.RoleDAOPAdmin:
+		u.PostID, u.StationID = "", ""
+	case models.RoleStationMaster:
+		if !StationExists(u.StationID) {
+			return fmt.Errorf("%w: unknown station_id %q", ErrInvalidUser, u.StationID)
+		}
+		u.PostID = ""
+	case models.RoleJPLOfficer:
+		station := StationForPost(u.PostID)
+		if station == "" {
+			return fmt.Errorf("%w: unknown post_id %q", ErrInvalidUser, u.PostID)
+		}
+		if u.StationID != "" && u.StationID != station {
+			return fmt.Errorf("%w: post %s belongs to station %s", ErrInvalidUser, u.PostID, station)
+		}
+		u.StationID = station
+	default:
+		return fmt.Errorf("%w: unknown role %q", ErrInvalidUser, u.Role)
+	}
+	return nil
+}
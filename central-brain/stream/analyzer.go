@@ -0,0 +1,270 @@
+package stream
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"central-brain/models"
+)
+
+// Analyzer thresholds. An issue must be seen on several consecutive samples
+// before it is reported, so a passing train or headlight sweep does not alert.
+const (
+	DefaultAnalysisInterval = 2 * time.Second
+
+	darkBrightness    = 30   // mean luma below this is too dark to see the crossing
+	glareSaturated    = 0.4  // fraction of blown-out pixels
+	blurSharpness     = 30   // Laplacian variance at analysisWidth
+	occludedCoverage  = 0.7  // fraction of featureless blocks
+	shiftPercent      = 6    // scene offset, percent of width or height
+	shiftImprovement  = 0.15 // the offset must explain the difference this much better than no offset
+	alertAfterSamples = 3
+	alertRepeat       = 5 * time.Second // same cadence as the AI engine's re-sends
+)
+
+// Frame issues found by the analyzer; they are reported as the object_class
+// of CAMERA_TAMPER or IMAGE_DEGRADED detections.
+const (
+	IssueDark     = "dark"
+	IssueGlare    = "glare"
+	IssueBlur     = "blur"
+	IssueOccluded = "occluded"
+	IssueShifted  = "shifted"
+)
+
+// issueInfo maps an issue to its detection type and a fixed object ID, so
+// each issue groups into its own incident without clashing with tracker IDs.
+var issueInfo = map[string]struct {
+	detectionType string
+	objectID      int
+}{
+	IssueDark:     {models.DetectionImageDegraded, -1},
+	IssueGlare:    {models.DetectionImageDegraded, -2},
+	IssueBlur:     {models.DetectionImageDegraded, -3},
+	IssueOccluded: {models.DetectionCameraTamper, -4},
+	IssueShifted:  {models.DetectionCameraTamper, -5},
+}
+
+// QualityReport is the latest analysis of a camera.
+type QualityReport struct {
+	CameraID     string         `json:"camera_id"`
+	SampledAt    time.Time      `json:"sampled_at"`
+	Metrics      QualityMetrics `json:"metrics"`
+	Issues       []string       `json:"issues"`
+	HasReference bool           `json:"has_reference"`
+}
+
+// Analyzer decodes sampled ingest frames and reports tamper and image quality
+// issues. Decoding runs on its own goroutine so ingest never waits for it.
+type Analyzer struct {
+	interval time.Duration
+	jobs     chan analysisJob
+
+	mu      sync.Mutex
+	cameras map[string]*cameraQuality
+	alert   []func(models.DetectionPayload)
+}
+
+type analysisJob struct {
+	cameraID string
+	data     []byte
+	at       time.Time
+}
+
+type cameraQuality struct {
+	next      time.Time
+	reference *grayImage // thumbnail of a healthy frame for shift detection
+	report    QualityReport
+	streak    map[string]int
+	since     map[string]time.Time
+	lastAlert map[string]time.Time
+}
+
+// NewAnalyzer creates an analyzer sampling each camera at most once per interval.
+func NewAnalyzer(interval time.Duration) *Analyzer {
+	if interval <= 0 {
+		interval = DefaultAnalysisInterval
+	}
+	return &Analyzer{
+		interval: interval,
+		jobs:     make(chan analysisJob, 8),
+		cameras:  make(map[string]*cameraQuality),
+	}
+}
+
+// OnAlert registers fn to receive tamper and quality detections.
+func (a *Analyzer) OnAlert(fn func(models.DetectionPayload)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.alert = append(a.alert, fn)
+}
+
+// Run analyzes queued frames until the process exits.
+func (a *Analyzer) Run() {
+	for job := range a.jobs {
+		a.analyze(job)
+	}
+}
+
+// Offer queues a frame for analysis if the camera is due for a sample.
+// frame may be reused by the caller afterwards. A nil Analyzer ignores frames.
+func (a *Analyzer) Offer(cameraID string, frame []byte) {
+	if a == nil {
+		return
+	}
+	now := time.Now()
+	a.mu.Lock()
+	cq := a.cameraLocked(cameraID)
+	if now.Before(cq.next) {
+		a.mu.Unlock()
+		return
+	}
+	cq.next = now.Add(a.interval)
+	a.mu.Unlock()
+
+	// Both may point into the request buffer
+	job := analysisJob{cameraID: strings.Clone(cameraID), data: append([]byte(nil), frame...), at: now}
+	select {
+	case a.jobs <- job:
+	default: // analysis is behind; skip this sample
+	}
+}
+
+// Report returns the latest analysis of a camera.
+func (a *Analyzer) Report(cameraID string) (QualityReport, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cq, ok := a.cameras[cameraID]
+	if !ok || cq.report.SampledAt.IsZero() {
+		return QualityReport{}, false
+	}
+	r := cq.report
+	r.Issues = append([]string{}, r.Issues...)
+	return r, true
+}
+
+// ResetReference forgets the reference frame of a camera, e.g. after it was
+// deliberately re-aimed. The next healthy sample becomes the new reference.
+func (a *Analyzer) ResetReference(cameraID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cq := a.cameraLocked(cameraID)
+	cq.reference = nil
+	cq.report.HasReference = false
+}
+
+func (a *Analyzer) analyze(job analysisJob) {
+	g, err := decodeGray(job.data, analysisWidth)
+	if err != nil {
+		log.Printf("[QUALITY] camera %s: cannot decode frame: %v", job.cameraID, err)
+		return
+	}
+	m := measure(g)
+	thumb := thumbnail(g)
+
+	details := make(map[string]string)
+	switch {
+	case m.Brightness < darkBrightness:
+		// Dark frames are flat and soft as well; report only the darkness
+		details[IssueDark] = fmt.Sprintf("mean brightness %.0f/255", m.Brightness)
+	default:
+		if m.Saturated > glareSaturated {
+			details[IssueGlare] = fmt.Sprintf("%.0f%% of the image is blown out", m.Saturated*100)
+		}
+		if m.Sharpness < blurSharpness {
+			details[IssueBlur] = fmt.Sprintf("Laplacian variance %.1f", m.Sharpness)
+		}
+		if m.Coverage > occludedCoverage {
+			details[IssueOccluded] = fmt.Sprintf("%.0f%% of the image is featureless", m.Coverage*100)
+		}
+	}
+
+	var alerts []models.DetectionPayload
+	a.mu.Lock()
+	cq := a.cameraLocked(job.cameraID)
+	if ref := cq.reference; ref != nil && ref.w == thumb.w && ref.h == thumb.h {
+		dx, dy, best, still := sceneShift(ref, thumb)
+		m.ShiftX = dx * 100 / thumb.w
+		m.ShiftY = dy * 100 / thumb.h
+		m.Mismatch = best
+		if (abs(m.ShiftX) >= shiftPercent || abs(m.ShiftY) >= shiftPercent) && still-best >= shiftImprovement {
+			details[IssueShifted] = fmt.Sprintf("scene moved %d%% horizontally, %d%% vertically", m.ShiftX, m.ShiftY)
+		}
+	} else if len(details) == 0 {
+		cq.reference = thumb
+	}
+
+	issues := make([]string, 0, len(details))
+	for issue := range details {
+		issues = append(issues, issue)
+	}
+	sort.Strings(issues)
+	cq.report = QualityReport{
+		CameraID:     job.cameraID,
+		SampledAt:    job.at.UTC(),
+		Metrics:      m,
+		Issues:       issues,
+		HasReference: cq.reference != nil,
+	}
+
+	for issue, info := range issueInfo {
+		detail, found := details[issue]
+		if !found {
+			delete(cq.streak, issue)
+			delete(cq.since, issue)
+			delete(cq.lastAlert, issue)
+			continue
+		}
+		if cq.streak[issue] == 0 {
+			cq.since[issue] = job.at
+		}
+		cq.streak[issue]++
+		if cq.streak[issue] < alertAfterSamples || job.at.Sub(cq.lastAlert[issue]) < alertRepeat {
+			continue
+		}
+		cq.lastAlert[issue] = job.at
+		alerts = append(alerts, models.DetectionPayload{
+			Type:             info.detectionType,
+			ObjectClass:      issue,
+			Confidence:       1,
+			ObjectID:         info.objectID,
+			DurationSeconds:  job.at.Sub(cq.since[issue]).Seconds(),
+			Timestamp:        job.at.UTC(),
+			CameraID:         job.cameraID,
+			AdditionalDetail: detail,
+		})
+	}
+	watch := a.alert
+	a.mu.Unlock()
+
+	for _, p := range alerts {
+		log.Printf("[QUALITY] camera %s: %s %s (%s)", p.CameraID, p.Type, p.ObjectClass, p.AdditionalDetail)
+		for _, fn := range watch {
+			fn(p)
+		}
+	}
+}
+
+func (a *Analyzer) cameraLocked(cameraID string) *cameraQuality {
+	cq, ok := a.cameras[cameraID]
+	if !ok {
+		cq = &cameraQuality{
+			streak:    make(map[string]int),
+			since:     make(map[string]time.Time),
+			lastAlert: make(map[string]time.Time),
+		}
+		a.cameras[strings.Clone(cameraID)] = cq
+	}
+	return cq
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
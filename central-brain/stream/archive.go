@@ -0,0 +1,486 @@
+package stream
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"central-brain/models"
+)
+
+// Archive defaults; retention and the byte cap apply per camera. Only
+// DefaultArchiveFPS frames a second are archived: playback is for review,
+// while incident clips keep the full rate from the ring buffer.
+const (
+	DefaultArchiveRetention = 72 * time.Hour
+	DefaultArchiveFPS       = 1
+
+	// archiveFrameBytes is the expected size of an archived 1080p JPEG,
+	// used to size the default byte cap to the retention.
+	archiveFrameBytes = 150 << 10
+
+	archiveSegmentLength = 5 * time.Minute
+	archivePruneInterval = time.Minute
+	archiveIndexEntry    = 20 // timestamp (int64 ns), offset (int64), length (uint32)
+)
+
+// ArchiveSegment is one archived file of a camera. Frames are stored back to
+// back in <start>.mjpeg (playable as raw MJPEG) with their timestamps and
+// offsets in <start>.idx.
+type ArchiveSegment struct {
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Frames int       `json:"frames"`
+	Bytes  int64     `json:"bytes"`
+	name   string
+}
+
+// Archive continuously writes every camera's frames to time-segmented files
+// under dir/<camera_id>/ and deletes segments past the retention limits.
+type Archive struct {
+	dir       string
+	retention time.Duration
+	maxBytes  int64
+	interval  time.Duration // minimum gap between archived frames
+
+	mu      sync.Mutex
+	cameras map[string]*cameraArchive
+}
+
+type cameraArchive struct {
+	segments  []ArchiveSegment // oldest first
+	hub       *MJPEGHub        // hub being recorded, nil when idle
+	writing   string           // name of the segment open for writing
+	capWarned bool             // byte cap evicts before retention was logged
+}
+
+// NewArchive creates an archive in dir that keeps up to fps frames a second
+// of each camera and indexes segments left by earlier runs. Zero values
+// select the defaults; the default byte cap holds the whole retention at
+// the expected frame size.
+func NewArchive(dir string, retention time.Duration, maxBytes int64, fps float64) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if retention <= 0 {
+		retention = DefaultArchiveRetention
+	}
+	if fps <= 0 {
+		fps = DefaultArchiveFPS
+	}
+	if maxBytes <= 0 {
+		maxBytes = int64(retention.Seconds() * fps * archiveFrameBytes)
+	}
+	a := &Archive{
+		dir:       dir,
+		retention: retention,
+		maxBytes:  maxBytes,
+		interval:  time.Duration(float64(time.Second) / fps),
+		cameras:   make(map[string]*cameraArchive),
+	}
+	if err := a.load(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Attach records every camera of reg, including cameras added later.
+// Call it before reg.Load so persisted cameras are recorded too.
+func (a *Archive) Attach(reg *Registry) {
+	reg.OnChange(func(cam models.Camera, removed bool) {
+		if removed {
+			return // the hub stops and closes the tap; footage ages out normally
+		}
+		// The registry calls watchers with its lock held
+		go a.record(reg, cam.ID)
+	})
+}
+
+// Run enforces retention until the process exits.
+func (a *Archive) Run() {
+	ticker := time.NewTicker(archivePruneInterval)
+	defer ticker.Stop()
+	for {
+		a.mu.Lock()
+		ids := make([]string, 0, len(a.cameras))
+		for id := range a.cameras {
+			ids = append(ids, id)
+		}
+		a.mu.Unlock()
+		for _, id := range ids {
+			a.prune(id)
+		}
+		<-ticker.C
+	}
+}
+
+// Segments returns the archived segments of a camera, oldest first.
+func (a *Archive) Segments(cameraID string) []ArchiveSegment {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ca, ok := a.cameras[cameraID]
+	if !ok {
+		return nil
+	}
+	return append([]ArchiveSegment(nil), ca.segments...)
+}
+
+// Replay calls fn for each archived frame of a camera between from and to,
+// in order, until fn returns an error.
+func (a *Archive) Replay(cameraID string, from, to time.Time, fn func(Frame) error) error {
+	for _, seg := range a.Segments(cameraID) {
+		if seg.End.Before(from) || seg.Start.After(to) {
+			continue
+		}
+		if err := a.replaySegment(cameraID, seg, from, to, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *Archive) replaySegment(cameraID string, seg ArchiveSegment, from, to time.Time, fn func(Frame) error) error {
+	base := filepath.Join(a.dir, cameraID, seg.name)
+	entries, err := readArchiveIndex(base + ".idx")
+	if os.IsNotExist(err) {
+		return nil // pruned meanwhile
+	}
+	if err != nil {
+		return err
+	}
+	data, err := os.Open(base + ".mjpeg")
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	fromNS, toNS := from.UnixNano(), to.UnixNano()
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].ts >= fromNS })
+	for ; i < len(entries) && entries[i].ts <= toNS; i++ {
+		e := entries[i]
+		buf := make([]byte, e.length)
+		if _, err := data.ReadAt(buf, e.offset); err != nil {
+			return fmt.Errorf("read %s: %w", base, err)
+		}
+		if err := fn(Frame{Data: buf, Timestamp: time.Unix(0, e.ts).UTC()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// record writes frames of a camera's current hub until the hub stops.
+func (a *Archive) record(reg *Registry, cameraID string) {
+	hub, ok := reg.Hub(cameraID)
+	if !ok {
+		return
+	}
+	a.mu.Lock()
+	ca := a.cameraLocked(cameraID)
+	if ca.hub == hub {
+		a.mu.Unlock()
+		return // camera update, already recording
+	}
+	ca.hub = hub
+	a.mu.Unlock()
+
+	frames, detach := hub.Tap(64)
+	defer detach()
+
+	var (
+		w    *segmentWriter
+		last time.Time // timestamp of the last archived frame
+	)
+	defer func() {
+		if w != nil {
+			w.close()
+		}
+		a.mu.Lock()
+		if ca.hub == hub {
+			ca.hub = nil
+			ca.writing = ""
+		}
+		a.mu.Unlock()
+	}()
+
+	for f := range frames {
+		if gap := f.Timestamp.Sub(last); gap >= 0 && gap < a.interval {
+			continue
+		}
+		last = f.Timestamp
+		if w == nil || f.Timestamp.Sub(w.start) >= archiveSegmentLength {
+			if w != nil {
+				w.close()
+				w = nil
+			}
+			next, err := a.openSegment(cameraID, f.Timestamp)
+			if err != nil {
+				log.Printf("[ARCHIVE] camera %s: %v", cameraID, err)
+				continue
+			}
+			w = next
+			a.prune(cameraID)
+		}
+		n, err := w.write(f)
+		if err != nil {
+			log.Printf("[ARCHIVE] camera %s: %v", cameraID, err)
+			w.close()
+			w = nil
+			continue
+		}
+		a.mu.Lock()
+		if last := len(ca.segments) - 1; last >= 0 && ca.segments[last].name == w.name {
+			seg := &ca.segments[last]
+			seg.End = f.Timestamp
+			seg.Frames++
+			seg.Bytes += n
+		}
+		a.mu.Unlock()
+	}
+}
+
+func (a *Archive) openSegment(cameraID string, start time.Time) (*segmentWriter, error) {
+	dir := filepath.Join(a.dir, cameraID)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%019d", start.UnixNano())
+	w, err := createSegment(filepath.Join(dir, name), name, start)
+	if err != nil {
+		return nil, err
+	}
+
+	a.mu.Lock()
+	ca := a.cameraLocked(cameraID)
+	ca.segments = append(ca.segments, ArchiveSegment{Start: start.UTC(), End: start.UTC(), name: name})
+	ca.writing = name
+	a.mu.Unlock()
+	return w, nil
+}
+
+// prune deletes the oldest segments of a camera while they are older than the
+// retention or the camera exceeds its byte budget. The open segment is kept.
+// When the byte budget is about to cut footage short of the retention, a
+// warning is logged once.
+func (a *Archive) prune(cameraID string) {
+	cutoff := time.Now().Add(-a.retention)
+
+	a.mu.Lock()
+	ca, ok := a.cameras[cameraID]
+	if !ok {
+		a.mu.Unlock()
+		return
+	}
+	var total int64
+	for _, seg := range ca.segments {
+		total += seg.Bytes
+	}
+	a.checkCapLocked(cameraID, ca)
+	var drop []ArchiveSegment
+	for len(ca.segments) > 0 {
+		oldest := ca.segments[0]
+		if oldest.name == ca.writing || (!oldest.End.Before(cutoff) && total <= a.maxBytes) {
+			break
+		}
+		total -= oldest.Bytes
+		drop = append(drop, oldest)
+		ca.segments = ca.segments[1:]
+	}
+	a.mu.Unlock()
+
+	for _, seg := range drop {
+		base := filepath.Join(a.dir, cameraID, seg.name)
+		for _, path := range []string{base + ".mjpeg", base + ".idx"} {
+			if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+				log.Printf("[ARCHIVE] failed to delete %s: %v", path, err)
+			}
+		}
+	}
+}
+
+// checkCapLocked projects the camera's recent byte rate over the retention
+// and warns once when the byte cap would evict footage first.
+func (a *Archive) checkCapLocked(cameraID string, ca *cameraArchive) {
+	// The newest closed segment gives the current rate
+	var rate float64 // bytes per second
+	for i := len(ca.segments) - 1; i >= 0; i-- {
+		seg := ca.segments[i]
+		if seg.name == ca.writing {
+			continue
+		}
+		if d := seg.End.Sub(seg.Start).Seconds(); d > 0 {
+			rate = float64(seg.Bytes) / d
+		}
+		break
+	}
+	if rate == 0 {
+		return
+	}
+	covered := time.Duration(float64(a.maxBytes) / rate * float64(time.Second))
+	if covered >= a.retention {
+		ca.capWarned = false
+		return
+	}
+	if !ca.capWarned {
+		ca.capWarned = true
+		log.Printf("[ARCHIVE] WARNING: camera %s archives %.0f KB/s; ARCHIVE_MAX_MB=%d keeps only %s of the %s retention (raise ARCHIVE_MAX_MB or lower ARCHIVE_FPS)",
+			cameraID, rate/1024, a.maxBytes>>20, covered.Round(time.Minute), a.retention)
+	}
+}
+
+// load indexes segments written by earlier runs.
+func (a *Archive) load() error {
+	dirs, err := os.ReadDir(a.dir)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		if !d.IsDir() || !cameraIDPattern.MatchString(d.Name()) {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(a.dir, d.Name()))
+		if err != nil {
+			return err
+		}
+		var segments []ArchiveSegment
+		for _, f := range files {
+			name, ok := strings.CutSuffix(f.Name(), ".idx")
+			if !ok {
+				continue
+			}
+			if _, err := strconv.ParseInt(name, 10, 64); err != nil {
+				continue
+			}
+			seg, err := loadSegment(filepath.Join(a.dir, d.Name(), name), name)
+			if err != nil {
+				log.Printf("[ARCHIVE] skipping %s/%s: %v", d.Name(), name, err)
+				continue
+			}
+			segments = append(segments, seg)
+		}
+		if len(segments) == 0 {
+			continue
+		}
+		sort.Slice(segments, func(i, j int) bool { return segments[i].name < segments[j].name })
+		a.cameraLocked(d.Name()).segments = segments
+	}
+	return nil
+}
+
+func (a *Archive) cameraLocked(cameraID string) *cameraArchive {
+	ca, ok := a.cameras[cameraID]
+	if !ok {
+		ca = &cameraArchive{}
+		a.cameras[cameraID] = ca
+	}
+	return ca
+}
+
+type archiveEntry struct {
+	ts     int64
+	offset int64
+	length uint32
+}
+
+func readArchiveIndex(path string) ([]archiveEntry, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// A trailing partial entry is a write in progress or cut off by a crash
+	entries := make([]archiveEntry, len(raw)/archiveIndexEntry)
+	for i := range entries {
+		b := raw[i*archiveIndexEntry:]
+		entries[i] = archiveEntry{
+			ts:     int64(binary.LittleEndian.Uint64(b[0:8])),
+			offset: int64(binary.LittleEndian.Uint64(b[8:16])),
+			length: binary.LittleEndian.Uint32(b[16:20]),
+		}
+	}
+	return entries, nil
+}
+
+func loadSegment(base, name string) (ArchiveSegment, error) {
+	entries, err := readArchiveIndex(base + ".idx")
+	if err != nil {
+		return ArchiveSegment{}, err
+	}
+	info, err := os.Stat(base + ".mjpeg")
+	if err != nil {
+		return ArchiveSegment{}, err
+	}
+	// Drop entries whose frame data never made it to disk
+	for len(entries) > 0 {
+		last := entries[len(entries)-1]
+		if last.offset+int64(last.length) <= info.Size() {
+			break
+		}
+		entries = entries[:len(entries)-1]
+	}
+	if len(entries) == 0 {
+		os.Remove(base + ".idx")
+		os.Remove(base + ".mjpeg")
+		return ArchiveSegment{}, io.ErrUnexpectedEOF
+	}
+	return ArchiveSegment{
+		Start:  time.Unix(0, entries[0].ts).UTC(),
+		End:    time.Unix(0, entries[len(entries)-1].ts).UTC(),
+		Frames: len(entries),
+		Bytes:  info.Size() + int64(len(entries)*archiveIndexEntry),
+		name:   name,
+	}, nil
+}
+
+type segmentWriter struct {
+	name   string
+	start  time.Time
+	data   *os.File
+	index  *os.File
+	offset int64
+	entry  [archiveIndexEntry]byte
+}
+
+func createSegment(base, name string, start time.Time) (*segmentWriter, error) {
+	data, err := os.OpenFile(base+".mjpeg", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	index, err := os.OpenFile(base+".idx", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		data.Close()
+		return nil, err
+	}
+	return &segmentWriter{name: name, start: start, data: data, index: index}, nil
+}
+
+// write appends a frame, then its index entry, so readers never see an entry
+// before its data. It returns the bytes added to the segment.
+func (w *segmentWriter) write(f Frame) (int64, error) {
+	if _, err := w.data.Write(f.Data); err != nil {
+		return 0, err
+	}
+	binary.LittleEndian.PutUint64(w.entry[0:8], uint64(f.Timestamp.UnixNano()))
+	binary.LittleEndian.PutUint64(w.entry[8:16], uint64(w.offset))
+	binary.LittleEndian.PutUint32(w.entry[16:20], uint32(len(f.Data)))
+	if _, err := w.index.Write(w.entry[:]); err != nil {
+		return 0, err
+	}
+	w.offset += int64(len(f.Data))
+	return int64(len(f.Data) + archiveIndexEntry), nil
+}
+
+func (w *segmentWriter) close() {
+	w.data.Close()
+	w.index.Close()
+}
@@ -0,0 +1,209 @@
+package stream
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"time"
+)
+
+// AVI header sizes, see the OpenDML/RIFF AVI reference.
+const (
+	aviMainHeaderSize   = 56
+	aviStreamHeaderSize = 56
+	bitmapInfoSize      = 40
+	aviIndexEntrySize   = 16
+
+	avifHasIndex   = 0x10
+	aviifKeyframe  = 0x10
+	defaultClipFPS = 10
+)
+
+// WriteMJPEGAVI writes frames as a Motion-JPEG AVI playable by common players.
+// The frame rate is the average over the clip; frame size is read from the
+// first JPEG.
+func WriteMJPEGAVI(w io.Writer, frames []Frame) error {
+	if len(frames) == 0 {
+		return errors.New("no frames")
+	}
+	width, height, ok := jpegSize(frames[0].Data)
+	if !ok {
+		return errors.New("first frame is not a JPEG")
+	}
+
+	fps := float64(defaultClipFPS)
+	if span := frames[len(frames)-1].Timestamp.Sub(frames[0].Timestamp).Seconds(); len(frames) > 1 && span > 0 {
+		fps = float64(len(frames)-1) / span
+	}
+	rate := uint32(math.Max(1, math.Round(fps*1000))) // in thousandths of a frame per second
+
+	moviSize := 4
+	maxFrame := 0
+	for _, f := range frames {
+		moviSize += 8 + padded(len(f.Data))
+		if len(f.Data) > maxFrame {
+			maxFrame = len(f.Data)
+		}
+	}
+	strlSize := 4 + 8 + aviStreamHeaderSize + 8 + bitmapInfoSize
+	hdrlSize := 4 + 8 + aviMainHeaderSize + 8 + strlSize
+	idxSize := aviIndexEntrySize * len(frames)
+	riffSize := 4 + 8 + hdrlSize + 8 + moviSize + 8 + idxSize
+
+	bw := bufio.NewWriter(w)
+	le := func(v ...interface{}) {
+		for _, x := range v {
+			binary.Write(bw, binary.LittleEndian, x)
+		}
+	}
+	chunk := func(fourcc string, size int) {
+		bw.WriteString(fourcc)
+		le(uint32(size))
+	}
+	list := func(size int, kind string) {
+		chunk("LIST", size)
+		bw.WriteString(kind)
+	}
+
+	chunk("RIFF", riffSize)
+	bw.WriteString("AVI ")
+
+	list(hdrlSize, "hdrl")
+	chunk("avih", aviMainHeaderSize)
+	le(
+		uint32(1e9/rate),              // dwMicroSecPerFrame
+		uint32(float64(maxFrame)*fps), // dwMaxBytesPerSec
+		uint32(0),                     // dwPaddingGranularity
+		uint32(avifHasIndex),          // dwFlags
+		uint32(len(frames)),           // dwTotalFrames
+		uint32(0),                     // dwInitialFrames
+		uint32(1),                     // dwStreams
+		uint32(maxFrame),              // dwSuggestedBufferSize
+		uint32(width),
+		uint32(height),
+		[4]uint32{}, // dwReserved
+	)
+
+	list(strlSize, "strl")
+	chunk("strh", aviStreamHeaderSize)
+	bw.WriteString("vids")
+	bw.WriteString("MJPG")
+	le(
+		uint32(0),           // dwFlags
+		uint16(0),           // wPriority
+		uint16(0),           // wLanguage
+		uint32(0),           // dwInitialFrames
+		uint32(1000),        // dwScale
+		rate,                // dwRate
+		uint32(0),           // dwStart
+		uint32(len(frames)), // dwLength
+		uint32(maxFrame),    // dwSuggestedBufferSize
+		int32(-1),           // dwQuality
+		uint32(0),           // dwSampleSize
+		[4]int16{0, 0, int16(width), int16(height)},
+	)
+	chunk("strf", bitmapInfoSize)
+	le(
+		uint32(bitmapInfoSize),
+		int32(width),
+		int32(height),
+		uint16(1),  // biPlanes
+		uint16(24), // biBitCount
+	)
+	bw.WriteString("MJPG")
+	le(uint32(width*height*3), int32(0), int32(0), uint32(0), uint32(0))
+
+	list(moviSize, "movi")
+	for _, f := range frames {
+		chunk("00dc", len(f.Data))
+		bw.Write(f.Data)
+		if len(f.Data)%2 == 1 {
+			bw.WriteByte(0)
+		}
+	}
+
+	// idx1 offsets are relative to the "movi" fourcc
+	chunk("idx1", idxSize)
+	offset := 4
+	for _, f := range frames {
+		bw.WriteString("00dc")
+		le(uint32(aviifKeyframe), uint32(offset), uint32(len(f.Data)))
+		offset += 8 + padded(len(f.Data))
+	}
+	return bw.Flush()
+}
+
+// ReadMJPEGAVI reads back the frames of an AVI written by WriteMJPEGAVI.
+// Timestamps are rebuilt from the header's frame duration.
+func ReadMJPEGAVI(r io.Reader) ([]Frame, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "AVI " {
+		return nil, errors.New("not an AVI file")
+	}
+
+	var (
+		frames   []Frame
+		perFrame time.Duration
+		start    = time.Unix(0, 0).UTC()
+	)
+	for i := 12; i+8 <= len(data); {
+		fourcc := string(data[i : i+4])
+		size := int(binary.LittleEndian.Uint32(data[i+4 : i+8]))
+		body := i + 8
+		if fourcc == "LIST" {
+			i = body + 4 // descend into hdrl, strl and movi
+			continue
+		}
+		if body+size > len(data) {
+			return nil, errors.New("truncated AVI chunk " + fourcc)
+		}
+		switch fourcc {
+		case "avih":
+			perFrame = time.Duration(binary.LittleEndian.Uint32(data[body:body+4])) * time.Microsecond
+		case "00dc":
+			frames = append(frames, Frame{
+				Data:      data[body : body+size],
+				Timestamp: start.Add(time.Duration(len(frames)) * perFrame),
+			})
+		}
+		i = body + padded(size)
+	}
+	if len(frames) == 0 {
+		return nil, errors.New("no frames")
+	}
+	return frames, nil
+}
+
+func padded(n int) int {
+	return n + n%2
+}
+
+// jpegSize reads the frame dimensions from the first SOF marker.
+func jpegSize(data []byte) (width, height int, ok bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0, 0, false
+	}
+	for i := 2; i+9 < len(data); {
+		if data[i] != 0xFF {
+			return 0, 0, false
+		}
+		marker := data[i+1]
+		if marker == 0xFF { // fill byte
+			i++
+			continue
+		}
+		length := int(data[i+2])<<8 | int(data[i+3])
+		if marker >= 0xC0 && marker <= 0xCF && marker != 0xC4 && marker != 0xC8 && marker != 0xCC {
+			height = int(data[i+5])<<8 | int(data[i+6])
+			width = int(data[i+7])<<8 | int(data[i+8])
+			return width, height, width > 0 && height > 0
+		}
+		i += 2 + length
+	}
+	return 0, 0, false
+}
@@ -0,0 +1,156 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"testing"
+	"time"
+)
+
+func testJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, w, h)), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestMJPEGAVIRoundTrip(t *testing.T) {
+	base := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	jpg := testJPEG(t, 64, 48)
+	odd := append(append([]byte(nil), jpg...), 0) // odd length, needs padding
+	if len(odd)%2 == 0 {
+		odd = append(odd, 0)
+	}
+
+	tests := []struct {
+		name     string
+		frames   []Frame
+		perFrame time.Duration
+	}{
+		{
+			name:     "single frame at the default rate",
+			frames:   []Frame{{Data: jpg, Timestamp: base}},
+			perFrame: 100 * time.Millisecond,
+		},
+		{
+			name: "average rate over the clip",
+			frames: []Frame{
+				{Data: jpg, Timestamp: base},
+				{Data: jpg, Timestamp: base.Add(150 * time.Millisecond)},
+				{Data: jpg, Timestamp: base.Add(200 * time.Millisecond)},
+			},
+			perFrame: 100 * time.Millisecond,
+		},
+		{
+			name: "odd-sized frames",
+			frames: []Frame{
+				{Data: odd, Timestamp: base},
+				{Data: jpg, Timestamp: base.Add(40 * time.Millisecond)},
+				{Data: odd, Timestamp: base.Add(80 * time.Millisecond)},
+			},
+			perFrame: 40 * time.Millisecond,
+		},
+		{
+			name: "same timestamps",
+			frames: []Frame{
+				{Data: jpg, Timestamp: base},
+				{Data: jpg, Timestamp: base},
+			},
+			perFrame: 100 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := WriteMJPEGAVI(&buf, tt.frames); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			data := buf.Bytes()
+			if got := int(binary.LittleEndian.Uint32(data[4:8])); got != len(data)-8 {
+				t.Errorf("RIFF size %d, file holds %d", got, len(data)-8)
+			}
+			// avih follows RIFF(12), LIST hdrl(12) and its own header (8)
+			avih := data[32:]
+			if w, h := binary.LittleEndian.Uint32(avih[32:36]), binary.LittleEndian.Uint32(avih[36:40]); w != 64 || h != 48 {
+				t.Errorf("frame size %dx%d, want 64x48", w, h)
+			}
+
+			frames, err := ReadMJPEGAVI(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if len(frames) != len(tt.frames) {
+				t.Fatalf("%d frames, want %d", len(frames), len(tt.frames))
+			}
+			start := time.Unix(0, 0).UTC()
+			for i, f := range frames {
+				if !bytes.Equal(f.Data, tt.frames[i].Data) {
+					t.Errorf("frame %d: data differs", i)
+				}
+				if want := start.Add(time.Duration(i) * tt.perFrame); !f.Timestamp.Equal(want) {
+					t.Errorf("frame %d: at %s, want %s", i, f.Timestamp.Sub(start), want.Sub(start))
+				}
+			}
+		})
+	}
+}
+
+func TestMJPEGAVIErrors(t *testing.T) {
+	var ok bytes.Buffer
+	if err := WriteMJPEGAVI(&ok, []Frame{{Data: testJPEG(t, 8, 8), Timestamp: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "not RIFF", data: []byte("RIFX\x00\x00\x00\x00AVI ")},
+		{name: "not AVI", data: []byte("RIFF\x04\x00\x00\x00WAVE")},
+		{name: "truncated", data: ok.Bytes()[:ok.Len()-100]},
+		{name: "no frames", data: []byte("RIFF\x04\x00\x00\x00AVI ")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ReadMJPEGAVI(bytes.NewReader(tt.data)); err == nil {
+				t.Error("read succeeded")
+			}
+		})
+	}
+
+	for name, frames := range map[string][]Frame{
+		"no frames": nil,
+		"not JPEG":  {{Data: []byte("not a jpeg"), Timestamp: time.Now()}},
+	} {
+		if err := WriteMJPEGAVI(&bytes.Buffer{}, frames); err == nil {
+			t.Errorf("write %s succeeded", name)
+		}
+	}
+}
+
+func TestJPEGSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          []byte
+		width, height int
+		ok            bool
+	}{
+		{name: "baseline", data: testJPEG(t, 320, 240), width: 320, height: 240, ok: true},
+		{name: "one pixel", data: testJPEG(t, 1, 1), width: 1, height: 1, ok: true},
+		{name: "no SOI", data: []byte{0x89, 'P', 'N', 'G'}},
+		{name: "SOI only", data: []byte{0xFF, 0xD8, 0xFF, 0xD9}},
+		{name: "garbage after SOI", data: []byte{0xFF, 0xD8, 0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, h, ok := jpegSize(tt.data)
+			if w != tt.width || h != tt.height || ok != tt.ok {
+				t.Errorf("jpegSize = %d, %d, %v; want %d, %d, %v", w, h, ok, tt.width, tt.height, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package stream
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Default clip window around the moment an incident opens.
+const (
+	DefaultPreRoll  = 10 * time.Second
+	DefaultPostRoll = 20 * time.Second
+)
+
+// ClipRecorder saves Motion-JPEG AVI clips from camera hubs, covering the
+// pre-roll held in the hub's ring buffer plus the frames that follow.
+type ClipRecorder struct {
+	reg       *Registry
+	dir       string
+	pre, post time.Duration
+
+	mu        sync.Mutex
+	recording map[string]bool
+	saved     []func(incidentID, path string)
+}
+
+// NewClipRecorder creates a recorder writing to dir. The pre-roll is capped at
+// DefaultBufferWindow since older frames are not kept.
+func NewClipRecorder(reg *Registry, dir string, pre, post time.Duration) (*ClipRecorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	if pre > DefaultBufferWindow {
+		pre = DefaultBufferWindow
+	}
+	return &ClipRecorder{
+		reg:       reg,
+		dir:       dir,
+		pre:       pre,
+		post:      post,
+		recording: make(map[string]bool),
+	}, nil
+}
+
+// OnSaved registers fn to be called after a clip file has been written.
+func (r *ClipRecorder) OnSaved(fn func(incidentID, path string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.saved = append(r.saved, fn)
+}
+
+// Path returns where the clip for an incident is (or will be) stored.
+func (r *ClipRecorder) Path(incidentID string) string {
+	return filepath.Join(r.dir, incidentID+".avi")
+}
+
+// Recording reports whether a clip for the incident is still being captured.
+func (r *ClipRecorder) Recording(incidentID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.recording[incidentID]
+}
+
+// Trigger starts recording a clip for an incident on a camera. It returns
+// immediately; the clip is written once the post-roll has elapsed.
+func (r *ClipRecorder) Trigger(cameraID, incidentID string) {
+	hub, ok := r.reg.Hub(cameraID)
+	if !ok {
+		log.Printf("[CLIP] no stream for camera %s, skipping clip for %s", cameraID, incidentID)
+		return
+	}
+
+	r.mu.Lock()
+	if r.recording[incidentID] {
+		r.mu.Unlock()
+		return
+	}
+	r.recording[incidentID] = true
+	r.mu.Unlock()
+
+	// Tap before reading the buffer so no frame falls between the two
+	frames, detach := hub.Tap(64)
+	now := time.Now()
+	pre := hub.Recent(now.Add(-r.pre))
+	go r.record(incidentID, pre, frames, detach, now.Add(r.post))
+}
+
+func (r *ClipRecorder) record(incidentID string, clip []Frame, frames <-chan Frame, detach func(), until time.Time) {
+	defer func() {
+		r.mu.Lock()
+		delete(r.recording, incidentID)
+		r.mu.Unlock()
+	}()
+
+	var last time.Time
+	if len(clip) > 0 {
+		last = clip[len(clip)-1].Timestamp
+	}
+	timer := time.NewTimer(time.Until(until))
+	defer timer.Stop()
+collect:
+	for {
+		select {
+		case f, ok := <-frames:
+			if !ok {
+				break collect // hub stopped
+			}
+			if f.Timestamp.After(last) {
+				clip = append(clip, f)
+				last = f.Timestamp
+			}
+		case <-timer.C:
+			break collect
+		}
+	}
+	detach()
+
+	if len(clip) == 0 {
+		log.Printf("[CLIP] no frames received for %s, nothing saved", incidentID)
+		return
+	}
+	path := r.Path(incidentID)
+	if err := writeClip(path, clip); err != nil {
+		log.Printf("[CLIP] failed to save %s: %v", path, err)
+		return
+	}
+	log.Printf("[CLIP] saved %s (%d frames)", path, len(clip))
+
+	r.mu.Lock()
+	saved := append([]func(string, string){}, r.saved...)
+	r.mu.Unlock()
+	for _, fn := range saved {
+		fn(incidentID, path)
+	}
+}
+
+// writeClip writes to a temporary file first so a partially written clip is never served.
+func writeClip(path string, frames []Frame) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".clip-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := WriteMJPEGAVI(tmp, frames); err != nil {
+		tmp.Close()
+		return fmt.Errorf("encode: %w", err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// WriteMasked writes the clip of an incident with the camera's privacy masks
+// applied to every frame. The clip on disk stays unmasked.
+func (r *ClipRecorder) WriteMasked(w io.Writer, incidentID, cameraID string) error {
+	f, err := os.Open(r.Path(incidentID))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	frames, err := ReadMJPEGAVI(f)
+	if err != nil {
+		return err
+	}
+
+	// A camera removed since keeps no mask config; refuse rather than leak
+	cam, ok := r.reg.Get(cameraID)
+	if !ok {
+		return ErrCameraNotFound
+	}
+	for i := range frames {
+		if frames[i].Data, err = MaskFrame(frames[i].Data, cam); err != nil {
+			return err
+		}
+	}
+	return WriteMJPEGAVI(w, frames)
+}
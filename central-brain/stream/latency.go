@@ -0,0 +1,244 @@
+package stream
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"central-brain/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Frame envelope headers. On ingest they may also be sent as multipart form
+// fields (capture_timestamp, sequence, engine_id, resolution) or inside the
+// meta JSON. X-Timestamp is taken by request signing, hence X-Capture-Timestamp.
+const (
+	HeaderCaptureTimestamp = "X-Capture-Timestamp" // RFC3339 or Unix milliseconds
+	HeaderSequence         = "X-Sequence"
+	HeaderEngineID         = "X-Engine-ID"
+	HeaderResolution       = "X-Resolution" // WIDTHxHEIGHT
+)
+
+// latencyBucketsMs are the upper bounds of the capture-to-ingest histogram.
+var latencyBucketsMs = []float64{10, 25, 50, 100, 250, 500, 1000, 2500, 5000}
+
+// LatencyStats is a camera's capture-to-ingest latency histogram and
+// sequence accounting since its hub started.
+type LatencyStats struct {
+	CameraID     string          `json:"camera_id"`
+	Frames       uint64          `json:"frames"` // frames with a capture timestamp
+	LastMs       float64         `json:"last_ms"`
+	AvgMs        float64         `json:"avg_ms"`
+	P50Ms        float64         `json:"p50_ms"` // bucket upper bound
+	P95Ms        float64         `json:"p95_ms"`
+	P99Ms        float64         `json:"p99_ms"`
+	MaxMs        float64         `json:"max_ms"`
+	Buckets      []LatencyBucket `json:"buckets"`
+	ClockSkewed  uint64          `json:"clock_skewed"` // capture time after receipt: engine clock ahead
+	EngineID     string          `json:"engine_id,omitempty"`
+	LastSequence uint64          `json:"last_sequence,omitempty"`
+	OutOfOrder   uint64          `json:"out_of_order"` // sequence not above the last one
+	Missing      uint64          `json:"missing"`      // sequence numbers skipped
+}
+
+// LatencyBucket counts frames with a latency up to Le milliseconds ("+Inf" for the rest).
+type LatencyBucket struct {
+	Le    string `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// latencyTracker accumulates LatencyStats; MJPEGHub guards it with its mutex.
+type latencyTracker struct {
+	counts      []uint64
+	frames      uint64
+	sumMs       float64
+	lastMs      float64
+	maxMs       float64
+	skewed      uint64
+	engineID    string
+	lastSeq     uint64
+	outOfOrder  uint64
+	missingSeqs uint64
+}
+
+func (t *latencyTracker) observe(f Frame) {
+	if f.Meta == nil {
+		return
+	}
+	m := f.Meta
+	if !m.CaptureTimestamp.IsZero() {
+		if t.counts == nil {
+			t.counts = make([]uint64, len(latencyBucketsMs)+1)
+		}
+		ms := float64(f.Timestamp.Sub(m.CaptureTimestamp)) / float64(time.Millisecond)
+		if ms < 0 {
+			t.skewed++
+			ms = 0
+		}
+		i := 0
+		for i < len(latencyBucketsMs) && ms > latencyBucketsMs[i] {
+			i++
+		}
+		t.counts[i]++
+		t.frames++
+		t.sumMs += ms
+		t.lastMs = ms
+		if ms > t.maxMs {
+			t.maxMs = ms
+		}
+	}
+
+	if m.Sequence == 0 {
+		return
+	}
+	switch {
+	case m.EngineID != t.engineID || t.lastSeq == 0 || m.Sequence == 1:
+		// New or restarted engine: its sequence starts over
+		t.engineID = m.EngineID
+		t.lastSeq = m.Sequence
+	case m.Sequence <= t.lastSeq:
+		t.outOfOrder++
+	default:
+		t.missingSeqs += m.Sequence - t.lastSeq - 1
+		t.lastSeq = m.Sequence
+	}
+}
+
+func (t *latencyTracker) stats() LatencyStats {
+	st := LatencyStats{
+		Frames:       t.frames,
+		LastMs:       t.lastMs,
+		MaxMs:        t.maxMs,
+		ClockSkewed:  t.skewed,
+		EngineID:     t.engineID,
+		LastSequence: t.lastSeq,
+		OutOfOrder:   t.outOfOrder,
+		Missing:      t.missingSeqs,
+		Buckets:      make([]LatencyBucket, len(latencyBucketsMs)+1),
+	}
+	for i := range st.Buckets {
+		st.Buckets[i].Le = "+Inf"
+		if i < len(latencyBucketsMs) {
+			st.Buckets[i].Le = strconv.FormatFloat(latencyBucketsMs[i], 'f', -1, 64)
+		}
+		if t.counts != nil {
+			st.Buckets[i].Count = t.counts[i]
+		}
+	}
+	if t.frames == 0 {
+		return st
+	}
+	st.AvgMs = t.sumMs / float64(t.frames)
+	st.P50Ms = t.quantile(0.50)
+	st.P95Ms = t.quantile(0.95)
+	st.P99Ms = t.quantile(0.99)
+	return st
+}
+
+// quantile returns the upper bound of the bucket holding the q-th frame, or
+// the maximum when it falls in the open-ended bucket.
+func (t *latencyTracker) quantile(q float64) float64 {
+	rank := uint64(q*float64(t.frames) + 0.5)
+	if rank == 0 {
+		rank = 1
+	}
+	var seen uint64
+	for i, n := range t.counts {
+		seen += n
+		if seen >= rank {
+			if i < len(latencyBucketsMs) && latencyBucketsMs[i] < t.maxMs {
+				return latencyBucketsMs[i]
+			}
+			return t.maxMs
+		}
+	}
+	return t.maxMs
+}
+
+// Latency returns the hub's capture-to-ingest latency and sequence stats.
+func (h *MJPEGHub) Latency() LatencyStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.latency.stats()
+}
+
+// readEnvelope fills meta's envelope from the request headers and multipart
+// fields, keeping values already sent in the meta JSON. It returns nil when
+// the frame came without any metadata.
+func readEnvelope(c *fiber.Ctx, meta *models.FrameMeta, frame []byte) (*models.FrameMeta, error) {
+	field := func(header, form string) string {
+		if v := c.Get(header); v != "" {
+			return v
+		}
+		if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+			return c.FormValue(form)
+		}
+		return ""
+	}
+	capture := field(HeaderCaptureTimestamp, "capture_timestamp")
+	sequence := field(HeaderSequence, "sequence")
+	engineID := field(HeaderEngineID, "engine_id")
+	resolution := field(HeaderResolution, "resolution")
+	if meta == nil {
+		if capture == "" && sequence == "" && engineID == "" && resolution == "" {
+			return nil, nil
+		}
+		meta = &models.FrameMeta{}
+	}
+
+	if capture != "" && meta.CaptureTimestamp.IsZero() {
+		ts, err := parseCaptureTime(capture)
+		if err != nil {
+			return nil, err
+		}
+		meta.CaptureTimestamp = ts
+	}
+	if sequence != "" && meta.Sequence == 0 {
+		n, err := strconv.ParseUint(sequence, 10, 64)
+		if err != nil {
+			return nil, errors.New(HeaderSequence + " must be an unsigned integer")
+		}
+		meta.Sequence = n
+	}
+	if meta.EngineID == "" {
+		// Values may point into the request buffer
+		meta.EngineID = strings.Clone(engineID)
+	}
+	if meta.Resolution == "" {
+		meta.Resolution = strings.Clone(resolution)
+	}
+	if meta.Resolution == "" {
+		if w, h, ok := jpegSize(frame); ok {
+			meta.Resolution = fmt.Sprintf("%dx%d", w, h)
+		}
+	}
+	return meta, nil
+}
+
+func parseCaptureTime(raw string) (time.Time, error) {
+	if ms, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		return time.UnixMilli(ms), nil
+	}
+	ts, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, errors.New(HeaderCaptureTimestamp + " must be RFC3339 or Unix milliseconds")
+	}
+	return ts, nil
+}
+
+// writeEnvelopeHeaders adds the capture time and sequence to an MJPEG part.
+func writeEnvelopeHeaders(w *bufio.Writer, meta *models.FrameMeta) {
+	if meta == nil {
+		return
+	}
+	if !meta.CaptureTimestamp.IsZero() {
+		w.WriteString(HeaderCaptureTimestamp + ": " + meta.CaptureTimestamp.UTC().Format(time.RFC3339Nano) + "\r\n")
+	}
+	if meta.Sequence > 0 {
+		w.WriteString(HeaderSequence + ": " + strconv.FormatUint(meta.Sequence, 10) + "\r\n")
+	}
+}
@@ -0,0 +1,148 @@
+package stream
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// maxLatestWait caps ?wait= so a long poll stays below common proxy timeouts.
+const maxLatestWait = 30 * time.Second
+
+// HeaderFrameSequence carries the hub's frame number on /latest responses.
+const HeaderFrameSequence = "X-Frame-Sequence"
+
+// latestFrame returns the latest frame, its number in the hub (frames
+// received so far) and a channel closed when a newer frame arrives.
+func (h *MJPEGHub) latestFrame() (Frame, uint64, <-chan struct{}) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.latest, h.frames, h.changed
+}
+
+// etag identifies frame seq of this hub. The hub's start time is part of it
+// so a re-registered camera, whose count starts over, never matches.
+func (h *MJPEGHub) etag(seq uint64) string {
+	return fmt.Sprintf(`"%x-%d"`, h.started.UnixNano(), seq)
+}
+
+// etagSequence returns the frame number of one of the entity tags in an
+// If-None-Match header when it belongs to this hub.
+func (h *MJPEGHub) etagSequence(header string) (uint64, bool) {
+	prefix := fmt.Sprintf(`"%x-`, h.started.UnixNano())
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if !strings.HasPrefix(tag, prefix) || !strings.HasSuffix(tag, `"`) {
+			continue
+		}
+		if n, err := strconv.ParseUint(tag[len(prefix):len(tag)-1], 10, 64); err == nil {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+// LatestFrame returns the latest frame as a single JPEG image (for polling).
+// GET /stream/:camera_id/latest?wait=10&after=4711
+//
+// The response carries an ETag and X-Frame-Sequence; a matching
+// If-None-Match is answered with 304. With wait (seconds, at most 30) the
+// request blocks until a frame newer than after (or than the If-None-Match
+// frame) arrives; when none does it answers 304, or 204 when the request was
+// not conditional. Callers for whom unmasked reports false get the frame
+// with the camera's privacy masks applied.
+func LatestFrame(reg *Registry, unmasked func(*fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		hub, ok := reg.Hub(c.Params("camera_id"))
+		if !ok {
+			return unknownCamera(c)
+		}
+
+		var wait time.Duration
+		if raw := c.Query("wait"); raw != "" {
+			secs, err := strconv.ParseFloat(raw, 64)
+			if err != nil || secs < 0 || secs > maxLatestWait.Seconds() {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":   "bad_request",
+					"message": fmt.Sprintf("wait must be between 0 and %d seconds", int(maxLatestWait.Seconds())),
+				})
+			}
+			wait = time.Duration(secs * float64(time.Second))
+		}
+
+		frame, seq, changed := hub.latestFrame()
+		known, conditional := hub.etagSequence(c.Get(fiber.HeaderIfNoneMatch))
+		if raw := c.Query("after"); raw != "" {
+			n, err := strconv.ParseUint(raw, 10, 64)
+			if err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":   "bad_request",
+					"message": "after must be a frame sequence number",
+				})
+			}
+			// A number above the hub's count is from before the camera was re-registered
+			if n <= seq {
+				known = n
+			}
+		} else if !conditional {
+			known = seq
+		}
+
+		if wait > 0 && seq <= known {
+			timer := time.NewTimer(wait)
+			defer timer.Stop()
+		poll:
+			for seq <= known {
+				select {
+				case <-changed:
+					frame, seq, changed = hub.latestFrame()
+				case <-timer.C:
+					break poll
+				}
+			}
+		}
+
+		c.Set("Cache-Control", "private, no-cache")
+		if len(frame.Data) == 0 {
+			return c.Status(fiber.StatusNoContent).SendString("No frame available")
+		}
+		c.Set(fiber.HeaderETag, hub.etag(seq))
+		c.Set(HeaderFrameSequence, strconv.FormatUint(seq, 10))
+		c.Set(fiber.HeaderLastModified, frame.Timestamp.UTC().Format(http.TimeFormat))
+		if conditional && seq == known {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		if wait > 0 && seq <= known {
+			return c.SendStatus(fiber.StatusNoContent)
+		}
+
+		if m := frame.Meta; m != nil {
+			if !m.CaptureTimestamp.IsZero() {
+				c.Set(HeaderCaptureTimestamp, m.CaptureTimestamp.UTC().Format(time.RFC3339Nano))
+			}
+			if m.Sequence > 0 {
+				c.Set(HeaderSequence, strconv.FormatUint(m.Sequence, 10))
+			}
+		}
+		data := frame.Data
+		if !unmasked(c) {
+			cam, ok := reg.Get(c.Params("camera_id"))
+			if !ok {
+				return unknownCamera(c)
+			}
+			var err error
+			if data, err = MaskFrame(data, cam); err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":   "server_error",
+					"message": "Failed to mask frame",
+				})
+			}
+		}
+		c.Set("Content-Type", "image/jpeg")
+		return c.Send(data)
+	}
+}
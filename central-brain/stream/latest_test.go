@@ -0,0 +1,123 @@
+package stream
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"central-brain/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// pushFrames sets n frames on hub and waits until the hub has taken them.
+func pushFrames(t *testing.T, hub *MJPEGHub, n int) {
+	t.Helper()
+	_, seq, _ := hub.latestFrame()
+	for i := 0; i < n; i++ {
+		hub.SetFrame([]byte{0xFF, 0xD8, byte(i), 0xFF, 0xD9})
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if _, got, _ := hub.latestFrame(); got >= seq+uint64(n) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("hub did not take the frames")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func newLatestApp(t *testing.T) (*fiber.App, *MJPEGHub) {
+	t.Helper()
+	reg := NewRegistry(nil)
+	if _, err := reg.Upsert(context.Background(), models.Camera{ID: "cam1"}); err != nil {
+		t.Fatal(err)
+	}
+	hub, _ := reg.Hub("cam1")
+	t.Cleanup(hub.Stop)
+	app := fiber.New()
+	app.Get("/stream/:camera_id/latest", LatestFrame(reg, func(*fiber.Ctx) bool { return true }))
+	return app, hub
+}
+
+func TestLatestFrame(t *testing.T) {
+	tests := []struct {
+		name        string
+		frames      int
+		path        string
+		ifNoneMatch func(h *MJPEGHub) string
+		status      int
+		seq         string
+	}{
+		{name: "unknown camera", path: "/stream/cam9/latest", status: 404},
+		{name: "no frame yet", status: 204},
+		{name: "latest frame", frames: 2, status: 200, seq: "2"},
+		{name: "current etag", frames: 2, ifNoneMatch: func(h *MJPEGHub) string { return h.etag(2) }, status: 304, seq: "2"},
+		{name: "weak current etag", frames: 2, ifNoneMatch: func(h *MJPEGHub) string { return `"x", W/` + h.etag(2) }, status: 304, seq: "2"},
+		{name: "stale etag", frames: 2, ifNoneMatch: func(h *MJPEGHub) string { return h.etag(1) }, status: 200, seq: "2"},
+		{name: "etag of an earlier hub", frames: 2, ifNoneMatch: func(*MJPEGHub) string { return `"1-2"` }, status: 200, seq: "2"},
+		{name: "wait without a new frame", frames: 1, path: "/stream/cam1/latest?wait=0.05", status: 204, seq: "1"},
+		{name: "wait on current etag", frames: 1, path: "/stream/cam1/latest?wait=0.05",
+			ifNoneMatch: func(h *MJPEGHub) string { return h.etag(1) }, status: 304, seq: "1"},
+		{name: "wait after an older frame", frames: 2, path: "/stream/cam1/latest?wait=5&after=1", status: 200, seq: "2"},
+		{name: "after from before re-registering", frames: 2, path: "/stream/cam1/latest?wait=5&after=99", status: 200, seq: "2"},
+		{name: "wait too long", path: "/stream/cam1/latest?wait=31", status: 400},
+		{name: "negative wait", path: "/stream/cam1/latest?wait=-1", status: 400},
+		{name: "bad after", path: "/stream/cam1/latest?after=x", status: 400},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app, hub := newLatestApp(t)
+			if tt.frames > 0 {
+				pushFrames(t, hub, tt.frames)
+			}
+			path := tt.path
+			if path == "" {
+				path = "/stream/cam1/latest"
+			}
+			req := httptest.NewRequest("GET", path, nil)
+			if tt.ifNoneMatch != nil {
+				req.Header.Set(fiber.HeaderIfNoneMatch, tt.ifNoneMatch(hub))
+			}
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+			if got := resp.Header.Get(HeaderFrameSequence); got != tt.seq {
+				t.Errorf("sequence %q, want %q", got, tt.seq)
+			}
+			if tt.seq != "" && resp.Header.Get(fiber.HeaderETag) == "" {
+				t.Error("no ETag")
+			}
+		})
+	}
+}
+
+func TestLatestFrameLongPollWakes(t *testing.T) {
+	app, hub := newLatestApp(t)
+	pushFrames(t, hub, 1)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		hub.SetFrame([]byte{0xFF, 0xD8, 0x01, 0xFF, 0xD9})
+	}()
+	req := httptest.NewRequest("GET", "/stream/cam1/latest?wait=5", nil)
+	req.Header.Set(fiber.HeaderIfNoneMatch, hub.etag(1))
+	start := time.Now()
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != 200 || resp.Header.Get(HeaderFrameSequence) != "2" {
+		t.Errorf("status %d, sequence %q; want 200, 2", resp.StatusCode, resp.Header.Get(HeaderFrameSequence))
+	}
+	if waited := time.Since(start); waited > 4*time.Second {
+		t.Errorf("answered after %s, not when the frame arrived", waited)
+	}
+}
@@ -0,0 +1,270 @@
+package stream
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"sort"
+	"strings"
+
+	"central-brain/models"
+)
+
+// Overlay style. Colors follow the AI engine's old in-frame drawing: the zone
+// is green when clear, boxes turn yellow inside it and red once alerting.
+const (
+	overlayQuality = 80
+	zoneAlpha      = 0.3
+	lineWidth      = 2
+	fontScale      = 2
+)
+
+var (
+	colorClear   = color.RGBA{0, 255, 0, 255}
+	colorWarning = color.RGBA{255, 255, 0, 255}
+	colorAlert   = color.RGBA{255, 0, 0, 255}
+	colorOutside = color.RGBA{0, 255, 255, 255}
+	colorText    = color.RGBA{0, 0, 0, 255}
+)
+
+// renderOverlay draws the danger zone polygon and the detections of meta onto
+// a JPEG frame and re-encodes it. roi and meta may be empty.
+func renderOverlay(data []byte, roi [][2]int, meta *models.FrameMeta) ([]byte, error) {
+	src, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	dst := image.NewRGBA(src.Bounds())
+	draw.Draw(dst, dst.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	var detections []models.FrameDetection
+	if meta != nil {
+		detections = meta.Detections
+	}
+
+	zone := colorClear
+	for _, d := range detections {
+		if d.InROI && d.Alert {
+			zone = colorAlert
+			break
+		}
+		if d.InROI {
+			zone = colorWarning
+		}
+	}
+	if len(roi) >= 3 {
+		fillPolygon(dst, roi, zone, zoneAlpha)
+		for i := range roi {
+			p, q := roi[i], roi[(i+1)%len(roi)]
+			drawLine(dst, p[0], p[1], q[0], q[1], zone)
+		}
+	}
+
+	for _, d := range detections {
+		c := colorOutside
+		switch {
+		case d.InROI && d.Alert:
+			c = colorAlert
+		case d.InROI:
+			c = colorWarning
+		}
+		x1, y1, x2, y2 := d.BBox[0], d.BBox[1], d.BBox[2], d.BBox[3]
+		drawLine(dst, x1, y1, x2, y1, c)
+		drawLine(dst, x2, y1, x2, y2, c)
+		drawLine(dst, x2, y2, x1, y2, c)
+		drawLine(dst, x1, y2, x1, y1, c)
+		drawLabel(dst, x1, y1, detectionLabel(d), c)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: overlayQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// detectionLabel formats e.g. "PERSON #12 87% 6.2S".
+func detectionLabel(d models.FrameDetection) string {
+	label := fmt.Sprintf("%s #%d %.0f%%", d.Class, d.TrackID, d.Confidence*100)
+	if d.InROI && d.DurationSeconds > 0 {
+		label += fmt.Sprintf(" %.1fs", d.DurationSeconds)
+	}
+	return strings.ToUpper(label)
+}
+
+// fillPolygon blends c over the polygon's interior with the given opacity.
+func fillPolygon(img *image.RGBA, poly [][2]int, c color.RGBA, alpha float64) {
+	polygonSpans(img.Bounds(), poly, func(y, from, to int) {
+		for x := from; x < to; x++ {
+			blend(img, x, y, c, alpha)
+		}
+	})
+}
+
+// polygonSpans calls fn with the horizontal runs [from, to) of each row of b
+// inside the polygon, using an even-odd scanline fill.
+func polygonSpans(b image.Rectangle, poly [][2]int, fn func(y, from, to int)) {
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		fy := float64(y) + 0.5
+		var xs []float64
+		for i := range poly {
+			p, q := poly[i], poly[(i+1)%len(poly)]
+			y0, y1 := float64(p[1]), float64(q[1])
+			if (y0 <= fy) == (y1 <= fy) {
+				continue
+			}
+			xs = append(xs, float64(p[0])+(fy-y0)*float64(q[0]-p[0])/(y1-y0))
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			from, to := int(xs[i]+0.5), int(xs[i+1]+0.5)
+			if from < b.Min.X {
+				from = b.Min.X
+			}
+			if to > b.Max.X {
+				to = b.Max.X
+			}
+			if from < to {
+				fn(y, from, to)
+			}
+		}
+	}
+}
+
+func blend(img *image.RGBA, x, y int, c color.RGBA, alpha float64) {
+	i := img.PixOffset(x, y)
+	p := img.Pix[i : i+3 : i+3]
+	p[0] = uint8(float64(p[0])*(1-alpha) + float64(c.R)*alpha)
+	p[1] = uint8(float64(p[1])*(1-alpha) + float64(c.G)*alpha)
+	p[2] = uint8(float64(p[2])*(1-alpha) + float64(c.B)*alpha)
+}
+
+// drawLine draws a lineWidth-thick line with Bresenham's algorithm.
+func drawLine(img *image.RGBA, x0, y0, x1, y1 int, c color.RGBA) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+	err := dx + dy
+	for {
+		fillRect(img, image.Rect(x0, y0, x0+lineWidth, y0+lineWidth), c)
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func fillRect(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	draw.Draw(img, r.Intersect(img.Bounds()), image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// drawLabel writes text on a filled background above (x, y), or just inside
+// the box when there is no room above it.
+func drawLabel(img *image.RGBA, x, y int, text string, bg color.RGBA) {
+	const (
+		pad     = 2
+		advance = (glyphWidth + 1) * fontScale
+		height  = glyphHeight*fontScale + 2*pad
+	)
+	b := img.Bounds()
+	top := y - height
+	if top < b.Min.Y {
+		top = y + lineWidth
+	}
+	width := len(text)*advance + 2*pad
+	if x+width > b.Max.X {
+		x = b.Max.X - width
+	}
+	if x < b.Min.X {
+		x = b.Min.X
+	}
+	fillRect(img, image.Rect(x, top, x+width, top+height), bg)
+
+	for i, r := range text {
+		glyph, ok := font5x7[r]
+		if !ok {
+			glyph = font5x7['?']
+		}
+		gx := x + pad + i*advance
+		for row, bits := range glyph {
+			for col := 0; col < glyphWidth; col++ {
+				if bits&(1<<(glyphWidth-1-col)) == 0 {
+					continue
+				}
+				px, py := gx+col*fontScale, top+pad+row*fontScale
+				fillRect(img, image.Rect(px, py, px+fontScale, py+fontScale), colorText)
+			}
+		}
+	}
+}
+
+// 5x7 bitmap font for labels; one byte per row, the low five bits are the
+// pixels from left to right. Labels are upper-cased before drawing.
+const (
+	glyphWidth  = 5
+	glyphHeight = 7
+)
+
+var font5x7 = map[rune][glyphHeight]byte{
+	' ': {},
+	'0': {0x0E, 0x11, 0x13, 0x15, 0x19, 0x11, 0x0E},
+	'1': {0x04, 0x0C, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'2': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x08, 0x1F},
+	'3': {0x1F, 0x02, 0x04, 0x02, 0x01, 0x11, 0x0E},
+	'4': {0x02, 0x06, 0x0A, 0x12, 0x1F, 0x02, 0x02},
+	'5': {0x1F, 0x10, 0x1E, 0x01, 0x01, 0x11, 0x0E},
+	'6': {0x06, 0x08, 0x10, 0x1E, 0x11, 0x11, 0x0E},
+	'7': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x08, 0x08},
+	'8': {0x0E, 0x11, 0x11, 0x0E, 0x11, 0x11, 0x0E},
+	'9': {0x0E, 0x11, 0x11, 0x0F, 0x01, 0x02, 0x0C},
+	'A': {0x0E, 0x11, 0x11, 0x11, 0x1F, 0x11, 0x11},
+	'B': {0x1E, 0x11, 0x11, 0x1E, 0x11, 0x11, 0x1E},
+	'C': {0x0E, 0x11, 0x10, 0x10, 0x10, 0x11, 0x0E},
+	'D': {0x1C, 0x12, 0x11, 0x11, 0x11, 0x12, 0x1C},
+	'E': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x1F},
+	'F': {0x1F, 0x10, 0x10, 0x1E, 0x10, 0x10, 0x10},
+	'G': {0x0E, 0x11, 0x10, 0x17, 0x11, 0x11, 0x0F},
+	'H': {0x11, 0x11, 0x11, 0x1F, 0x11, 0x11, 0x11},
+	'I': {0x0E, 0x04, 0x04, 0x04, 0x04, 0x04, 0x0E},
+	'J': {0x07, 0x02, 0x02, 0x02, 0x02, 0x12, 0x0C},
+	'K': {0x11, 0x12, 0x14, 0x18, 0x14, 0x12, 0x11},
+	'L': {0x10, 0x10, 0x10, 0x10, 0x10, 0x10, 0x1F},
+	'M': {0x11, 0x1B, 0x15, 0x15, 0x11, 0x11, 0x11},
+	'N': {0x11, 0x11, 0x19, 0x15, 0x13, 0x11, 0x11},
+	'O': {0x0E, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'P': {0x1E, 0x11, 0x11, 0x1E, 0x10, 0x10, 0x10},
+	'Q': {0x0E, 0x11, 0x11, 0x11, 0x15, 0x12, 0x0D},
+	'R': {0x1E, 0x11, 0x11, 0x1E, 0x14, 0x12, 0x11},
+	'S': {0x0F, 0x10, 0x10, 0x0E, 0x01, 0x01, 0x1E},
+	'T': {0x1F, 0x04, 0x04, 0x04, 0x04, 0x04, 0x04},
+	'U': {0x11, 0x11, 0x11, 0x11, 0x11, 0x11, 0x0E},
+	'V': {0x11, 0x11, 0x11, 0x11, 0x11, 0x0A, 0x04},
+	'W': {0x11, 0x11, 0x11, 0x15, 0x15, 0x15, 0x0A},
+	'X': {0x11, 0x11, 0x0A, 0x04, 0x0A, 0x11, 0x11},
+	'Y': {0x11, 0x11, 0x11, 0x0A, 0x04, 0x04, 0x04},
+	'Z': {0x1F, 0x01, 0x02, 0x04, 0x08, 0x10, 0x1F},
+	'.': {0x00, 0x00, 0x00, 0x00, 0x00, 0x0C, 0x0C},
+	'#': {0x0A, 0x0A, 0x1F, 0x0A, 0x1F, 0x0A, 0x0A},
+	':': {0x00, 0x0C, 0x0C, 0x00, 0x0C, 0x0C, 0x00},
+	'-': {0x00, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00},
+	'%': {0x18, 0x19, 0x02, 0x04, 0x08, 0x13, 0x03},
+	'/': {0x00, 0x01, 0x02, 0x04, 0x08, 0x10, 0x00},
+	'(': {0x02, 0x04, 0x08, 0x08, 0x08, 0x04, 0x02},
+	')': {0x08, 0x04, 0x02, 0x02, 0x02, 0x04, 0x08},
+	'_': {0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x1F},
+	'?': {0x0E, 0x11, 0x01, 0x02, 0x04, 0x00, 0x04},
+}
@@ -0,0 +1,143 @@
+package stream
+
+import (
+	"bufio"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// Playback speed bounds and the longest pause between two frames, so gaps in
+// the recording (camera offline) do not stall the stream.
+const (
+	maxPlaybackSpeed = 32
+	minPlaybackSpeed = 0.25
+	maxPlaybackGap   = time.Second
+)
+
+var errClientGone = errors.New("client disconnected")
+
+// Playback serves archived frames as MJPEG, paced by their original timestamps.
+// The archive holds the unmasked originals; callers for whom unmasked reports
+// false get the camera's privacy masks applied.
+// GET /stream/:camera_id/playback?from=RFC3339&to=RFC3339&speed=1
+func Playback(a *Archive, reg *Registry, unmasked func(*fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cameraID := c.Params("camera_id")
+		from, err := time.Parse(time.RFC3339, c.Query("from"))
+		if err != nil {
+			return badPlayback(c, "from must be an RFC3339 time")
+		}
+		to := time.Now()
+		if raw := c.Query("to"); raw != "" {
+			if to, err = time.Parse(time.RFC3339, raw); err != nil {
+				return badPlayback(c, "to must be an RFC3339 time")
+			}
+		}
+		if !to.After(from) {
+			return badPlayback(c, "to must be after from")
+		}
+		speed := 1.0
+		if raw := c.Query("speed"); raw != "" {
+			speed, err = strconv.ParseFloat(raw, 64)
+			if err != nil || speed < minPlaybackSpeed || speed > maxPlaybackSpeed {
+				return badPlayback(c, "speed must be between 0.25 and 32")
+			}
+		}
+
+		if !a.hasRange(cameraID, from, to) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   "not_found",
+				"message": "No archived frames for " + cameraID + " in the requested range",
+			})
+		}
+
+		mask := func(f Frame) ([]byte, error) { return f.Data, nil }
+		if !unmasked(c) {
+			cam, ok := reg.Get(cameraID)
+			if !ok {
+				// No mask configuration to apply
+				return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+					"error":   "forbidden",
+					"message": "Unmasked archive of removed camera " + cameraID + " is restricted to DAOP_ADMIN",
+				})
+			}
+			mask = func(f Frame) ([]byte, error) { return MaskFrame(f.Data, cam) }
+		}
+
+		cameraID = utils.CopyString(cameraID) // the stream writer outlives the request
+		c.Set("Content-Type", "multipart/x-mixed-replace; boundary=frame")
+		c.Set("Cache-Control", "no-cache, no-store, must-revalidate")
+
+		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+			var prev time.Time
+			a.Replay(cameraID, from, to, func(f Frame) error {
+				if !prev.IsZero() {
+					gap := time.Duration(float64(f.Timestamp.Sub(prev)) / speed)
+					if gap > maxPlaybackGap {
+						gap = maxPlaybackGap
+					}
+					time.Sleep(gap)
+				}
+				prev = f.Timestamp
+
+				data, err := mask(f)
+				if err != nil {
+					return nil // skip frames that cannot be masked
+				}
+				w.WriteString("--frame\r\n")
+				w.WriteString("Content-Type: image/jpeg\r\n")
+				w.WriteString("X-Timestamp: " + f.Timestamp.Format(time.RFC3339Nano) + "\r\n")
+				w.WriteString("Content-Length: ")
+				w.WriteString(strconv.Itoa(len(data)))
+				w.WriteString("\r\n\r\n")
+				w.Write(data)
+				w.WriteString("\r\n")
+				if err := w.Flush(); err != nil {
+					return errClientGone
+				}
+				return nil
+			})
+		})
+		return nil
+	}
+}
+
+// ArchiveIndex lists the archived time ranges of a camera.
+// GET /stream/:camera_id/archive
+func ArchiveIndex(a *Archive) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		segments := a.Segments(c.Params("camera_id"))
+		var total int64
+		for _, seg := range segments {
+			total += seg.Bytes
+		}
+		if segments == nil {
+			segments = []ArchiveSegment{}
+		}
+		return c.JSON(fiber.Map{
+			"camera_id":   c.Params("camera_id"),
+			"segments":    segments,
+			"total_bytes": total,
+		})
+	}
+}
+
+func (a *Archive) hasRange(cameraID string, from, to time.Time) bool {
+	for _, seg := range a.Segments(cameraID) {
+		if !seg.End.Before(from) && !seg.Start.After(to) {
+			return true
+		}
+	}
+	return false
+}
+
+func badPlayback(c *fiber.Ctx, msg string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "bad_request",
+		"message": msg,
+	})
+}
@@ -0,0 +1,99 @@
+package stream
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+
+	"central-brain/models"
+)
+
+// pixelBlocks is the number of mosaic blocks across the frame width when
+// pixelating, so faces stay unrecognizable at any resolution.
+const (
+	pixelBlocks  = 32
+	minPixelSize = 8
+)
+
+// MaskFrame hides the camera's privacy mask polygons in a JPEG frame. Frames
+// of cameras without masks are returned unchanged.
+func MaskFrame(data []byte, cam models.Camera) ([]byte, error) {
+	if len(cam.PrivacyMasks) == 0 {
+		return data, nil
+	}
+	src, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	img := image.NewRGBA(src.Bounds())
+	draw.Draw(img, img.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	b := img.Bounds()
+	inside := make([]bool, b.Dx()*b.Dy())
+	for _, poly := range cam.PrivacyMasks {
+		polygonSpans(b, poly, func(y, from, to int) {
+			row := (y - b.Min.Y) * b.Dx()
+			for x := from; x < to; x++ {
+				inside[row+x-b.Min.X] = true
+			}
+		})
+	}
+
+	if cam.PrivacyMaskMode == models.PrivacyMaskBlack {
+		for i, in := range inside {
+			if in {
+				setRGB(img, b.Min.X+i%b.Dx(), b.Min.Y+i/b.Dx(), 0, 0, 0)
+			}
+		}
+	} else {
+		pixelate(img, inside)
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: overlayQuality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// pixelate replaces every masked pixel with the average color of its mosaic block.
+func pixelate(img *image.RGBA, inside []bool) {
+	b := img.Bounds()
+	size := b.Dx() / pixelBlocks
+	if size < minPixelSize {
+		size = minPixelSize
+	}
+	for by := b.Min.Y; by < b.Max.Y; by += size {
+		for bx := b.Min.X; bx < b.Max.X; bx += size {
+			block := image.Rect(bx, by, bx+size, by+size).Intersect(b)
+			masked := false
+			var r, g, bl, n int
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					masked = masked || inside[(y-b.Min.Y)*b.Dx()+x-b.Min.X]
+					i := img.PixOffset(x, y)
+					r += int(img.Pix[i])
+					g += int(img.Pix[i+1])
+					bl += int(img.Pix[i+2])
+					n++
+				}
+			}
+			if !masked {
+				continue
+			}
+			for y := block.Min.Y; y < block.Max.Y; y++ {
+				for x := block.Min.X; x < block.Max.X; x++ {
+					if inside[(y-b.Min.Y)*b.Dx()+x-b.Min.X] {
+						setRGB(img, x, y, uint8(r/n), uint8(g/n), uint8(bl/n))
+					}
+				}
+			}
+		}
+	}
+}
+
+func setRGB(img *image.RGBA, x, y int, r, g, b uint8) {
+	i := img.PixOffset(x, y)
+	img.Pix[i], img.Pix[i+1], img.Pix[i+2] = r, g, b
+}
@@ -0,0 +1,86 @@
+package stream
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"central-brain/models"
+)
+
+// checkerJPEG encodes a 64x64 frame of 4px black and white squares.
+func checkerJPEG(t *testing.T) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 64, 64))
+	for y := 0; y < 64; y++ {
+		for x := 0; x < 64; x++ {
+			if (x/4+y/4)%2 == 1 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func grayAt(t *testing.T, data []byte, x, y int) int {
+	t.Helper()
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, g, b, _ := img.At(x, y).RGBA()
+	return int((r + g + b) / 3 >> 8)
+}
+
+func TestMaskFrame(t *testing.T) {
+	frame := checkerJPEG(t)
+	square := [][2]int{{16, 16}, {48, 16}, {48, 48}, {16, 48}}
+
+	tests := []struct {
+		name     string
+		mode     string
+		min, max int // gray level inside the mask
+	}{
+		{name: "black", mode: models.PrivacyMaskBlack, min: 0, max: 24},
+		{name: "pixelate", mode: models.PrivacyMaskPixelate, min: 100, max: 156},
+		{name: "pixelate by default", min: 100, max: 156},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cam := models.Camera{ID: "cam1", PrivacyMasks: [][][2]int{square}, PrivacyMaskMode: tt.mode}
+			masked, err := MaskFrame(frame, cam)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// (26,26) is a black square and (30,26) a white one in the original
+			for _, p := range [][2]int{{26, 26}, {30, 26}, {40, 40}} {
+				if g := grayAt(t, masked, p[0], p[1]); g < tt.min || g > tt.max {
+					t.Errorf("gray %d at %v inside the mask, want %d-%d", g, p, tt.min, tt.max)
+				}
+			}
+			// Outside the mask the squares keep their contrast
+			if white, black := grayAt(t, masked, 6, 2), grayAt(t, masked, 2, 2); white-black < 200 {
+				t.Errorf("outside the mask white %d, black %d", white, black)
+			}
+		})
+	}
+}
+
+func TestMaskFrameWithoutMasks(t *testing.T) {
+	frame := []byte("not even a jpeg")
+	got, err := MaskFrame(frame, models.Camera{ID: "cam1"})
+	if err != nil || !bytes.Equal(got, frame) {
+		t.Errorf("MaskFrame = %q, %v; want the frame unchanged", got, err)
+	}
+
+	cam := models.Camera{ID: "cam1", PrivacyMasks: [][][2]int{{{0, 0}, {8, 0}, {8, 8}}}}
+	if _, err := MaskFrame(frame, cam); err == nil {
+		t.Error("masking a broken frame succeeded")
+	}
+}
@@ -0,0 +1,205 @@
+package stream
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"math"
+)
+
+// Analysis resolution. Frames are reduced to a grayscale image of this width
+// before measuring, which keeps a 1080p frame at a few milliseconds.
+const (
+	analysisWidth = 320
+	blockSize     = 16 // coverage is measured on blocks of this size
+	thumbWidth    = 64 // scene shift is searched on a thumbnail of this width
+	maxShift      = 8  // in thumbnail pixels
+)
+
+// QualityMetrics are the image measurements of one sampled frame.
+type QualityMetrics struct {
+	Brightness float64 `json:"brightness"` // mean luma 0-255
+	Saturated  float64 `json:"saturated"`  // fraction of near-white pixels (glare)
+	Sharpness  float64 `json:"sharpness"`  // variance of the Laplacian; low means blurred
+	Coverage   float64 `json:"coverage"`   // fraction of featureless blocks (paint, tape, lens cap)
+	ShiftX     int     `json:"shift_x"`    // scene offset against the reference, percent of width
+	ShiftY     int     `json:"shift_y"`    // scene offset against the reference, percent of height
+	Mismatch   float64 `json:"mismatch"`   // remaining difference after the shift, 0 = identical
+}
+
+// grayImage is a small luma plane.
+type grayImage struct {
+	w, h int
+	pix  []float64
+}
+
+func (g *grayImage) at(x, y int) float64 { return g.pix[y*g.w+x] }
+
+// decodeGray decodes a JPEG and box-downsamples its luma to the given width.
+func decodeGray(data []byte, width int) (*grayImage, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	if b.Dx() < width {
+		width = b.Dx()
+	}
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	luma := func(x, y int) float64 {
+		return float64(color.GrayModel.Convert(img.At(x, y)).(color.Gray).Y)
+	}
+	// JPEG frames decode to YCbCr; read the Y plane directly
+	if ycc, ok := img.(*image.YCbCr); ok {
+		luma = func(x, y int) float64 {
+			return float64(ycc.Y[ycc.YOffset(x, y)])
+		}
+	}
+
+	g := &grayImage{w: width, h: height, pix: make([]float64, width*height)}
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := b.Min.Y + (y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := b.Min.X + (x+1)*b.Dx()/width
+			var sum float64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sum += luma(sx, sy)
+				}
+			}
+			g.pix[y*width+x] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return g, nil
+}
+
+// measure computes every metric except the scene shift.
+func measure(g *grayImage) QualityMetrics {
+	var m QualityMetrics
+
+	var sum float64
+	saturated := 0
+	for _, v := range g.pix {
+		sum += v
+		if v >= 250 {
+			saturated++
+		}
+	}
+	m.Brightness = sum / float64(len(g.pix))
+	m.Saturated = float64(saturated) / float64(len(g.pix))
+
+	// Laplacian variance
+	var lsum, lsq float64
+	n := 0
+	for y := 1; y < g.h-1; y++ {
+		for x := 1; x < g.w-1; x++ {
+			l := g.at(x-1, y) + g.at(x+1, y) + g.at(x, y-1) + g.at(x, y+1) - 4*g.at(x, y)
+			lsum += l
+			lsq += l * l
+			n++
+		}
+	}
+	if n > 0 {
+		mean := lsum / float64(n)
+		m.Sharpness = lsq/float64(n) - mean*mean
+	}
+
+	// Featureless blocks
+	blocks, flat := 0, 0
+	for by := 0; by+blockSize <= g.h; by += blockSize {
+		for bx := 0; bx+blockSize <= g.w; bx += blockSize {
+			var s, sq float64
+			for y := by; y < by+blockSize; y++ {
+				for x := bx; x < bx+blockSize; x++ {
+					v := g.at(x, y)
+					s += v
+					sq += v * v
+				}
+			}
+			cnt := float64(blockSize * blockSize)
+			mean := s / cnt
+			if sq/cnt-mean*mean < 9 { // standard deviation below 3 grey levels
+				flat++
+			}
+			blocks++
+		}
+	}
+	if blocks > 0 {
+		m.Coverage = float64(flat) / float64(blocks)
+	}
+	return m
+}
+
+// thumbnail reduces g to thumbWidth and normalizes it to zero mean and unit
+// variance, so the shift search ignores overall lighting changes.
+func thumbnail(g *grayImage) *grayImage {
+	h := g.h * thumbWidth / g.w
+	t := &grayImage{w: thumbWidth, h: h, pix: make([]float64, thumbWidth*h)}
+	for y := 0; y < h; y++ {
+		for x := 0; x < thumbWidth; x++ {
+			var sum float64
+			cnt := 0
+			for sy := y * g.h / h; sy < (y+1)*g.h/h; sy++ {
+				for sx := x * g.w / thumbWidth; sx < (x+1)*g.w/thumbWidth; sx++ {
+					sum += g.at(sx, sy)
+					cnt++
+				}
+			}
+			if cnt > 0 {
+				t.pix[y*thumbWidth+x] = sum / float64(cnt)
+			}
+		}
+	}
+
+	var sum, sq float64
+	for _, v := range t.pix {
+		sum += v
+		sq += v * v
+	}
+	mean := sum / float64(len(t.pix))
+	std := math.Sqrt(sq/float64(len(t.pix)) - mean*mean)
+	if std < 1 {
+		std = 1
+	}
+	for i, v := range t.pix {
+		t.pix[i] = (v - mean) / std
+	}
+	return t
+}
+
+// sceneShift finds the offset of cur against ref with the lowest mean
+// absolute difference. It returns that offset, its difference, and the
+// difference without any offset.
+func sceneShift(ref, cur *grayImage) (dx, dy int, best, still float64) {
+	best = math.Inf(1)
+	for oy := -maxShift; oy <= maxShift; oy++ {
+		for ox := -maxShift; ox <= maxShift; ox++ {
+			var diff float64
+			n := 0
+			for y := maxShift; y < ref.h-maxShift; y++ {
+				for x := maxShift; x < ref.w-maxShift; x++ {
+					diff += math.Abs(ref.at(x, y) - cur.at(x+ox, y+oy))
+					n++
+				}
+			}
+			if n == 0 {
+				return 0, 0, 0, 0
+			}
+			diff /= float64(n)
+			if ox == 0 && oy == 0 {
+				still = diff
+			}
+			if diff < best {
+				best, dx, dy = diff, ox, oy
+			}
+		}
+	}
+	return dx, dy, best, still
+}
@@ -0,0 +1,207 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"central-brain/models"
+)
+
+// ErrInvalidCameraID is returned when a camera ID is not usable as a route segment.
+var ErrInvalidCameraID = errors.New("camera id must be 1-64 characters of letters, digits, '-' or '_'")
+
+// ErrInvalidROI is returned when a danger zone polygon has fewer than three points.
+var ErrInvalidROI = errors.New("roi must be a polygon of at least 3 [x, y] points")
+
+// ErrInvalidPrivacyMask is returned for a mask with fewer than three points or an unknown mode.
+var ErrInvalidPrivacyMask = errors.New("privacy_masks must be polygons of at least 3 [x, y] points and privacy_mask_mode PIXELATE or BLACK")
+
+// ErrCameraNotFound is returned when a camera is not registered.
+var ErrCameraNotFound = errors.New("camera not found")
+
+var cameraIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// CameraStore persists camera configuration for the registry.
+type CameraStore interface {
+	ListCameras(ctx context.Context) ([]models.Camera, error)
+	UpsertCamera(ctx context.Context, cam models.Camera) error
+	DeleteCamera(ctx context.Context, id string) error
+}
+
+// Registry owns one MJPEGHub per registered camera, keyed by camera ID.
+type Registry struct {
+	mu      sync.RWMutex
+	cameras map[string]models.Camera
+	hubs    map[string]*MJPEGHub
+	store   CameraStore
+	watch   []func(cam models.Camera, removed bool)
+}
+
+// NewRegistry creates an empty registry. store may be nil for memory-only operation.
+func NewRegistry(store CameraStore) *Registry {
+	return &Registry{
+		cameras: make(map[string]models.Camera),
+		hubs:    make(map[string]*MJPEGHub),
+		store:   store,
+	}
+}
+
+// Load starts hubs for all persisted cameras. When the store holds no cameras,
+// defaults are registered (and persisted) instead so a fresh install has streams.
+func (r *Registry) Load(ctx context.Context, defaults []models.Camera) error {
+	var cams []models.Camera
+	if r.store != nil {
+		list, err := r.store.ListCameras(ctx)
+		if err != nil {
+			return err
+		}
+		cams = list
+	}
+
+	if len(cams) == 0 {
+		for _, cam := range defaults {
+			if _, err := r.Upsert(ctx, cam); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, cam := range cams {
+		r.cameras[cam.ID] = cam
+		r.startHubLocked(cam.ID)
+		r.notifyLocked(cam, false)
+	}
+	return nil
+}
+
+// OnChange registers fn to be called after a camera is loaded, added, updated or removed.
+// Call it before Load so persisted cameras are reported too.
+func (r *Registry) OnChange(fn func(cam models.Camera, removed bool)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watch = append(r.watch, fn)
+}
+
+// Hub returns the MJPEG hub for a camera.
+func (r *Registry) Hub(id string) (*MJPEGHub, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hub, ok := r.hubs[id]
+	return hub, ok
+}
+
+// Get returns the configuration of a camera.
+func (r *Registry) Get(id string) (models.Camera, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cam, ok := r.cameras[id]
+	return cam, ok
+}
+
+// List returns all cameras sorted by ID.
+func (r *Registry) List() []models.Camera {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	out := make([]models.Camera, 0, len(r.cameras))
+	for _, cam := range r.cameras {
+		out = append(out, cam)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Upsert adds or updates a camera and makes sure its hub is running.
+func (r *Registry) Upsert(ctx context.Context, cam models.Camera) (models.Camera, error) {
+	if !cameraIDPattern.MatchString(cam.ID) {
+		return models.Camera{}, ErrInvalidCameraID
+	}
+	if len(cam.ROI) > 0 && len(cam.ROI) < 3 {
+		return models.Camera{}, ErrInvalidROI
+	}
+	for _, mask := range cam.PrivacyMasks {
+		if len(mask) < 3 {
+			return models.Camera{}, ErrInvalidPrivacyMask
+		}
+	}
+	switch cam.PrivacyMaskMode {
+	case "":
+		cam.PrivacyMaskMode = models.PrivacyMaskPixelate
+	case models.PrivacyMaskPixelate, models.PrivacyMaskBlack:
+	default:
+		return models.Camera{}, ErrInvalidPrivacyMask
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now().UTC()
+	if existing, ok := r.cameras[cam.ID]; ok {
+		cam.CreatedAt = existing.CreatedAt
+	} else if cam.CreatedAt.IsZero() {
+		cam.CreatedAt = now
+	}
+	cam.UpdatedAt = now
+	if cam.Status == "" {
+		cam.Status = "ONLINE"
+	}
+
+	if r.store != nil {
+		if err := r.store.UpsertCamera(ctx, cam); err != nil {
+			return models.Camera{}, err
+		}
+	}
+
+	r.cameras[cam.ID] = cam
+	r.startHubLocked(cam.ID)
+	r.notifyLocked(cam, false)
+	return cam, nil
+}
+
+// Remove deletes a camera and stops its hub, disconnecting any viewers.
+func (r *Registry) Remove(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.cameras[id]; !ok {
+		return ErrCameraNotFound
+	}
+	if r.store != nil {
+		if err := r.store.DeleteCamera(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	cam := r.cameras[id]
+	delete(r.cameras, id)
+	r.notifyLocked(cam, true)
+	if hub, ok := r.hubs[id]; ok {
+		hub.Stop()
+		delete(r.hubs, id)
+	}
+	log.Printf("[STREAM] camera %s removed", id)
+	return nil
+}
+
+func (r *Registry) startHubLocked(id string) {
+	if _, ok := r.hubs[id]; ok {
+		return
+	}
+	hub := NewMJPEGHub()
+	go hub.Run()
+	r.hubs[id] = hub
+	log.Printf("[STREAM] camera %s online", id)
+}
+
+func (r *Registry) notifyLocked(cam models.Camera, removed bool) {
+	for _, fn := range r.watch {
+		fn(cam, removed)
+	}
+}
@@ -0,0 +1,192 @@
+package stream
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"central-brain/models"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+// RenderFunc produces the derived version of a camera's frame. Returning the
+// frame data unchanged is allowed; a nil RenderFunc passes frames through.
+type RenderFunc func(cam models.Camera, f Frame) ([]byte, error)
+
+// Renderer re-renders a camera's frames into a second hub (annotated, masked,
+// resized), so the raw hub stays untouched for evidence. Each camera and
+// variant is rendered once and shared by its viewers, and only while someone
+// watches it.
+type Renderer struct {
+	reg *Registry
+	fn  RenderFunc
+
+	mu    sync.Mutex
+	feeds map[string]*renderedFeed
+}
+
+type renderedFeed struct {
+	key     string
+	variant Variant
+	src     *MJPEGHub
+	out     *MJPEGHub
+	viewers int
+	detach  func()
+}
+
+// NewRenderer creates a renderer applying render to the cameras of reg.
+func NewRenderer(reg *Registry, render RenderFunc) *Renderer {
+	return &Renderer{reg: reg, fn: render, feeds: make(map[string]*renderedFeed)}
+}
+
+// NewAnnotator renders boxes, labels and the ROI polygon.
+func NewAnnotator(reg *Registry) *Renderer {
+	return NewRenderer(reg, func(cam models.Camera, f Frame) ([]byte, error) {
+		return renderOverlay(f.Data, cam.ROI, f.Meta)
+	})
+}
+
+// NewMasker renders the camera's privacy masks.
+func NewMasker(reg *Registry) *Renderer {
+	return NewRenderer(reg, func(cam models.Camera, f Frame) ([]byte, error) {
+		return MaskFrame(f.Data, cam)
+	})
+}
+
+// NewMaskedAnnotator renders the overlay on top of the privacy-masked frame,
+// for viewers who may not see the unmasked original.
+func NewMaskedAnnotator(reg *Registry) *Renderer {
+	return NewRenderer(reg, func(cam models.Camera, f Frame) ([]byte, error) {
+		masked, err := MaskFrame(f.Data, cam)
+		if err != nil {
+			return nil, err
+		}
+		return renderOverlay(masked, cam.ROI, f.Meta)
+	})
+}
+
+// acquire returns the rendered hub of a camera variant, starting its renderer
+// for the first viewer. release must be called when the viewer leaves.
+func (r *Renderer) acquire(cameraID string, v Variant) (hub *MJPEGHub, release func(), ok bool) {
+	src, ok := r.reg.Hub(cameraID)
+	if !ok {
+		return nil, nil, false
+	}
+
+	key := cameraID + "|" + v.key()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.feeds[key]
+	if !ok || f.src != src {
+		f = &renderedFeed{key: key, variant: v, src: src, out: NewMJPEGHub()}
+		frames, detach := src.Tap(1)
+		f.detach = detach
+		go f.out.Run()
+		go r.render(cameraID, f, frames)
+		r.feeds[key] = f
+	}
+	f.viewers++
+
+	var once sync.Once
+	return f.out, func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if f.viewers--; f.viewers == 0 {
+				f.detach()
+				if r.feeds[f.key] == f {
+					delete(r.feeds, f.key)
+				}
+			}
+		})
+	}, true
+}
+
+// render draws every tapped frame until the tap is detached or the camera's
+// hub stops, then stops the rendered hub. Frames above the variant's frame
+// rate are dropped before any decoding.
+func (r *Renderer) render(cameraID string, f *renderedFeed, frames <-chan Frame) {
+	defer f.out.Stop()
+	warned := false
+	var last time.Time
+	for fr := range frames {
+		if f.variant.FPS > 0 && fr.Timestamp.Sub(last) < time.Duration(float64(time.Second)/f.variant.FPS) {
+			continue
+		}
+		cam, ok := r.reg.Get(cameraID)
+		if !ok {
+			continue
+		}
+		img, err := fr.Data, error(nil)
+		if r.fn != nil {
+			img, err = r.fn(cam, fr)
+		}
+		if err == nil {
+			img, err = f.variant.apply(img)
+		}
+		if err != nil {
+			// Never fall back to the source frame; it may be unmasked
+			if !warned {
+				log.Printf("[STREAM] camera %s: cannot render frame: %v", cameraID, err)
+				warned = true
+			}
+			continue
+		}
+		last = fr.Timestamp
+		f.out.SetFrameMeta(img, fr.Meta)
+	}
+
+	r.mu.Lock()
+	if r.feeds[f.key] == f {
+		delete(r.feeds, f.key)
+	}
+	r.mu.Unlock()
+}
+
+// StreamRendered serves multipart/x-mixed-replace of a renderer's frames,
+// reduced by the variant query parameters (see ParseVariant).
+func StreamRendered(r *Renderer) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		v, err := ParseVariant(c)
+		if err != nil {
+			return badVariant(c, err)
+		}
+		// Params point into the request buffer; the ID is kept as a map key
+		hub, release, ok := r.acquire(utils.CopyString(c.Params("camera_id")), v)
+		if !ok {
+			return unknownCamera(c)
+		}
+		return serveMJPEG(c, hub, release)
+	}
+}
+
+// RequireMasks rejects cameras without privacy masks, whose masked feed
+// would be the unmasked original.
+func RequireMasks(reg *Registry) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		cam, ok := reg.Get(c.Params("camera_id"))
+		if !ok {
+			return unknownCamera(c)
+		}
+		if len(cam.PrivacyMasks) == 0 {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "conflict",
+				"message": "Camera " + cam.ID + " has no privacy masks configured",
+			})
+		}
+		return c.Next()
+	}
+}
+
+// ForViewer serves full to callers for whom unmasked reports true and the
+// privacy-masked equivalent to everyone else.
+func ForViewer(unmasked func(*fiber.Ctx) bool, full, masked fiber.Handler) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if unmasked(c) {
+			return full(c)
+		}
+		return masked(c)
+	}
+}
@@ -0,0 +1,50 @@
+package stream
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"central-brain/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRequireMasks(t *testing.T) {
+	reg := NewRegistry(nil)
+	cams := []models.Camera{
+		{ID: "plain"},
+		{ID: "masked", PrivacyMasks: [][][2]int{{{0, 0}, {8, 0}, {8, 8}}}},
+	}
+	for _, cam := range cams {
+		if _, err := reg.Upsert(context.Background(), cam); err != nil {
+			t.Fatal(err)
+		}
+		hub, _ := reg.Hub(cam.ID)
+		t.Cleanup(hub.Stop)
+	}
+	app := fiber.New()
+	app.Get("/stream/:camera_id/masked", RequireMasks(reg), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	tests := []struct {
+		camera string
+		status int
+	}{
+		{camera: "masked", status: 200},
+		{camera: "plain", status: 409},
+		{camera: "missing", status: 404},
+	}
+	for _, tt := range tests {
+		t.Run(tt.camera, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", "/stream/"+tt.camera+"/masked", nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.status {
+				t.Errorf("status %d, want %d", resp.StatusCode, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package stream
+
+import (
+	"time"
+
+	"central-brain/models"
+)
+
+// Frame is a JPEG frame with the time the hub received it.
+type Frame struct {
+	Data      []byte
+	Timestamp time.Time
+	Meta      *models.FrameMeta // detections sent with the frame, if any
+}
+
+// frameRing keeps the most recent frames within a time window and byte budget.
+// It is not safe for concurrent use; MJPEGHub guards it with its mutex.
+type frameRing struct {
+	frames   []Frame
+	window   time.Duration
+	maxBytes int
+	bytes    int
+}
+
+func newFrameRing(window time.Duration, maxBytes int) *frameRing {
+	return &frameRing{window: window, maxBytes: maxBytes}
+}
+
+func (r *frameRing) push(f Frame) {
+	r.frames = append(r.frames, f)
+	r.bytes += len(f.Data)
+
+	cutoff := f.Timestamp.Add(-r.window)
+	drop := 0
+	for drop < len(r.frames)-1 && (r.frames[drop].Timestamp.Before(cutoff) || r.bytes > r.maxBytes) {
+		r.bytes -= len(r.frames[drop].Data)
+		r.frames[drop] = Frame{}
+		drop++
+	}
+	if drop > 0 {
+		// Reuse the backing array once the dropped prefix dominates it
+		if drop > cap(r.frames)/2 {
+			r.frames = append(make([]Frame, 0, len(r.frames)-drop+1), r.frames[drop:]...)
+		} else {
+			r.frames = r.frames[drop:]
+		}
+	}
+}
+
+// since returns the frames received at or after t, oldest first.
+// Frame data is shared; callers must not modify it.
+func (r *frameRing) since(t time.Time) []Frame {
+	for i, f := range r.frames {
+		if !f.Timestamp.Before(t) {
+			return append([]Frame(nil), r.frames[i:]...)
+		}
+	}
+	return nil
+}
+
+// count returns how many frames were received at or after t.
+func (r *frameRing) count(t time.Time) int {
+	n := 0
+	for i := len(r.frames) - 1; i >= 0 && !r.frames[i].Timestamp.Before(t); i-- {
+		n++
+	}
+	return n
+}
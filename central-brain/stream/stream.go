@@ -2,55 +2,118 @@ package stream
 
 import (
 	"bufio"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"io"
 	"strconv"
+	"strings"
 	"sync"
+	"time"
+
+	"central-brain/models"
 
 	"github.com/gofiber/fiber/v2"
 )
 
+// Rolling buffer kept by every hub so recordings can start before the event.
+const (
+	DefaultBufferWindow = 15 * time.Second
+	maxBufferBytes      = 64 << 20
+	fpsWindow           = 5 * time.Second
+)
+
 // MJPEGHub stores the latest frame and broadcasts to subscribers.
 type MJPEGHub struct {
 	mu           sync.RWMutex
-	latestFrame  []byte
-	subscribers  map[chan []byte]struct{}
-	subscribe    chan chan []byte
-	unsubscribe  chan chan []byte
-	broadcastReq chan []byte
+	latest       Frame
+	ring         *frameRing
+	started      time.Time
+	lastFrameAt  time.Time
+	frames       uint64
+	lastSum      uint32    // checksum of the latest frame
+	sameSince    time.Time // first frame of the current run of identical frames
+	latency      latencyTracker
+	changed      chan struct{} // closed and replaced on every frame, for long polls
+	taps         map[chan Frame]struct{}
+	subscribers  map[chan Frame]struct{}
+	subscribe    chan chan Frame
+	unsubscribe  chan chan Frame
+	broadcastReq chan Frame
+	quit         chan struct{}
+	stopOnce     sync.Once
 }
 
 // NewMJPEGHub initializes hub.
 func NewMJPEGHub() *MJPEGHub {
 	return &MJPEGHub{
-		subscribers:  make(map[chan []byte]struct{}),
-		subscribe:    make(chan chan []byte),
-		unsubscribe:  make(chan chan []byte),
+		ring:        newFrameRing(DefaultBufferWindow, maxBufferBytes),
+		started:     time.Now(),
+		changed:     make(chan struct{}),
+		taps:        make(map[chan Frame]struct{}),
+		subscribers: make(map[chan Frame]struct{}),
+		subscribe:   make(chan chan Frame),
+		unsubscribe: make(chan chan Frame),
 		// Increased buffer to handle bursts (was 8, now 16)
-		broadcastReq: make(chan []byte, 16),
+		broadcastReq: make(chan Frame, 16),
+		quit:         make(chan struct{}),
 	}
 }
 
-// Run processes subscriptions and frame broadcasts.
+// Run processes subscriptions and frame broadcasts until Stop is called.
 func (h *MJPEGHub) Run() {
 	for {
 		select {
+		case <-h.quit:
+			for sub := range h.subscribers {
+				delete(h.subscribers, sub)
+				close(sub)
+			}
+			h.mu.Lock()
+			for tap := range h.taps {
+				delete(h.taps, tap)
+				close(tap)
+			}
+			h.mu.Unlock()
+			return
 		case sub := <-h.subscribe:
 			h.subscribers[sub] = struct{}{}
 			// Send latest frame immediately if exists
-			if frame := h.Latest(); len(frame) > 0 {
-				sub <- frame
+			h.mu.RLock()
+			latest := h.latest
+			h.mu.RUnlock()
+			if len(latest.Data) > 0 {
+				sub <- latest
 			}
 		case sub := <-h.unsubscribe:
 			if _, ok := h.subscribers[sub]; ok {
 				delete(h.subscribers, sub)
 				close(sub)
 			}
-		case frame := <-h.broadcastReq:
+		case f := <-h.broadcastReq:
+			sum := crc32.ChecksumIEEE(f.Data)
 			h.mu.Lock()
-			h.latestFrame = frame
+			if h.frames == 0 || sum != h.lastSum {
+				h.sameSince = f.Timestamp
+			}
+			h.lastSum = sum
+			h.lastFrameAt = f.Timestamp
+			h.frames++
+			h.latest = f
+			h.latency.observe(f)
+			h.ring.push(f)
+			close(h.changed)
+			h.changed = make(chan struct{})
+			for tap := range h.taps {
+				select {
+				case tap <- f:
+				default:
+				}
+			}
 			h.mu.Unlock()
 			for ch := range h.subscribers {
 				select {
-				case ch <- frame:
+				case ch <- f:
 				default:
 				}
 			}
@@ -58,108 +121,294 @@ func (h *MJPEGHub) Run() {
 	}
 }
 
+// Stop terminates Run and closes all subscriber channels.
+func (h *MJPEGHub) Stop() {
+	h.stopOnce.Do(func() {
+		close(h.quit)
+	})
+}
+
 // SetFrame updates the latest frame and broadcasts.
 func (h *MJPEGHub) SetFrame(frame []byte) {
+	h.SetFrameMeta(frame, nil)
+}
+
+// SetFrameMeta is SetFrame with the detection metadata sent alongside the
+// frame; taps receive it for server-side overlays.
+func (h *MJPEGHub) SetFrameMeta(frame []byte, meta *models.FrameMeta) {
 	if frame == nil {
 		return
 	}
 	copyFrame := make([]byte, len(frame))
 	copy(copyFrame, frame)
-	h.broadcastReq <- copyFrame
+	select {
+	case h.broadcastReq <- Frame{Data: copyFrame, Timestamp: time.Now(), Meta: meta}:
+	case <-h.quit:
+	}
+}
+
+// Recent returns the buffered frames received at or after since, oldest first.
+func (h *MJPEGHub) Recent(since time.Time) []Frame {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.ring.since(since)
+}
+
+// HubStats describes the ingest side of a hub.
+type HubStats struct {
+	Started      time.Time
+	LastFrameAt  time.Time // zero until the first frame
+	Frames       uint64
+	FPS          float64       // over the last few seconds
+	IdenticalFor time.Duration // how long every frame has been byte-identical
+}
+
+// Stats reports frame timing and the identical-frame run as of now.
+func (h *MJPEGHub) Stats(now time.Time) HubStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	st := HubStats{Started: h.started, LastFrameAt: h.lastFrameAt, Frames: h.frames}
+	if h.frames == 0 {
+		return st
+	}
+	window := fpsWindow
+	if up := now.Sub(h.started); up < window {
+		window = up
+	}
+	if window > 0 {
+		st.FPS = float64(h.ring.count(now.Add(-window))) / window.Seconds()
+	}
+	st.IdenticalFor = h.lastFrameAt.Sub(h.sameSince)
+	return st
+}
+
+// Tap returns a channel receiving every new frame with its timestamp, and a
+// function that detaches it. Frames are dropped when the channel is full.
+// The channel is closed when the hub stops.
+func (h *MJPEGHub) Tap(buffer int) (<-chan Frame, func()) {
+	ch := make(chan Frame, buffer)
+	h.mu.Lock()
+	select {
+	case <-h.quit:
+		close(ch)
+	default:
+		h.taps[ch] = struct{}{}
+	}
+	h.mu.Unlock()
+
+	return ch, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.taps[ch]; ok {
+			delete(h.taps, ch)
+			close(ch)
+		}
+	}
+}
+
+// addSubscriber registers a subscriber channel; it reports false once the hub is stopped.
+func (h *MJPEGHub) addSubscriber(sub chan Frame) bool {
+	select {
+	case h.subscribe <- sub:
+		return true
+	case <-h.quit:
+		return false
+	}
+}
+
+// removeSubscriber unregisters a subscriber channel unless the hub already stopped.
+func (h *MJPEGHub) removeSubscriber(sub chan Frame) {
+	select {
+	case h.unsubscribe <- sub:
+	case <-h.quit:
+	}
 }
 
 // Latest returns a copy of latest frame.
 func (h *MJPEGHub) Latest() []byte {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
-	if h.latestFrame == nil {
+	if h.latest.Data == nil {
 		return nil
 	}
-	out := make([]byte, len(h.latestFrame))
-	copy(out, h.latestFrame)
+	out := make([]byte, len(h.latest.Data))
+	copy(out, h.latest.Data)
 	return out
 }
 
-// IngestFrame handles POST /api/internal/stream/cam1 with raw JPEG.
-func IngestFrame(hub *MJPEGHub) fiber.Handler {
+// IngestFrame handles POST /api/internal/stream/:camera_id with raw JPEG, or
+// multipart/form-data with the JPEG in a "frame" part and detection metadata
+// (models.FrameMeta JSON) in a "meta" field. Capture time, sequence, engine
+// and resolution may come as X- headers as well (see readEnvelope).
+// Frames are also offered to the analyzer when one is configured (non-nil).
+func IngestFrame(reg *Registry, analyzer *Analyzer) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		hub, ok := reg.Hub(c.Params("camera_id"))
+		if !ok {
+			return unknownCamera(c)
+		}
 		body := c.Body()
+		var meta *models.FrameMeta
+		if strings.HasPrefix(c.Get(fiber.HeaderContentType), fiber.MIMEMultipartForm) {
+			var err error
+			if body, meta, err = readFramePart(c); err != nil {
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+					"error":   "bad_request",
+					"message": err.Error(),
+				})
+			}
+		}
 		if len(body) == 0 {
 			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 				"error":   "bad_request",
 				"message": "empty body",
 			})
 		}
-		hub.SetFrame(body)
+		meta, err := readEnvelope(c, meta, body)
+		if err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "bad_request",
+				"message": err.Error(),
+			})
+		}
+		hub.SetFrameMeta(body, meta)
+		analyzer.Offer(c.Params("camera_id"), body)
 		return c.SendStatus(fiber.StatusAccepted)
 	}
 }
 
-// StreamMJPEG serves multipart/x-mixed-replace for latest frames.
-func StreamMJPEG(hub *MJPEGHub) fiber.Handler {
+// readFramePart extracts the JPEG and optional metadata of a multipart ingest.
+func readFramePart(c *fiber.Ctx) ([]byte, *models.FrameMeta, error) {
+	fh, err := c.FormFile("frame")
+	if err != nil {
+		return nil, nil, errors.New("multipart frame part is required")
+	}
+	f, err := fh.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	raw := c.FormValue("meta")
+	if raw == "" {
+		return data, nil, nil
+	}
+	var meta models.FrameMeta
+	if err := json.Unmarshal([]byte(raw), &meta); err != nil {
+		return nil, nil, errors.New("invalid meta: " + err.Error())
+	}
+	return data, &meta, nil
+}
+
+// StreamMJPEG serves multipart/x-mixed-replace for latest frames. With
+// variant query parameters (?width=, ?height=, ?fps=, ?quality=) the frames
+// are reduced once per variant and shared by its viewers.
+func StreamMJPEG(reg *Registry) fiber.Handler {
+	variants := NewRenderer(reg, nil)
+	reduced := StreamRendered(variants)
 	return func(c *fiber.Ctx) error {
-		c.Set("Content-Type", "multipart/x-mixed-replace; boundary=frame")
-		c.Set("Cache-Control", "no-cache, no-store, must-revalidate")
-		c.Set("Pragma", "no-cache")
-		c.Set("Connection", "keep-alive")
-
-		// Increased buffer size to reduce lag (was 4, now 8)
-		subscriber := make(chan []byte, 8)
-		hub.subscribe <- subscriber
-		defer func() {
-			hub.unsubscribe <- subscriber
-		}()
-
-		c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
-			for {
-				// Non-blocking read with frame dropping for slow clients
-				select {
-				case frame, ok := <-subscriber:
-					if !ok {
-						return // Channel closed
-					}
-					if len(frame) == 0 {
-						continue
-					}
-					// Drop old frames if channel has more (client too slow)
-					for {
-						select {
-						case newerFrame := <-subscriber:
-							if len(newerFrame) > 0 {
-								frame = newerFrame // Use latest frame
-							}
-						default:
-							goto writeFrame // No more frames, write current
+		v, err := ParseVariant(c)
+		if err != nil {
+			return badVariant(c, err)
+		}
+		if !v.IsZero() {
+			return reduced(c)
+		}
+		hub, ok := reg.Hub(c.Params("camera_id"))
+		if !ok {
+			return unknownCamera(c)
+		}
+		return serveMJPEG(c, hub, nil)
+	}
+}
+
+// mjpegKeepalive is how often a quiet MJPEG stream repeats its last frame.
+const mjpegKeepalive = 5 * time.Second
+
+// serveMJPEG streams hub's frames as multipart/x-mixed-replace. done, if not
+// nil, is called when the viewer goes away.
+func serveMJPEG(c *fiber.Ctx, hub *MJPEGHub, done func()) error {
+	c.Set("Content-Type", "multipart/x-mixed-replace; boundary=frame")
+	c.Set("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Set("Pragma", "no-cache")
+	c.Set("Connection", "keep-alive")
+
+	// Increased buffer size to reduce lag (was 4, now 8)
+	subscriber := make(chan Frame, 8)
+	if !hub.addSubscriber(subscriber) {
+		if done != nil {
+			done()
+		}
+		return unknownCamera(c)
+	}
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer hub.removeSubscriber(subscriber)
+		if done != nil {
+			defer done()
+		}
+		// A write is the only way to notice a viewer that went away, so the
+		// last frame is repeated while the camera is quiet.
+		keepalive := time.NewTicker(mjpegKeepalive)
+		defer keepalive.Stop()
+		var last Frame
+		lastWrite := time.Now()
+		for {
+			var frame Frame
+			// Non-blocking read with frame dropping for slow clients
+			select {
+			case f, ok := <-subscriber:
+				if !ok {
+					return // Channel closed
+				}
+				if len(f.Data) == 0 {
+					continue
+				}
+				frame = f
+				// Drop old frames if channel has more (client too slow)
+			drain:
+				for {
+					select {
+					case newerFrame := <-subscriber:
+						if len(newerFrame.Data) > 0 {
+							frame = newerFrame // Use latest frame
 						}
+					default:
+						break drain // No more frames, write current
 					}
-				writeFrame:
-					w.WriteString("--frame\r\n")
-					w.WriteString("Content-Type: image/jpeg\r\n")
-					w.WriteString("Content-Length: ")
-					w.WriteString(strconv.Itoa(len(frame)))
-					w.WriteString("\r\n\r\n")
-					w.Write(frame)
-					w.WriteString("\r\n")
-					w.Flush()
 				}
+			case <-keepalive.C:
+				if len(last.Data) == 0 || time.Since(lastWrite) < mjpegKeepalive {
+					continue
+				}
+				frame = last
 			}
-		})
-		return nil
-	}
-}
 
-// LatestFrame returns the latest frame as a single JPEG image (for polling).
-func LatestFrame(hub *MJPEGHub) fiber.Handler {
-	return func(c *fiber.Ctx) error {
-		frame := hub.Latest()
-		if len(frame) == 0 {
-			return c.Status(fiber.StatusNoContent).SendString("No frame available")
+			w.WriteString("--frame\r\n")
+			w.WriteString("Content-Type: image/jpeg\r\n")
+			writeEnvelopeHeaders(w, frame.Meta)
+			w.WriteString("Content-Length: ")
+			w.WriteString(strconv.Itoa(len(frame.Data)))
+			w.WriteString("\r\n\r\n")
+			w.Write(frame.Data)
+			w.WriteString("\r\n")
+			if err := w.Flush(); err != nil {
+				return // viewer went away
+			}
+			last, lastWrite = frame, time.Now()
 		}
-		c.Set("Content-Type", "image/jpeg")
-		c.Set("Cache-Control", "no-cache, no-store, must-revalidate")
-		c.Set("Pragma", "no-cache")
-		c.Set("Expires", "0")
-		return c.Send(frame)
-	}
+	})
+	return nil
 }
 
+func unknownCamera(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error":   "not_found",
+		"message": "Unknown camera: " + c.Params("camera_id"),
+	})
+}
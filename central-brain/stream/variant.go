@@ -0,0 +1,279 @@
+package stream
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Variant limits. Frames are only ever scaled down.
+const (
+	minVariantSize    = 16
+	maxVariantFPS     = 30
+	minVariantFPS     = 0.1
+	variantQuality    = 75 // used when only the size is reduced
+	defaultThumbWidth = 320
+	maxThumbWidth     = 640
+	thumbnailTTL      = 5 * time.Second
+)
+
+// Variant is a reduced version of a stream for grids and remote posts. The
+// zero Variant is the stream as ingested.
+type Variant struct {
+	MaxWidth  int     // 0 = any
+	MaxHeight int     // 0 = any
+	FPS       float64 // 0 = every frame
+	Quality   int     // JPEG quality 10-95; 0 = keep the frame's encoding when not resized
+}
+
+// ParseVariant reads ?width=, ?height=, ?fps= and ?quality= of a stream request.
+func ParseVariant(c *fiber.Ctx) (Variant, error) {
+	var v Variant
+	var err error
+	if v.MaxWidth, err = queryInt(c, "width", minVariantSize, 7680); err != nil {
+		return Variant{}, err
+	}
+	if v.MaxHeight, err = queryInt(c, "height", minVariantSize, 4320); err != nil {
+		return Variant{}, err
+	}
+	if v.Quality, err = queryInt(c, "quality", 10, 95); err != nil {
+		return Variant{}, err
+	}
+	if raw := c.Query("fps"); raw != "" {
+		v.FPS, err = strconv.ParseFloat(raw, 64)
+		if err != nil || v.FPS < minVariantFPS || v.FPS > maxVariantFPS {
+			return Variant{}, fmt.Errorf("fps must be between %g and %d", minVariantFPS, maxVariantFPS)
+		}
+	}
+	return v, nil
+}
+
+func queryInt(c *fiber.Ctx, name string, min, max int) (int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be between %d and %d", name, min, max)
+	}
+	return n, checkRange(name, n, min, max)
+}
+
+func checkRange(name string, n, min, max int) error {
+	if n != 0 && (n < min || n > max) {
+		return fmt.Errorf("%s must be between %d and %d", name, min, max)
+	}
+	return nil
+}
+
+// validate applies ParseVariant's limits to a variant built from a
+// WebSocket subscription.
+func (v Variant) validate() error {
+	if err := checkRange("width", v.MaxWidth, minVariantSize, 7680); err != nil {
+		return err
+	}
+	if err := checkRange("height", v.MaxHeight, minVariantSize, 4320); err != nil {
+		return err
+	}
+	if err := checkRange("quality", v.Quality, 10, 95); err != nil {
+		return err
+	}
+	if v.FPS != 0 && (v.FPS < minVariantFPS || v.FPS > maxVariantFPS) {
+		return fmt.Errorf("fps must be between %g and %d", minVariantFPS, maxVariantFPS)
+	}
+	return nil
+}
+
+// IsZero reports whether v leaves the stream as ingested.
+func (v Variant) IsZero() bool {
+	return v == Variant{}
+}
+
+func (v Variant) key() string {
+	return fmt.Sprintf("%dx%d@%g/q%d", v.MaxWidth, v.MaxHeight, v.FPS, v.Quality)
+}
+
+// apply scales a JPEG frame to fit the variant's size and re-encodes it.
+// Frames needing neither are returned unchanged.
+func (v Variant) apply(data []byte) ([]byte, error) {
+	if v.MaxWidth == 0 && v.MaxHeight == 0 && v.Quality == 0 {
+		return data, nil
+	}
+	width, height, ok := jpegSize(data)
+	if !ok {
+		return nil, errors.New("frame is not a JPEG")
+	}
+	w, h := fitSize(width, height, v.MaxWidth, v.MaxHeight)
+	if w == width && h == height && v.Quality == 0 {
+		return data, nil
+	}
+	src, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	quality := v.Quality
+	if quality == 0 {
+		quality = variantQuality
+	}
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaleDown(src, w, h), &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fitSize scales width x height down to fit within maxW x maxH, keeping the
+// aspect ratio. A zero bound is ignored.
+func fitSize(width, height, maxW, maxH int) (int, int) {
+	w, h := width, height
+	if maxW > 0 && w > maxW {
+		h = h * maxW / w
+		w = maxW
+	}
+	if maxH > 0 && h > maxH {
+		w = w * maxH / h
+		h = maxH
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+// scaleDown box-filters src to w x h. Each output pixel averages the source
+// pixels it covers, which is sharp enough for downscaling and cheap.
+func scaleDown(src image.Image, w, h int) image.Image {
+	b := src.Bounds()
+	if b.Dx() == w && b.Dy() == h {
+		return src
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		y0, y1 := y*b.Dy()/h, (y+1)*b.Dy()/h
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*b.Dx()/w, (x+1)*b.Dx()/w
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, bl int
+			for sy := y0; sy < y1; sy++ {
+				i := rgba.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(rgba.Pix[i])
+					g += int(rgba.Pix[i+1])
+					bl += int(rgba.Pix[i+2])
+					i += 4
+				}
+			}
+			n := (y1 - y0) * (x1 - x0)
+			o := dst.PixOffset(x, y)
+			dst.Pix[o], dst.Pix[o+1], dst.Pix[o+2], dst.Pix[o+3] = uint8(r/n), uint8(g/n), uint8(bl/n), 255
+		}
+	}
+	return dst
+}
+
+func badVariant(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "bad_request",
+		"message": err.Error(),
+	})
+}
+
+// thumbnailCache keeps one small JPEG per camera and width, refreshed at most
+// every thumbnailTTL and only when new frames arrived.
+type thumbnailCache struct {
+	mu      sync.Mutex
+	entries map[string]thumbnailEntry
+}
+
+type thumbnailEntry struct {
+	data   []byte
+	made   time.Time
+	frames uint64 // hub frame count the thumbnail was made from
+}
+
+// Thumbnail serves a cached, reduced copy of a camera's latest frame for map
+// popups and camera lists.
+// GET /stream/:camera_id/thumbnail?width=320
+//
+// Callers for whom unmasked reports false get a thumbnail of the
+// privacy-masked frame, cached separately.
+func Thumbnail(reg *Registry, unmasked func(*fiber.Ctx) bool) fiber.Handler {
+	cache := &thumbnailCache{entries: make(map[string]thumbnailEntry)}
+	return func(c *fiber.Ctx) error {
+		hub, ok := reg.Hub(c.Params("camera_id"))
+		if !ok {
+			return unknownCamera(c)
+		}
+		width := defaultThumbWidth
+		if raw := c.Query("width"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < minVariantSize || n > maxThumbWidth {
+				return badVariant(c, fmt.Errorf("width must be between %d and %d", minVariantSize, maxThumbWidth))
+			}
+			width = n
+		}
+
+		now := time.Now()
+		frames := hub.Stats(now).Frames
+		masked := !unmasked(c)
+		key := c.Params("camera_id") + "|" + strconv.Itoa(width) + "|" + strconv.FormatBool(masked)
+		cache.mu.Lock()
+		entry, cached := cache.entries[key]
+		cache.mu.Unlock()
+
+		if !cached || (now.Sub(entry.made) >= thumbnailTTL && frames != entry.frames) {
+			frame := hub.Latest()
+			if len(frame) == 0 {
+				return c.Status(fiber.StatusNoContent).SendString("No frame available")
+			}
+			var err error
+			if masked {
+				cam, ok := reg.Get(c.Params("camera_id"))
+				if !ok {
+					return unknownCamera(c)
+				}
+				frame, err = MaskFrame(frame, cam)
+			}
+			var data []byte
+			if err == nil {
+				data, err = Variant{MaxWidth: width, Quality: variantQuality}.apply(frame)
+			}
+			if err != nil {
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error":   "server_error",
+					"message": "Failed to render thumbnail",
+				})
+			}
+			entry = thumbnailEntry{data: data, made: now, frames: frames}
+			cache.mu.Lock()
+			cache.entries[key] = entry
+			cache.mu.Unlock()
+		}
+
+		c.Set("Content-Type", "image/jpeg")
+		c.Set("Cache-Control", "private, max-age="+strconv.Itoa(int(thumbnailTTL.Seconds())))
+		c.Set("Last-Modified", entry.made.UTC().Format(http.TimeFormat))
+		return c.Send(entry.data)
+	}
+}
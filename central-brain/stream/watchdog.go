@@ -0,0 +1,180 @@
+package stream
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"central-brain/models"
+)
+
+// Watchdog thresholds. The AI engine pushes frames several times a second,
+// so a few seconds of silence already means something is wrong.
+const (
+	DefaultStaleAfter   = 10 * time.Second
+	DefaultOfflineAfter = 60 * time.Second
+	DefaultFrozenAfter  = 15 * time.Second
+
+	watchdogInterval = 2 * time.Second
+)
+
+// Watchdog checks every hub's ingest and reports cameras whose feed went
+// STALE, OFFLINE or FROZEN, and when they recover.
+type Watchdog struct {
+	reg          *Registry
+	staleAfter   time.Duration
+	offlineAfter time.Duration
+	frozenAfter  time.Duration
+
+	mu     sync.Mutex
+	health map[string]models.CameraHealth
+	watch  []func(models.CameraHealth)
+}
+
+// NewWatchdog creates a watchdog over reg with the default thresholds.
+func NewWatchdog(reg *Registry) *Watchdog {
+	return &Watchdog{
+		reg:          reg,
+		staleAfter:   DefaultStaleAfter,
+		offlineAfter: DefaultOfflineAfter,
+		frozenAfter:  DefaultFrozenAfter,
+		health:       make(map[string]models.CameraHealth),
+	}
+}
+
+// OnChange registers fn to be called when a camera's health status changes.
+func (w *Watchdog) OnChange(fn func(models.CameraHealth)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.watch = append(w.watch, fn)
+}
+
+// Run checks all cameras periodically until the process exits.
+func (w *Watchdog) Run() {
+	ticker := time.NewTicker(watchdogInterval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		w.check(now)
+	}
+}
+
+// Get returns the latest health of a camera.
+func (w *Watchdog) Get(cameraID string) (models.CameraHealth, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	h, ok := w.health[cameraID]
+	return h, ok
+}
+
+// statusRank orders health statuses from best to worst.
+var statusRank = map[string]int{
+	models.CameraOnline:  0,
+	models.CameraStale:   1,
+	models.CameraFrozen:  2,
+	models.CameraOffline: 3,
+}
+
+// UnitStatus returns the worst status among the cameras of a hierarchy
+// unit, so one recovered camera does not hide another that is still down.
+// Units without checked cameras are ONLINE.
+func (w *Watchdog) UnitStatus(unitID string) string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	status := models.CameraOnline
+	for _, h := range w.health {
+		if h.UnitID == unitID && statusRank[h.Status] > statusRank[status] {
+			status = h.Status
+		}
+	}
+	return status
+}
+
+// List returns the latest health of all cameras sorted by camera ID.
+func (w *Watchdog) List() []models.CameraHealth {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := make([]models.CameraHealth, 0, len(w.health))
+	for _, h := range w.health {
+		out = append(out, h)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CameraID < out[j].CameraID })
+	return out
+}
+
+func (w *Watchdog) check(now time.Time) {
+	var changed []models.CameraHealth
+
+	w.mu.Lock()
+	seen := make(map[string]bool)
+	for _, cam := range w.reg.List() {
+		hub, ok := w.reg.Hub(cam.ID)
+		if !ok {
+			continue
+		}
+		seen[cam.ID] = true
+
+		st := hub.Stats(now)
+		h := models.CameraHealth{
+			CameraID: cam.ID,
+			UnitID:   cam.UnitID,
+			Status:   w.classify(now, st),
+			FPS:      st.FPS,
+		}
+		if !st.LastFrameAt.IsZero() {
+			last := st.LastFrameAt.UTC()
+			h.LastFrameAt = &last
+		}
+		if st.IdenticalFor >= time.Second {
+			h.FrozenSeconds = st.IdenticalFor.Seconds()
+		}
+
+		// Cameras start out ONLINE, like the hierarchy's units
+		prev, known := w.health[cam.ID]
+		if !known {
+			prev = models.CameraHealth{Status: models.CameraOnline, Since: now.UTC()}
+		}
+		h.Since = prev.Since
+		if h.Status != prev.Status {
+			h.Since = now.UTC()
+			h.PreviousStatus = prev.Status
+			changed = append(changed, h)
+		} else {
+			h.PreviousStatus = prev.PreviousStatus
+		}
+		w.health[cam.ID] = h
+	}
+	for id := range w.health {
+		if !seen[id] {
+			delete(w.health, id)
+		}
+	}
+	watch := w.watch
+	w.mu.Unlock()
+
+	for _, h := range changed {
+		log.Printf("[WATCHDOG] camera %s %s -> %s", h.CameraID, h.PreviousStatus, h.Status)
+		for _, fn := range watch {
+			fn(h)
+		}
+	}
+}
+
+// classify maps hub stats to a health status. A hub that never received a
+// frame is measured from when it started.
+func (w *Watchdog) classify(now time.Time, st HubStats) string {
+	last := st.LastFrameAt
+	if last.IsZero() {
+		last = st.Started
+	}
+	switch silent := now.Sub(last); {
+	case silent >= w.offlineAfter:
+		return models.CameraOffline
+	case silent >= w.staleAfter:
+		return models.CameraStale
+	case st.IdenticalFor >= w.frozenAfter:
+		return models.CameraFrozen
+	default:
+		return models.CameraOnline
+	}
+}
@@ -0,0 +1,64 @@
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"central-brain/models"
+)
+
+func TestWatchdogClassify(t *testing.T) {
+	w := NewWatchdog(nil)
+	now := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		st   HubStats
+		want string
+	}{
+		{name: "fresh frames", st: HubStats{Started: now.Add(-time.Hour), LastFrameAt: now.Add(-time.Second)}, want: models.CameraOnline},
+		{name: "just below stale", st: HubStats{LastFrameAt: now.Add(-DefaultStaleAfter + time.Millisecond)}, want: models.CameraOnline},
+		{name: "stale", st: HubStats{LastFrameAt: now.Add(-DefaultStaleAfter)}, want: models.CameraStale},
+		{name: "offline", st: HubStats{LastFrameAt: now.Add(-DefaultOfflineAfter)}, want: models.CameraOffline},
+		{name: "never sent, just started", st: HubStats{Started: now.Add(-time.Second)}, want: models.CameraOnline},
+		{name: "never sent since start", st: HubStats{Started: now.Add(-2 * DefaultOfflineAfter)}, want: models.CameraOffline},
+		{name: "frozen", st: HubStats{LastFrameAt: now, IdenticalFor: DefaultFrozenAfter}, want: models.CameraFrozen},
+		{name: "briefly identical", st: HubStats{LastFrameAt: now, IdenticalFor: DefaultFrozenAfter - time.Second}, want: models.CameraOnline},
+		// Silence wins over identical frames: nothing arrives to be identical
+		{name: "frozen then silent", st: HubStats{LastFrameAt: now.Add(-DefaultOfflineAfter), IdenticalFor: time.Hour}, want: models.CameraOffline},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := w.classify(now, tt.st); got != tt.want {
+				t.Errorf("classify = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWatchdogUnitStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []string
+		want     string
+	}{
+		{name: "no cameras", want: models.CameraOnline},
+		{name: "all online", statuses: []string{models.CameraOnline, models.CameraOnline}, want: models.CameraOnline},
+		{name: "one recovered, one offline", statuses: []string{models.CameraOnline, models.CameraOffline}, want: models.CameraOffline},
+		{name: "stale and frozen", statuses: []string{models.CameraStale, models.CameraFrozen}, want: models.CameraFrozen},
+		{name: "one stale", statuses: []string{models.CameraStale, models.CameraOnline}, want: models.CameraStale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWatchdog(nil)
+			for i, status := range tt.statuses {
+				id := string(rune('a' + i))
+				w.health[id] = models.CameraHealth{CameraID: id, UnitID: "CCTV-JBG-01", Status: status}
+			}
+			// Other units do not count
+			w.health["other"] = models.CameraHealth{CameraID: "other", UnitID: "CCTV-KTS-01", Status: models.CameraOffline}
+			if got := w.UnitStatus("CCTV-JBG-01"); got != tt.want {
+				t.Errorf("UnitStatus = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,537 @@
+package stream
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"central-brain/realtime"
+
+	"github.com/gofiber/websocket/v2"
+)
+
+// WebSocket frame delivery limits.
+const (
+	wsFrameWriteWait   = 10 * time.Second
+	wsFramePongWait    = 60 * time.Second
+	wsFrameMaxCameras  = 16 // a 4x4 grid
+	wsFrameControlSize = 4096
+)
+
+// Stream kinds a WebSocket subscription may ask for.
+const (
+	KindRaw             = "raw"
+	KindAnnotated       = "annotated"
+	KindMasked          = "masked"
+	KindMaskedAnnotated = "masked_annotated"
+)
+
+// maskedKinds is what a viewer who may not see unmasked frames gets instead.
+var maskedKinds = map[string]string{
+	KindRaw:       KindMasked,
+	KindAnnotated: KindMaskedAnnotated,
+}
+
+// FrameHeader precedes the JPEG in every binary message of /ws/stream. A
+// message is a 4-byte big-endian header length, the header JSON, then the JPEG.
+type FrameHeader struct {
+	CameraID         string     `json:"camera_id"`
+	Kind             string     `json:"kind"`
+	Timestamp        time.Time  `json:"timestamp"`
+	CaptureTimestamp *time.Time `json:"capture_timestamp,omitempty"`
+	Sequence         uint64     `json:"sequence,omitempty"`
+	Size             int        `json:"size"`
+	Dropped          uint64     `json:"dropped"` // frames skipped for this camera because the client was slow
+}
+
+// FrameRequest is a control message on /ws/stream. Kind defaults to raw and
+// the size/rate fields follow ParseVariant.
+type FrameRequest struct {
+	Type    string   `json:"type"` // "auth", "subscribe" or "unsubscribe"
+	Token   string   `json:"token,omitempty"`
+	Cameras []string `json:"cameras"`
+	Kind    string   `json:"kind,omitempty"`
+	Width   int      `json:"width,omitempty"`
+	Height  int      `json:"height,omitempty"`
+	FPS     float64  `json:"fps,omitempty"`
+	Quality int      `json:"quality,omitempty"`
+}
+
+// frameConn is one /ws/stream connection. Each subscribed camera keeps only
+// its newest unsent frame, so a slow client skips frames instead of queuing
+// them; the writer goroutine is the only one writing to the socket.
+type frameConn struct {
+	conn      *websocket.Conn
+	authorize realtime.Authorizer
+	unmasked  func(realtime.Scope) bool
+	reg       *Registry
+	renderers map[string]*Renderer
+
+	mu       sync.Mutex
+	scope    realtime.Scope // replaced when the client re-authenticates
+	scopeGen uint64         // bumped when scope is replaced by re-authentication
+	subs     map[string]*frameSub
+	pending  map[string]Frame
+	dropped  map[string]uint64
+
+	wake    chan struct{}
+	control chan interface{}
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+type frameSub struct {
+	kind    string
+	variant Variant
+	stop    func()
+}
+
+// WSFrames pushes JPEG frames as binary WebSocket messages.
+// GET /ws/stream/:camera_id?token=...&kind=raw&width=&height=&fps=&quality=
+//
+// The path camera is subscribed on connect; more cameras can be added to the
+// same connection with {"type":"subscribe","cameras":[...],"kind":"masked",
+// "width":480,"fps":5} and removed with {"type":"unsubscribe","cameras":[...]}.
+// The session is re-checked on every ping; {"type":"auth","token":"..."}
+// with a refreshed token keeps the connection open past the first token.
+// renderers maps the annotated and masked kinds to their shared renderers.
+// Viewers for whom unmasked reports false get masked frames for raw and
+// annotated subscriptions; the header kind says which was served.
+func WSFrames(reg *Registry, renderers map[string]*Renderer, authorize realtime.Authorizer, unmasked func(realtime.Scope) bool) func(*websocket.Conn) {
+	all := map[string]*Renderer{KindRaw: NewRenderer(reg, nil)}
+	for kind, r := range renderers {
+		all[kind] = r
+	}
+	return func(c *websocket.Conn) {
+		c.SetReadLimit(wsFrameControlSize)
+
+		scope, ok := realtime.Authenticate(c, authorize)
+		if !ok {
+			return
+		}
+
+		initial, err := initialRequest(c)
+		if err != nil {
+			realtime.WriteError(c, "bad_request", err.Error())
+			return
+		}
+
+		fc := &frameConn{
+			conn:      c,
+			authorize: authorize,
+			unmasked:  unmasked,
+			scope:     scope,
+			reg:       reg,
+			renderers: all,
+			subs:      make(map[string]*frameSub),
+			pending:   make(map[string]Frame),
+			dropped:   make(map[string]uint64),
+			wake:      make(chan struct{}, 1),
+			control:   make(chan interface{}, 16),
+			quit:      make(chan struct{}),
+			done:      make(chan struct{}),
+		}
+		go fc.writePump()
+		defer func() {
+			fc.unsubscribeAll()
+			close(fc.quit)
+			// The connection is recycled once this handler returns
+			<-fc.done
+		}()
+
+		fc.handle(initial)
+
+		_ = c.SetReadDeadline(time.Now().Add(wsFramePongWait))
+		c.SetPongHandler(func(string) error {
+			return c.SetReadDeadline(time.Now().Add(wsFramePongWait))
+		})
+		for {
+			_, msg, err := c.ReadMessage()
+			if err != nil {
+				break
+			}
+			var req FrameRequest
+			if err := json.Unmarshal(msg, &req); err != nil {
+				fc.send(frameError("bad_request", "Invalid JSON message", nil))
+				continue
+			}
+			fc.handle(req)
+		}
+	}
+}
+
+// handle applies an auth or subscribe/unsubscribe request and echoes the subscriptions.
+func (fc *frameConn) handle(req FrameRequest) {
+	switch req.Type {
+	case "auth":
+		scope, err := realtime.Reauthenticate(fc.currentScope(), fc.authorize, req.Token)
+		if err != nil {
+			fc.send(frameError("unauthorized", err.Error(), nil))
+			return
+		}
+		fc.mu.Lock()
+		fc.scope = scope
+		fc.scopeGen++
+		fc.mu.Unlock()
+		fc.dropOutOfScope()
+	case "subscribe":
+		kind := req.Kind
+		if kind == "" {
+			kind = KindRaw
+		}
+		scope := fc.currentScope()
+		if masked, ok := maskedKinds[kind]; ok && !fc.unmasked(scope) {
+			kind = masked
+		}
+		if _, ok := fc.renderers[kind]; !ok {
+			fc.send(frameError("bad_request", "Unknown stream kind: "+kind, nil))
+			return
+		}
+		v := Variant{MaxWidth: req.Width, MaxHeight: req.Height, FPS: req.FPS, Quality: req.Quality}
+		if err := v.validate(); err != nil {
+			fc.send(frameError("bad_request", err.Error(), nil))
+			return
+		}
+		var denied, unknown []string
+		for _, cam := range req.Cameras {
+			if !scope.Allows(cam) || cam == "" {
+				denied = append(denied, cam)
+				continue
+			}
+			switch err := fc.subscribe(cam, kind, v); err {
+			case nil:
+			case errTooManyCameras:
+				fc.send(frameError("bad_request", err.Error(), []string{cam}))
+			default:
+				unknown = append(unknown, cam)
+			}
+		}
+		if len(denied) > 0 {
+			fc.send(frameError("forbidden", "Cameras outside your scope were ignored", denied))
+		}
+		if len(unknown) > 0 {
+			fc.send(frameError("not_found", "Unknown cameras were ignored", unknown))
+		}
+	case "unsubscribe":
+		for _, cam := range req.Cameras {
+			fc.unsubscribe(cam)
+		}
+	default:
+		fc.send(frameError("bad_request", "Unknown message type: "+req.Type, nil))
+		return
+	}
+	fc.send(fc.snapshot())
+}
+
+// initialRequest subscribes the path camera with the kind and variant of the
+// query string.
+func initialRequest(c *websocket.Conn) (FrameRequest, error) {
+	req := FrameRequest{Type: "subscribe", Cameras: []string{c.Params("camera_id")}, Kind: c.Query("kind")}
+	for _, q := range []struct {
+		name string
+		dst  *int
+	}{{"width", &req.Width}, {"height", &req.Height}, {"quality", &req.Quality}} {
+		if raw := c.Query(q.name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				return FrameRequest{}, fmt.Errorf("%s must be an integer", q.name)
+			}
+			*q.dst = n
+		}
+	}
+	if raw := c.Query("fps"); raw != "" {
+		fps, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return FrameRequest{}, errors.New("fps must be a number")
+		}
+		req.FPS = fps
+	}
+	return req, nil
+}
+
+var (
+	errTooManyCameras = fmt.Errorf("at most %d cameras per connection", wsFrameMaxCameras)
+	errUnknownCamera  = errors.New("unknown camera")
+)
+
+// subscribe starts forwarding a camera's frames, replacing an earlier
+// subscription to it with another kind or variant.
+func (fc *frameConn) subscribe(cameraID, kind string, v Variant) error {
+	fc.mu.Lock()
+	old, exists := fc.subs[cameraID]
+	count := len(fc.subs)
+	fc.mu.Unlock()
+	if exists && old.kind == kind && old.variant == v {
+		return nil
+	}
+	if !exists && count >= wsFrameMaxCameras {
+		return errTooManyCameras
+	}
+
+	var hub *MJPEGHub
+	var release func()
+	if kind == KindRaw && v.IsZero() {
+		h, ok := fc.reg.Hub(cameraID)
+		if !ok {
+			return errUnknownCamera
+		}
+		hub = h
+	} else {
+		h, rel, ok := fc.renderers[kind].acquire(cameraID, v)
+		if !ok {
+			return errUnknownCamera
+		}
+		hub, release = h, rel
+	}
+
+	ch := make(chan Frame, 2)
+	if !hub.addSubscriber(ch) {
+		if release != nil {
+			release()
+		}
+		return errUnknownCamera
+	}
+	sub := &frameSub{kind: kind, variant: v}
+	sub.stop = func() {
+		hub.removeSubscriber(ch)
+		if release != nil {
+			release()
+		}
+	}
+
+	fc.mu.Lock()
+	if exists {
+		delete(fc.pending, cameraID)
+	}
+	fc.subs[cameraID] = sub
+	fc.mu.Unlock()
+	if exists {
+		old.stop()
+	}
+
+	go func() {
+		for f := range ch {
+			fc.offer(cameraID, sub, f)
+		}
+	}()
+	return nil
+}
+
+func (fc *frameConn) unsubscribe(cameraID string) {
+	fc.mu.Lock()
+	sub, ok := fc.subs[cameraID]
+	delete(fc.subs, cameraID)
+	delete(fc.pending, cameraID)
+	delete(fc.dropped, cameraID)
+	fc.mu.Unlock()
+	if ok {
+		sub.stop()
+	}
+}
+
+func (fc *frameConn) unsubscribeAll() {
+	fc.mu.Lock()
+	subs := fc.subs
+	fc.subs = make(map[string]*frameSub)
+	fc.mu.Unlock()
+	for _, sub := range subs {
+		sub.stop()
+	}
+}
+
+// offer makes f the camera's pending frame, counting the one it replaces as
+// dropped, and wakes the writer.
+func (fc *frameConn) offer(cameraID string, sub *frameSub, f Frame) {
+	if len(f.Data) == 0 {
+		return
+	}
+	fc.mu.Lock()
+	if fc.subs[cameraID] != sub {
+		// Frame of a subscription replaced or removed meanwhile
+		fc.mu.Unlock()
+		return
+	}
+	if _, waiting := fc.pending[cameraID]; waiting {
+		fc.dropped[cameraID]++
+	}
+	fc.pending[cameraID] = f
+	fc.mu.Unlock()
+
+	select {
+	case fc.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (fc *frameConn) currentScope() realtime.Scope {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.scope
+}
+
+// refreshScope re-resolves the connection's scope and drops cameras it no
+// longer covers. A scope replaced by re-authentication meanwhile is kept.
+func (fc *frameConn) refreshScope() error {
+	fc.mu.Lock()
+	scope, gen := fc.scope, fc.scopeGen
+	fc.mu.Unlock()
+
+	fresh, err := scope.Refresh()
+	if err != nil {
+		return err
+	}
+	fc.mu.Lock()
+	if fc.scopeGen == gen {
+		fc.scope = fresh
+	}
+	fc.mu.Unlock()
+	fc.dropOutOfScope()
+	return nil
+}
+
+// dropOutOfScope unsubscribes the cameras the current scope does not cover.
+func (fc *frameConn) dropOutOfScope() {
+	fc.mu.Lock()
+	var lost []string
+	for cam := range fc.subs {
+		if !fc.scope.Allows(cam) {
+			lost = append(lost, cam)
+		}
+	}
+	fc.mu.Unlock()
+	for _, cam := range lost {
+		fc.unsubscribe(cam)
+	}
+}
+
+// send queues a JSON control message; it is dropped when the queue is full.
+func (fc *frameConn) send(v interface{}) {
+	select {
+	case fc.control <- v:
+	default:
+	}
+}
+
+// writePump writes control messages and the pending frames, and pings the
+// client once the session is re-checked. It closes the connection on the
+// first failed write or ended session so the read loop ends as well.
+func (fc *frameConn) writePump() {
+	ticker := time.NewTicker(wsFramePongWait * 9 / 10)
+	defer func() {
+		ticker.Stop()
+		_ = fc.conn.Close()
+		close(fc.done)
+	}()
+	for {
+		select {
+		case <-fc.quit:
+			return
+		case v := <-fc.control:
+			_ = fc.conn.SetWriteDeadline(time.Now().Add(wsFrameWriteWait))
+			if err := fc.conn.WriteJSON(v); err != nil {
+				return
+			}
+		case <-fc.wake:
+			if err := fc.writePending(); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if fc.refreshScope() != nil {
+				realtime.WriteError(fc.conn, "unauthorized", "Session ended; reconnect with a new token")
+				return
+			}
+			_ = fc.conn.SetWriteDeadline(time.Now().Add(wsFrameWriteWait))
+			if err := fc.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writePending sends the newest frame of every camera that has one waiting.
+func (fc *frameConn) writePending() error {
+	fc.mu.Lock()
+	frames := fc.pending
+	fc.pending = make(map[string]Frame, len(frames))
+	headers := make(map[string]FrameHeader, len(frames))
+	for cam, f := range frames {
+		h := FrameHeader{CameraID: cam, Timestamp: f.Timestamp, Size: len(f.Data), Dropped: fc.dropped[cam]}
+		if sub, ok := fc.subs[cam]; ok {
+			h.Kind = sub.kind
+		}
+		if f.Meta != nil {
+			if !f.Meta.CaptureTimestamp.IsZero() {
+				capture := f.Meta.CaptureTimestamp
+				h.CaptureTimestamp = &capture
+			}
+			h.Sequence = f.Meta.Sequence
+		}
+		headers[cam] = h
+	}
+	fc.mu.Unlock()
+
+	for cam, f := range frames {
+		msg, err := encodeFrameMessage(headers[cam], f.Data)
+		if err != nil {
+			continue
+		}
+		_ = fc.conn.SetWriteDeadline(time.Now().Add(wsFrameWriteWait))
+		if err := fc.conn.WriteMessage(websocket.BinaryMessage, msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func encodeFrameMessage(h FrameHeader, data []byte) ([]byte, error) {
+	header, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, 4+len(header)+len(data))
+	binary.BigEndian.PutUint32(msg, uint32(len(header)))
+	copy(msg[4:], header)
+	copy(msg[4+len(header):], data)
+	return msg, nil
+}
+
+// snapshot describes the connection's current subscriptions.
+func (fc *frameConn) snapshot() map[string]interface{} {
+	type subscription struct {
+		CameraID string  `json:"camera_id"`
+		Kind     string  `json:"kind"`
+		Width    int     `json:"width,omitempty"`
+		Height   int     `json:"height,omitempty"`
+		FPS      float64 `json:"fps,omitempty"`
+		Quality  int     `json:"quality,omitempty"`
+	}
+	fc.mu.Lock()
+	subs := make([]subscription, 0, len(fc.subs))
+	for cam, s := range fc.subs {
+		subs = append(subs, subscription{cam, s.kind, s.variant.MaxWidth, s.variant.MaxHeight, s.variant.FPS, s.variant.Quality})
+	}
+	fc.mu.Unlock()
+	sort.Slice(subs, func(i, j int) bool { return subs[i].CameraID < subs[j].CameraID })
+	return map[string]interface{}{
+		"type":          "subscriptions",
+		"subscriptions": subs,
+	}
+}
+
+func frameError(code, message string, cameras []string) map[string]interface{} {
+	payload := map[string]interface{}{
+		"type":    "error",
+		"error":   code,
+		"message": message,
+	}
+	if len(cameras) > 0 {
+		sort.Strings(cameras)
+		payload["cameras"] = cameras
+	}
+	return payload
+}
@@ -0,0 +1,135 @@
+package trains
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"central-brain/models"
+)
+
+// Column names accepted in timetable CSVs; the GTFS stop_times.txt names are
+// aliases so feeds can be imported as exported.
+var timetableColumns = map[string][]string{
+	"train_id": {"train_id", "trip_id"},
+	"post_id":  {"post_id", "stop_id"},
+	"time":     {"time", "arrival_time", "departure_time"},
+	"days":     {"days"},
+}
+
+// ParseTimetable reads a CSV timetable for postID. The header row names the
+// columns: train_id (or trip_id), time (or arrival_time, departure_time), and
+// optionally post_id (or stop_id) and days. Rows of other posts are skipped,
+// so one GTFS-like file can be imported post by post.
+func ParseTimetable(r io.Reader, postID string) ([]models.TimetableEntry, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, errors.New("timetable is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+	col := make(map[string]int)
+	for field, names := range timetableColumns {
+		col[field] = -1
+		for i, h := range header {
+			h = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))
+			if contains(names, h) && col[field] < 0 {
+				col[field] = i
+			}
+		}
+	}
+	if col["train_id"] < 0 || col["time"] < 0 {
+		return nil, errors.New("timetable header needs train_id (or trip_id) and time (or arrival_time)")
+	}
+
+	var out []models.TimetableEntry
+	for line := 2; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		field := func(name string) string {
+			if i := col[name]; i >= 0 && i < len(rec) {
+				return strings.TrimSpace(rec[i])
+			}
+			return ""
+		}
+		if p := field("post_id"); p != "" && p != postID {
+			continue
+		}
+		e := models.TimetableEntry{
+			PostID:  postID,
+			TrainID: field("train_id"),
+			Time:    field("time"),
+			Days:    field("days"),
+		}
+		if e.TrainID == "" {
+			return nil, fmt.Errorf("line %d: train_id is empty", line)
+		}
+		if err := normalize(&e); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		out = append(out, e)
+	}
+	if len(out) == 0 {
+		return nil, errors.New("timetable has no rows for post " + postID)
+	}
+	return out, nil
+}
+
+// normalize validates an entry's time and days and fills Seconds.
+func normalize(e *models.TimetableEntry) error {
+	secs, err := parseClock(e.Time)
+	if err != nil {
+		return err
+	}
+	for _, d := range e.Days {
+		if d < '1' || d > '7' {
+			return fmt.Errorf("days %q must only list ISO weekdays 1-7", e.Days)
+		}
+	}
+	e.Seconds = secs
+	return nil
+}
+
+// parseClock parses HH:MM or HH:MM:SS into seconds after midnight. Hours up
+// to 47 are allowed for trains running past midnight, as in GTFS.
+func parseClock(raw string) (int, error) {
+	parts := strings.Split(raw, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("time %q must be HH:MM or HH:MM:SS", raw)
+	}
+	limits := []int{47, 59, 59}
+	secs := 0
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 || n > limits[i] {
+			return 0, fmt.Errorf("time %q must be HH:MM or HH:MM:SS", raw)
+		}
+		secs = secs*60 + n
+	}
+	if len(parts) == 2 {
+		secs *= 60
+	}
+	return secs, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
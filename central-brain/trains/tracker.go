@@ -0,0 +1,253 @@
+// Package trains knows when trains reach the JPL posts' crossings, from each
+// post's timetable and from approach signals of track circuits or axle counters.
+package trains
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"central-brain/models"
+)
+
+const (
+	// DefaultInboundWindow is how long before a scheduled pass a train counts
+	// as inbound. Signalled trains are always inbound.
+	DefaultInboundWindow = 5 * time.Minute
+	// lateGrace keeps a scheduled train expected for a while after its time,
+	// since trains run late more often than early.
+	lateGrace = 2 * time.Minute
+	// signalTimeout drops an APPROACHING that never got its PASSED.
+	signalTimeout = 10 * time.Minute
+)
+
+// ErrInvalidEvent is returned for train events other than APPROACHING or PASSED.
+var ErrInvalidEvent = errors.New("event must be APPROACHING or PASSED")
+
+// Store persists timetables.
+type Store interface {
+	SaveTimetable(ctx context.Context, postID string, entries []models.TimetableEntry) error
+	ListTimetables(ctx context.Context) ([]models.TimetableEntry, error)
+}
+
+// Tracker combines timetables and approach signals into each post's next train.
+type Tracker struct {
+	window time.Duration
+	store  Store
+
+	mu         sync.Mutex
+	timetables map[string][]models.TimetableEntry      // post -> entries by time
+	signals    map[string]map[string]models.TrainEvent // post -> train -> APPROACHING
+	passed     map[string]map[string]time.Time         // post -> train -> last PASSED
+	approach   []func(models.TrainApproach)
+}
+
+// NewTracker creates a tracker. store may be nil.
+func NewTracker(window time.Duration, store Store) *Tracker {
+	if window <= 0 {
+		window = DefaultInboundWindow
+	}
+	return &Tracker{
+		window:     window,
+		store:      store,
+		timetables: make(map[string][]models.TimetableEntry),
+		signals:    make(map[string]map[string]models.TrainEvent),
+		passed:     make(map[string]map[string]time.Time),
+	}
+}
+
+// OnApproach registers fn to be called after an APPROACHING event is recorded.
+func (t *Tracker) OnApproach(fn func(models.TrainApproach)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.approach = append(t.approach, fn)
+}
+
+// Load restores the timetables from the store.
+func (t *Tracker) Load(ctx context.Context) error {
+	if t.store == nil {
+		return nil
+	}
+	list, err := t.store.ListTimetables(ctx)
+	if err != nil {
+		return err
+	}
+	byPost := make(map[string][]models.TimetableEntry)
+	for _, e := range list {
+		if err := normalize(&e); err != nil {
+			return err
+		}
+		byPost[e.PostID] = append(byPost[e.PostID], e)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for postID, entries := range byPost {
+		sortEntries(entries)
+		t.timetables[postID] = entries
+	}
+	return nil
+}
+
+// SetTimetable replaces a post's timetable. Entries must come from ParseTimetable.
+func (t *Tracker) SetTimetable(ctx context.Context, postID string, entries []models.TimetableEntry) error {
+	entries = append([]models.TimetableEntry(nil), entries...)
+	sortEntries(entries)
+	if t.store != nil {
+		if err := t.store.SaveTimetable(ctx, postID, entries); err != nil {
+			return err
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.timetables[postID] = entries
+	return nil
+}
+
+// Timetable returns a post's timetable ordered by time.
+func (t *Tracker) Timetable(postID string) []models.TimetableEntry {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]models.TimetableEntry(nil), t.timetables[postID]...)
+}
+
+// Record applies a train event. APPROACHING marks the train inbound until its
+// PASSED; PASSED without a train ID clears every signalled train of the post.
+// A PASSED with a train ID also ends that train's scheduled pass.
+func (t *Tracker) Record(ev models.TrainEvent) error {
+	if ev.Event != models.TrainApproaching && ev.Event != models.TrainPassed {
+		return ErrInvalidEvent
+	}
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now().UTC()
+	}
+
+	t.mu.Lock()
+	if ev.Event == models.TrainPassed {
+		if ev.TrainID == "" {
+			delete(t.signals, ev.PostID)
+		} else {
+			delete(t.signals[ev.PostID], ev.TrainID)
+			if t.passed[ev.PostID] == nil {
+				t.passed[ev.PostID] = make(map[string]time.Time)
+			}
+			t.passed[ev.PostID][ev.TrainID] = ev.Timestamp
+		}
+		t.mu.Unlock()
+		return nil
+	}
+
+	if t.signals[ev.PostID] == nil {
+		t.signals[ev.PostID] = make(map[string]models.TrainEvent)
+	}
+	t.signals[ev.PostID][ev.TrainID] = ev
+	a, _ := t.approachLocked(ev.PostID, time.Now())
+	hooks := t.approach
+	t.mu.Unlock()
+
+	for _, fn := range hooks {
+		fn(a)
+	}
+	return nil
+}
+
+// Approach returns the next train expected at a post, or false when neither a
+// signal nor the timetable expects one.
+func (t *Tracker) Approach(postID string, now time.Time) (models.TrainApproach, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.approachLocked(postID, now)
+}
+
+// Approaches returns the next train of every post that expects one, by post.
+func (t *Tracker) Approaches(now time.Time) []models.TrainApproach {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	posts := make(map[string]bool)
+	for postID := range t.timetables {
+		posts[postID] = true
+	}
+	for postID := range t.signals {
+		posts[postID] = true
+	}
+	out := make([]models.TrainApproach, 0, len(posts))
+	for postID := range posts {
+		if a, ok := t.approachLocked(postID, now); ok {
+			out = append(out, a)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PostID < out[j].PostID })
+	return out
+}
+
+// approachLocked prefers signalled trains, which are on their way, over the
+// timetable.
+func (t *Tracker) approachLocked(postID string, now time.Time) (models.TrainApproach, bool) {
+	var best models.TrainApproach
+	found := false
+	for trainID, ev := range t.signals[postID] {
+		at := ev.Timestamp.Add(time.Duration(ev.ETASeconds * float64(time.Second)))
+		if now.Sub(at) > signalTimeout {
+			delete(t.signals[postID], trainID)
+			continue
+		}
+		if !found || at.Before(best.ExpectedAt) {
+			best = models.TrainApproach{PostID: postID, TrainID: trainID, Source: models.TrainSourceSignal, ExpectedAt: at, Inbound: true}
+			found = true
+		}
+	}
+
+	if !found {
+		local := now.Local()
+		for _, e := range t.timetables[postID] {
+			// Yesterday's service day covers times past 24:00
+			for day := -1; day <= 1; day++ {
+				service := time.Date(local.Year(), local.Month(), local.Day()+day, 0, 0, 0, 0, local.Location())
+				if !runsOn(e.Days, service.Weekday()) {
+					continue
+				}
+				at := service.Add(time.Duration(e.Seconds) * time.Second)
+				if at.Before(now.Add(-lateGrace)) || t.passed[postID][e.TrainID].After(at.Add(-t.window)) {
+					continue
+				}
+				if !found || at.Before(best.ExpectedAt) {
+					best = models.TrainApproach{PostID: postID, TrainID: e.TrainID, Source: models.TrainSourceTimetable, ExpectedAt: at}
+					found = true
+				}
+			}
+		}
+		best.Inbound = found && best.ExpectedAt.Sub(now) <= t.window
+	}
+	if !found {
+		return models.TrainApproach{}, false
+	}
+
+	if eta := best.ExpectedAt.Sub(now); eta > 0 {
+		best.ETASeconds = eta.Round(time.Second).Seconds()
+	}
+	best.ExpectedAt = best.ExpectedAt.UTC()
+	return best, true
+}
+
+// runsOn reports whether a train with the given ISO weekday list runs on wd.
+func runsOn(days string, wd time.Weekday) bool {
+	if days == "" {
+		return true
+	}
+	iso := byte('0' + wd)
+	if wd == time.Sunday {
+		iso = '7'
+	}
+	for i := 0; i < len(days); i++ {
+		if days[i] == iso {
+			return true
+		}
+	}
+	return false
+}
+
+func sortEntries(entries []models.TimetableEntry) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Seconds < entries[j].Seconds })
+}
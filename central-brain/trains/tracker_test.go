@@ -0,0 +1,238 @@
+package trains
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"central-brain/models"
+)
+
+func entry(t *testing.T, trainID, clock, days string) models.TimetableEntry {
+	t.Helper()
+	e := models.TimetableEntry{PostID: "JPL-102", TrainID: trainID, Time: clock, Days: days}
+	if err := normalize(&e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestApproach(t *testing.T) {
+	// A Wednesday (ISO weekday 3)
+	now := time.Date(2026, 3, 4, 10, 0, 0, 0, time.Local)
+	early := time.Date(2026, 3, 4, 0, 28, 0, 0, time.Local)
+	live := time.Now().Local().Truncate(time.Minute)
+	clock := func(after time.Duration) string { return live.Add(after).Format("15:04") }
+
+	tests := []struct {
+		name       string
+		now        time.Time
+		entries    [][3]string // train, time, days
+		events     []models.TrainEvent
+		found      bool
+		trainID    string
+		source     string
+		inbound    bool
+		eta        float64
+		expectedAt time.Time
+	}{
+		{name: "nothing expected", now: now},
+		{
+			name: "inside the inbound window", now: now,
+			entries: [][3]string{{"A", "10:03", ""}, {"B", "10:20", ""}},
+			found:   true, trainID: "A", source: models.TrainSourceTimetable, inbound: true, eta: 180,
+		},
+		{
+			name: "outside the inbound window", now: now,
+			entries: [][3]string{{"B", "10:20", ""}},
+			found:   true, trainID: "B", source: models.TrainSourceTimetable, eta: 1200,
+		},
+		{
+			name: "running a little late", now: now,
+			entries: [][3]string{{"A", "09:59", ""}, {"B", "10:20", ""}},
+			found:   true, trainID: "A", source: models.TrainSourceTimetable, inbound: true, eta: 0,
+		},
+		{
+			name: "too late, tomorrow's run", now: now,
+			entries: [][3]string{{"A", "09:57", ""}},
+			found:   true, trainID: "A", source: models.TrainSourceTimetable, eta: 86220,
+			expectedAt: time.Date(2026, 3, 5, 9, 57, 0, 0, time.Local),
+		},
+		{
+			name: "not running today", now: now,
+			entries: [][3]string{{"A", "10:03", "67"}},
+		},
+		{
+			name: "running today", now: now,
+			entries: [][3]string{{"A", "10:03", "3"}},
+			found:   true, trainID: "A", source: models.TrainSourceTimetable, inbound: true, eta: 180,
+		},
+		{
+			name: "past midnight on yesterday's service day", now: early,
+			entries: [][3]string{{"N", "24:30", "2"}},
+			found:   true, trainID: "N", source: models.TrainSourceTimetable, inbound: true, eta: 120,
+		},
+		{
+			name: "already passed", now: now,
+			entries: [][3]string{{"A", "10:03", ""}, {"B", "10:20", ""}},
+			events:  []models.TrainEvent{{TrainID: "A", Event: models.TrainPassed, Timestamp: now.Add(-time.Minute)}},
+			found:   true, trainID: "B", source: models.TrainSourceTimetable, eta: 1200,
+		},
+		// Record drops signals that are stale by the wall clock, so these
+		// run at the current time.
+		{
+			name: "signal before timetable", now: live,
+			entries: [][3]string{{"A", clock(3 * time.Minute), ""}},
+			events:  []models.TrainEvent{{TrainID: "X", Event: models.TrainApproaching, ETASeconds: 600, Timestamp: live}},
+			found:   true, trainID: "X", source: models.TrainSourceSignal, inbound: true, eta: 600,
+		},
+		{
+			name: "earliest signal", now: live,
+			events: []models.TrainEvent{
+				{TrainID: "X", Event: models.TrainApproaching, ETASeconds: 90, Timestamp: live},
+				{TrainID: "Y", Event: models.TrainApproaching, ETASeconds: 30, Timestamp: live},
+			},
+			found: true, trainID: "Y", source: models.TrainSourceSignal, inbound: true, eta: 30,
+		},
+		{
+			name: "signal cleared by PASSED", now: live,
+			entries: [][3]string{{"A", clock(3 * time.Minute), ""}},
+			events: []models.TrainEvent{
+				{TrainID: "X", Event: models.TrainApproaching, ETASeconds: 90, Timestamp: live.Add(-2 * time.Minute)},
+				{Event: models.TrainPassed, Timestamp: live.Add(-time.Minute)},
+			},
+			found: true, trainID: "A", source: models.TrainSourceTimetable, inbound: true, eta: 180,
+		},
+		{
+			name: "stale signal", now: live,
+			events: []models.TrainEvent{{TrainID: "X", Event: models.TrainApproaching, ETASeconds: 60, Timestamp: live.Add(-20 * time.Minute)}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr := NewTracker(0, nil)
+			var entries []models.TimetableEntry
+			for _, e := range tt.entries {
+				entries = append(entries, entry(t, e[0], e[1], e[2]))
+			}
+			if err := tr.SetTimetable(context.Background(), "JPL-102", entries); err != nil {
+				t.Fatal(err)
+			}
+			for _, ev := range tt.events {
+				ev.PostID = "JPL-102"
+				if err := tr.Record(ev); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			a, found := tr.Approach("JPL-102", tt.now)
+			if found != tt.found {
+				t.Fatalf("found %v, want %v (%+v)", found, tt.found, a)
+			}
+			if !found {
+				return
+			}
+			if a.TrainID != tt.trainID || a.Source != tt.source || a.Inbound != tt.inbound || a.ETASeconds != tt.eta {
+				t.Errorf("approach %+v, want train %s from %s, inbound %v, eta %v", a, tt.trainID, tt.source, tt.inbound, tt.eta)
+			}
+			if !tt.expectedAt.IsZero() && !a.ExpectedAt.Equal(tt.expectedAt) {
+				t.Errorf("expected at %s, want %s", a.ExpectedAt, tt.expectedAt)
+			}
+		})
+	}
+}
+
+func TestRecordRejectsUnknownEvent(t *testing.T) {
+	if err := NewTracker(0, nil).Record(models.TrainEvent{PostID: "JPL-102", Event: "ARRIVED"}); err != ErrInvalidEvent {
+		t.Errorf("error %v, want ErrInvalidEvent", err)
+	}
+}
+
+func TestParseClock(t *testing.T) {
+	tests := []struct {
+		raw  string
+		secs int
+		ok   bool
+	}{
+		{raw: "00:00", secs: 0, ok: true},
+		{raw: "10:03", secs: 36180, ok: true},
+		{raw: "10:03:15", secs: 36195, ok: true},
+		{raw: "24:30", secs: 88200, ok: true},
+		{raw: "47:59:59", secs: 172799, ok: true},
+		{raw: "48:00"},
+		{raw: "10:60"},
+		{raw: "10"},
+		{raw: "10:00:00:00"},
+		{raw: "ten:00"},
+		{raw: "-1:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			secs, err := parseClock(tt.raw)
+			if (err == nil) != tt.ok || secs != tt.secs {
+				t.Errorf("parseClock = %d, %v; want %d, ok %v", secs, err, tt.secs, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParseTimetable(t *testing.T) {
+	tests := []struct {
+		name   string
+		csv    string
+		trains []string
+		err    string
+	}{
+		{name: "own columns", csv: "train_id,time,days\nKA-1,10:03,12345\nKA-2, 11:00,\n", trains: []string{"KA-1", "KA-2"}},
+		{name: "GTFS stop_times", csv: "trip_id,arrival_time,departure_time,stop_id\nT1,08:00:00,08:01:00,JPL-102\nT2,09:00:00,09:01:00,JPL-105\n", trains: []string{"T1"}},
+		{name: "byte order mark", csv: "\ufefftrain_id,time\nKA-1,10:03\n", trains: []string{"KA-1"}},
+		{name: "empty", csv: "", err: "empty"},
+		{name: "missing columns", csv: "train,when\nKA-1,10:03\n", err: "header"},
+		{name: "no rows for post", csv: "train_id,time,post_id\nKA-1,10:03,JPL-98\n", err: "no rows"},
+		{name: "empty train", csv: "train_id,time\n,10:03\n", err: "line 2"},
+		{name: "bad time", csv: "train_id,time\nKA-1,10:03\nKA-2,25h\n", err: "line 3"},
+		{name: "bad days", csv: "train_id,time,days\nKA-1,10:03,08\n", err: "ISO weekdays"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ParseTimetable(strings.NewReader(tt.csv), "JPL-102")
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want one mentioning %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.trains) {
+				t.Fatalf("%d entries, want %v", len(entries), tt.trains)
+			}
+			for i, e := range entries {
+				if e.TrainID != tt.trains[i] || e.PostID != "JPL-102" || e.Seconds == 0 {
+					t.Errorf("entry %d: %+v", i, e)
+				}
+			}
+		})
+	}
+}
+
+func TestOnApproach(t *testing.T) {
+	tr := NewTracker(0, nil)
+	var got []models.TrainApproach
+	tr.OnApproach(func(a models.TrainApproach) { got = append(got, a) })
+
+	events := []models.TrainEvent{
+		{PostID: "JPL-102", TrainID: "X", Event: models.TrainApproaching, ETASeconds: 90},
+		{PostID: "JPL-102", TrainID: "X", Event: models.TrainPassed},
+	}
+	for _, ev := range events {
+		if err := tr.Record(ev); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(got) != 1 || got[0].TrainID != "X" || !got[0].Inbound || got[0].Source != models.TrainSourceSignal {
+		t.Errorf("approaches %+v, want X signalled once", got)
+	}
+}
@@ -301,7 +301,7 @@ export default function DashboardPage() {
         const res = await fetch(DETECTION_API);
         if (!res.ok) return;
         const data = await res.json();
-        setDetections(data.detections || []);
+        setDetections(data.data || data.detections || []);
       } catch (e) {
         console.warn('Failed to fetch detections', e);
       }